@@ -157,6 +157,10 @@ const (
 
 	// serialisationVersion is the current version of the on-disk format.
 	serialisationVersion = 1
+
+	// msgStoreChanceFactor is the factor by which the selection chance of
+	// an address which advertises a message store is increased.
+	msgStoreChanceFactor = 2.0
 )
 
 // updateAddress is a helper function to either update an address already known
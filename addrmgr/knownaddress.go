@@ -35,7 +35,8 @@ func (ka *KnownAddress) LastAttempt() time.Time {
 
 // chance returns the selection probability for a known address.  The priority
 // depends upon how recently the address has been seen, how recently it was last
-// attempted and how often attempts to connect to it have failed.
+// attempted, how often attempts to connect to it have failed and whether it
+// advertises a message store which can serve ciphrtxt message headers.
 func (ka *KnownAddress) chance() float64 {
 	now := time.Now()
 	lastSeen := now.Sub(ka.na.Timestamp)
@@ -60,6 +61,12 @@ func (ka *KnownAddress) chance() float64 {
 		c /= 1.5
 	}
 
+	// Peers which can serve message headers are preferred since blocks
+	// can not be validated without their nonce headers.
+	if ka.na.Services&wire.SFNodeMsgStore == wire.SFNodeMsgStore {
+		c *= msgStoreChanceFactor
+	}
+
 	return c
 }
 
@@ -43,6 +43,12 @@ func TestChance(t *testing.T) {
 			addrmgr.TstNewKnownAddress(&wire.NetAddress{Timestamp: time.Now().Add(-35 * time.Second)},
 				2, time.Now().Add(-30*time.Minute), time.Now(), false, 0),
 			1 / 1.5 / 1.5,
+		}, {
+			//Test case with a message store.
+			addrmgr.TstNewKnownAddress(&wire.NetAddress{Timestamp: time.Now().Add(-35 * time.Second),
+				Services: wire.SFNodeNetwork | wire.SFNodeMsgStore},
+				0, time.Now().Add(-30*time.Minute), time.Now(), false, 0),
+			2.0,
 		},
 	}
 
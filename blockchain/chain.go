@@ -14,7 +14,6 @@ import (
 
 	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
-	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
 	"github.com/jadeblaquiere/cttd/database"
 	"github.com/jadeblaquiere/cttd/txscript"
 	"github.com/jadeblaquiere/cttd/wire"
@@ -64,6 +63,11 @@ type blockNode struct {
 	version   int32
 	bits      uint32
 	timestamp time.Time
+
+	// nonceIKeys houses the I keys of the message headers used as the
+	// nonces of the block to aid in detecting nonce header reuse.  The
+	// key of a nonce header which does not parse is empty.
+	nonceIKeys [2]string
 }
 
 // newBlockNode returns a new block node for the given block header.  It is
@@ -84,6 +88,8 @@ func newBlockNode(blockHeader *wire.BlockHeader, blockHash *chainhash.Hash, heig
 		bits:       blockHeader.Bits,
 		timestamp:  blockHeader.Timestamp,
 	}
+	ikeyA, ikeyB := nonceHeaderIKeys(blockHeader)
+	node.nonceIKeys = [2]string{string(ikeyA), string(ikeyB)}
 	return &node
 }
 
@@ -136,6 +142,7 @@ type BestState struct {
 	NumTxns    uint64          // The number of txns in the block.
 	TotalTxns  uint64          // The total number of txns in the chain.
 	MedianTime time.Time       // Median time as per CalcPastMedianTime.
+	WorkSum    *big.Int        // The total work of the chain.
 }
 
 // newBestState returns a new best stats instance for the given parameters.
@@ -148,6 +155,7 @@ func newBestState(node *blockNode, blockSize, numTxns, totalTxns uint64, medianT
 		NumTxns:    numTxns,
 		TotalTxns:  totalTxns,
 		MedianTime: medianTime,
+		WorkSum:    node.workSum,
 	}
 }
 
@@ -161,7 +169,7 @@ type BlockChain struct {
 	// separate mutex.
 	checkpointsByHeight map[int32]*chaincfg.Checkpoint
 	db                  database.DB
-	headerCache         *ciphrtxt.HeaderCache
+	headerCache         MessageHeaderSource
 	chainParams         *chaincfg.Params
 	timeSource          MedianTimeSource
 	notifications       NotificationCallback
@@ -207,11 +215,23 @@ type BlockChain struct {
 	oldestOrphan *orphanBlock
 	blockCache   map[chainhash.Hash]*cttutil.Block
 
+	// These fields are related to handling of blocks which are waiting
+	// for their nonce headers to become known to the message header
+	// source.  They are protected by a combination of the chain lock and
+	// the pending lock.
+	pendingLock   sync.RWMutex
+	pendingBlocks map[chainhash.Hash]*pendingBlock
+
 	// These fields are related to checkpoint handling.  They are protected
 	// by the chain lock.
 	nextCheckpoint  *chaincfg.Checkpoint
 	checkpointBlock *cttutil.Block
 
+	// deploymentCaches caches the current deployment threshold state for
+	// blocks in each of the actively defined deployments.  They are
+	// protected by the chain lock.
+	deploymentCaches []thresholdStateCache
+
 	// The state is used as a fairly efficient way to cache information
 	// about the current best chain state that is returned to callers when
 	// requested.  It operates on the principle of MVCC such that any time a
@@ -242,7 +262,8 @@ func (b *BlockChain) DisableVerify(disable bool) {
 
 // HaveBlock returns whether or not the chain instance has the block represented
 // by the passed hash.  This includes checking the various places a block can
-// be like part of the main chain, on a side chain, or in the orphan pool.
+// be like part of the main chain, on a side chain, in the orphan pool, or in
+// the pool of blocks awaiting their nonce headers.
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) HaveBlock(hash *chainhash.Hash) (bool, error) {
@@ -253,7 +274,7 @@ func (b *BlockChain) HaveBlock(hash *chainhash.Hash) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return exists || b.IsKnownOrphan(hash), nil
+	return exists || b.IsKnownOrphan(hash) || b.IsKnownPending(hash), nil
 }
 
 // IsKnownOrphan returns whether the passed hash is currently a known orphan.
@@ -1460,12 +1481,12 @@ type Config struct {
 	// index manager.
 	IndexManager IndexManager
     
-    // HeaderCache provides a connection to the header cache which is
-    // used to validate that block nonces are referencing headers which 
-    // are in the message store cache
-    //
-    // If HeaderCache is nil, this check will be skipped
-    HeaderCache *ciphrtxt.HeaderCache
+	// HeaderCache defines the source of message headers which is used to
+	// validate that block nonces are referencing headers which are in the
+	// message store.
+	//
+	// This field can be nil, in which case the check is skipped.
+	HeaderCache MessageHeaderSource
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1492,6 +1513,13 @@ func New(config *Config) (*BlockChain, error) {
 	targetTimespan := int64(params.TargetTimespan)
 	targetTimePerBlock := int64(params.TargetTimePerBlock)
 	adjustmentFactor := params.RetargetAdjustmentFactor
+
+	// Keep enough nodes in memory for both the retarget interval and the
+	// window of the LWMA difficulty algorithm.
+	minMemoryNodes := int32(targetTimespan / targetTimePerBlock)
+	if params.LWMAWindow+1 > minMemoryNodes {
+		minMemoryNodes = params.LWMAWindow + 1
+	}
 	b := BlockChain{
 		checkpointsByHeight: checkpointsByHeight,
 		db:                  config.DB,
@@ -1504,13 +1532,15 @@ func New(config *Config) (*BlockChain, error) {
 		minRetargetTimespan: targetTimespan / adjustmentFactor,
 		maxRetargetTimespan: targetTimespan * adjustmentFactor,
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
-		minMemoryNodes:      int32(targetTimespan / targetTimePerBlock),
+		minMemoryNodes:      minMemoryNodes,
 		bestNode:            nil,
 		index:               make(map[chainhash.Hash]*blockNode),
 		depNodes:            make(map[chainhash.Hash][]*blockNode),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
+		pendingBlocks:       make(map[chainhash.Hash]*pendingBlock),
 		blockCache:          make(map[chainhash.Hash]*cttutil.Block),
+		deploymentCaches:    newThresholdCaches(chaincfg.DefinedDeployments),
 	}
 
 	// Initialize the chain state from the passed database.  When the db
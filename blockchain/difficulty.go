@@ -5,12 +5,21 @@
 package blockchain
 
 import (
+	"fmt"
 	"math/big"
 	"time"
 
+	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/wire"
 )
 
+// lwmaMaxSolveTimeFactor is the multiple of the target time per block which
+// the solve time of a block is limited to by the LWMA difficulty algorithm.  It
+// limits how much a block with a timestamp far in the future is able to lower
+// the difficulty.
+const lwmaMaxSolveTimeFactor = 6
+
 var (
 	// bigOne is 1 represented as a big.Int.  It is defined here to avoid
 	// the overhead of creating it multiple times.
@@ -153,14 +162,24 @@ func CalcWork(bits uint32) *big.Int {
 }
 
 // calcEasiestDifficulty calculates the easiest possible difficulty that a block
-// can have given starting difficulty bits and a duration.  It is mainly used to
-// verify that claimed proof of work by a block is sane as compared to a
-// known good checkpoint.
-func (b *BlockChain) calcEasiestDifficulty(bits uint32, duration time.Duration) uint32 {
+// at the passed height can have given starting difficulty bits and a duration.
+// It is mainly used to verify that claimed proof of work by a block is sane as
+// compared to a known good checkpoint.
+func (b *BlockChain) calcEasiestDifficulty(bits uint32, duration time.Duration, height int32) uint32 {
 	// Convert types used in the calculations below.
 	durationVal := int64(duration)
 	adjustmentFactor := big.NewInt(b.chainParams.RetargetAdjustmentFactor)
 
+	// The per-block LWMA difficulty algorithm lowers the difficulty with
+	// every block rather than once per retarget interval and the number of
+	// blocks within a duration is not limited, so there is no easier
+	// difficulty than the proof of work limit for the blocks it applies to.
+	if b.chainParams.LWMAWindow > 0 &&
+		height >= b.chainParams.LWMAActivationHeight && durationVal > 0 {
+
+		return b.chainParams.PowLimitBits
+	}
+
 	// The test network rules allow minimum difficulty blocks after more
 	// than twice the desired amount of time needed to generate a block has
 	// elapsed.
@@ -221,6 +240,117 @@ func (b *BlockChain) findPrevTestNetDifficulty(startNode *blockNode) (uint32, er
 	return lastBits, nil
 }
 
+// calcLWMARequiredDifficulty calculates the required difficulty of the block
+// after a window of blocks with the passed timestamps and difficulty bits using
+// the linearly weighted moving average (LWMA) difficulty algorithm.  The
+// timestamps are unix times ordered from oldest to newest and start with the
+// timestamp of the block before the window, so there is one more of them than
+// there are bits.
+//
+// The solve time of each block of the window is weighted by its position, so
+// the newest block counts N times as much as the oldest one of a window of N
+// blocks, which makes the difficulty respond quickly to changes in the hash
+// rate while still averaging out the variance of the solve times.  The new
+// target is the average target of the window scaled by the ratio of the
+// weighted solve times to the weighted target time per block T:
+//
+//	newTarget = avgTarget * sum(i * solveTime_i) / (N * (N + 1) / 2 * T)
+//
+// A timestamp which is not after the one before it is treated as one second
+// after it, and solve times are limited to lwmaMaxSolveTimeFactor times the
+// target time per block, so blocks with out of order or far future timestamps
+// are not able to move the difficulty much.
+func calcLWMARequiredDifficulty(timestamps []int64, bits []uint32, targetTimePerBlock int64, powLimit *big.Int) uint32 {
+	maxSolveTime := lwmaMaxSolveTimeFactor * targetTimePerBlock
+	weightedSolveTimes := new(big.Int)
+	sumTargets := new(big.Int)
+	prevTimestamp := timestamps[0]
+	for i := range bits {
+		timestamp := timestamps[i+1]
+		if timestamp <= prevTimestamp {
+			timestamp = prevTimestamp + 1
+		}
+		solveTime := timestamp - prevTimestamp
+		if solveTime > maxSolveTime {
+			solveTime = maxSolveTime
+		}
+		prevTimestamp = timestamp
+
+		weight := int64(i + 1)
+		weightedSolveTimes.Add(weightedSolveTimes,
+			big.NewInt(solveTime*weight))
+		sumTargets.Add(sumTargets, CompactToBig(bits[i]))
+	}
+
+	// Calculate the new target as:
+	//  (sumTargets / N) * weightedSolveTimes / (N * (N + 1) / 2 * T)
+	// which is rearranged to only divide once to avoid losing precision.
+	n := int64(len(bits))
+	denominator := big.NewInt(n * n * (n + 1) / 2 * targetTimePerBlock)
+	newTarget := new(big.Int).Mul(sumTargets, weightedSolveTimes)
+	newTarget.Div(newTarget, denominator)
+
+	// Limit new value to the proof of work limit.
+	if newTarget.Cmp(powLimit) > 0 {
+		newTarget.Set(powLimit)
+	}
+	return BigToCompact(newTarget)
+}
+
+// calcNextLWMADifficulty calculates the required difficulty for the block after
+// the passed previous block node with the LWMA difficulty algorithm over the
+// window of blocks which ends with it.  The window only holds the blocks after
+// the genesis block when the chain is not yet long enough for a full one.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) calcNextLWMADifficulty(lastNode *blockNode) (uint32, error) {
+	// Gather the nodes of the window along with the node before it from
+	// newest to oldest.
+	window := b.chainParams.LWMAWindow
+	nodes := make([]*blockNode, 0, window+1)
+	for node := lastNode; node != nil && int32(len(nodes)) <= window; {
+		nodes = append(nodes, node)
+
+		// Get the previous block node.  This function is used over
+		// simply accessing node.parent directly as it will dynamically
+		// create previous block nodes as needed.  This helps allow only
+		// the pieces of the chain that are needed to remain in memory.
+		var err error
+		node, err = b.getPrevNodeFromNode(node)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// There are no solve times to average when the previous block is the
+	// genesis block, so keep its difficulty.
+	if len(nodes) < 2 {
+		return lastNode.bits, nil
+	}
+
+	timestamps := make([]int64, len(nodes))
+	bits := make([]uint32, len(nodes)-1)
+	for i, node := range nodes {
+		j := len(nodes) - 1 - i
+		timestamps[j] = node.timestamp.Unix()
+		if j > 0 {
+			bits[j-1] = node.bits
+		}
+	}
+
+	targetTimePerBlock := int64(b.chainParams.TargetTimePerBlock / time.Second)
+	newTargetBits := calcLWMARequiredDifficulty(timestamps, bits,
+		targetTimePerBlock, b.chainParams.PowLimit)
+	log.Debugf("LWMA difficulty at block height %d over %d blocks",
+		lastNode.height+1, len(bits))
+	log.Debugf("Old target %08x (%064x)", lastNode.bits,
+		CompactToBig(lastNode.bits))
+	log.Debugf("New target %08x (%064x)", newTargetBits,
+		CompactToBig(newTargetBits))
+
+	return newTargetBits, nil
+}
+
 // calcNextRequiredDifficulty calculates the required difficulty for the block
 // after the passed previous block node based on the difficulty retarget rules.
 // This function differs from the exported CalcNextRequiredDifficulty in that
@@ -234,6 +364,14 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 		return b.chainParams.PowLimitBits, nil
 	}
 
+	// Networks which use the per-block LWMA difficulty algorithm calculate
+	// the difficulty of every block with it once it activates.
+	if b.chainParams.LWMAWindow > 0 &&
+		lastNode.height+1 >= b.chainParams.LWMAActivationHeight {
+
+		return b.calcNextLWMADifficulty(lastNode)
+	}
+
 	// Return the previous block's difficulty requirements if this block
 	// is not at a difficulty retarget interval.
 	if (lastNode.height+1)%b.blocksPerRetarget != 0 {
@@ -334,3 +472,70 @@ func (b *BlockChain) CalcNextRequiredDifficulty(timestamp time.Time) (uint32, er
 	b.chainLock.Unlock()
 	return difficulty, err
 }
+
+// DifficultyHeadersNeeded returns the number of the most recent block headers
+// of a chain which CalcNextRequiredDifficultyFromHeaders needs to calculate
+// the required difficulty of the next block with the difficulty retarget rules
+// of the passed chain parameters.  Fewer headers are only needed when the
+// chain is shorter than that.
+func DifficultyHeadersNeeded(params *chaincfg.Params) int32 {
+	needed := int32(params.TargetTimespan / params.TargetTimePerBlock)
+	if params.LWMAWindow >= needed {
+		needed = params.LWMAWindow + 1
+	}
+	return needed
+}
+
+// CalcNextRequiredDifficultyFromHeaders calculates the required difficulty for
+// a block with the passed timestamp which extends the chain ending with the
+// passed block headers based on the difficulty retarget rules of the passed
+// chain parameters.  The headers are ordered from oldest to newest and the last
+// one is at the passed height.  They must either start with the genesis block
+// or consist of at least DifficultyHeadersNeeded headers.
+//
+// It allows the required difficulty to be calculated without a block chain
+// instance, such as by clients which fetch the headers from a node.
+func CalcNextRequiredDifficultyFromHeaders(headers []wire.BlockHeader, lastHeight int32, newBlockTime time.Time, params *chaincfg.Params) (uint32, error) {
+	numHeaders := int32(len(headers))
+	firstHeight := lastHeight - numHeaders + 1
+	if numHeaders == 0 || firstHeight < 0 {
+		return 0, fmt.Errorf("%d headers ending at height %d do not "+
+			"form a valid chain", numHeaders, lastHeight)
+	}
+	if firstHeight != 0 && numHeaders < DifficultyHeadersNeeded(params) {
+		return 0, fmt.Errorf("%d headers are needed to calculate the "+
+			"required difficulty, but only %d were provided",
+			DifficultyHeadersNeeded(params), numHeaders)
+	}
+
+	// Link block nodes for the headers.  The hash of each node is the
+	// previous block hash of the header after it, so only the hash of the
+	// last header, which is never looked up, needs to be calculated.
+	targetTimespan := int64(params.TargetTimespan)
+	targetTimePerBlock := int64(params.TargetTimePerBlock)
+	adjustmentFactor := params.RetargetAdjustmentFactor
+	b := BlockChain{
+		chainParams:         params,
+		minRetargetTimespan: targetTimespan / adjustmentFactor,
+		maxRetargetTimespan: targetTimespan * adjustmentFactor,
+		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
+	}
+	var lastNode *blockNode
+	for i := range headers {
+		var hash *chainhash.Hash
+		switch {
+		case firstHeight+int32(i) == 0:
+			hash = params.GenesisHash
+		case i+1 < len(headers):
+			hash = &headers[i+1].PrevBlock
+		default:
+			blockHash := headers[i].BlockHash()
+			hash = &blockHash
+		}
+		node := newBlockNode(&headers[i], hash, firstHeight+int32(i))
+		node.parent = lastNode
+		lastNode = node
+	}
+
+	return b.calcNextRequiredDifficulty(lastNode, newBlockTime)
+}
@@ -7,8 +7,11 @@ package blockchain_test
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/wire"
 )
 
 func TestBigToCompact(t *testing.T) {
@@ -69,3 +72,443 @@ func TestCalcWork(t *testing.T) {
 		}
 	}
 }
+
+// lwmaTimestamps returns the timestamps of a window of n blocks which were each
+// solved the passed number of seconds after the previous one, starting with
+// the timestamp of the block before the window.
+func lwmaTimestamps(n int, solveTime int64) []int64 {
+	timestamps := make([]int64, n+1)
+	for i := range timestamps {
+		timestamps[i] = 1481000000 + int64(i)*solveTime
+	}
+	return timestamps
+}
+
+// lwmaBits returns the difficulty bits of a window of n blocks which all have
+// the passed bits.
+func lwmaBits(n int, bits uint32) []uint32 {
+	all := make([]uint32, n)
+	for i := range all {
+		all[i] = bits
+	}
+	return all
+}
+
+// scaleBits returns the passed compact bits scaled by num / denom.
+func scaleBits(bits uint32, num, denom int64) uint32 {
+	target := blockchain.CompactToBig(bits)
+	target.Mul(target, big.NewInt(num))
+	target.Div(target, big.NewInt(denom))
+	return blockchain.BigToCompact(target)
+}
+
+// TestCalcLWMARequiredDifficulty ensures the LWMA difficulty algorithm scales
+// the average target of the window by the weighted solve times for every
+// window size along with the handling of out of order timestamps, solve time
+// limits, the proof of work limit and the weighting of the solve times.
+func TestCalcLWMARequiredDifficulty(t *testing.T) {
+	const targetTimePerBlock = 60
+	const bits = 0x1e00ffff
+	powLimit := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 248),
+		big.NewInt(1))
+
+	// Blocks which are all solved in the same amount of time scale the
+	// target by the ratio of it to the target time per block, up to the
+	// solve time limit of six times the target time per block, for every
+	// window size.
+	solveTimes := []int64{1, 2, 15, 30, 59, 60, 61, 90, 120, 300, 360,
+		361, 3600, 86400}
+	for n := 1; n <= 144; n++ {
+		for _, solveTime := range solveTimes {
+			limited := solveTime
+			if limited > 6*targetTimePerBlock {
+				limited = 6 * targetTimePerBlock
+			}
+			want := scaleBits(bits, limited, targetTimePerBlock)
+			got := blockchain.TstCalcLWMARequiredDifficulty(
+				lwmaTimestamps(n, solveTime), lwmaBits(n, bits),
+				targetTimePerBlock, powLimit)
+			if got != want {
+				t.Fatalf("window %d, solve time %d: got %08x, "+
+					"want %08x", n, solveTime, got, want)
+			}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		timestamps []int64
+		bits       []uint32
+		want       uint32
+	}{
+		{
+			// The third block is treated as solved one second
+			// after the second one, so the weighted solve times
+			// are 60*1 + 1*2 + 59*3 = 239 instead of 360.
+			name:       "out of order timestamp",
+			timestamps: []int64{0, 60, 30, 120},
+			bits:       lwmaBits(3, bits),
+			want:       scaleBits(bits, 239, 360),
+		},
+		{
+			name:       "equal timestamps",
+			timestamps: []int64{100, 100, 100, 100, 100},
+			bits:       lwmaBits(4, bits),
+			want:       scaleBits(bits, 1, 60),
+		},
+		{
+			// The weighted solve times are 30*1 + 60*2 + 90*3 =
+			// 420 while the weighted target is 60*6 = 360.
+			name:       "weighted solve times",
+			timestamps: []int64{0, 30, 90, 180},
+			bits:       lwmaBits(3, bits),
+			want:       scaleBits(bits, 420, 360),
+		},
+		{
+			name:       "average target",
+			timestamps: lwmaTimestamps(4, 60),
+			bits: []uint32{bits, scaleBits(bits, 3, 1), bits,
+				scaleBits(bits, 3, 1)},
+			want: scaleBits(bits, 2, 1),
+		},
+		{
+			name:       "limited to the proof of work limit",
+			timestamps: lwmaTimestamps(10, 3600),
+			bits:       lwmaBits(10, blockchain.BigToCompact(powLimit)),
+			want:       blockchain.BigToCompact(powLimit),
+		},
+		{
+			name:       "far future timestamp",
+			timestamps: []int64{0, 60, 60 + 86400*365},
+			bits:       lwmaBits(2, bits),
+			want:       scaleBits(bits, 60*1+360*2, 180),
+		},
+	}
+
+	for _, test := range tests {
+		got := blockchain.TstCalcLWMARequiredDifficulty(test.timestamps,
+			test.bits, targetTimePerBlock, powLimit)
+		if got != test.want {
+			t.Errorf("%s: got %08x, want %08x", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// TestLWMAWeighting ensures the solve times of newer blocks of the window
+// change the difficulty more than the solve times of older ones and that a
+// slower block never makes the difficulty harder.
+func TestLWMAWeighting(t *testing.T) {
+	const targetTimePerBlock = 60
+	const bits = 0x1e00ffff
+	const n = 30
+	powLimit := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 248),
+		big.NewInt(1))
+
+	// slowBlockTarget returns the target for a window of blocks solved on
+	// target apart from the block at the passed position, which takes the
+	// passed solve time.
+	slowBlockTarget := func(pos int, solveTime int64) *big.Int {
+		timestamps := make([]int64, n+1)
+		for i := 1; i <= n; i++ {
+			timestamps[i] = timestamps[i-1] + targetTimePerBlock
+			if i == pos {
+				timestamps[i] += solveTime - targetTimePerBlock
+			}
+		}
+		got := blockchain.TstCalcLWMARequiredDifficulty(timestamps,
+			lwmaBits(n, bits), targetTimePerBlock, powLimit)
+		return blockchain.CompactToBig(got)
+	}
+
+	for _, solveTime := range []int64{120, 240, 360} {
+		prev := blockchain.CompactToBig(bits)
+		for pos := 1; pos <= n; pos++ {
+			target := slowBlockTarget(pos, solveTime)
+			if target.Cmp(prev) <= 0 {
+				t.Fatalf("solve time %d: block %d target %064x "+
+					"is not easier than block %d target %064x",
+					solveTime, pos, target, pos-1, prev)
+			}
+			prev = target
+		}
+	}
+
+	for pos := 1; pos <= n; pos++ {
+		prev := slowBlockTarget(pos, 1)
+		for solveTime := int64(2); solveTime <= 400; solveTime++ {
+			target := slowBlockTarget(pos, solveTime)
+			if target.Cmp(prev) < 0 {
+				t.Fatalf("block %d: solve time %d target %064x "+
+					"is harder than solve time %d target %064x",
+					pos, solveTime, target, solveTime-1, prev)
+			}
+			prev = target
+		}
+	}
+}
+
+// TestCalcEasiestDifficultyLWMA ensures the easiest difficulty of blocks which
+// use the LWMA difficulty algorithm is the proof of work limit while the blocks
+// before it activates are still limited by the retarget rules.
+func TestCalcEasiestDifficultyLWMA(t *testing.T) {
+	const bits = 0x1b0404cb
+	params := chaincfg.CTIndigoNetParams
+	params.LWMAWindow = 10
+	params.LWMAActivationHeight = 100
+	retargeted := scaleBits(bits, params.RetargetAdjustmentFactor, 1)
+
+	tests := []struct {
+		name     string
+		window   int32
+		duration time.Duration
+		height   int32
+		want     uint32
+	}{
+		{
+			name:     "at activation",
+			window:   10,
+			duration: time.Hour,
+			height:   100,
+			want:     params.PowLimitBits,
+		},
+		{
+			name:     "after activation",
+			window:   10,
+			duration: time.Hour,
+			height:   5000,
+			want:     params.PowLimitBits,
+		},
+		{
+			name:     "before activation",
+			window:   10,
+			duration: time.Hour,
+			height:   99,
+			want:     retargeted,
+		},
+		{
+			name:     "disabled",
+			window:   0,
+			duration: time.Hour,
+			height:   100,
+			want:     retargeted,
+		},
+		{
+			name:     "no elapsed time",
+			window:   10,
+			duration: 0,
+			height:   100,
+			want:     bits,
+		},
+	}
+
+	for _, test := range tests {
+		params.LWMAWindow = test.window
+		got := blockchain.TstCalcEasiestDifficulty(&params, bits,
+			test.duration, test.height)
+		if got != test.want {
+			t.Errorf("%s: got %08x, want %08x", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// lwmaTestChain returns the headers of a chain which starts with the genesis
+// block of the passed parameters and has the passed number of blocks after it,
+// each with the passed bits and solved the passed amount of time after the
+// previous one.
+func lwmaTestChain(params *chaincfg.Params, numBlocks int, bits uint32, solveTime time.Duration) []wire.BlockHeader {
+	headers := make([]wire.BlockHeader, numBlocks+1)
+	headers[0] = params.GenesisBlock.Header
+	for i := 1; i <= numBlocks; i++ {
+		headers[i] = headers[0]
+		headers[i].Bits = bits
+		headers[i].Timestamp = headers[i-1].Timestamp.Add(solveTime)
+	}
+	return headers
+}
+
+// TestCalcNextRequiredDifficultyLWMA ensures the LWMA difficulty algorithm is
+// only used when it is enabled for the network and once it activates, that it
+// uses the blocks after the genesis block when the chain is shorter than the
+// window and that the minimum difficulty reduction rule does not apply to it.
+func TestCalcNextRequiredDifficultyLWMA(t *testing.T) {
+	const bits = 0x1e00ffff
+	tests := []struct {
+		name              string
+		window            int32
+		activationHeight  int32
+		reduceMinDiff     bool
+		numBlocks         int
+		solveTime         time.Duration
+		newBlockTimeAfter time.Duration
+		want              uint32
+	}{
+		{
+			name:      "disabled",
+			window:    0,
+			numBlocks: 20,
+			solveTime: time.Minute * 2,
+			want:      bits,
+		},
+		{
+			name:             "before activation",
+			window:           10,
+			activationHeight: 21,
+			numBlocks:        19,
+			solveTime:        time.Minute * 2,
+			want:             bits,
+		},
+		{
+			name:             "at activation",
+			window:           10,
+			activationHeight: 21,
+			numBlocks:        20,
+			solveTime:        time.Minute * 2,
+			want:             scaleBits(bits, 2, 1),
+		},
+		{
+			name:             "after activation",
+			window:           10,
+			activationHeight: 5,
+			numBlocks:        20,
+			solveTime:        time.Second * 20,
+			want:             scaleBits(bits, 1, 3),
+		},
+		{
+			// The genesis block has different bits, so they would
+			// change the result if they were part of the window.
+			name:             "chain shorter than window",
+			window:           60,
+			activationHeight: 0,
+			numBlocks:        7,
+			solveTime:        time.Minute * 3,
+			want:             scaleBits(bits, 3, 1),
+		},
+		{
+			name:             "only genesis block",
+			window:           60,
+			activationHeight: 0,
+			numBlocks:        0,
+			want:             chaincfg.CTIndigoNetParams.GenesisBlock.Header.Bits,
+		},
+		{
+			name:              "min difficulty reduction ignored",
+			window:            10,
+			activationHeight:  0,
+			reduceMinDiff:     true,
+			numBlocks:         20,
+			solveTime:         time.Minute,
+			newBlockTimeAfter: time.Hour,
+			want:              bits,
+		},
+	}
+
+	for _, test := range tests {
+		params := chaincfg.CTIndigoNetParams
+		params.LWMAWindow = test.window
+		params.LWMAActivationHeight = test.activationHeight
+		params.ReduceMinDifficulty = test.reduceMinDiff
+		params.MinDiffReductionTime = time.Minute * 2
+
+		headers := lwmaTestChain(&params, test.numBlocks, bits,
+			test.solveTime)
+		newBlockTime := headers[len(headers)-1].Timestamp.Add(
+			test.newBlockTimeAfter)
+		got, err := blockchain.CalcNextRequiredDifficultyFromHeaders(
+			headers, int32(test.numBlocks), newBlockTime, &params)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %08x, want %08x", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// TestCalcNextRequiredDifficultyFromHeaders ensures the required difficulty is
+// calculated from headers which do not start with the genesis block as long as
+// there are enough of them and that invalid header chains are rejected.
+func TestCalcNextRequiredDifficultyFromHeaders(t *testing.T) {
+	const bits = 0x1e00ffff
+	params := chaincfg.CTIndigoNetParams
+	params.TargetTimespan = time.Minute * 10
+	params.TargetTimePerBlock = time.Minute
+	params.ReduceMinDifficulty = true
+	params.MinDiffReductionTime = time.Minute * 2
+	needed := blockchain.DifficultyHeadersNeeded(&params)
+	if needed != 10 {
+		t.Fatalf("DifficultyHeadersNeeded: got %d, want 10", needed)
+	}
+
+	// The headers from height 15 to height 24 have minimum difficulty bits
+	// apart from the one at height 20, which starts a retarget interval.
+	headers := lwmaTestChain(&params, int(needed), params.PowLimitBits,
+		time.Minute*3)[1:]
+	headers[5].Bits = bits
+	lastHeight := 2*needed + 4
+	lastTime := headers[len(headers)-1].Timestamp
+
+	tests := []struct {
+		name         string
+		headers      []wire.BlockHeader
+		lastHeight   int32
+		newBlockTime time.Time
+		want         uint32
+		wantErr      bool
+	}{
+		{
+			name:         "last difficulty without min rule",
+			headers:      headers,
+			lastHeight:   lastHeight,
+			newBlockTime: lastTime.Add(time.Minute),
+			want:         bits,
+		},
+		{
+			name:         "min difficulty after reduction time",
+			headers:      headers,
+			lastHeight:   lastHeight,
+			newBlockTime: lastTime.Add(time.Minute * 3),
+			want:         params.PowLimitBits,
+		},
+		{
+			name:       "too few headers",
+			headers:    headers[1:],
+			lastHeight: lastHeight,
+			wantErr:    true,
+		},
+		{
+			name:       "no headers",
+			lastHeight: lastHeight,
+			wantErr:    true,
+		},
+		{
+			name:       "more headers than blocks",
+			headers:    headers,
+			lastHeight: needed - 2,
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := blockchain.CalcNextRequiredDifficultyFromHeaders(
+			test.headers, test.lastHeight, test.newBlockTime, &params)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: did not receive expected error",
+					test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %08x, want %08x", test.name, got,
+				test.want)
+		}
+	}
+}
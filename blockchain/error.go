@@ -18,6 +18,16 @@ func (e AssertError) Error() string {
 	return "assertion failed: " + string(e)
 }
 
+// DeploymentError identifies an error that indicates a deployment ID was
+// specified that does not exist.
+type DeploymentError uint32
+
+// Error returns the deployment error as a human-readable string and satisfies
+// the error interface.
+func (e DeploymentError) Error() string {
+	return fmt.Sprintf("deployment ID %d does not exist", uint32(e))
+}
+
 // ErrorCode identifies a kind of error.
 type ErrorCode int
 
@@ -192,6 +202,32 @@ const (
 	// ErrNonceValidation indicates the message header nonces which solve
     // the block are malformed or not available via the message service
 	ErrNonceValidation
+
+	// ErrBadAccessKey indicates a transaction output which registers a
+	// network access key is malformed, is not signed by the key it
+	// registers, or registers a key which has already expired.
+	ErrBadAccessKey
+
+	// ErrNonceHeaderReuse indicates a block uses the same message header
+	// as both of its nonces or uses a message header which is already
+	// used as a nonce by a recent ancestor.
+	ErrNonceHeaderReuse
+
+	// ErrMissingNonceHeader indicates a message header used as a nonce of
+	// a block has not expired but is not yet known to the message header
+	// source.  This is typically temporary since blocks may propagate
+	// faster than the messages they reference.
+	ErrMissingNonceHeader
+
+	// ErrNonceHeaderTooOld indicates a message header used as a nonce of a
+	// block was stored more than the nonce header max age of the chain
+	// parameters before the block timestamp.
+	ErrNonceHeaderTooOld
+
+	// ErrNonceHeaderExpiring indicates a message header used as a nonce of
+	// a block expires less than the nonce header min lifetime of the chain
+	// parameters after the block timestamp.
+	ErrNonceHeaderExpiring
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -235,6 +271,11 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrScriptMalformed:       "ErrScriptMalformed",
 	ErrScriptValidation:      "ErrScriptValidation",
 	ErrNonceValidation:       "ErrNonceValidation",
+	ErrBadAccessKey:          "ErrBadAccessKey",
+	ErrNonceHeaderReuse:      "ErrNonceHeaderReuse",
+	ErrMissingNonceHeader:    "ErrMissingNonceHeader",
+	ErrNonceHeaderTooOld:     "ErrNonceHeaderTooOld",
+	ErrNonceHeaderExpiring:   "ErrNonceHeaderExpiring",
 }
 
 // String returns the ErrorCode as a human-readable name.
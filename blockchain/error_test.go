@@ -55,6 +55,12 @@ func TestErrorCodeStringer(t *testing.T) {
 		{blockchain.ErrBadCoinbaseHeight, "ErrBadCoinbaseHeight"},
 		{blockchain.ErrScriptMalformed, "ErrScriptMalformed"},
 		{blockchain.ErrScriptValidation, "ErrScriptValidation"},
+		{blockchain.ErrNonceValidation, "ErrNonceValidation"},
+		{blockchain.ErrBadAccessKey, "ErrBadAccessKey"},
+		{blockchain.ErrNonceHeaderReuse, "ErrNonceHeaderReuse"},
+		{blockchain.ErrMissingNonceHeader, "ErrMissingNonceHeader"},
+		{blockchain.ErrNonceHeaderTooOld, "ErrNonceHeaderTooOld"},
+		{blockchain.ErrNonceHeaderExpiring, "ErrNonceHeaderExpiring"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
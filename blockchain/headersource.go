@@ -0,0 +1,198 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+)
+
+// MessageHeaderSource provides access to the ciphrtxt message headers which
+// are used as the nonces of blocks.  Block validation uses it to ensure the
+// unexpired nonce headers of a block refer to messages which are known to the
+// message store and the miner uses it to find candidate nonce headers.
+//
+// The ciphrtxt header cache, which mirrors the headers of a live message store
+// into a local database, is the implementation used by a running node.
+type MessageHeaderSource interface {
+	// FindByI returns the message header with the passed I key.  An error
+	// is returned when the header is not known.
+	FindByI(I []byte) (*ciphrtxt.RawMessageHeader, error)
+
+	// FindExpiringAfter returns all known message headers which expire
+	// after the passed unix time.
+	FindExpiringAfter(tstamp uint32) ([]ciphrtxt.RawMessageHeader, error)
+
+	// Insert adds the passed message header to the source.  It returns
+	// whether or not the header was added, which is false when the header
+	// is already known.
+	Insert(h *ciphrtxt.RawMessageHeader) (bool, error)
+}
+
+// Ensure the ciphrtxt header cache implements the MessageHeaderSource
+// interface.
+var _ MessageHeaderSource = (*ciphrtxt.HeaderCache)(nil)
+
+// errMessageHeaderNotFound is returned by MemHeaderSource.FindByI when there is
+// no header with the requested I key.
+var errMessageHeaderNotFound = errors.New("message header not found")
+
+// headersByIKey implements sort.Interface to allow a slice of message headers
+// to be sorted by their I keys.
+type headersByIKey []ciphrtxt.RawMessageHeader
+
+// Len returns the number of headers in the slice.  It is part of the
+// sort.Interface implementation.
+func (s headersByIKey) Len() int {
+	return len(s)
+}
+
+// Swap swaps the headers at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s headersByIKey) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the header with index i should sort before the header
+// with index j.  It is part of the sort.Interface implementation.
+func (s headersByIKey) Less(i, j int) bool {
+	return bytes.Compare(s[i].IKey(), s[j].IKey()) < 0
+}
+
+// MemHeaderSource is a MessageHeaderSource which keeps all of its message
+// headers in memory.  It does not require a message store or a database, so it
+// is suitable for unit tests and networks which are not backed by a message
+// store.
+//
+// It is safe for concurrent access.
+type MemHeaderSource struct {
+	mtx     sync.RWMutex
+	headers map[string]ciphrtxt.RawMessageHeader
+}
+
+// Ensure MemHeaderSource implements the MessageHeaderSource interface.
+var _ MessageHeaderSource = (*MemHeaderSource)(nil)
+
+// FindByI returns the message header with the passed I key.  An error is
+// returned when the header is not known.
+//
+// This is part of the MessageHeaderSource interface implementation.
+func (s *MemHeaderSource) FindByI(I []byte) (*ciphrtxt.RawMessageHeader, error) {
+	s.mtx.RLock()
+	h, ok := s.headers[string(I)]
+	s.mtx.RUnlock()
+	if !ok {
+		return nil, errMessageHeaderNotFound
+	}
+	return &h, nil
+}
+
+// FindExpiringAfter returns all known message headers which expire after the
+// passed unix time.  The headers are sorted by their I keys so the result is
+// deterministic.
+//
+// This is part of the MessageHeaderSource interface implementation.
+func (s *MemHeaderSource) FindExpiringAfter(tstamp uint32) ([]ciphrtxt.RawMessageHeader, error) {
+	after := time.Unix(int64(tstamp), 0)
+
+	s.mtx.RLock()
+	hdrs := make([]ciphrtxt.RawMessageHeader, 0, len(s.headers))
+	for _, h := range s.headers {
+		if h.ExpireTime().After(after) {
+			hdrs = append(hdrs, h)
+		}
+	}
+	s.mtx.RUnlock()
+
+	sort.Sort(headersByIKey(hdrs))
+	return hdrs, nil
+}
+
+// Insert adds the passed message header to the source.  It returns whether or
+// not the header was added, which is false when a header with the same I key
+// is already known.
+//
+// This is part of the MessageHeaderSource interface implementation.
+func (s *MemHeaderSource) Insert(h *ciphrtxt.RawMessageHeader) (bool, error) {
+	if h == nil {
+		return false, errors.New("message header is nil")
+	}
+
+	key := string(h.IKey())
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.headers[key]; ok {
+		return false, nil
+	}
+	s.headers[key] = *h
+	return true, nil
+}
+
+// NewMemHeaderSource returns a new empty in-memory message header source.
+func NewMemHeaderSource() *MemHeaderSource {
+	return &MemHeaderSource{
+		headers: make(map[string]ciphrtxt.RawMessageHeader),
+	}
+}
+
+// HeaderCallback is used by NotifyHeaderSource to notify the caller about
+// message headers which were added to the source.
+type HeaderCallback func(h *ciphrtxt.RawMessageHeader)
+
+// NotifyHeaderSource is a MessageHeaderSource which passes all requests on to
+// another message header source and invokes the subscribed callbacks whenever
+// a message header is added through it.  This allows the users of the source,
+// such as the pending block pool and the miners, to react to new headers
+// without polling.
+//
+// It is safe for concurrent access.
+type NotifyHeaderSource struct {
+	MessageHeaderSource
+
+	callbacksLock sync.RWMutex
+	callbacks     []HeaderCallback
+}
+
+// Ensure NotifyHeaderSource implements the MessageHeaderSource interface.
+var _ MessageHeaderSource = (*NotifyHeaderSource)(nil)
+
+// Subscribe registers the passed callback to be invoked with each message
+// header which is added to the source.  The callbacks are invoked
+// synchronously by Insert, so they must not block.
+func (s *NotifyHeaderSource) Subscribe(callback HeaderCallback) {
+	s.callbacksLock.Lock()
+	s.callbacks = append(s.callbacks, callback)
+	s.callbacksLock.Unlock()
+}
+
+// Insert adds the passed message header to the underlying source and notifies
+// the subscribers when it was added.  It returns whether or not the header was
+// added, which is false when the header is already known.
+//
+// This is part of the MessageHeaderSource interface implementation.
+func (s *NotifyHeaderSource) Insert(h *ciphrtxt.RawMessageHeader) (bool, error) {
+	added, err := s.MessageHeaderSource.Insert(h)
+	if err != nil || !added {
+		return added, err
+	}
+
+	s.callbacksLock.RLock()
+	for _, callback := range s.callbacks {
+		callback(h)
+	}
+	s.callbacksLock.RUnlock()
+	return true, nil
+}
+
+// NewNotifyHeaderSource returns a new message header source which notifies its
+// subscribers about the message headers added to the passed source through it.
+func NewNotifyHeaderSource(source MessageHeaderSource) *NotifyHeaderSource {
+	return &NotifyHeaderSource{MessageHeaderSource: source}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+// fixedTimeSource is a blockchain.MedianTimeSource which always reports the
+// same adjusted time.
+type fixedTimeSource struct {
+	adjustedTime time.Time
+}
+
+// AdjustedTime returns the fixed time of the time source.
+//
+// This is part of the blockchain.MedianTimeSource interface.
+func (s *fixedTimeSource) AdjustedTime() time.Time {
+	return s.adjustedTime
+}
+
+// AddTimeSample ignores the passed time sample.
+//
+// This is part of the blockchain.MedianTimeSource interface.
+func (s *fixedTimeSource) AddTimeSample(id string, timeVal time.Time) {}
+
+// Offset always returns a zero offset.
+//
+// This is part of the blockchain.MedianTimeSource interface.
+func (s *fixedTimeSource) Offset() time.Duration {
+	return 0
+}
+
+// genesisNonceHeaders returns the nonce headers of the passed genesis block.
+func genesisNonceHeaders(t *testing.T, params *chaincfg.Params) []*ciphrtxt.RawMessageHeader {
+	header := &params.GenesisBlock.Header
+	headerA := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderA[:])
+	headerB := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderB[:])
+	if headerA == nil || headerB == nil {
+		t.Fatalf("unable to import genesis nonce headers")
+	}
+	return []*ciphrtxt.RawMessageHeader{headerA, headerB}
+}
+
+// TestMemHeaderSource ensures the in-memory message header source finds the
+// headers inserted into it.
+func TestMemHeaderSource(t *testing.T) {
+	t.Parallel()
+
+	hs := blockchain.NewMemHeaderSource()
+	hdrs := genesisNonceHeaders(t, &chaincfg.CTIndigoNetParams)
+	for i, h := range hdrs {
+		if _, err := hs.FindByI(h.IKey()); err == nil {
+			t.Fatalf("FindByI #%d: found header before insert", i)
+		}
+		added, err := hs.Insert(h)
+		if err != nil || !added {
+			t.Fatalf("Insert #%d: unexpected result - added %v, "+
+				"err %v", i, added, err)
+		}
+		added, err = hs.Insert(h)
+		if err != nil || added {
+			t.Fatalf("Insert #%d: unexpected result for duplicate "+
+				"- added %v, err %v", i, added, err)
+		}
+		found, err := hs.FindByI(h.IKey())
+		if err != nil {
+			t.Fatalf("FindByI #%d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(found.IKey(), h.IKey()) {
+			t.Fatalf("FindByI #%d: mismatched header - got %x, "+
+				"want %x", i, found.IKey(), h.IKey())
+		}
+	}
+	if _, err := hs.Insert(nil); err == nil {
+		t.Fatalf("Insert: did not receive expected error for nil " +
+			"header")
+	}
+
+	// Both headers expire after the genesis block and long before now.
+	genesisTime := chaincfg.CTIndigoNetParams.GenesisBlock.Header.Timestamp
+	unexpired, err := hs.FindExpiringAfter(uint32(genesisTime.Unix()))
+	if err != nil {
+		t.Fatalf("FindExpiringAfter: unexpected error: %v", err)
+	}
+	if len(unexpired) != len(hdrs) {
+		t.Fatalf("FindExpiringAfter: unexpected number of headers - "+
+			"got %d, want %d", len(unexpired), len(hdrs))
+	}
+	for i := 1; i < len(unexpired); i++ {
+		if bytes.Compare(unexpired[i-1].IKey(), unexpired[i].IKey()) >= 0 {
+			t.Fatalf("FindExpiringAfter: headers are not sorted by " +
+				"I key")
+		}
+	}
+	unexpired, err = hs.FindExpiringAfter(uint32(time.Now().Unix()))
+	if err != nil {
+		t.Fatalf("FindExpiringAfter: unexpected error: %v", err)
+	}
+	if len(unexpired) != 0 {
+		t.Fatalf("FindExpiringAfter: unexpected number of headers - "+
+			"got %d, want 0", len(unexpired))
+	}
+}
+
+// TestNotifyHeaderSource ensures the notifying message header source passes
+// the headers on to the underlying source and notifies its subscribers only
+// about the headers which were added.
+func TestNotifyHeaderSource(t *testing.T) {
+	t.Parallel()
+
+	mem := blockchain.NewMemHeaderSource()
+	hs := blockchain.NewNotifyHeaderSource(mem)
+	var notified [][]byte
+	hs.Subscribe(func(h *ciphrtxt.RawMessageHeader) {
+		notified = append(notified, h.IKey())
+	})
+
+	hdrs := genesisNonceHeaders(t, &chaincfg.CTIndigoNetParams)
+	for i, h := range hdrs {
+		added, err := hs.Insert(h)
+		if err != nil || !added {
+			t.Fatalf("Insert #%d: unexpected result - added %v, "+
+				"err %v", i, added, err)
+		}
+		if _, err := mem.FindByI(h.IKey()); err != nil {
+			t.Fatalf("FindByI #%d: header not added to the "+
+				"underlying source: %v", i, err)
+		}
+		added, err = hs.Insert(h)
+		if err != nil || added {
+			t.Fatalf("Insert #%d: unexpected result for duplicate "+
+				"- added %v, err %v", i, added, err)
+		}
+	}
+	if _, err := hs.Insert(nil); err == nil {
+		t.Fatalf("Insert: did not receive expected error for nil " +
+			"header")
+	}
+
+	if len(notified) != len(hdrs) {
+		t.Fatalf("unexpected number of notifications - got %d, "+
+			"want %d", len(notified), len(hdrs))
+	}
+	for i, h := range hdrs {
+		if !bytes.Equal(notified[i], h.IKey()) {
+			t.Fatalf("notification #%d: mismatched header - got "+
+				"%x, want %x", i, notified[i], h.IKey())
+		}
+	}
+}
+
+// TestCheckBlockSanityNonceHeaders ensures CheckBlockSanity requires the
+// unexpired nonce headers of a block to be known to the message header source.
+func TestCheckBlockSanityNonceHeaders(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.CTIndigoNetParams
+	block := cttutil.NewBlock(params.GenesisBlock)
+	timeSource := &fixedTimeSource{params.GenesisBlock.Header.Timestamp}
+
+	// The nonce headers are not checked without a header source.
+	err := blockchain.CheckBlockSanity(block, params, timeSource,
+		nil)
+	if err != nil {
+		t.Fatalf("CheckBlockSanity: unexpected error: %v", err)
+	}
+
+	// The nonce headers are unexpired as of the genesis block, so they
+	// must be known to the header source.
+	hs := blockchain.NewMemHeaderSource()
+	err = blockchain.CheckBlockSanity(block, params, timeSource,
+		hs)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrMissingNonceHeader {
+
+		t.Fatalf("CheckBlockSanity: did not receive expected error - "+
+			"got %v, want %v", err, blockchain.ErrMissingNonceHeader)
+	}
+
+	// Ensure the block is not sane while only one header is known.
+	hdrs := genesisNonceHeaders(t, params)
+	if _, err := hs.Insert(hdrs[0]); err != nil {
+		t.Fatalf("Insert: unexpected error: %v", err)
+	}
+	err = blockchain.CheckBlockSanity(block, params, timeSource,
+		hs)
+	if err == nil {
+		t.Fatalf("CheckBlockSanity: did not receive expected error " +
+			"with a missing nonce header")
+	}
+	if _, err := hs.Insert(hdrs[1]); err != nil {
+		t.Fatalf("Insert: unexpected error: %v", err)
+	}
+	err = blockchain.CheckBlockSanity(block, params, timeSource,
+		hs)
+	if err != nil {
+		t.Fatalf("CheckBlockSanity: unexpected error: %v", err)
+	}
+
+	// Expired nonce headers do not need to be known.
+	current := &fixedTimeSource{time.Now()}
+	err = blockchain.CheckBlockSanity(block, params, current,
+		blockchain.NewMemHeaderSource())
+	if err != nil {
+		t.Fatalf("CheckBlockSanity: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/database"
+	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+const (
+	// akIndexName is the human-readable name for the index.
+	akIndexName = "access key index"
+
+	// akKeyTypePubKey is the key type prefix of the entries which map a
+	// public key, expiration time and registering output to the
+	// registration details.
+	akKeyTypePubKey = 0
+
+	// akKeyTypeExpire is the key type prefix of the entries which map an
+	// expiration time, public key and registering output to the
+	// registration details.
+	akKeyTypeExpire = 1
+
+	// akOutPointSize is the number of bytes the tx hash and output index
+	// which identify a registration consume.
+	akOutPointSize = chainhash.HashSize + 4
+
+	// akIndexKeySize is the number of bytes an access key index key
+	// consumes.  It consists of 1 byte key type + 4 bytes expire + 33
+	// bytes public key + 32 bytes tx hash + 4 bytes output index.
+	akIndexKeySize = 1 + txscript.AccessKeyExpireSize +
+		txscript.AccessKeyPubKeySize + akOutPointSize
+
+	// akIndexValueSize is the number of bytes an access key index value
+	// consumes.  It consists of 64 bytes signature + 4 bytes block height.
+	akIndexValueSize = txscript.AccessKeySignatureSize + 4
+)
+
+var (
+	// akIndexKey is the key of the access key index and the db bucket used
+	// to house it.
+	akIndexKey = []byte("akbypubkeyidx")
+
+	// akByteOrder is the byte order used for the expiration times in the
+	// index keys.  Big endian is used so the keys sort by time.
+	akByteOrder = binary.BigEndian
+)
+
+// -----------------------------------------------------------------------------
+// The access key index maps every properly signed network access key (NAK)
+// registered via an OP_REGISTERACCESSKEY output in the main chain to the
+// details of its registration.
+//
+// Two entries are stored for every registration in a single bucket, which
+// allows the index to be dropped in the same manner as the other indexes.  The
+// first one is keyed by the public key followed by the expiration time and
+// supports looking up the registrations of a given key.  The second one is
+// keyed by the expiration time followed by the public key and supports
+// iterating all keys that are valid at a given time.  Since the expiration
+// time is serialized big endian, the entries for both key types are ordered
+// by expiration time.
+//
+// Both keys end with the tx hash and output index of the registering output,
+// so registering the same public key with the same expiration time more than
+// once stores every registration and disconnecting a block removes exactly
+// the registrations it added.
+//
+// The serialized key format is:
+//
+//   <key type><pubkey><expire><tx hash><output index>   (key type 0)
+//   <key type><expire><pubkey><tx hash><output index>   (key type 1)
+//
+//   Field           Type              Size
+//   key type        uint8             1 byte
+//   pubkey          compressed point  33 bytes
+//   expire          uint32 (BE)       4 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes
+//   -----
+//   Total: 74 bytes
+//
+// The serialized value format is:
+//
+//   <signature><block height>
+//
+//   Field           Type              Size
+//   signature       r || s            64 bytes
+//   block height    uint32            4 bytes
+//   -----
+//   Total: 68 bytes
+// -----------------------------------------------------------------------------
+
+// AccessKeyEntry houses the details of a network access key registration
+// stored in the access key index.
+type AccessKeyEntry struct {
+	// Expire is the unix time at which the access key expires.
+	Expire uint32
+
+	// PubKey is the serialized compressed public key.
+	PubKey []byte
+
+	// Signature is the 64-byte (r, s) signature over the expire time and
+	// the public key.
+	Signature []byte
+
+	// TxHash and OutputIndex identify the output which registered the
+	// access key.
+	TxHash      chainhash.Hash
+	OutputIndex uint32
+
+	// BlockHeight is the height of the block which contains the
+	// registration.
+	BlockHeight int32
+}
+
+// akPubKeyIndexKey returns the index key of the passed registration which is
+// used to look up registrations by public key.
+func akPubKeyIndexKey(entry *AccessKeyEntry) []byte {
+	key := make([]byte, akIndexKeySize)
+	key[0] = akKeyTypePubKey
+	offset := 1 + copy(key[1:], entry.PubKey)
+	akByteOrder.PutUint32(key[offset:], entry.Expire)
+	offset += txscript.AccessKeyExpireSize
+	putOutPoint(key[offset:], &entry.TxHash, entry.OutputIndex)
+	return key
+}
+
+// akExpireIndexKey returns the index key of the passed registration which is
+// used to look up registrations by expiration time.
+func akExpireIndexKey(entry *AccessKeyEntry) []byte {
+	key := make([]byte, akIndexKeySize)
+	key[0] = akKeyTypeExpire
+	akByteOrder.PutUint32(key[1:], entry.Expire)
+	offset := 1 + txscript.AccessKeyExpireSize
+	offset += copy(key[offset:], entry.PubKey)
+	putOutPoint(key[offset:], &entry.TxHash, entry.OutputIndex)
+	return key
+}
+
+// serializeAccessKeyEntry returns the serialized index value for the passed
+// entry according to the format described above.
+func serializeAccessKeyEntry(entry *AccessKeyEntry) []byte {
+	serialized := make([]byte, akIndexValueSize)
+	offset := copy(serialized, entry.Signature[:txscript.AccessKeySignatureSize])
+	byteOrder.PutUint32(serialized[offset:], uint32(entry.BlockHeight))
+	return serialized
+}
+
+// deserializeAccessKeyEntry decodes the passed index key and value into an
+// access key entry.  The key may be of either key type.
+func deserializeAccessKeyEntry(key, serialized []byte) (*AccessKeyEntry, error) {
+	if len(key) != akIndexKeySize || len(serialized) != akIndexValueSize {
+		return nil, errDeserialize("unexpected access key index entry " +
+			"size")
+	}
+
+	entry := AccessKeyEntry{
+		PubKey:    make([]byte, txscript.AccessKeyPubKeySize),
+		Signature: make([]byte, txscript.AccessKeySignatureSize),
+	}
+	switch key[0] {
+	case akKeyTypePubKey:
+		copy(entry.PubKey, key[1:])
+		entry.Expire = akByteOrder.Uint32(key[1+txscript.AccessKeyPubKeySize:])
+
+	case akKeyTypeExpire:
+		entry.Expire = akByteOrder.Uint32(key[1:])
+		copy(entry.PubKey, key[1+txscript.AccessKeyExpireSize:])
+
+	default:
+		return nil, errDeserialize(fmt.Sprintf("unknown access key "+
+			"index key type %d", key[0]))
+	}
+	outPoint := key[akIndexKeySize-akOutPointSize:]
+	copy(entry.TxHash[:], outPoint)
+	entry.OutputIndex = byteOrder.Uint32(outPoint[chainhash.HashSize:])
+
+	offset := copy(entry.Signature, serialized)
+	entry.BlockHeight = int32(byteOrder.Uint32(serialized[offset:]))
+	return &entry, nil
+}
+
+// dbPutAccessKeyEntry uses an existing database transaction to add both index
+// entries for the passed access key registration.
+func dbPutAccessKeyEntry(bucket internalBucket, entry *AccessKeyEntry) error {
+	serialized := serializeAccessKeyEntry(entry)
+	if err := bucket.Put(akPubKeyIndexKey(entry), serialized); err != nil {
+		return err
+	}
+	return bucket.Put(akExpireIndexKey(entry), serialized)
+}
+
+// dbRemoveAccessKeyEntry uses an existing database transaction to remove both
+// index entries for the passed access key registration.  Other registrations
+// of the same key and expiration time are left intact.
+func dbRemoveAccessKeyEntry(bucket internalBucket, entry *AccessKeyEntry) error {
+	if err := bucket.Delete(akPubKeyIndexKey(entry)); err != nil {
+		return err
+	}
+	return bucket.Delete(akExpireIndexKey(entry))
+}
+
+// accessKeyEntries returns an access key entry for every OP_REGISTERACCESSKEY
+// output in the passed block which registers a well-formed access key signed by
+// its public key.  All other outputs are ignored, since blocks which predate
+// the access key consensus rules may contain forged or unsigned keys.
+func accessKeyEntries(block *cttutil.Block) []*AccessKeyEntry {
+	var entries []*AccessKeyEntry
+	for _, tx := range block.Transactions() {
+		for i, txOut := range tx.MsgTx().TxOut {
+			ak, err := txscript.ExtractAccessKey(txOut.PkScript)
+			if err != nil || ak.Verify() != nil {
+				continue
+			}
+
+			entries = append(entries, &AccessKeyEntry{
+				Expire:      ak.Expire,
+				PubKey:      ak.PubKey,
+				Signature:   ak.Signature,
+				TxHash:      *tx.Hash(),
+				OutputIndex: uint32(i),
+				BlockHeight: block.Height(),
+			})
+		}
+	}
+	return entries
+}
+
+// AccessKeyIndex implements an index of the network access keys (NAKs)
+// registered in the main chain.  That is to say, it supports querying the
+// registration of a given public key as well as all keys which are valid at a
+// given time.
+type AccessKeyIndex struct {
+	db database.DB
+}
+
+// Ensure the AccessKeyIndex type implements the Indexer interface.
+var _ Indexer = (*AccessKeyIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *AccessKeyIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *AccessKeyIndex) Key() []byte {
+	return akIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *AccessKeyIndex) Name() string {
+	return akIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the access key
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *AccessKeyIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(akIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the entries for every access
+// key registered in the block.
+//
+// This is part of the Indexer interface.
+func (idx *AccessKeyIndex) ConnectBlock(dbTx database.Tx, block *cttutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(akIndexKey)
+	for _, entry := range accessKeyEntries(block) {
+		if err := dbPutAccessKeyEntry(bucket, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for
+// every access key registered in the block.
+//
+// This is part of the Indexer interface.
+func (idx *AccessKeyIndex) DisconnectBlock(dbTx database.Tx, block *cttutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(akIndexKey)
+	for _, entry := range accessKeyEntries(block) {
+		if err := dbRemoveAccessKeyEntry(bucket, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AccessKey returns the registration of the passed serialized compressed
+// public key with the latest expiration time.  The earliest registration is
+// returned when the key was registered with that expiration time more than
+// once.  When the key has never been registered, nil will be returned for both
+// the entry and the error.
+//
+// This function is safe for concurrent access.
+func (idx *AccessKeyIndex) AccessKey(pubKey []byte) (*AccessKeyEntry, error) {
+	if len(pubKey) != txscript.AccessKeyPubKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes",
+			txscript.AccessKeyPubKeySize)
+	}
+
+	prefix := make([]byte, 1+txscript.AccessKeyPubKeySize)
+	prefix[0] = akKeyTypePubKey
+	copy(prefix[1:], pubKey)
+
+	var entry *AccessKeyEntry
+	err := idx.db.View(func(dbTx database.Tx) error {
+		// The entries for the key are ordered by expiration time, so
+		// the last ones with the prefix are the most recent.
+		cursor := dbTx.Metadata().Bucket(akIndexKey).Cursor()
+		for ok := cursor.Seek(prefix); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, prefix) {
+				break
+			}
+
+			next, err := deserializeAccessKeyEntry(key, cursor.Value())
+			if err != nil {
+				return err
+			}
+			if entry == nil || next.Expire > entry.Expire ||
+				next.BlockHeight < entry.BlockHeight {
+
+				entry = next
+			}
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// AccessKeysValidAt returns all access key registrations which have not
+// expired at the passed unix time ordered by expiration time.
+//
+// This function is safe for concurrent access.
+func (idx *AccessKeyIndex) AccessKeysValidAt(validAt uint32) ([]*AccessKeyEntry, error) {
+	seek := make([]byte, 1+txscript.AccessKeyExpireSize)
+	seek[0] = akKeyTypeExpire
+	akByteOrder.PutUint32(seek[1:], validAt)
+
+	var entries []*AccessKeyEntry
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(akIndexKey).Cursor()
+		for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if len(key) == 0 || key[0] != akKeyTypeExpire {
+				break
+			}
+
+			entry, err := deserializeAccessKeyEntry(key, cursor.Value())
+			if err != nil {
+				return err
+			}
+			if entry.Expire <= validAt {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// NewAccessKeyIndex returns a new instance of an indexer that is used to
+// create a mapping of all network access keys registered in the blockchain to
+// the details of their registration.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAccessKeyIndex(db database.DB) *AccessKeyIndex {
+	return &AccessKeyIndex{db: db}
+}
+
+// DropAccessKeyIndex drops the access key index from the provided database if
+// it exists.
+func DropAccessKeyIndex(db database.DB) error {
+	return dropIndex(db, akIndexKey, akIndexName)
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/wire"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+// akIndexBucket provides a mock access key index database bucket by
+// implementing the internalBucket interface.
+type akIndexBucket struct {
+	entries map[[akIndexKeySize]byte][]byte
+}
+
+// Get returns the value associated with the key from the mock access key index
+// bucket.
+//
+// This is part of the internalBucket interface.
+func (b *akIndexBucket) Get(key []byte) []byte {
+	var k [akIndexKeySize]byte
+	copy(k[:], key)
+	return b.entries[k]
+}
+
+// Put stores the provided key/value pair to the mock access key index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *akIndexBucket) Put(key []byte, value []byte) error {
+	var k [akIndexKeySize]byte
+	copy(k[:], key)
+	b.entries[k] = value
+	return nil
+}
+
+// Delete removes the provided key from the mock access key index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *akIndexBucket) Delete(key []byte) error {
+	var k [akIndexKeySize]byte
+	copy(k[:], key)
+	delete(b.entries, k)
+	return nil
+}
+
+// TestAccessKeyIndexEntries ensures access key index entries serialize and
+// deserialize for both key types and that every registration is added and
+// removed on its own when the same key is registered more than once.
+func TestAccessKeyIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	pubKey := bytes.Repeat([]byte{0x02}, txscript.AccessKeyPubKeySize)
+	sig := bytes.Repeat([]byte{0x5a}, txscript.AccessKeySignatureSize)
+	first := &AccessKeyEntry{
+		Expire:      1475000000,
+		PubKey:      pubKey,
+		Signature:   sig,
+		TxHash:      chainhash.Hash{0x01},
+		OutputIndex: 1,
+		BlockHeight: 100,
+	}
+	dup := *first
+	dup.TxHash = chainhash.Hash{0x02}
+	dup.BlockHeight = 101
+
+	// Ensure the entry round trips through both key types.
+	serialized := serializeAccessKeyEntry(first)
+	for _, key := range [][]byte{
+		akPubKeyIndexKey(first),
+		akExpireIndexKey(first),
+	} {
+		entry, err := deserializeAccessKeyEntry(key, serialized)
+		if err != nil {
+			t.Fatalf("deserializeAccessKeyEntry: unexpected error: %v",
+				err)
+		}
+		if !reflect.DeepEqual(entry, first) {
+			t.Fatalf("deserializeAccessKeyEntry: mismatched entry - "+
+				"got %+v, want %+v", entry, first)
+		}
+	}
+
+	// Ensure a short value is rejected.
+	_, err := deserializeAccessKeyEntry(akPubKeyIndexKey(first),
+		serialized[1:])
+	if !isDeserializeErr(err) {
+		t.Fatalf("deserializeAccessKeyEntry: did not receive expected "+
+			"error - got %v", err)
+	}
+
+	// Ensure a duplicate registration is stored alongside the first one.
+	bucket := &akIndexBucket{entries: make(map[[akIndexKeySize]byte][]byte)}
+	if err := dbPutAccessKeyEntry(bucket, first); err != nil {
+		t.Fatalf("dbPutAccessKeyEntry: unexpected error: %v", err)
+	}
+	if err := dbPutAccessKeyEntry(bucket, &dup); err != nil {
+		t.Fatalf("dbPutAccessKeyEntry: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 4 {
+		t.Fatalf("unexpected number of entries - got %d, want 4",
+			len(bucket.entries))
+	}
+
+	// Ensure removing the first registration, such as when its block is
+	// disconnected, leaves the duplicate intact.
+	if err := dbRemoveAccessKeyEntry(bucket, first); err != nil {
+		t.Fatalf("dbRemoveAccessKeyEntry: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 2 {
+		t.Fatalf("unexpected number of entries - got %d, want 2",
+			len(bucket.entries))
+	}
+	key := akExpireIndexKey(&dup)
+	entry, err := deserializeAccessKeyEntry(key, bucket.Get(key))
+	if err != nil {
+		t.Fatalf("deserializeAccessKeyEntry: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(entry, &dup) {
+		t.Fatalf("unexpected entry after removing the first "+
+			"registration - got %+v, want %+v", entry, &dup)
+	}
+
+	// Ensure removing the duplicate removes its entries as well.
+	if err := dbRemoveAccessKeyEntry(bucket, &dup); err != nil {
+		t.Fatalf("dbRemoveAccessKeyEntry: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 0 {
+		t.Fatalf("unexpected number of entries - got %d, want 0",
+			len(bucket.entries))
+	}
+}
+
+// TestAccessKeyEntries ensures only the outputs of a block which register
+// access keys signed by their public keys are indexed.
+func TestAccessKeyEntries(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	signed, err := txscript.SignAccessKey(1475000000, privKey)
+	if err != nil {
+		t.Fatalf("SignAccessKey: unexpected error: %v", err)
+	}
+	forged := *signed
+	forged.Expire++
+
+	akScript := func(ak *txscript.AccessKey) []byte {
+		script, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_REGISTERACCESSKEY).
+			AddData(ak.Serialize()).Script()
+		if err != nil {
+			t.Fatalf("Script: unexpected error: %v", err)
+		}
+		return script
+	}
+
+	tx := wire.NewMsgTx()
+	tx.AddTxOut(wire.NewTxOut(0, akScript(&forged)))
+	tx.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	tx.AddTxOut(wire.NewTxOut(0, akScript(signed)))
+	block := cttutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{tx},
+	})
+	block.SetHeight(100)
+
+	entries := accessKeyEntries(block)
+	if len(entries) != 1 {
+		t.Fatalf("accessKeyEntries: got %d entries, want 1",
+			len(entries))
+	}
+	if entries[0].OutputIndex != 2 || entries[0].Expire != signed.Expire ||
+		!bytes.Equal(entries[0].PubKey, signed.PubKey) {
+
+		t.Fatalf("accessKeyEntries: unexpected entry %+v", entries[0])
+	}
+}
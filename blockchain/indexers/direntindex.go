@@ -0,0 +1,447 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/database"
+	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+const (
+	// direntIndexName is the human-readable name for the index.
+	direntIndexName = "directory index"
+
+	// direntKeyTypeEntry is the key type prefix of the entries which map
+	// the output that posted a directory entry to the entry itself.
+	direntKeyTypeEntry = 0
+
+	// direntKeyTypeOwner is the key type prefix of the entries which
+	// reference the directory entries posted by a given owner.
+	direntKeyTypeOwner = 1
+
+	// direntKeyTypeName is the key type prefix of the entries which
+	// reference the directory entries posted under a given name.
+	direntKeyTypeName = 2
+
+	// direntNameTerminator terminates the name in the name index keys.
+	// Names may not contain control characters, so it never appears in a
+	// name and allows the keys for a name to be distinguished from the
+	// keys of longer names which share it as a prefix.
+	direntNameTerminator = 0x00
+
+	// direntOutPointSize is the number of bytes the tx hash and output
+	// index which identify a posting consume.
+	direntOutPointSize = chainhash.HashSize + 4
+)
+
+var (
+	// direntIndexKey is the key of the directory index and the db bucket
+	// used to house it.
+	direntIndexKey = []byte("direntidx")
+)
+
+// -----------------------------------------------------------------------------
+// The directory index maps every well-formed and properly signed directory
+// entry posted via an OP_POSTDIRECTORY output in the main chain to the output
+// that posted it, and supports looking entries up by owner public key and by
+// name.
+//
+// Three kinds of entries are stored in a single bucket, which allows the index
+// to be dropped in the same manner as the other indexes.  Every posting is
+// identified by its tx hash and output index, so postings never collide and
+// disconnecting a block removes exactly the postings it added.
+//
+// The serialized key formats are:
+//
+//   <key type><tx hash><output index>                   (key type 0)
+//   <key type><owner pubkey><tx hash><output index>     (key type 1)
+//   <key type><name><terminator><tx hash><output index> (key type 2)
+//
+//   Field           Type              Size
+//   key type        uint8             1 byte
+//   owner pubkey    compressed point  33 bytes
+//   name            UTF-8             1 to dirent.MaxNameLen bytes
+//   terminator      uint8 (0x00)      1 byte
+//   tx hash         chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes
+//
+// The serialized value format for key type 0 is:
+//
+//   <block height><serialized directory entry>
+//
+//   Field           Type              Size
+//   block height    uint32            4 bytes
+//   entry           dirent.Entry      variable
+//
+// The serialized value for key types 1 and 2 is the 4 byte block height.
+// -----------------------------------------------------------------------------
+
+// DirectoryEntry houses a directory entry stored in the directory index along
+// with the details of the output which posted it.
+type DirectoryEntry struct {
+	// Entry is the decoded directory entry.
+	Entry *dirent.Entry
+
+	// TxHash and OutputIndex identify the output which posted the entry.
+	TxHash      chainhash.Hash
+	OutputIndex uint32
+
+	// BlockHeight is the height of the block which contains the posting.
+	BlockHeight int32
+}
+
+// putOutPoint serializes the passed tx hash and output index into the passed
+// target byte slice which must be at least direntOutPointSize bytes.
+func putOutPoint(target []byte, txHash *chainhash.Hash, index uint32) {
+	copy(target, txHash[:])
+	byteOrder.PutUint32(target[chainhash.HashSize:], index)
+}
+
+// direntEntryKey returns the index key of the directory entry posted by the
+// passed output.
+func direntEntryKey(txHash *chainhash.Hash, index uint32) []byte {
+	key := make([]byte, 1+direntOutPointSize)
+	key[0] = direntKeyTypeEntry
+	putOutPoint(key[1:], txHash, index)
+	return key
+}
+
+// direntOwnerIndexKey returns the index key which references the passed
+// directory entry by its owner.
+func direntOwnerIndexKey(entry *DirectoryEntry) []byte {
+	key := make([]byte, 1+dirent.PubKeySize+direntOutPointSize)
+	key[0] = direntKeyTypeOwner
+	copy(key[1:], entry.Entry.PubKey)
+	putOutPoint(key[1+dirent.PubKeySize:], &entry.TxHash,
+		entry.OutputIndex)
+	return key
+}
+
+// direntNameIndexKey returns the index key which references the passed
+// directory entry by its name.
+func direntNameIndexKey(entry *DirectoryEntry) []byte {
+	name := entry.Entry.Name
+	key := make([]byte, 1+len(name)+1+direntOutPointSize)
+	key[0] = direntKeyTypeName
+	copy(key[1:], name)
+	key[1+len(name)] = direntNameTerminator
+	putOutPoint(key[2+len(name):], &entry.TxHash, entry.OutputIndex)
+	return key
+}
+
+// serializeDirectoryEntry returns the serialized index value for the passed
+// entry according to the format described above.
+func serializeDirectoryEntry(entry *DirectoryEntry) ([]byte, error) {
+	serializedEntry, err := entry.Entry.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	serialized := make([]byte, 4+len(serializedEntry))
+	byteOrder.PutUint32(serialized, uint32(entry.BlockHeight))
+	copy(serialized[4:], serializedEntry)
+	return serialized, nil
+}
+
+// deserializeDirectoryEntry decodes the passed index key and value of key type
+// 0 into a directory entry.
+func deserializeDirectoryEntry(key, serialized []byte) (*DirectoryEntry, error) {
+	if len(key) != 1+direntOutPointSize || key[0] != direntKeyTypeEntry {
+		return nil, errDeserialize("unexpected directory index entry key")
+	}
+	if len(serialized) < 4 {
+		return nil, errDeserialize("unexpected directory index entry " +
+			"size")
+	}
+
+	entry, err := dirent.Decode(serialized[4:])
+	if err != nil {
+		return nil, errDeserialize(fmt.Sprintf("unable to decode "+
+			"directory entry: %v", err))
+	}
+	dirEntry := DirectoryEntry{
+		Entry:       entry,
+		OutputIndex: byteOrder.Uint32(key[1+chainhash.HashSize:]),
+		BlockHeight: int32(byteOrder.Uint32(serialized)),
+	}
+	copy(dirEntry.TxHash[:], key[1:])
+	return &dirEntry, nil
+}
+
+// dbPutDirectoryEntry uses an existing database transaction to add the passed
+// directory entry along with the entries which reference it by owner and by
+// name.
+func dbPutDirectoryEntry(bucket internalBucket, entry *DirectoryEntry) error {
+	serialized, err := serializeDirectoryEntry(entry)
+	if err != nil {
+		return err
+	}
+	key := direntEntryKey(&entry.TxHash, entry.OutputIndex)
+	if err := bucket.Put(key, serialized); err != nil {
+		return err
+	}
+
+	height := serialized[:4]
+	if err := bucket.Put(direntOwnerIndexKey(entry), height); err != nil {
+		return err
+	}
+	return bucket.Put(direntNameIndexKey(entry), height)
+}
+
+// dbRemoveDirectoryEntry uses an existing database transaction to remove the
+// passed directory entry along with the entries which reference it.
+func dbRemoveDirectoryEntry(bucket internalBucket, entry *DirectoryEntry) error {
+	key := direntEntryKey(&entry.TxHash, entry.OutputIndex)
+	if err := bucket.Delete(key); err != nil {
+		return err
+	}
+	if err := bucket.Delete(direntOwnerIndexKey(entry)); err != nil {
+		return err
+	}
+	return bucket.Delete(direntNameIndexKey(entry))
+}
+
+// directoryEntries returns a directory entry for every OP_POSTDIRECTORY output
+// in the passed block which posts a well-formed entry signed by its owner.
+// All other outputs are ignored.
+func directoryEntries(block *cttutil.Block) []*DirectoryEntry {
+	var entries []*DirectoryEntry
+	for _, tx := range block.Transactions() {
+		for i, txOut := range tx.MsgTx().TxOut {
+			entry, err := txscript.ExtractDirectoryEntry(txOut.PkScript)
+			if err != nil || entry.Verify() != nil {
+				continue
+			}
+
+			entries = append(entries, &DirectoryEntry{
+				Entry:       entry,
+				TxHash:      *tx.Hash(),
+				OutputIndex: uint32(i),
+				BlockHeight: block.Height(),
+			})
+		}
+	}
+	return entries
+}
+
+// DirectoryIndex implements an index of the directory entries posted in the
+// main chain.  That is to say, it supports querying the entries posted by a
+// given owner as well as those posted under a given name or name prefix.
+type DirectoryIndex struct {
+	db database.DB
+}
+
+// Ensure the DirectoryIndex type implements the Indexer interface.
+var _ Indexer = (*DirectoryIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *DirectoryIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *DirectoryIndex) Key() []byte {
+	return direntIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *DirectoryIndex) Name() string {
+	return direntIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the directory
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *DirectoryIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(direntIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds every directory entry posted
+// in the block.
+//
+// This is part of the Indexer interface.
+func (idx *DirectoryIndex) ConnectBlock(dbTx database.Tx, block *cttutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(direntIndexKey)
+	for _, entry := range directoryEntries(block) {
+		if err := dbPutDirectoryEntry(bucket, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes every directory
+// entry posted in the block.
+//
+// This is part of the Indexer interface.
+func (idx *DirectoryIndex) DisconnectBlock(dbTx database.Tx, block *cttutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(direntIndexKey)
+	for _, entry := range directoryEntries(block) {
+		if err := dbRemoveDirectoryEntry(bucket, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchEntries returns the directory entries referenced by every key which
+// starts with the passed prefix, skipping entries which expire at or before
+// the passed unix time.  At most limit entries are returned unless it is zero.
+// The entries are returned in key order.
+func (idx *DirectoryIndex) fetchEntries(prefix []byte, validAt uint32, limit int) ([]*DirectoryEntry, error) {
+	var entries []*DirectoryEntry
+	err := idx.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(direntIndexKey)
+		cursor := bucket.Cursor()
+		for ok := cursor.Seek(prefix); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, prefix) {
+				break
+			}
+			if len(key) < 1+direntOutPointSize {
+				return errDeserialize("unexpected directory " +
+					"index key size")
+			}
+
+			// The referenced entry is identified by the outpoint at
+			// the end of the key.
+			entryKey := make([]byte, 1+direntOutPointSize)
+			entryKey[0] = direntKeyTypeEntry
+			copy(entryKey[1:], key[len(key)-direntOutPointSize:])
+			entry, err := deserializeDirectoryEntry(entryKey,
+				bucket.Get(entryKey))
+			if err != nil {
+				return err
+			}
+			if entry.Entry.Expire <= validAt {
+				continue
+			}
+
+			entries = append(entries, entry)
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// entriesByHeight implements sort.Interface to allow a slice of directory
+// entries to be sorted by the height of the block which contains them.
+type entriesByHeight []*DirectoryEntry
+
+// Len returns the number of entries in the slice.  It is part of the
+// sort.Interface implementation.
+func (s entriesByHeight) Len() int {
+	return len(s)
+}
+
+// Swap swaps the entries at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s entriesByHeight) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the entry with index i should sort before the entry
+// with index j.  It is part of the sort.Interface implementation.
+func (s entriesByHeight) Less(i, j int) bool {
+	return s[i].BlockHeight < s[j].BlockHeight
+}
+
+// EntriesByOwner returns all directory entries posted by the owner of the
+// passed serialized compressed public key which have not expired at the passed
+// unix time ordered by the height of the block which contains them.  Passing
+// zero for the time includes all entries.
+//
+// This function is safe for concurrent access.
+func (idx *DirectoryIndex) EntriesByOwner(pubKey []byte, validAt uint32) ([]*DirectoryEntry, error) {
+	if len(pubKey) != dirent.PubKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes",
+			dirent.PubKeySize)
+	}
+
+	prefix := make([]byte, 1+dirent.PubKeySize)
+	prefix[0] = direntKeyTypeOwner
+	copy(prefix[1:], pubKey)
+	entries, err := idx.fetchEntries(prefix, validAt, 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(entriesByHeight(entries))
+	return entries, nil
+}
+
+// EntriesByName returns all directory entries posted under exactly the passed
+// name which have not expired at the passed unix time ordered by the height of
+// the block which contains them.  Passing zero for the time includes all
+// entries.
+//
+// This function is safe for concurrent access.
+func (idx *DirectoryIndex) EntriesByName(name string, validAt uint32) ([]*DirectoryEntry, error) {
+	prefix := make([]byte, 1+len(name)+1)
+	prefix[0] = direntKeyTypeName
+	copy(prefix[1:], name)
+	prefix[1+len(name)] = direntNameTerminator
+	entries, err := idx.fetchEntries(prefix, validAt, 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(entriesByHeight(entries))
+	return entries, nil
+}
+
+// SearchEntries returns up to limit directory entries whose name begins with
+// the passed prefix and which have not expired at the passed unix time ordered
+// by name.  Passing zero for the time includes all entries and passing zero
+// for the limit returns all matching entries.
+//
+// This function is safe for concurrent access.
+func (idx *DirectoryIndex) SearchEntries(namePrefix string, validAt uint32, limit int) ([]*DirectoryEntry, error) {
+	prefix := make([]byte, 1+len(namePrefix))
+	prefix[0] = direntKeyTypeName
+	copy(prefix[1:], namePrefix)
+	return idx.fetchEntries(prefix, validAt, limit)
+}
+
+// NewDirectoryIndex returns a new instance of an indexer that is used to
+// create a mapping of all directory entries posted in the blockchain to the
+// outputs which posted them, searchable by owner and by name.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewDirectoryIndex(db database.DB) *DirectoryIndex {
+	return &DirectoryIndex{db: db}
+}
+
+// DropDirectoryIndex drops the directory index from the provided database if
+// it exists.
+func DropDirectoryIndex(db database.DB) error {
+	return dropIndex(db, direntIndexKey, direntIndexName)
+}
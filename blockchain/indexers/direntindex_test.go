@@ -0,0 +1,142 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
+)
+
+// direntIndexBucket provides a mock directory index database bucket by
+// implementing the internalBucket interface.
+type direntIndexBucket struct {
+	entries map[string][]byte
+}
+
+// Get returns the value associated with the key from the mock directory index
+// bucket.
+//
+// This is part of the internalBucket interface.
+func (b *direntIndexBucket) Get(key []byte) []byte {
+	return b.entries[string(key)]
+}
+
+// Put stores the provided key/value pair to the mock directory index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *direntIndexBucket) Put(key []byte, value []byte) error {
+	b.entries[string(key)] = value
+	return nil
+}
+
+// Delete removes the provided key from the mock directory index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *direntIndexBucket) Delete(key []byte) error {
+	delete(b.entries, string(key))
+	return nil
+}
+
+// TestDirectoryIndexEntries ensures directory index entries serialize and
+// deserialize and that adding and removing postings adds and removes exactly
+// the index entries which belong to them.
+func TestDirectoryIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	signed, err := dirent.Sign(1475000000, "alice", []byte{0x01}, privKey)
+	if err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+	first := &DirectoryEntry{
+		Entry:       signed,
+		TxHash:      chainhash.Hash{0x01},
+		OutputIndex: 1,
+		BlockHeight: 100,
+	}
+	repost := *first
+	repost.TxHash = chainhash.Hash{0x02}
+	repost.BlockHeight = 101
+
+	// Ensure the entry round trips.
+	key := direntEntryKey(&first.TxHash, first.OutputIndex)
+	serialized, err := serializeDirectoryEntry(first)
+	if err != nil {
+		t.Fatalf("serializeDirectoryEntry: unexpected error: %v", err)
+	}
+	entry, err := deserializeDirectoryEntry(key, serialized)
+	if err != nil {
+		t.Fatalf("deserializeDirectoryEntry: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(entry, first) {
+		t.Fatalf("deserializeDirectoryEntry: mismatched entry - got "+
+			"%+v, want %+v", entry, first)
+	}
+
+	// Ensure corrupt values are rejected.
+	for _, value := range [][]byte{serialized[:3], serialized[:10]} {
+		_, err := deserializeDirectoryEntry(key, value)
+		if !isDeserializeErr(err) {
+			t.Fatalf("deserializeDirectoryEntry: did not receive "+
+				"expected error - got %v", err)
+		}
+	}
+
+	// Ensure the name keys of a name are not a prefix of the keys of a
+	// longer name which begins with it.
+	longer := *first
+	longer.Entry = &dirent.Entry{Name: "alice2"}
+	if bytes.HasPrefix(direntNameIndexKey(&longer),
+		direntNameIndexKey(first)[:1+len("alice")+1]) {
+
+		t.Fatalf("direntNameIndexKey: key for %q begins with the name "+
+			"prefix for %q", "alice2", "alice")
+	}
+
+	// Ensure adding both postings stores three entries each and removing
+	// one leaves the other intact.
+	bucket := &direntIndexBucket{entries: make(map[string][]byte)}
+	if err := dbPutDirectoryEntry(bucket, first); err != nil {
+		t.Fatalf("dbPutDirectoryEntry: unexpected error: %v", err)
+	}
+	if err := dbPutDirectoryEntry(bucket, &repost); err != nil {
+		t.Fatalf("dbPutDirectoryEntry: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 6 {
+		t.Fatalf("unexpected number of entries - got %d, want 6",
+			len(bucket.entries))
+	}
+	if err := dbRemoveDirectoryEntry(bucket, &repost); err != nil {
+		t.Fatalf("dbRemoveDirectoryEntry: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 3 {
+		t.Fatalf("unexpected number of entries - got %d, want 3",
+			len(bucket.entries))
+	}
+	for _, key := range [][]byte{key, direntOwnerIndexKey(first),
+		direntNameIndexKey(first)} {
+
+		if bucket.Get(key) == nil {
+			t.Fatalf("missing entry for key %x after removing "+
+				"repost", key)
+		}
+	}
+
+	if err := dbRemoveDirectoryEntry(bucket, first); err != nil {
+		t.Fatalf("dbRemoveDirectoryEntry: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 0 {
+		t.Fatalf("unexpected number of entries - got %d, want 0",
+			len(bucket.entries))
+	}
+}
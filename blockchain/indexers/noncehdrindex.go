@@ -0,0 +1,297 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/database"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+const (
+	// nonceHdrIndexName is the human-readable name for the index.
+	nonceHdrIndexName = "nonce header index"
+
+	// nonceHdrIndexValueSize is the number of bytes a nonce header index
+	// value consumes.  It consists of 32 bytes block hash + 1 byte usage
+	// flags.
+	nonceHdrIndexValueSize = chainhash.HashSize + 1
+
+	// nonceHdrUsedAsA and nonceHdrUsedAsB are the usage flags which
+	// indicate the message header is used as the NonceHeaderA and the
+	// NonceHeaderB of the block respectively.
+	nonceHdrUsedAsA = 1 << 0
+	nonceHdrUsedAsB = 1 << 1
+)
+
+var (
+	// nonceHdrIndexKey is the key of the nonce header index and the db
+	// bucket used to house it.
+	nonceHdrIndexKey = []byte("noncehdridx")
+
+	// nonceHdrByteOrder is the byte order used for the block heights in
+	// the index keys.  Big endian is used so the keys sort by height.
+	nonceHdrByteOrder = binary.BigEndian
+)
+
+// -----------------------------------------------------------------------------
+// The nonce header index maps the I key of every ciphrtxt message header which
+// is used as a nonce of a block in the main chain to the blocks which use it.
+//
+// An entry is stored for every distinct message header of every block.  It is
+// keyed by the I key of the message header followed by the height of the
+// block, so the blocks which use a given header are ordered by height.  Since
+// there is only one main chain block at a given height, disconnecting a block
+// removes exactly the entries it added.
+//
+// The serialized key format is:
+//
+//   <I key><block height>
+//
+//   Field           Type              Size
+//   I key           []byte            variable
+//   block height    uint32 (BE)       4 bytes
+//
+// The serialized value format is:
+//
+//   <block hash><usage flags>
+//
+//   Field           Type              Size
+//   block hash      chainhash.Hash    32 bytes
+//   usage flags     uint8             1 byte
+//   -----
+//   Total: 33 bytes
+//
+// The usage flags indicate whether the message header is used as the
+// NonceHeaderA (bit 0) and/or the NonceHeaderB (bit 1) of the block.
+// -----------------------------------------------------------------------------
+
+// NonceHeaderUse houses the details of a block which uses a message header as
+// one of its nonces.
+type NonceHeaderUse struct {
+	// BlockHash and BlockHeight identify the block.
+	BlockHash   chainhash.Hash
+	BlockHeight int32
+
+	// NonceA and NonceB indicate whether the message header is used as
+	// the NonceHeaderA and NonceHeaderB of the block respectively.
+	NonceA bool
+	NonceB bool
+}
+
+// nonceHdrIndexEntryKey returns the index key for the passed I key and block
+// height.
+func nonceHdrIndexEntryKey(ikey []byte, height int32) []byte {
+	key := make([]byte, len(ikey)+4)
+	copy(key, ikey)
+	nonceHdrByteOrder.PutUint32(key[len(ikey):], uint32(height))
+	return key
+}
+
+// serializeNonceHeaderUse returns the serialized index value for the passed
+// use according to the format described above.
+func serializeNonceHeaderUse(use *NonceHeaderUse) []byte {
+	serialized := make([]byte, nonceHdrIndexValueSize)
+	copy(serialized, use.BlockHash[:])
+	var flags byte
+	if use.NonceA {
+		flags |= nonceHdrUsedAsA
+	}
+	if use.NonceB {
+		flags |= nonceHdrUsedAsB
+	}
+	serialized[chainhash.HashSize] = flags
+	return serialized
+}
+
+// deserializeNonceHeaderUse decodes the passed index key and value into a
+// nonce header use.
+func deserializeNonceHeaderUse(key, serialized []byte) (*NonceHeaderUse, error) {
+	if len(key) < 4 || len(serialized) != nonceHdrIndexValueSize {
+		return nil, errDeserialize("unexpected nonce header index " +
+			"entry size")
+	}
+
+	var use NonceHeaderUse
+	use.BlockHeight = int32(nonceHdrByteOrder.Uint32(key[len(key)-4:]))
+	copy(use.BlockHash[:], serialized)
+	flags := serialized[chainhash.HashSize]
+	use.NonceA = flags&nonceHdrUsedAsA != 0
+	use.NonceB = flags&nonceHdrUsedAsB != 0
+	return &use, nil
+}
+
+// nonceHeaderUses returns the uses of the nonce headers of the passed block
+// keyed by I key.  A message header which is used as both nonces of the block
+// results in a single use.  Nonce headers which do not parse are ignored.
+func nonceHeaderUses(block *cttutil.Block) map[string]*NonceHeaderUse {
+	uses := make(map[string]*NonceHeaderUse, 2)
+	ikeyA, ikeyB, err := blockchain.NonceHeaderIKeys(&block.MsgBlock().Header)
+	if err != nil {
+		return uses
+	}
+
+	useFor := func(ikey []byte) *NonceHeaderUse {
+		use, ok := uses[string(ikey)]
+		if !ok {
+			use = &NonceHeaderUse{
+				BlockHash:   *block.Hash(),
+				BlockHeight: block.Height(),
+			}
+			uses[string(ikey)] = use
+		}
+		return use
+	}
+	useFor(ikeyA).NonceA = true
+	useFor(ikeyB).NonceB = true
+	return uses
+}
+
+// dbPutNonceHeaderUses uses an existing database transaction to add the index
+// entries for the nonce headers of the passed block.
+func dbPutNonceHeaderUses(bucket internalBucket, block *cttutil.Block) error {
+	for ikey, use := range nonceHeaderUses(block) {
+		key := nonceHdrIndexEntryKey([]byte(ikey), use.BlockHeight)
+		err := bucket.Put(key, serializeNonceHeaderUse(use))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dbRemoveNonceHeaderUses uses an existing database transaction to remove the
+// index entries for the nonce headers of the passed block.  Entries which
+// belong to a different block at the same height are left intact.
+func dbRemoveNonceHeaderUses(bucket internalBucket, block *cttutil.Block) error {
+	for ikey, use := range nonceHeaderUses(block) {
+		key := nonceHdrIndexEntryKey([]byte(ikey), use.BlockHeight)
+		existing := bucket.Get(key)
+		if len(existing) < chainhash.HashSize ||
+			!bytes.Equal(existing[:chainhash.HashSize], use.BlockHash[:]) {
+
+			continue
+		}
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NonceHeaderIndex implements an index of the ciphrtxt message headers used as
+// the nonces of the blocks in the main chain.  That is to say, it supports
+// querying all blocks which use a given message header.
+type NonceHeaderIndex struct {
+	db database.DB
+}
+
+// Ensure the NonceHeaderIndex type implements the Indexer interface.
+var _ Indexer = (*NonceHeaderIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *NonceHeaderIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *NonceHeaderIndex) Key() []byte {
+	return nonceHdrIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *NonceHeaderIndex) Name() string {
+	return nonceHdrIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the nonce
+// header index.
+//
+// This is part of the Indexer interface.
+func (idx *NonceHeaderIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(nonceHdrIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the entries for the nonce
+// headers of the block.
+//
+// This is part of the Indexer interface.
+func (idx *NonceHeaderIndex) ConnectBlock(dbTx database.Tx, block *cttutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(nonceHdrIndexKey)
+	return dbPutNonceHeaderUses(bucket, block)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for the
+// nonce headers of the block.
+//
+// This is part of the Indexer interface.
+func (idx *NonceHeaderIndex) DisconnectBlock(dbTx database.Tx, block *cttutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(nonceHdrIndexKey)
+	return dbRemoveNonceHeaderUses(bucket, block)
+}
+
+// BlocksByNonceHeader returns the main chain blocks which use the message
+// header with the passed I key as a nonce ordered by height.
+//
+// This function is safe for concurrent access.
+func (idx *NonceHeaderIndex) BlocksByNonceHeader(ikey []byte) ([]*NonceHeaderUse, error) {
+	var uses []*NonceHeaderUse
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(nonceHdrIndexKey).Cursor()
+		for ok := cursor.Seek(ikey); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, ikey) {
+				break
+			}
+
+			// Skip the entries of longer keys which begin with
+			// the requested one.
+			if len(key) != len(ikey)+4 {
+				continue
+			}
+
+			use, err := deserializeNonceHeaderUse(key, cursor.Value())
+			if err != nil {
+				return err
+			}
+			uses = append(uses, use)
+		}
+		return nil
+	})
+	return uses, err
+}
+
+// NewNonceHeaderIndex returns a new instance of an indexer that is used to
+// create a mapping of the ciphrtxt message headers used as block nonces to the
+// blocks which use them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewNonceHeaderIndex(db database.DB) *NonceHeaderIndex {
+	return &NonceHeaderIndex{db: db}
+}
+
+// DropNonceHeaderIndex drops the nonce header index from the provided database
+// if it exists.
+func DropNonceHeaderIndex(db database.DB) error {
+	return dropIndex(db, nonceHdrIndexKey, nonceHdrIndexName)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+// TestNonceHeaderIndexEntries ensures nonce header index entries serialize and
+// deserialize and that disconnecting a block only removes the entries which
+// belong to it.
+func TestNonceHeaderIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	msgBlock := *chaincfg.CTIndigoNetParams.GenesisBlock
+	block := cttutil.NewBlock(&msgBlock)
+	block.SetHeight(5)
+	ikeyA, ikeyB, err := blockchain.NonceHeaderIKeys(&msgBlock.Header)
+	if err != nil {
+		t.Fatalf("NonceHeaderIKeys: unexpected error: %v", err)
+	}
+
+	// Ensure an entry round trips through its serialized form.
+	want := &NonceHeaderUse{
+		BlockHash:   *block.Hash(),
+		BlockHeight: 5,
+		NonceA:      true,
+	}
+	key := nonceHdrIndexEntryKey(ikeyA, 5)
+	use, err := deserializeNonceHeaderUse(key, serializeNonceHeaderUse(want))
+	if err != nil {
+		t.Fatalf("deserializeNonceHeaderUse: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(use, want) {
+		t.Fatalf("deserializeNonceHeaderUse: mismatched use - got %+v, "+
+			"want %+v", use, want)
+	}
+
+	// Ensure a short value is rejected.
+	_, err = deserializeNonceHeaderUse(key, serializeNonceHeaderUse(want)[1:])
+	if !isDeserializeErr(err) {
+		t.Fatalf("deserializeNonceHeaderUse: did not receive expected "+
+			"error - got %v", err)
+	}
+
+	// Ensure connecting the block adds an entry for each nonce header.
+	bucket := &direntIndexBucket{entries: make(map[string][]byte)}
+	if err := dbPutNonceHeaderUses(bucket, block); err != nil {
+		t.Fatalf("dbPutNonceHeaderUses: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 2 {
+		t.Fatalf("unexpected number of entries - got %d, want 2",
+			len(bucket.entries))
+	}
+	wantB := *want
+	wantB.NonceA, wantB.NonceB = false, true
+	use, err = deserializeNonceHeaderUse(nonceHdrIndexEntryKey(ikeyB, 5),
+		bucket.Get(nonceHdrIndexEntryKey(ikeyB, 5)))
+	if err != nil || !reflect.DeepEqual(use, &wantB) {
+		t.Fatalf("unexpected entry for nonce header B - got %+v, want "+
+			"%+v (err %v)", use, &wantB, err)
+	}
+
+	// Ensure disconnecting a different block at the same height which uses
+	// the same nonce headers leaves the entries intact.
+	otherMsgBlock := msgBlock
+	otherMsgBlock.Header.Timestamp = msgBlock.Header.Timestamp.Add(time.Second)
+	other := cttutil.NewBlock(&otherMsgBlock)
+	other.SetHeight(5)
+	if err := dbRemoveNonceHeaderUses(bucket, other); err != nil {
+		t.Fatalf("dbRemoveNonceHeaderUses: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 2 {
+		t.Fatalf("unexpected number of entries - got %d, want 2",
+			len(bucket.entries))
+	}
+
+	// Ensure disconnecting the block removes its entries.
+	if err := dbRemoveNonceHeaderUses(bucket, block); err != nil {
+		t.Fatalf("dbRemoveNonceHeaderUses: unexpected error: %v", err)
+	}
+	if len(bucket.entries) != 0 {
+		t.Fatalf("unexpected number of entries - got %d, want 0",
+			len(bucket.entries))
+	}
+
+	// Ensure a message header used as both nonces results in a single
+	// entry flagged with both uses.
+	sameMsgBlock := msgBlock
+	sameMsgBlock.Header.NonceHeaderB = msgBlock.Header.NonceHeaderA
+	same := cttutil.NewBlock(&sameMsgBlock)
+	same.SetHeight(6)
+	uses := nonceHeaderUses(same)
+	if len(uses) != 1 {
+		t.Fatalf("nonceHeaderUses: got %d uses, want 1", len(uses))
+	}
+	if use := uses[string(ikeyA)]; use == nil || !use.NonceA || !use.NonceB {
+		t.Fatalf("nonceHeaderUses: unexpected use %+v", use)
+	}
+}
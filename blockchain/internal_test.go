@@ -15,6 +15,8 @@ package blockchain
 import (
 	"sort"
 	"time"
+
+	"github.com/jadeblaquiere/cttd/chaincfg"
 )
 
 // TstSetCoinbaseMaturity makes the ability to set the coinbase maturity
@@ -46,3 +48,19 @@ var TstCheckBlockScripts = checkBlockScripts
 // TstDeserializeUtxoEntry makes the internal deserializeUtxoEntry function
 // available to the test package.
 var TstDeserializeUtxoEntry = deserializeUtxoEntry
+
+// TstCalcLWMARequiredDifficulty makes the internal calcLWMARequiredDifficulty
+// function available to the test package.
+var TstCalcLWMARequiredDifficulty = calcLWMARequiredDifficulty
+
+// TstCalcEasiestDifficulty makes the internal calcEasiestDifficulty function
+// available to the test package.  It is called on a chain with the passed chain
+// parameters.
+func TstCalcEasiestDifficulty(params *chaincfg.Params, bits uint32, duration time.Duration, height int32) uint32 {
+	targetTimespan := int64(params.TargetTimespan)
+	b := BlockChain{
+		chainParams:         params,
+		maxRetargetTimespan: targetTimespan * params.RetargetAdjustmentFactor,
+	}
+	return b.calcEasiestDifficulty(bits, duration, height)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/wire"
+)
+
+const (
+	// nonceHeaderTimeOffset is the offset of the big endian unix time the
+	// message was stored at within a V2 binary message header.
+	nonceHeaderTimeOffset = 4
+
+	// nonceHeaderExpireOffset is the offset of the big endian unix time the
+	// message expires at within a V2 binary message header.
+	nonceHeaderExpireOffset = 8
+)
+
+// nonceHeaderTimes returns the time the message described by the passed V2
+// binary message header was stored at along with the time it expires at.
+func nonceHeaderTimes(bh *ciphrtxt.BinaryMessageHeaderV2) (time.Time, time.Time) {
+	created := binary.BigEndian.Uint32(bh[nonceHeaderTimeOffset:])
+	expire := binary.BigEndian.Uint32(bh[nonceHeaderExpireOffset:])
+	return time.Unix(int64(created), 0), time.Unix(int64(expire), 0)
+}
+
+// CheckNonceHeaderLifetime ensures the passed message header may be used as a
+// nonce header of a block with the passed timestamp.  The message must not
+// have been stored more than the nonce header max age of the chain parameters
+// before the block timestamp and must not expire less than the nonce header
+// min lifetime of the chain parameters after it.  Either limit is not checked
+// when it is zero.
+func CheckNonceHeaderLifetime(bh *ciphrtxt.BinaryMessageHeaderV2, blockTime time.Time, chainParams *chaincfg.Params) error {
+	created, expire := nonceHeaderTimes(bh)
+
+	maxAge := chainParams.NonceHeaderMaxAge
+	if maxAge != 0 && blockTime.Sub(created) > maxAge {
+		str := fmt.Sprintf("nonce header %s was stored at %v which is "+
+			"more than %v before the block timestamp of %v",
+			hex.EncodeToString(bh[:]), created, maxAge, blockTime)
+		return ruleError(ErrNonceHeaderTooOld, str)
+	}
+
+	minLifetime := chainParams.NonceHeaderMinLifetime
+	if minLifetime != 0 && expire.Sub(blockTime) < minLifetime {
+		str := fmt.Sprintf("nonce header %s expires at %v which is "+
+			"less than %v after the block timestamp of %v",
+			hex.EncodeToString(bh[:]), expire, minLifetime, blockTime)
+		return ruleError(ErrNonceHeaderExpiring, str)
+	}
+
+	return nil
+}
+
+// NonceHeaderLastBlockTime returns the latest block timestamp for which the
+// passed message header satisfies the nonce header lifetime limits of the
+// chain parameters.  False is returned when neither limit is set, since the
+// header may then be used by blocks with any timestamp.  It allows miners to
+// know how long a list of nonce header candidates remains valid.
+func NonceHeaderLastBlockTime(bh *ciphrtxt.BinaryMessageHeaderV2, chainParams *chaincfg.Params) (time.Time, bool) {
+	created, expire := nonceHeaderTimes(bh)
+
+	var last time.Time
+	limited := false
+	if maxAge := chainParams.NonceHeaderMaxAge; maxAge != 0 {
+		last = created.Add(maxAge)
+		limited = true
+	}
+	if minLifetime := chainParams.NonceHeaderMinLifetime; minLifetime != 0 {
+		lastUnexpired := expire.Add(-minLifetime)
+		if !limited || lastUnexpired.Before(last) {
+			last = lastUnexpired
+		}
+		limited = true
+	}
+	return last, limited
+}
+
+// checkNonceHeaderLifetimes ensures both nonce headers of the passed block
+// header may be used as nonce headers of a block with its timestamp according
+// to the nonce header max age and min lifetime of the chain parameters.
+func checkNonceHeaderLifetimes(header *wire.BlockHeader, chainParams *chaincfg.Params) error {
+	err := CheckNonceHeaderLifetime(&header.NonceHeaderA, header.Timestamp,
+		chainParams)
+	if err != nil {
+		return err
+	}
+	return CheckNonceHeaderLifetime(&header.NonceHeaderB, header.Timestamp,
+		chainParams)
+}
+
+// checkActiveNonceHeaderLifetimes ensures the nonce headers of the passed block
+// header satisfy the nonce header lifetime limits of the chain parameters when
+// its version is nonceHeaderLifetimeVersion or newer and a majority of the
+// blocks before it have upgraded to that version.  Blocks are not held to the
+// limits before then, so nodes which do not enforce them yet agree with the
+// upgraded nodes on the validity of every block until the rule activates.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) checkActiveNonceHeaderLifetimes(header *wire.BlockHeader, prevNode *blockNode) error {
+	if header.Version < nonceHeaderLifetimeVersion ||
+		!b.isMajorityVersion(nonceHeaderLifetimeVersion, prevNode,
+			b.chainParams.BlockEnforceNumRequired) {
+
+		return nil
+	}
+	return checkNonceHeaderLifetimes(header, b.chainParams)
+}
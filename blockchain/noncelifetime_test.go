@@ -0,0 +1,259 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+)
+
+// TestCheckNonceHeaderLifetime ensures message headers which were stored too
+// long before or expire too soon after the block timestamp are rejected as
+// nonce headers and that a zero limit is not enforced.
+func TestCheckNonceHeaderLifetime(t *testing.T) {
+	t.Parallel()
+
+	blockTime := time.Unix(1480000000, 0)
+	header := func(created, expire time.Time) *ciphrtxt.BinaryMessageHeaderV2 {
+		var bh ciphrtxt.BinaryMessageHeaderV2
+		binary.BigEndian.PutUint32(bh[nonceHeaderTimeOffset:],
+			uint32(created.Unix()))
+		binary.BigEndian.PutUint32(bh[nonceHeaderExpireOffset:],
+			uint32(expire.Unix()))
+		return &bh
+	}
+
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		minLifetime time.Duration
+		created     time.Time
+		expire      time.Time
+		want        ErrorCode
+		valid       bool
+	}{
+		{
+			name:        "within limits",
+			maxAge:      time.Hour * 24,
+			minLifetime: time.Hour,
+			created:     blockTime.Add(-time.Hour),
+			expire:      blockTime.Add(time.Hour * 2),
+			valid:       true,
+		},
+		{
+			name:        "exactly at limits",
+			maxAge:      time.Hour * 24,
+			minLifetime: time.Hour,
+			created:     blockTime.Add(-time.Hour * 24),
+			expire:      blockTime.Add(time.Hour),
+			valid:       true,
+		},
+		{
+			name:        "stored after block timestamp",
+			maxAge:      time.Hour * 24,
+			minLifetime: time.Hour,
+			created:     blockTime.Add(time.Minute),
+			expire:      blockTime.Add(time.Hour * 2),
+			valid:       true,
+		},
+		{
+			name:        "too old",
+			maxAge:      time.Hour * 24,
+			minLifetime: time.Hour,
+			created:     blockTime.Add(-time.Hour*24 - time.Second),
+			expire:      blockTime.Add(time.Hour * 2),
+			want:        ErrNonceHeaderTooOld,
+		},
+		{
+			name:        "expiring",
+			maxAge:      time.Hour * 24,
+			minLifetime: time.Hour,
+			created:     blockTime.Add(-time.Hour),
+			expire:      blockTime.Add(time.Hour - time.Second),
+			want:        ErrNonceHeaderExpiring,
+		},
+		{
+			name:        "already expired",
+			maxAge:      time.Hour * 24,
+			minLifetime: time.Hour,
+			created:     blockTime.Add(-time.Hour * 2),
+			expire:      blockTime.Add(-time.Hour),
+			want:        ErrNonceHeaderExpiring,
+		},
+		{
+			name:    "no limits",
+			created: blockTime.Add(-time.Hour * 24 * 365),
+			expire:  blockTime.Add(-time.Hour * 24 * 300),
+			valid:   true,
+		},
+	}
+
+	for _, test := range tests {
+		params := chaincfg.CTIndigoNetParams
+		params.NonceHeaderMaxAge = test.maxAge
+		params.NonceHeaderMinLifetime = test.minLifetime
+
+		err := CheckNonceHeaderLifetime(header(test.created, test.expire),
+			blockTime, &params)
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != test.want {
+			t.Errorf("%s: did not receive expected error - got %v, "+
+				"want %v", test.name, err, test.want)
+		}
+	}
+}
+
+// TestNonceHeaderLastBlockTime ensures the last block timestamp a message
+// header may be used as a nonce header for is the earlier of the limits and
+// that the header is rejected by blocks after it.
+func TestNonceHeaderLastBlockTime(t *testing.T) {
+	t.Parallel()
+
+	created := time.Unix(1480000000, 0)
+	var bh ciphrtxt.BinaryMessageHeaderV2
+	binary.BigEndian.PutUint32(bh[nonceHeaderTimeOffset:],
+		uint32(created.Unix()))
+	binary.BigEndian.PutUint32(bh[nonceHeaderExpireOffset:],
+		uint32(created.Add(time.Hour*48).Unix()))
+
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		minLifetime time.Duration
+		want        time.Time
+		limited     bool
+	}{
+		{
+			name:        "max age first",
+			maxAge:      time.Hour * 24,
+			minLifetime: time.Hour,
+			want:        created.Add(time.Hour * 24),
+			limited:     true,
+		},
+		{
+			name:        "min lifetime first",
+			maxAge:      time.Hour * 72,
+			minLifetime: time.Hour,
+			want:        created.Add(time.Hour * 47),
+			limited:     true,
+		},
+		{
+			name:    "only max age",
+			maxAge:  time.Hour * 72,
+			want:    created.Add(time.Hour * 72),
+			limited: true,
+		},
+		{
+			name:        "only min lifetime",
+			minLifetime: time.Hour * 2,
+			want:        created.Add(time.Hour * 46),
+			limited:     true,
+		},
+		{
+			name: "no limits",
+		},
+	}
+
+	for _, test := range tests {
+		params := chaincfg.CTIndigoNetParams
+		params.NonceHeaderMaxAge = test.maxAge
+		params.NonceHeaderMinLifetime = test.minLifetime
+
+		last, limited := NonceHeaderLastBlockTime(&bh, &params)
+		if limited != test.limited || !last.Equal(test.want) {
+			t.Errorf("%s: got %v (limited %v), want %v (limited %v)",
+				test.name, last, limited, test.want, test.limited)
+			continue
+		}
+		if !limited {
+			continue
+		}
+		if err := CheckNonceHeaderLifetime(&bh, last, &params); err != nil {
+			t.Errorf("%s: unexpected error at last block time: %v",
+				test.name, err)
+		}
+		after := last.Add(time.Second)
+		if err := CheckNonceHeaderLifetime(&bh, after, &params); err == nil {
+			t.Errorf("%s: header accepted after last block time",
+				test.name)
+		}
+	}
+}
+
+// TestCheckActiveNonceHeaderLifetimes ensures the nonce header lifetimes are
+// not part of the context free nonce header checks and are only enforced for
+// blocks with the nonce header lifetime version or newer once a majority of the
+// blocks before them have upgraded.
+func TestCheckActiveNonceHeaderLifetimes(t *testing.T) {
+	t.Parallel()
+
+	// The nonce headers of the genesis block have long expired as of a
+	// block timestamp a year later.
+	params := chaincfg.CTSimNetParams
+	header := params.GenesisBlock.Header
+	header.Timestamp = header.Timestamp.Add(time.Hour * 24 * 365)
+	header.Version = nonceHeaderLifetimeVersion
+	if err := checkBlockHeaderNonces(&header, nil, nil); err != nil {
+		t.Fatalf("checkBlockHeaderNonces: unexpected error: %v", err)
+	}
+
+	numBlocks := int32(params.BlockUpgradeNumToCheck)
+	tests := []struct {
+		name         string
+		version      int32
+		numUpgraded  int32
+		wantEnforced bool
+	}{
+		{
+			name:         "majority upgraded",
+			version:      nonceHeaderLifetimeVersion,
+			numUpgraded:  int32(params.BlockEnforceNumRequired),
+			wantEnforced: true,
+		},
+		{
+			name:        "majority not upgraded",
+			version:     nonceHeaderLifetimeVersion,
+			numUpgraded: int32(params.BlockEnforceNumRequired) - 1,
+		},
+		{
+			name:        "old version after majority upgraded",
+			version:     nonceHeaderLifetimeVersion - 1,
+			numUpgraded: numBlocks,
+		},
+	}
+
+	for _, test := range tests {
+		chain := &BlockChain{chainParams: &params}
+		tip := thresholdTestChain(&params, numBlocks,
+			func(height int32) int32 {
+				if height > numBlocks-test.numUpgraded {
+					return nonceHeaderLifetimeVersion
+				}
+				return nonceHeaderLifetimeVersion - 1
+			})
+
+		header.Version = test.version
+		err := chain.checkActiveNonceHeaderLifetimes(&header, tip)
+		if !test.wantEnforced {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if _, ok := err.(RuleError); !ok {
+			t.Errorf("%s: did not receive expected rule error - "+
+				"got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/wire"
+)
+
+// nonceHeaderIKeys returns the I keys of the message headers used as the
+// nonces of the passed block header.  The key of a nonce header which does not
+// parse is nil.
+func nonceHeaderIKeys(header *wire.BlockHeader) ([]byte, []byte) {
+	var ikeyA, ikeyB []byte
+	if h := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderA[:]); h != nil {
+		ikeyA = h.IKey()
+	}
+	if h := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderB[:]); h != nil {
+		ikeyB = h.IKey()
+	}
+	return ikeyA, ikeyB
+}
+
+// NonceHeaderIKeys returns the I keys of the message headers used as the
+// NonceHeaderA and NonceHeaderB nonces of the passed block header.  An error
+// is returned when either nonce header does not parse.
+func NonceHeaderIKeys(header *wire.BlockHeader) ([]byte, []byte, error) {
+	ikeyA, ikeyB := nonceHeaderIKeys(header)
+	if ikeyA == nil || ikeyB == nil {
+		return nil, nil, fmt.Errorf("block %v has a nonce header which "+
+			"does not parse", header.BlockHash())
+	}
+	return ikeyA, ikeyB, nil
+}
+
+// checkNonceHeaderReuse ensures the block with the passed header does not use
+// the same message header as both of its nonces and that neither of its nonce
+// headers is already used as a nonce by the blocks within the nonce header
+// reuse window of the chain parameters which end with the passed previous
+// node.  No ancestors are checked when the window is zero.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) checkNonceHeaderReuse(header *wire.BlockHeader, prevNode *blockNode) error {
+	ikeyA, ikeyB := nonceHeaderIKeys(header)
+	if ikeyA == nil || ikeyB == nil {
+		str := fmt.Sprintf("block %v has a nonce header which does "+
+			"not parse", header.BlockHash())
+		return ruleError(ErrNonceValidation, str)
+	}
+	if string(ikeyA) == string(ikeyB) {
+		str := fmt.Sprintf("block %v uses message header %s as both "+
+			"of its nonces", header.BlockHash(),
+			hex.EncodeToString(ikeyA))
+		return ruleError(ErrNonceHeaderReuse, str)
+	}
+
+	iterNode := prevNode
+	for i := int32(0); i < b.chainParams.NonceHeaderReuseWindow &&
+		iterNode != nil; i++ {
+
+		for _, used := range iterNode.nonceIKeys {
+			if used == "" {
+				continue
+			}
+			if used == string(ikeyA) || used == string(ikeyB) {
+				str := fmt.Sprintf("block %v reuses message "+
+					"header %s which is a nonce of block "+
+					"%v at height %d", header.BlockHash(),
+					hex.EncodeToString([]byte(used)),
+					iterNode.hash, iterNode.height)
+				return ruleError(ErrNonceHeaderReuse, str)
+			}
+		}
+
+		// Get the previous block node.  This function is used over
+		// simply accessing iterNode.parent directly as it will
+		// dynamically create previous block nodes as needed.
+		var err error
+		iterNode, err = b.getPrevNodeFromNode(iterNode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecentNonceHeaderIKeys returns the I keys of the message headers used as
+// nonces by the blocks within the nonce header reuse window of the chain
+// parameters which end with the current best chain tip.  A block which extends
+// the main chain must not use any of them as a nonce.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) RecentNonceHeaderIKeys() (map[string]struct{}, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	used := make(map[string]struct{})
+	iterNode := b.bestNode
+	for i := int32(0); i < b.chainParams.NonceHeaderReuseWindow &&
+		iterNode != nil; i++ {
+
+		for _, ikey := range iterNode.nonceIKeys {
+			if ikey != "" {
+				used[ikey] = struct{}{}
+			}
+		}
+
+		var err error
+		iterNode, err = b.getPrevNodeFromNode(iterNode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return used, nil
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+)
+
+// TestCheckNonceHeaderReuse ensures blocks which reuse a message header as a
+// nonce within the nonce header reuse window are rejected.
+func TestCheckNonceHeaderReuse(t *testing.T) {
+	t.Parallel()
+
+	// Build a two block chain which uses the nonce headers of the red
+	// network genesis block on top of the indigo network genesis block.
+	params := chaincfg.CTIndigoNetParams
+	genesis := params.GenesisBlock.Header
+	genesisHash := genesis.BlockHash()
+	genesisNode := newBlockNode(&genesis, &genesisHash, 0)
+
+	red := chaincfg.CTRedNetParams.GenesisBlock.Header
+	header1 := genesis
+	header1.PrevBlock = genesisHash
+	header1.NonceHeaderA = red.NonceHeaderA
+	header1.NonceHeaderB = red.NonceHeaderB
+	hash1 := header1.BlockHash()
+	node1 := newBlockNode(&header1, &hash1, 1)
+	node1.parent = genesisNode
+
+	indigoA, indigoB := genesis.NonceHeaderA, genesis.NonceHeaderB
+	tests := []struct {
+		name    string
+		window  int32
+		nonceA  ciphrtxt.BinaryMessageHeaderV2
+		nonceB  ciphrtxt.BinaryMessageHeaderV2
+		errCode ErrorCode
+		wantErr bool
+	}{
+		{
+			name:    "same header as both nonces",
+			window:  0,
+			nonceA:  indigoA,
+			nonceB:  indigoA,
+			errCode: ErrNonceHeaderReuse,
+			wantErr: true,
+		},
+		{
+			name:    "reused from parent",
+			window:  1,
+			nonceA:  red.NonceHeaderA,
+			nonceB:  indigoB,
+			errCode: ErrNonceHeaderReuse,
+			wantErr: true,
+		},
+		{
+			name:   "reused from grandparent outside window",
+			window: 1,
+			nonceA: indigoA,
+			nonceB: indigoB,
+		},
+		{
+			name:    "reused from grandparent inside window",
+			window:  2,
+			nonceA:  indigoA,
+			nonceB:  indigoB,
+			errCode: ErrNonceHeaderReuse,
+			wantErr: true,
+		},
+		{
+			name:    "unparsable nonce header",
+			window:  2,
+			nonceB:  indigoB,
+			errCode: ErrNonceValidation,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		params.NonceHeaderReuseWindow = test.window
+		chain := &BlockChain{chainParams: &params}
+
+		header := header1
+		header.PrevBlock = hash1
+		header.NonceHeaderA = test.nonceA
+		header.NonceHeaderB = test.nonceB
+		err := chain.checkNonceHeaderReuse(&header, node1)
+		if !test.wantErr {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		rerr, ok := err.(RuleError)
+		if !ok || rerr.ErrorCode != test.errCode {
+			t.Errorf("%s: did not receive expected error code %v - "+
+				"got %v", test.name, test.errCode, err)
+		}
+	}
+}
+
+// TestRecentNonceHeaderIKeys ensures the I keys of the nonce headers used by
+// the blocks within the nonce header reuse window are reported.
+func TestRecentNonceHeaderIKeys(t *testing.T) {
+	t.Parallel()
+
+	params := chaincfg.CTIndigoNetParams
+	genesis := params.GenesisBlock.Header
+	genesisHash := genesis.BlockHash()
+	genesisNode := newBlockNode(&genesis, &genesisHash, 0)
+
+	red := chaincfg.CTRedNetParams.GenesisBlock.Header
+	header1 := genesis
+	header1.PrevBlock = genesisHash
+	header1.NonceHeaderA = red.NonceHeaderA
+	header1.NonceHeaderB = red.NonceHeaderB
+	hash1 := header1.BlockHash()
+	node1 := newBlockNode(&header1, &hash1, 1)
+	node1.parent = genesisNode
+
+	tests := []struct {
+		window int32
+		want   int
+	}{
+		{window: 0, want: 0},
+		{window: 1, want: 2},
+		{window: 2, want: 4},
+		{window: 3, want: 4},
+	}
+
+	for _, test := range tests {
+		params.NonceHeaderReuseWindow = test.window
+		chain := &BlockChain{chainParams: &params, bestNode: node1}
+		used, err := chain.RecentNonceHeaderIKeys()
+		if err != nil {
+			t.Errorf("window %d: unexpected error: %v", test.window,
+				err)
+			continue
+		}
+		if len(used) != test.want {
+			t.Errorf("window %d: got %d I keys, want %d",
+				test.window, len(used), test.want)
+		}
+	}
+}
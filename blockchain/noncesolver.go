@@ -0,0 +1,126 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/wire"
+)
+
+// nonceHashBatchSize is the number of nonce header pairs SolveNonceHeaders
+// hashes at once.  Hashing them in batches shares the field inversion of the
+// point multiplications between the headers.
+const nonceHashBatchSize = 64
+
+// NonceHeaderPair returns the indices of the candidates used as NonceHeaderA
+// and NonceHeaderB by the pair with the passed index.  The pairs are numbered
+// from 0 to numCandidates*(numCandidates-1)-1 and every ordered pair of two
+// distinct candidates has exactly one index, so the same candidate is never
+// used as both nonces since such blocks are rejected by the consensus rules.
+func NonceHeaderPair(pair, numCandidates uint64) (uint64, uint64) {
+	a := pair / (numCandidates - 1)
+	b := pair % (numCandidates - 1)
+	if b >= a {
+		b++
+	}
+	return a, b
+}
+
+// NonceHeaderCandidates returns the message headers known to the passed message
+// header source which may be used as the nonce headers of a block with the
+// passed timestamp along with the set of their I keys.  Headers which do not
+// satisfy the nonce header lifetime limits of the passed chain parameters or
+// whose I keys are in the passed set of keys already used by the recent blocks
+// of the chain are excluded.  The candidates are in the order the source
+// returns them in.
+func NonceHeaderCandidates(hs MessageHeaderSource, used map[string]struct{}, blockTime time.Time, chainParams *chaincfg.Params) ([]ciphrtxt.BinaryMessageHeaderV2, map[string]struct{}, error) {
+	minExpireTime := blockTime.Add(chainParams.NonceHeaderMinLifetime)
+	rhdrs, err := hs.FindExpiringAfter(uint32(minExpireTime.Unix()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	candidates := make([]ciphrtxt.BinaryMessageHeaderV2, 0, len(rhdrs))
+	ikeys := make(map[string]struct{}, len(rhdrs))
+	for i := range rhdrs {
+		ikey := string(rhdrs[i].IKey())
+		if _, exists := used[ikey]; exists {
+			continue
+		}
+		bh := rhdrs[i].ExportBinaryHeaderV2()
+		if bh == nil {
+			continue
+		}
+		if CheckNonceHeaderLifetime(bh, blockTime, chainParams) != nil {
+			continue
+		}
+		candidates = append(candidates, *bh)
+		ikeys[ikey] = struct{}{}
+	}
+
+	return candidates, ikeys, nil
+}
+
+// SolveNonceHeaders searches the nonce header pairs with the indices first,
+// first+stride, first+2*stride and so on which are less than end for one which
+// makes the hash of the passed block header no more than the passed target
+// difficulty.  The pairs are hashed in batches.  When a solution is found, the
+// nonce headers of the passed block header are set to it and true is returned.
+// The number of hashes computed is returned in either case so miners can report
+// their hash rate.
+func SolveNonceHeaders(header *wire.BlockHeader, candidates []ciphrtxt.BinaryMessageHeaderV2, first, end, stride uint64, targetDifficulty *big.Int) (bool, uint64) {
+	numCandidates := uint64(len(candidates))
+	if numCandidates < 2 || stride == 0 {
+		return false, 0
+	}
+	if numPairs := numCandidates * (numCandidates - 1); end > numPairs {
+		end = numPairs
+	}
+
+	// Buffers for the serialized headers of a batch of nonce header pairs
+	// and their hashes which are reused for every batch.
+	var headerBufs [nonceHashBatchSize]bytes.Buffer
+	var headerBytes [nonceHashBatchSize][]byte
+	var hashes [nonceHashBatchSize]chainhash.Hash
+	var batchPairs [nonceHashBatchSize]uint64
+
+	hashesCompleted := uint64(0)
+	for pair := first; pair < end; {
+		// Update the nonce headers and serialize the block header for
+		// each pair of the batch and then hash all of them at once.
+		numHeaders := 0
+		for ; pair < end && numHeaders < nonceHashBatchSize; pair += stride {
+			a, b := NonceHeaderPair(pair, numCandidates)
+			header.NonceHeaderA = candidates[a]
+			header.NonceHeaderB = candidates[b]
+			headerBufs[numHeaders].Reset()
+			header.Serialize(&headerBufs[numHeaders])
+			headerBytes[numHeaders] = headerBufs[numHeaders].Bytes()
+			batchPairs[numHeaders] = pair
+			numHeaders++
+		}
+		chainhash.ShaMulSha256Batch(headerBytes[:numHeaders],
+			hashes[:numHeaders])
+		hashesCompleted += uint64(numHeaders)
+
+		for i := 0; i < numHeaders; i++ {
+			if HashToBig(&hashes[i]).Cmp(targetDifficulty) <= 0 {
+				a, b := NonceHeaderPair(batchPairs[i],
+					numCandidates)
+				header.NonceHeaderA = candidates[a]
+				header.NonceHeaderB = candidates[b]
+				return true, hashesCompleted
+			}
+		}
+	}
+
+	return false, hashesCompleted
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+)
+
+// TestNonceHeaderPairSplit ensures the nonce header pairs searched by miners
+// which split them by stride cover every ordered pair of distinct candidates
+// exactly once regardless of how many miners split them.
+func TestNonceHeaderPairSplit(t *testing.T) {
+	t.Parallel()
+
+	for numCandidates := uint64(2); numCandidates <= 7; numCandidates++ {
+		numPairs := numCandidates * (numCandidates - 1)
+		for numWorkers := uint64(1); numWorkers <= 5; numWorkers++ {
+			seen := make(map[[2]uint64]int)
+			for worker := uint64(0); worker < numWorkers; worker++ {
+				for pair := worker; pair < numPairs; pair += numWorkers {
+					a, b := blockchain.NonceHeaderPair(pair,
+						numCandidates)
+					if a == b || a >= numCandidates ||
+						b >= numCandidates {
+
+						t.Fatalf("NonceHeaderPair(%d, %d): "+
+							"invalid pair (%d, %d)", pair,
+							numCandidates, a, b)
+					}
+					seen[[2]uint64{a, b}]++
+				}
+			}
+
+			if uint64(len(seen)) != numPairs {
+				t.Fatalf("%d candidates, %d workers: got %d "+
+					"distinct pairs, want %d", numCandidates,
+					numWorkers, len(seen), numPairs)
+			}
+			for pair, count := range seen {
+				if count != 1 {
+					t.Fatalf("%d candidates, %d workers: pair "+
+						"%v searched %d times", numCandidates,
+						numWorkers, pair, count)
+				}
+			}
+		}
+	}
+}
+
+// TestNonceHeaderCandidates ensures the nonce header candidates exclude the
+// headers which expire too soon after the block timestamp and the headers which
+// are already used.
+func TestNonceHeaderCandidates(t *testing.T) {
+	t.Parallel()
+
+	params := chaincfg.CTIndigoNetParams
+	params.NonceHeaderMaxAge = 0
+	params.NonceHeaderMinLifetime = 0
+	hs := blockchain.NewMemHeaderSource()
+	hdrs := genesisNonceHeaders(t, &params)
+	for i, h := range hdrs {
+		if _, err := hs.Insert(h); err != nil {
+			t.Fatalf("Insert #%d: unexpected error: %v", i, err)
+		}
+	}
+
+	genesisTime := params.GenesisBlock.Header.Timestamp
+	tests := []struct {
+		name      string
+		used      map[string]struct{}
+		blockTime time.Time
+		want      int
+	}{
+		{
+			name:      "all unexpired",
+			blockTime: genesisTime,
+			want:      2,
+		},
+		{
+			name:      "one used",
+			used:      map[string]struct{}{string(hdrs[0].IKey()): {}},
+			blockTime: genesisTime,
+			want:      1,
+		},
+		{
+			name:      "all expired",
+			blockTime: time.Now(),
+			want:      0,
+		},
+	}
+	for _, test := range tests {
+		candidates, ikeys, err := blockchain.NonceHeaderCandidates(hs,
+			test.used, test.blockTime, &params)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(candidates) != test.want || len(ikeys) != test.want {
+			t.Errorf("%s: unexpected number of candidates - got %d "+
+				"(%d I keys), want %d", test.name, len(candidates),
+				len(ikeys), test.want)
+			continue
+		}
+		for ikey := range test.used {
+			if _, exists := ikeys[ikey]; exists {
+				t.Errorf("%s: used header %x is a candidate",
+					test.name, ikey)
+			}
+		}
+	}
+}
+
+// TestSolveNonceHeaders ensures the nonce header solver sets the nonce headers
+// of a block header to a pair of distinct candidates when it finds a solution
+// and searches all of the requested pairs when there is none.
+func TestSolveNonceHeaders(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.CTIndigoNetParams
+	hdrs := genesisNonceHeaders(t, params)
+	candidates := make([]ciphrtxt.BinaryMessageHeaderV2, 0, len(hdrs))
+	for _, h := range hdrs {
+		candidates = append(candidates, *h.ExportBinaryHeaderV2())
+	}
+
+	// Every hash meets the maximum target, so the first pair solves it.
+	header := params.GenesisBlock.Header
+	maxTarget := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256),
+		big.NewInt(1))
+	solved, numHashes := blockchain.SolveNonceHeaders(&header, candidates,
+		1, 2, 1, maxTarget)
+	if !solved || numHashes != 1 {
+		t.Fatalf("SolveNonceHeaders: unexpected result - solved %v, "+
+			"hashes %d", solved, numHashes)
+	}
+	if header.NonceHeaderA != candidates[1] ||
+		header.NonceHeaderB != candidates[0] {
+
+		t.Fatalf("SolveNonceHeaders: nonce headers not set to pair 1")
+	}
+
+	// No hash meets a zero target, so every pair is searched.
+	solved, numHashes = blockchain.SolveNonceHeaders(&header, candidates,
+		0, 10, 1, big.NewInt(0))
+	if solved || numHashes != 2 {
+		t.Fatalf("SolveNonceHeaders: unexpected result - solved %v, "+
+			"hashes %d", solved, numHashes)
+	}
+
+	// A single candidate has no pairs to search.
+	solved, numHashes = blockchain.SolveNonceHeaders(&header,
+		candidates[:1], 0, 10, 1, maxTarget)
+	if solved || numHashes != 0 {
+		t.Fatalf("SolveNonceHeaders: unexpected result - solved %v, "+
+			"hashes %d", solved, numHashes)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/wire"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+const (
+	// maxPendingBlocks is the maximum number of blocks awaiting their
+	// nonce headers that can be queued.
+	maxPendingBlocks = 100
+
+	// pendingBlockTimeout is how long a block is held in the pending pool
+	// while waiting for its nonce headers to become known to the message
+	// header source before it is rejected.
+	pendingBlockTimeout = 5 * time.Minute
+)
+
+// pendingBlock represents a block which uses a nonce header that is not yet
+// known to the message header source.  It is a normal block plus the flags
+// it was processed with and an expiration time to prevent holding the block
+// forever.
+type pendingBlock struct {
+	block      *cttutil.Block
+	flags      BehaviorFlags
+	expiration time.Time
+}
+
+// pendingBlocksByExpiration provides sorting of pending blocks by their
+// expiration time so they are retried in the order they were received.
+type pendingBlocksByExpiration []*pendingBlock
+
+// Len returns the number of pending blocks in the slice.  It is part of the
+// sort.Interface implementation.
+func (s pendingBlocksByExpiration) Len() int {
+	return len(s)
+}
+
+// Swap swaps the pending blocks at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s pendingBlocksByExpiration) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the pending block with index i expires before the
+// pending block with index j.  It is part of the sort.Interface
+// implementation.
+func (s pendingBlocksByExpiration) Less(i, j int) bool {
+	return s[i].expiration.Before(s[j].expiration)
+}
+
+// PendingBlockResult houses the outcome of processing a block which was held
+// in the pending pool.  Err is a RuleError with the ErrMissingNonceHeader code
+// when the block was dropped because its nonce headers did not become known
+// before it expired.
+type PendingBlockResult struct {
+	Block    *cttutil.Block
+	IsOrphan bool
+	Err      error
+}
+
+// IsKnownPending returns whether the passed hash is currently a block held in
+// the pending pool while waiting for its nonce headers.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsKnownPending(hash *chainhash.Hash) bool {
+	b.pendingLock.RLock()
+	defer b.pendingLock.RUnlock()
+
+	_, exists := b.pendingBlocks[*hash]
+	return exists
+}
+
+// NumPendingBlocks returns the number of blocks held in the pending pool.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) NumPendingBlocks() int {
+	b.pendingLock.RLock()
+	defer b.pendingLock.RUnlock()
+
+	return len(b.pendingBlocks)
+}
+
+// removePendingBlock removes the block with the passed hash from the pending
+// pool.
+func (b *BlockChain) removePendingBlock(hash *chainhash.Hash) {
+	b.pendingLock.Lock()
+	defer b.pendingLock.Unlock()
+
+	delete(b.pendingBlocks, *hash)
+}
+
+// addPendingBlock adds the passed block (which is already determined to use a
+// nonce header which is not yet known) to the pending pool.  It imposes a
+// maximum limit on the number of pending blocks and will remove the block
+// which expires first if the limit is exceeded.
+func (b *BlockChain) addPendingBlock(block *cttutil.Block, flags BehaviorFlags) {
+	b.pendingLock.Lock()
+	defer b.pendingLock.Unlock()
+
+	// Limit pending blocks to prevent memory exhaustion.
+	if len(b.pendingBlocks)+1 > maxPendingBlocks {
+		var oldest *pendingBlock
+		for _, pBlock := range b.pendingBlocks {
+			if oldest == nil || pBlock.expiration.Before(oldest.expiration) {
+				oldest = pBlock
+			}
+		}
+		log.Debugf("Evicting pending block %v", oldest.block.Hash())
+		delete(b.pendingBlocks, *oldest.block.Hash())
+	}
+
+	b.pendingBlocks[*block.Hash()] = &pendingBlock{
+		block:      block,
+		flags:      flags,
+		expiration: time.Now().Add(pendingBlockTimeout),
+	}
+}
+
+// ProcessPendingBlocks re-checks the nonce headers of the blocks held in the
+// pending pool against the message header source.  Blocks whose nonce headers
+// are now known are removed from the pool and processed as if they were just
+// received, while blocks which are still missing a nonce header are dropped
+// once they expire.  The outcome for every block which left the pool is
+// returned in the order the blocks were received.
+//
+// It should be called periodically and whenever new message headers become
+// known to the message header source.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessPendingBlocks() []PendingBlockResult {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	b.pendingLock.RLock()
+	pending := make([]*pendingBlock, 0, len(b.pendingBlocks))
+	for _, pBlock := range b.pendingBlocks {
+		pending = append(pending, pBlock)
+	}
+	b.pendingLock.RUnlock()
+	sort.Sort(pendingBlocksByExpiration(pending))
+
+	var results []PendingBlockResult
+	now := time.Now()
+	for _, pBlock := range pending {
+		header := &pBlock.block.MsgBlock().Header
+		err := checkBlockHeaderNonces(header, b.timeSource, b.headerCache)
+		if rerr, ok := err.(RuleError); ok &&
+			rerr.ErrorCode == ErrMissingNonceHeader {
+
+			if now.Before(pBlock.expiration) {
+				continue
+			}
+			b.removePendingBlock(pBlock.block.Hash())
+			results = append(results, PendingBlockResult{
+				Block: pBlock.block,
+				Err:   err,
+			})
+			continue
+		}
+
+		b.removePendingBlock(pBlock.block.Hash())
+		isOrphan, err := b.processBlock(pBlock.block, pBlock.flags)
+		results = append(results, PendingBlockResult{
+			Block:    pBlock.block,
+			IsOrphan: isOrphan,
+			Err:      err,
+		})
+	}
+	return results
+}
+
+// MissingNonceHeaders returns the I keys of the unexpired message headers used
+// as nonces by the passed block header which are not yet known to the message
+// header source.  Nonce headers which do not parse are ignored since they can
+// never become known.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) MissingNonceHeaders(header *wire.BlockHeader) [][]byte {
+	if b.headerCache == nil {
+		return nil
+	}
+
+	minExpireTime := b.timeSource.AdjustedTime().Add(time.Second *
+		allowedClockDrift)
+	var missing [][]byte
+	for _, nonce := range []*ciphrtxt.BinaryMessageHeaderV2{
+		&header.NonceHeaderA, &header.NonceHeaderB} {
+
+		h := ciphrtxt.ImportBinaryHeaderV2(nonce[:])
+		if h == nil || !h.ExpireTime().After(minExpireTime) {
+			continue
+		}
+		ikey := h.IKey()
+		if len(missing) == 1 && bytes.Equal(missing[0], ikey) {
+			continue
+		}
+		if _, err := b.headerCache.FindByI(ikey); err != nil {
+			missing = append(missing, ikey)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+// pendingTimeSource is a MedianTimeSource which always reports the same
+// adjusted time.
+type pendingTimeSource time.Time
+
+// AdjustedTime returns the fixed time of the time source.
+//
+// This is part of the MedianTimeSource interface.
+func (s pendingTimeSource) AdjustedTime() time.Time {
+	return time.Time(s)
+}
+
+// AddTimeSample ignores the passed time sample.
+//
+// This is part of the MedianTimeSource interface.
+func (s pendingTimeSource) AddTimeSample(id string, timeVal time.Time) {}
+
+// Offset always returns a zero offset.
+//
+// This is part of the MedianTimeSource interface.
+func (s pendingTimeSource) Offset() time.Duration {
+	return 0
+}
+
+// TestPendingBlocks ensures blocks awaiting their nonce headers are held until
+// they expire and that the pending pool is limited in size.
+func TestPendingBlocks(t *testing.T) {
+	t.Parallel()
+
+	// The nonce headers of the genesis block are unexpired as of its
+	// timestamp and unknown to the empty header source.
+	params := chaincfg.CTIndigoNetParams
+	genesis := params.GenesisBlock
+	chain := &BlockChain{
+		chainParams:   &params,
+		timeSource:    pendingTimeSource(genesis.Header.Timestamp),
+		headerCache:   NewMemHeaderSource(),
+		pendingBlocks: make(map[chainhash.Hash]*pendingBlock),
+	}
+
+	block := cttutil.NewBlock(genesis)
+	chain.addPendingBlock(block, BFNone)
+	if !chain.IsKnownPending(block.Hash()) || chain.NumPendingBlocks() != 1 {
+		t.Fatalf("addPendingBlock: block is not pending")
+	}
+
+	// Ensure the block is held while its nonce headers are missing and it
+	// has not expired.
+	if results := chain.ProcessPendingBlocks(); len(results) != 0 {
+		t.Fatalf("ProcessPendingBlocks: got %d results, want 0",
+			len(results))
+	}
+	if !chain.IsKnownPending(block.Hash()) {
+		t.Fatalf("ProcessPendingBlocks: block is no longer pending")
+	}
+
+	// Ensure the block is dropped with the missing nonce header error once
+	// it expires.
+	chain.pendingBlocks[*block.Hash()].expiration = time.Now().Add(-time.Second)
+	results := chain.ProcessPendingBlocks()
+	if len(results) != 1 {
+		t.Fatalf("ProcessPendingBlocks: got %d results, want 1",
+			len(results))
+	}
+	rerr, ok := results[0].Err.(RuleError)
+	if !ok || rerr.ErrorCode != ErrMissingNonceHeader {
+		t.Fatalf("ProcessPendingBlocks: did not receive expected error "+
+			"- got %v, want %v", results[0].Err, ErrMissingNonceHeader)
+	}
+	if chain.IsKnownPending(block.Hash()) || chain.NumPendingBlocks() != 0 {
+		t.Fatalf("ProcessPendingBlocks: expired block is still pending")
+	}
+
+	// Fill the pending pool with blocks which expire in the order they
+	// were added and ensure adding one more evicts the first one.
+	base := time.Now().Add(time.Hour)
+	blocks := make([]*cttutil.Block, maxPendingBlocks+1)
+	for i := range blocks {
+		msgBlock := *genesis
+		msgBlock.Header.Timestamp = genesis.Header.Timestamp.Add(
+			time.Duration(i) * time.Second)
+		blocks[i] = cttutil.NewBlock(&msgBlock)
+	}
+	for i, block := range blocks[:maxPendingBlocks] {
+		chain.addPendingBlock(block, BFNone)
+		chain.pendingBlocks[*block.Hash()].expiration = base.Add(
+			time.Duration(i) * time.Second)
+	}
+	chain.addPendingBlock(blocks[maxPendingBlocks], BFNone)
+	if chain.NumPendingBlocks() != maxPendingBlocks {
+		t.Fatalf("addPendingBlock: got %d pending blocks, want %d",
+			chain.NumPendingBlocks(), maxPendingBlocks)
+	}
+	if chain.IsKnownPending(blocks[0].Hash()) {
+		t.Fatalf("addPendingBlock: oldest block was not evicted")
+	}
+	if !chain.IsKnownPending(blocks[maxPendingBlocks].Hash()) {
+		t.Fatalf("addPendingBlock: newest block is not pending")
+	}
+}
+
+// TestMissingNonceHeaders ensures the I keys of nonce headers which are not
+// yet known to the message header source are reported.
+func TestMissingNonceHeaders(t *testing.T) {
+	t.Parallel()
+
+	params := chaincfg.CTIndigoNetParams
+	header := params.GenesisBlock.Header
+	headerCache := NewMemHeaderSource()
+	chain := &BlockChain{
+		chainParams: &params,
+		timeSource:  pendingTimeSource(header.Timestamp),
+		headerCache: headerCache,
+	}
+
+	if missing := chain.MissingNonceHeaders(&header); len(missing) != 2 {
+		t.Fatalf("MissingNonceHeaders: got %d missing headers, want 2",
+			len(missing))
+	}
+
+	// Ensure a known nonce header is no longer reported.
+	nonceA := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderA[:])
+	if _, err := headerCache.Insert(nonceA); err != nil {
+		t.Fatalf("Insert: unexpected error: %v", err)
+	}
+	missing := chain.MissingNonceHeaders(&header)
+	nonceB := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderB[:])
+	if len(missing) != 1 || !bytes.Equal(missing[0], nonceB.IKey()) {
+		t.Fatalf("MissingNonceHeaders: got %x, want [%x]", missing,
+			nonceB.IKey())
+	}
+
+	// Ensure the same header used as both nonces is only reported once.
+	header.NonceHeaderA = header.NonceHeaderB
+	if missing := chain.MissingNonceHeaders(&header); len(missing) != 1 {
+		t.Fatalf("MissingNonceHeaders: got %d missing headers, want 1",
+			len(missing))
+	}
+}
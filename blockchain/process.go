@@ -124,11 +124,23 @@ func (b *BlockChain) processOrphans(hash *chainhash.Hash, flags BehaviorFlags) e
 // any errors that occurred during processing.  The returned bool is only valid
 // when the error is nil.
 //
+// A block which uses an unexpired nonce header that is not yet known to the
+// message header source is held in the pending pool and a RuleError with the
+// ErrMissingNonceHeader code is returned.  See ProcessPendingBlocks.
+//
 // This function is safe for concurrent access.
 func (b *BlockChain) ProcessBlock(block *cttutil.Block, flags BehaviorFlags) (bool, error) {
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
+	return b.processBlock(block, flags)
+}
+
+// processBlock is the internal implementation of ProcessBlock.  See its
+// comments for details.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) processBlock(block *cttutil.Block, flags BehaviorFlags) (bool, error) {
 	fastAdd := flags&BFFastAdd == BFFastAdd
 	dryRun := flags&BFDryRun == BFDryRun
 
@@ -151,9 +163,24 @@ func (b *BlockChain) ProcessBlock(block *cttutil.Block, flags BehaviorFlags) (bo
 		return false, ruleError(ErrDuplicateBlock, str)
 	}
 
+	// The block must not already be awaiting its nonce headers.
+	if b.IsKnownPending(blockHash) {
+		str := fmt.Sprintf("already have block (pending) %v", blockHash)
+		return false, ruleError(ErrDuplicateBlock, str)
+	}
+
 	// Perform preliminary sanity checks on the block and its transactions.
-	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags, b.headerCache)
+	err = checkBlockSanity(block, b.chainParams, b.timeSource, flags, b.headerCache)
 	if err != nil {
+		// Hold blocks which use a nonce header that is not yet known
+		// since it is typically still propagating.
+		if rerr, ok := err.(RuleError); ok &&
+			rerr.ErrorCode == ErrMissingNonceHeader && !dryRun {
+
+			log.Infof("Deferring validation of block %v until its "+
+				"nonce headers are known", blockHash)
+			b.addPendingBlock(block, flags)
+		}
 		return false, err
 	}
 
@@ -185,9 +212,30 @@ func (b *BlockChain) ProcessBlock(block *cttutil.Block, flags BehaviorFlags) (bo
 			// check ensures the proof of work is at least the minimum
 			// expected based on elapsed time since the last checkpoint and
 			// maximum adjustment allowed by the retarget rules.
+			//
+			// The height of the block follows the height of its
+			// parent when the parent is known.  Otherwise the block
+			// is assumed to extend the best chain.  The height its
+			// coinbase claims is not verified yet, so it is never
+			// used since any block could otherwise claim a height
+			// which allows the proof of work limit.
+			height := b.bestNode.height + 1
+			prevExists, err := b.blockExists(&blockHeader.PrevBlock)
+			if err != nil {
+				return false, err
+			}
+			if prevExists {
+				prevNode, err := b.getPrevNodeFromBlock(block)
+				if err != nil {
+					return false, err
+				}
+				if prevNode != nil {
+					height = prevNode.height + 1
+				}
+			}
 			duration := blockHeader.Timestamp.Sub(checkpointTime)
 			requiredTarget := CompactToBig(b.calcEasiestDifficulty(
-				checkpointHeader.Bits, duration))
+				checkpointHeader.Bits, duration, height))
 			currentTarget := CompactToBig(blockHeader.Bits)
 			if currentTarget.Cmp(requiredTarget) > 0 {
 				str := fmt.Sprintf("block target difficulty of %064x "+
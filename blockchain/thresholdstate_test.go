@@ -0,0 +1,277 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+)
+
+// TestThresholdStateStringer tests the stringized output for the
+// ThresholdState type.
+func TestThresholdStateStringer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   ThresholdState
+		want string
+	}{
+		{ThresholdDefined, "ThresholdDefined"},
+		{ThresholdStarted, "ThresholdStarted"},
+		{ThresholdLockedIn, "ThresholdLockedIn"},
+		{ThresholdActive, "ThresholdActive"},
+		{ThresholdFailed, "ThresholdFailed"},
+		{0xff, "Unknown ThresholdState (255)"},
+	}
+
+	// Detect additional threshold states that don't have the stringer
+	// tested.
+	if len(tests)-1 != int(numThresholdsStates) {
+		t.Errorf("It appears a threshold state was added without " +
+			"adding an associated stringer test")
+	}
+
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+		}
+	}
+}
+
+// thresholdTestChain returns the tip of a chain of numBlocks blocks on top of
+// the genesis block of the passed params.  The blocks are a minute apart and
+// their versions are returned by the passed function for each height.
+func thresholdTestChain(params *chaincfg.Params, numBlocks int32, version func(height int32) int32) *blockNode {
+	genesis := params.GenesisBlock.Header
+	node := newBlockNode(&genesis, params.GenesisHash, 0)
+	for height := int32(1); height <= numBlocks; height++ {
+		header := genesis
+		header.PrevBlock = *node.hash
+		header.Version = version(height)
+		header.Timestamp = genesis.Timestamp.Add(time.Minute *
+			time.Duration(height))
+
+		// The hashes only need to be unique within the chain.
+		var hash chainhash.Hash
+		binary.LittleEndian.PutUint32(hash[:], uint32(height))
+		hash[chainhash.HashSize-1] = 0xff
+		child := newBlockNode(&header, &hash, height)
+		child.parent = node
+		node = child
+	}
+	return node
+}
+
+// TestThresholdState ensures the threshold state of a deployment moves through
+// the states defined by BIP0009 as blocks signal for it.
+func TestThresholdState(t *testing.T) {
+	t.Parallel()
+
+	// Use windows of 10 blocks which lock in a deployment once 8 of their
+	// blocks signal for it.
+	params := chaincfg.CTSimNetParams
+	params.MinerConfirmationWindow = 10
+	params.RuleChangeActivationThreshold = 8
+	const bit = 28
+	signal := int32(vbTopBits | 1<<bit)
+	genesisTime := uint64(params.GenesisBlock.Header.Timestamp.Unix())
+
+	// The median time of a window is five minutes before the time of its
+	// last block.
+	tests := []struct {
+		name       string
+		startTime  uint64
+		expireTime uint64
+		version    func(height int32) int32
+		states     map[int32]ThresholdState
+	}{
+		{
+			name:       "every block signals",
+			expireTime: math.MaxInt64,
+			version:    func(int32) int32 { return signal },
+			states: map[int32]ThresholdState{
+				0:  ThresholdDefined,
+				8:  ThresholdDefined,
+				9:  ThresholdStarted,
+				18: ThresholdStarted,
+				19: ThresholdLockedIn,
+				28: ThresholdLockedIn,
+				29: ThresholdActive,
+				49: ThresholdActive,
+			},
+		},
+		{
+			name:       "threshold reached",
+			expireTime: math.MaxInt64,
+			version: func(height int32) int32 {
+				if height%10 < 8 {
+					return signal
+				}
+				return vbTopBits
+			},
+			states: map[int32]ThresholdState{
+				9:  ThresholdStarted,
+				19: ThresholdLockedIn,
+				29: ThresholdActive,
+			},
+		},
+		{
+			name:       "threshold not reached",
+			expireTime: math.MaxInt64,
+			version: func(height int32) int32 {
+				if height%10 < 7 {
+					return signal
+				}
+				return vbTopBits
+			},
+			states: map[int32]ThresholdState{
+				9:  ThresholdStarted,
+				19: ThresholdStarted,
+				49: ThresholdStarted,
+			},
+		},
+		{
+			name:       "signal without version bits scheme",
+			expireTime: math.MaxInt64,
+			version:    func(int32) int32 { return 104 | 1<<bit },
+			states: map[int32]ThresholdState{
+				9:  ThresholdStarted,
+				49: ThresholdStarted,
+			},
+		},
+		{
+			name:       "start time not reached",
+			startTime:  genesisTime + 30*60,
+			expireTime: math.MaxInt64,
+			version:    func(int32) int32 { return signal },
+			states: map[int32]ThresholdState{
+				9:  ThresholdDefined,
+				29: ThresholdDefined,
+				39: ThresholdStarted,
+				49: ThresholdLockedIn,
+			},
+		},
+		{
+			name:       "expired before lock in",
+			expireTime: genesisTime + 15*60,
+			version:    func(int32) int32 { return vbTopBits },
+			states: map[int32]ThresholdState{
+				9:  ThresholdStarted,
+				19: ThresholdStarted,
+				29: ThresholdFailed,
+				49: ThresholdFailed,
+			},
+		},
+		{
+			name:       "locked in before expiring",
+			expireTime: genesisTime + 15*60,
+			version:    func(int32) int32 { return signal },
+			states: map[int32]ThresholdState{
+				19: ThresholdLockedIn,
+				29: ThresholdActive,
+			},
+		},
+		{
+			name:    "expired before start",
+			version: func(int32) int32 { return signal },
+			states: map[int32]ThresholdState{
+				8:  ThresholdDefined,
+				9:  ThresholdFailed,
+				49: ThresholdFailed,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		params.Deployments[chaincfg.DeploymentTestDummy] =
+			chaincfg.ConsensusDeployment{
+				BitNumber:  bit,
+				StartTime:  test.startTime,
+				ExpireTime: test.expireTime,
+			}
+		chain := &BlockChain{
+			chainParams: &params,
+			deploymentCaches: newThresholdCaches(
+				chaincfg.DefinedDeployments),
+		}
+		tip := thresholdTestChain(&params, 49, test.version)
+
+		for height, want := range test.states {
+			node, err := chain.ancestorNode(tip, height)
+			if err != nil {
+				t.Fatalf("%s: ancestorNode: %v", test.name, err)
+			}
+			state, err := chain.deploymentState(node,
+				chaincfg.DeploymentTestDummy)
+			if err != nil {
+				t.Errorf("%s: deploymentState: %v", test.name, err)
+				continue
+			}
+			if state != want {
+				t.Errorf("%s: mismatched state after height %d "+
+					"- got %v, want %v", test.name, height, state,
+					want)
+			}
+		}
+	}
+}
+
+// TestCalcNextBlockVersion ensures the version of the next block signals the
+// deployments which are started or locked in.
+func TestCalcNextBlockVersion(t *testing.T) {
+	t.Parallel()
+
+	params := chaincfg.CTSimNetParams
+	params.MinerConfirmationWindow = 10
+	params.RuleChangeActivationThreshold = 8
+	deployment := &params.Deployments[chaincfg.DeploymentTestDummy]
+	signal := int32(vbTopBits | 1<<deployment.BitNumber)
+	chain := &BlockChain{
+		chainParams:      &params,
+		deploymentCaches: newThresholdCaches(chaincfg.DefinedDeployments),
+	}
+	tip := thresholdTestChain(&params, 29, func(int32) int32 {
+		return signal
+	})
+
+	tests := []struct {
+		height int32
+		want   int32
+	}{
+		{height: 8, want: vbTopBits},  // defined
+		{height: 9, want: signal},     // started
+		{height: 19, want: signal},    // locked in
+		{height: 29, want: vbTopBits}, // active
+	}
+	for _, test := range tests {
+		node, err := chain.ancestorNode(tip, test.height)
+		if err != nil {
+			t.Fatalf("ancestorNode: %v", err)
+		}
+		version, err := chain.calcNextBlockVersion(node)
+		if err != nil {
+			t.Errorf("calcNextBlockVersion: %v", err)
+			continue
+		}
+		if version != test.want {
+			t.Errorf("mismatched version after height %d - got %x, "+
+				"want %x", test.height, version, test.want)
+		}
+	}
+
+	// Unknown deployments are rejected.
+	_, err := chain.deploymentState(tip, chaincfg.DefinedDeployments)
+	if _, ok := err.(DeploymentError); !ok {
+		t.Errorf("deploymentState: unexpected error for unknown "+
+			"deployment: %v", err)
+	}
+}
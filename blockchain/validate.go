@@ -49,6 +49,23 @@ const (
 	// coinbases to start with the serialized block height.
 	serializedHeightVersion = 2
 
+	// accessKeyVersion is the block version which requires all outputs
+	// which register network access keys to be well formed, signed by the
+	// key they register and not yet expired.
+	accessKeyVersion = 102
+
+	// nonceHeaderReuseVersion is the block version which forbids using the
+	// same message header as both nonces of a block or using a message
+	// header which is already used as a nonce by one of the blocks in the
+	// nonce header reuse window of the chain parameters.
+	nonceHeaderReuseVersion = 103
+
+	// nonceHeaderLifetimeVersion is the block version which requires the
+	// nonce headers of a block to have been stored no more than the nonce
+	// header max age of the chain parameters before the block timestamp
+	// and to expire no less than the nonce header min lifetime after it.
+	nonceHeaderLifetimeVersion = 104
+
 	// baseSubsidyCoins is the starting subsidy amount for mined blocks.  This
 	// value is halved every SubsidyHalvingInterval blocks.
 	baseSubsidyCoins = 1024
@@ -299,6 +316,38 @@ func CheckTransactionSanity(tx *cttutil.Tx) error {
 	return nil
 }
 
+// CheckAccessKeys ensures every output of the passed transaction which begins
+// with OP_REGISTERACCESSKEY registers an access key of exactly
+// txscript.AccessKeySize bytes, that the public key of the access key parses,
+// that the access key is signed by the private key which corresponds to it,
+// and that the access key has not expired as of the passed time.
+func CheckAccessKeys(tx *cttutil.Tx, now time.Time) error {
+	for i, txOut := range tx.MsgTx().TxOut {
+		if !txscript.IsAccessKeyRegistration(txOut.PkScript) {
+			continue
+		}
+
+		ak, err := txscript.ExtractAccessKey(txOut.PkScript)
+		if err == nil {
+			err = ak.Verify()
+		}
+		if err != nil {
+			str := fmt.Sprintf("transaction %v output %d: %v",
+				tx.Hash(), i, err)
+			return ruleError(ErrBadAccessKey, str)
+		}
+
+		if int64(ak.Expire) <= now.Unix() {
+			str := fmt.Sprintf("transaction %v output %d: access key "+
+				"expired at %v", tx.Hash(), i,
+				time.Unix(int64(ak.Expire), 0))
+			return ruleError(ErrBadAccessKey, str)
+		}
+	}
+
+	return nil
+}
+
 // checkProofOfWork ensures the block header bits which indicate the target
 // difficulty is in min/max range and that the block hash is less than the
 // target difficulty as claimed.
@@ -463,60 +512,55 @@ func checkBlockHeaderSanity(header *wire.BlockHeader, powLimit *big.Int, timeSou
 }
 
 // checkBlockHeaderNonces checks the message headers used as nonces to solve the
-// block. All headers are validated as syntactically correct headers (which 
+// block. All headers are validated as syntactically correct headers (which
 // themselves contain a sha256 nonce). Unexpired messages are also validated to
-// exist in the message cache. 
+// exist in the passed message header source and ErrMissingNonceHeader is
+// returned when they do not.  The existence check is skipped when the header
+// source is nil.
 //
-// The flags do not modify the behavior of this function directly, however they
-// are needed to pass along to checkBlockHeaderSanity.
-func checkBlockHeaderNonces(header *wire.BlockHeader, timeSource MedianTimeSource, hc *ciphrtxt.HeaderCache) error {
-    headerA := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderA[:])
-    if headerA == nil {
-        str := fmt.Sprintf("Failed to import NonceA : ", 
-            hex.EncodeToString(header.NonceHeaderA[:]))
-        return ruleError(ErrNonceValidation, str)
-    }
-    
-    headerB := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderB[:])
-    if headerB == nil {
-        str := fmt.Sprintf("Failed to import NonceB : ", 
-            hex.EncodeToString(header.NonceHeaderB[:]))
-        return ruleError(ErrNonceValidation, str)
-    }
-    
-    if hc == nil {
-        return nil
-    }
-    
-    minExpireTime := timeSource.AdjustedTime().Add(time.Second *
+// The nonce header lifetimes depend on the version of the previous blocks, so
+// they are checked by checkBlockHeaderContext instead.
+func checkBlockHeaderNonces(header *wire.BlockHeader, timeSource MedianTimeSource, hs MessageHeaderSource) error {
+	headerA := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderA[:])
+	if headerA == nil {
+		str := fmt.Sprintf("failed to import nonce header A: %s",
+			hex.EncodeToString(header.NonceHeaderA[:]))
+		return ruleError(ErrNonceValidation, str)
+	}
+
+	headerB := ciphrtxt.ImportBinaryHeaderV2(header.NonceHeaderB[:])
+	if headerB == nil {
+		str := fmt.Sprintf("failed to import nonce header B: %s",
+			hex.EncodeToString(header.NonceHeaderB[:]))
+		return ruleError(ErrNonceValidation, str)
+	}
+
+	if hs == nil {
+		return nil
+	}
+
+	minExpireTime := timeSource.AdjustedTime().Add(time.Second *
 		allowedClockDrift)
-    
-    
-    if headerA.ExpireTime().After(minExpireTime) {
-        ikey := headerA.IKey()
-        
-        _, err := hc.FindByI(ikey)
-        if err != nil {
-            str := fmt.Sprintf("Message not found in HeaderCache : ", 
-                hex.EncodeToString(header.NonceHeaderA[:]))
-            return ruleError(ErrNonceValidation, str)
-        }
-    } 
-    
-    if headerB.ExpireTime().After(minExpireTime) {
-        ikey := headerB.IKey()
-        
-        _, err := hc.FindByI(ikey)
-        if err != nil {
-            str := fmt.Sprintf("Message not found in HeaderCache : ", 
-                hex.EncodeToString(header.NonceHeaderA[:]))
-            return ruleError(ErrNonceValidation, str)
-        }
-    } 
-    
-    fmt.Println("checkBlockHeaderNonces passed for " + header.BlockHash().String())
-    
-    return nil
+
+	if headerA.ExpireTime().After(minExpireTime) {
+		if _, err := hs.FindByI(headerA.IKey()); err != nil {
+			str := fmt.Sprintf("nonce header A not found in message "+
+				"header source: %s",
+				hex.EncodeToString(header.NonceHeaderA[:]))
+			return ruleError(ErrMissingNonceHeader, str)
+		}
+	}
+
+	if headerB.ExpireTime().After(minExpireTime) {
+		if _, err := hs.FindByI(headerB.IKey()); err != nil {
+			str := fmt.Sprintf("nonce header B not found in message "+
+				"header source: %s",
+				hex.EncodeToString(header.NonceHeaderB[:]))
+			return ruleError(ErrMissingNonceHeader, str)
+		}
+	}
+
+	return nil
 }
 
 // checkBlockSanity performs some preliminary checks on a block to ensure it is
@@ -524,15 +568,16 @@ func checkBlockHeaderNonces(header *wire.BlockHeader, timeSource MedianTimeSourc
 //
 // The flags do not modify the behavior of this function directly, however they
 // are needed to pass along to checkBlockHeaderSanity.
-func checkBlockSanity(block *cttutil.Block, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags, hc *ciphrtxt.HeaderCache) error {
+func checkBlockSanity(block *cttutil.Block, chainParams *chaincfg.Params, timeSource MedianTimeSource, flags BehaviorFlags, hs MessageHeaderSource) error {
 	msgBlock := block.MsgBlock()
 	header := &msgBlock.Header
-	err := checkBlockHeaderSanity(header, powLimit, timeSource, flags)
+	err := checkBlockHeaderSanity(header, chainParams.PowLimit, timeSource,
+		flags)
 	if err != nil {
 		return err
 	}
 
-	err = checkBlockHeaderNonces(header , timeSource, hc)
+	err = checkBlockHeaderNonces(header, timeSource, hs)
     if err != nil {
 		return err
 	}
@@ -635,8 +680,11 @@ func checkBlockSanity(block *cttutil.Block, powLimit *big.Int, timeSource Median
 
 // CheckBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
-func CheckBlockSanity(block *cttutil.Block, powLimit *big.Int, timeSource MedianTimeSource, hc *ciphrtxt.HeaderCache) error {
-	return checkBlockSanity(block, powLimit, timeSource, BFNone, hc)
+// The unexpired nonce headers of the block are required to be known to the
+// passed message header source unless it is nil.  The proof of work limit and
+// the nonce header lifetime limits are taken from the passed chain parameters.
+func CheckBlockSanity(block *cttutil.Block, chainParams *chaincfg.Params, timeSource MedianTimeSource, hs MessageHeaderSource) error {
+	return checkBlockSanity(block, chainParams, timeSource, BFNone, hs)
 }
 
 // ExtractCoinbaseHeight attempts to extract the height of the block from the
@@ -758,6 +806,63 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 	}
 
 	if !fastAdd {
+		// Ensure the nonce headers of the block are not reused for
+		// blocks whose version is the nonceHeaderReuseVersion or newer
+		// once a majority of the network has upgraded.
+		if header.Version >= nonceHeaderReuseVersion &&
+			b.isMajorityVersion(nonceHeaderReuseVersion, prevNode,
+				b.chainParams.BlockEnforceNumRequired) {
+
+			err := b.checkNonceHeaderReuse(header, prevNode)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Ensure the nonce headers of the block satisfy the nonce
+		// header lifetime limits for blocks whose version is the
+		// nonceHeaderLifetimeVersion or newer once a majority of the
+		// network has upgraded.
+		err = b.checkActiveNonceHeaderLifetimes(header, prevNode)
+		if err != nil {
+			return err
+		}
+
+		// Reject version 103 blocks once a majority of the network has
+		// upgraded to enforce nonce header lifetimes.  This prevents
+		// miners from avoiding the lifetime limits by continuing to
+		// produce older versions.
+		if header.Version < nonceHeaderLifetimeVersion &&
+			b.isMajorityVersion(nonceHeaderLifetimeVersion, prevNode,
+				b.chainParams.BlockRejectNumRequired) {
+
+			str := "new blocks with version %d are no longer valid"
+			str = fmt.Sprintf(str, header.Version)
+			return ruleError(ErrBlockVersionTooOld, str)
+		}
+
+		// Reject version 102 blocks once a majority of the network has
+		// upgraded to enforce nonce header reuse.
+		if header.Version < nonceHeaderReuseVersion && b.isMajorityVersion(
+			nonceHeaderReuseVersion, prevNode,
+			b.chainParams.BlockRejectNumRequired) {
+
+			str := "new blocks with version %d are no longer valid"
+			str = fmt.Sprintf(str, header.Version)
+			return ruleError(ErrBlockVersionTooOld, str)
+		}
+
+		// Reject version 101 blocks once a majority of the network has
+		// upgraded to enforce access key registrations.
+		if header.Version < accessKeyVersion && b.isMajorityVersion(
+			accessKeyVersion, prevNode,
+			b.chainParams.BlockRejectNumRequired) {
+
+			str := "new blocks with version %d are no longer valid"
+			str = fmt.Sprintf(str, header.Version)
+			return ruleError(ErrBlockVersionTooOld, str)
+		}
+
 		// Reject version 3 blocks once a majority of the network has
 		// upgraded.  This is part of BIP0065.
 		if header.Version < 4 && b.isMajorityVersion(4, prevNode,
@@ -847,6 +952,22 @@ func (b *BlockChain) checkBlockContext(block *cttutil.Block, prevNode *blockNode
 				return err
 			}
 		}
+
+		// Ensure all access key registrations in the block are valid
+		// as of the block time for blocks whose version is the
+		// accessKeyVersion or newer once a majority of the network has
+		// upgraded.
+		if header.Version >= accessKeyVersion &&
+			b.isMajorityVersion(accessKeyVersion, prevNode,
+				b.chainParams.BlockEnforceNumRequired) {
+
+			for _, tx := range block.Transactions() {
+				err := CheckAccessKeys(tx, header.Timestamp)
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
 
 	return nil
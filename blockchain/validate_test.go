@@ -39,10 +39,10 @@ func TestCheckConnectBlock(t *testing.T) {
 // TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
 // as expected.
 func TestCheckBlockSanity(t *testing.T) {
-	powLimit := chaincfg.MainNetParams.PowLimit
+	params := &chaincfg.MainNetParams
 	block := cttutil.NewBlock(&Block100000)
 	timeSource := blockchain.NewMedianTime()
-	err := blockchain.CheckBlockSanity(block, powLimit, timeSource)
+	err := blockchain.CheckBlockSanity(block, params, timeSource, nil)
 	if err != nil {
 		t.Errorf("CheckBlockSanity: %v", err)
 	}
@@ -51,7 +51,7 @@ func TestCheckBlockSanity(t *testing.T) {
 	// second fails.
 	timestamp := block.MsgBlock().Header.Timestamp
 	block.MsgBlock().Header.Timestamp = timestamp.Add(time.Nanosecond)
-	err = blockchain.CheckBlockSanity(block, powLimit, timeSource)
+	err = blockchain.CheckBlockSanity(block, params, timeSource, nil)
 	if err == nil {
 		t.Errorf("CheckBlockSanity: error is nil when it shouldn't be")
 	}
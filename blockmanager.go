@@ -13,6 +13,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
 	"github.com/jadeblaquiere/cttd/blockchain"
 	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
@@ -46,6 +47,11 @@ const (
 	// maxRequestedTxns is the maximum number of requested transactions
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
+
+	// pendingBlockRetryInterval is the interval at which blocks waiting
+	// for their nonce headers are re-checked against the message header
+	// source.
+	pendingBlockRetryInterval = 15 * time.Second
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
@@ -77,6 +83,13 @@ type headersMsg struct {
 	peer    *serverPeer
 }
 
+// msgHdrMsg packages a bitcoin msghdr message and the peer it came from
+// together so the block handler has access to that information.
+type msgHdrMsg struct {
+	header *wire.MsgMsgHdr
+	peer   *serverPeer
+}
+
 // donePeerMsg signifies a newly disconnected peer to the block handler.
 type donePeerMsg struct {
 	peer *serverPeer
@@ -120,6 +133,11 @@ type isCurrentMsg struct {
 	reply chan bool
 }
 
+// retryPendingBlocksMsg is a message type to be sent across the message
+// channel for requesting the blocks waiting for their nonce headers are
+// re-checked.
+type retryPendingBlocksMsg struct{}
+
 // pauseMsg is a message type to be sent across the message channel for
 // pausing the block manager.  This effectively provides the caller with
 // exclusive access over the manager until a receive is performed on the
@@ -166,6 +184,7 @@ type blockManager struct {
 	server            *server
 	started           int32
 	shutdown          int32
+	retryQueued       int32
 	chain             *blockchain.BlockChain
 	rejectedTxns      map[chainhash.Hash]struct{}
 	requestedTxns     map[chainhash.Hash]struct{}
@@ -180,6 +199,11 @@ type blockManager struct {
 	wg                sync.WaitGroup
 	quit              chan struct{}
 
+	// pendingBlockPeers tracks the peers which relayed the blocks waiting
+	// for their nonce headers so they can be notified once the blocks are
+	// processed.
+	pendingBlockPeers map[chainhash.Hash]*serverPeer
+
 	// The following fields are used for headers-first mode.
 	headersFirstMode bool
 	headerList       *list.List
@@ -283,8 +307,11 @@ func (b *blockManager) startSync(peers *list.List) {
 		}
 
 		// TODO(davec): Use a better algorithm to choose the best peer.
-		// For now, just pick the first available candidate.
-		bestPeer = sp
+		// For now, just pick the first available candidate, preferring
+		// one which can serve the nonce headers of the blocks it sends.
+		if bestPeer == nil || (!hasMsgStore(bestPeer) && hasMsgStore(sp)) {
+			bestPeer = sp
+		}
 	}
 
 	// Start syncing from the best peer if one was selected.
@@ -340,8 +367,16 @@ func (b *blockManager) startSync(peers *list.List) {
 	}
 }
 
+// hasMsgStore returns whether or not the peer advertises a message store which
+// can serve the ciphrtxt message headers used as block nonces.
+func hasMsgStore(sp *serverPeer) bool {
+	return sp.Services()&wire.SFNodeMsgStore == wire.SFNodeMsgStore
+}
+
 // isSyncCandidate returns whether or not the peer is a candidate to consider
-// syncing from.
+// syncing from.  Full nodes without a message store remain candidates so nodes
+// can still sync when none is available, however startSync prefers those that
+// advertise one.
 func (b *blockManager) isSyncCandidate(sp *serverPeer) bool {
 	// Typically a peer is not a candidate for sync if it's not a full node,
 	// however regression test is special in that the regression tool is
@@ -571,6 +606,26 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	// handling, etc.
 	isOrphan, err := b.chain.ProcessBlock(bmsg.block, behaviorFlags)
 	if err != nil {
+		// Blocks which use a nonce header that is not yet known are
+		// held by the chain until the header arrives, so the peer is
+		// not at fault and is not sent a reject message.
+		if isMissingNonceHeaderErr(err) {
+			bmgrLog.Debugf("Deferred block %v from %s: %v", blockHash,
+				bmsg.peer, err)
+			b.pendingBlockPeers[*blockHash] = bmsg.peer
+			b.requestMissingNonceHeaders(bmsg.peer,
+				&bmsg.block.MsgBlock().Header)
+
+			// Keep downloading the blocks of the header list while
+			// the block waits for its nonce headers.  A deferred
+			// checkpoint block finishes the round of headers once
+			// it is connected by processPendingBlocks.
+			if !isCheckpointBlock {
+				b.continueHeadersFirst(bmsg.peer, blockHash, false)
+			}
+			return
+		}
+
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
 		// it as such.  Otherwise, something really did go wrong, so log
@@ -665,17 +720,31 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// Update the block height for this peer. But only send a message to
-	// the server for updating peer heights if this is an orphan or our
-	// chain is "current". This avoids sending a spammy amount of messages
-	// if we're syncing the chain from scratch.
+	// Update the block height for this peer.
 	if blkHashUpdate != nil && heightUpdate != 0 {
-		bmsg.peer.UpdateLastBlockHeight(heightUpdate)
-		if isOrphan || b.current() {
-			go b.server.UpdatePeerHeights(blkHashUpdate, int32(heightUpdate), bmsg.peer)
-		}
+		b.updatePeerHeight(bmsg.peer, blkHashUpdate, heightUpdate,
+			isOrphan)
 	}
 
+	b.continueHeadersFirst(bmsg.peer, blockHash, isCheckpointBlock)
+}
+
+// updatePeerHeight updates the latest block height of the passed peer.  Only
+// send a message to the server for updating peer heights if the block is an
+// orphan or our chain is "current".  This avoids sending a spammy amount of
+// messages if we're syncing the chain from scratch.
+func (b *blockManager) updatePeerHeight(peer *serverPeer, hash *chainhash.Hash, height int32, isOrphan bool) {
+	peer.UpdateLastBlockHeight(height)
+	if isOrphan || b.current() {
+		go b.server.UpdatePeerHeights(hash, height, peer)
+	}
+}
+
+// continueHeadersFirst continues the headers-first download after the passed
+// block from the passed peer has been processed.  It requests more blocks from
+// the header list when the request queue is getting short, or the next round
+// of headers when the block is the checkpoint the headers lead up to.
+func (b *blockManager) continueHeadersFirst(peer *serverPeer, blockHash *chainhash.Hash, isCheckpointBlock bool) {
 	// Nothing more to do if we aren't in headers-first mode.
 	if !b.headersFirstMode {
 		return
@@ -686,7 +755,7 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	// getting short.
 	if !isCheckpointBlock {
 		if b.startHeader != nil &&
-			len(bmsg.peer.requestedBlocks) < minInFlightBlocks {
+			len(peer.requestedBlocks) < minInFlightBlocks {
 			b.fetchHeaderBlocks()
 		}
 		return
@@ -701,15 +770,15 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	b.nextCheckpoint = b.findNextHeaderCheckpoint(prevHeight)
 	if b.nextCheckpoint != nil {
 		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := bmsg.peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
+		err := peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
 		if err != nil {
 			bmgrLog.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", bmsg.peer.Addr(), err)
+				"peer %s: %v", peer.Addr(), err)
 			return
 		}
 		bmgrLog.Infof("Downloading headers for blocks %d to %d from "+
 			"peer %s", prevHeight+1, b.nextCheckpoint.Height,
-			b.syncPeer.Addr())
+			peer.Addr())
 		return
 	}
 
@@ -720,10 +789,10 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	b.headerList.Init()
 	bmgrLog.Infof("Reached the final checkpoint -- switching to normal mode")
 	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = bmsg.peer.PushGetBlocksMsg(locator, &zeroHash)
+	err := peer.PushGetBlocksMsg(locator, &zeroHash)
 	if err != nil {
 		bmgrLog.Warnf("Failed to send getblocks message to peer %s: %v",
-			bmsg.peer.Addr(), err)
+			peer.Addr(), err)
 		return
 	}
 }
@@ -1087,6 +1156,188 @@ func (b *blockManager) limitMap(m map[chainhash.Hash]struct{}, limit int) {
 	}
 }
 
+// isMissingNonceHeaderErr returns whether or not the passed error is a rule
+// error which indicates a block uses a nonce header that is not yet known.
+func isMissingNonceHeaderErr(err error) bool {
+	rerr, ok := err.(blockchain.RuleError)
+	return ok && rerr.ErrorCode == blockchain.ErrMissingNonceHeader
+}
+
+// requestMissingNonceHeaders requests the nonce headers of the passed block
+// header which are not yet known to the message header source from the peer
+// that relayed the block.  Headers which were already requested from the peer
+// are not requested again.
+func (b *blockManager) requestMissingNonceHeaders(sp *serverPeer, header *wire.BlockHeader) {
+	if sp.ProtocolVersion() < wire.MsgHdrVersion {
+		return
+	}
+
+	gmsg := wire.NewMsgGetMsgHdr()
+	for _, ikey := range b.chain.MissingNonceHeaders(header) {
+		if _, exists := sp.requestedMsgHdrs[string(ikey)]; exists {
+			continue
+		}
+		if err := gmsg.AddIKey(ikey); err != nil {
+			bmgrLog.Debugf("Unable to request message header %x from "+
+				"%s: %v", ikey, sp, err)
+			continue
+		}
+		sp.requestedMsgHdrs[string(ikey)] = struct{}{}
+	}
+	if len(gmsg.IKeys) > 0 {
+		sp.QueueMessage(gmsg, nil)
+	}
+}
+
+// handleMsgHdrMsg handles message headers sent by peers in response to a
+// getmsghdr request.  Requested headers are inserted into the message header
+// source, which in turn causes the blocks waiting for them to be retried.
+func (b *blockManager) handleMsgHdrMsg(hmsg *msgHdrMsg) {
+	peer := hmsg.peer
+	h := ciphrtxt.ImportBinaryHeaderV2(hmsg.header.Header[:])
+	if h == nil {
+		peer.addBanScore(0, 20, "invalid msghdr")
+		return
+	}
+
+	// Ignore headers which were not requested from the peer since they
+	// are not needed to validate any pending block.
+	ikey := h.IKey()
+	if _, exists := peer.requestedMsgHdrs[string(ikey)]; !exists {
+		bmgrLog.Debugf("Ignoring unrequested message header %x from %s",
+			ikey, peer)
+		return
+	}
+	delete(peer.requestedMsgHdrs, string(ikey))
+
+	headerCache := b.server.headerCache
+	if headerCache == nil {
+		return
+	}
+	if _, err := headerCache.Insert(h); err != nil {
+		bmgrLog.Warnf("Failed to insert message header %x from %s: %v",
+			ikey, peer, err)
+		return
+	}
+	bmgrLog.Debugf("Received message header %x from %s", ikey, peer)
+}
+
+// processPendingBlocks has the chain re-check the blocks waiting for their
+// nonce headers and handles the outcome of the blocks which were processed or
+// dropped as a result.  Connected blocks are handled like the blocks in
+// handleBlockMsg, including the headers-first download steps which were put
+// off while they were waiting.
+func (b *blockManager) processPendingBlocks() {
+	for _, result := range b.chain.ProcessPendingBlocks() {
+		blockHash := result.Block.Hash()
+		peer := b.pendingBlockPeers[*blockHash]
+		delete(b.pendingBlockPeers, *blockHash)
+		if peer != nil {
+			// The nonce headers of the block are no longer needed, so
+			// stop expecting them from the peer.
+			ikeyA, ikeyB, err := blockchain.NonceHeaderIKeys(
+				&result.Block.MsgBlock().Header)
+			if err == nil {
+				delete(peer.requestedMsgHdrs, string(ikeyA))
+				delete(peer.requestedMsgHdrs, string(ikeyB))
+			}
+			if !peer.Connected() {
+				peer = nil
+			}
+		}
+
+		if result.Err != nil {
+			// Blocks dropped because their nonce headers never
+			// became known are not the fault of the relaying peer.
+			if isMissingNonceHeaderErr(result.Err) {
+				bmgrLog.Infof("Dropped pending block %v: %v",
+					blockHash, result.Err)
+				continue
+			}
+
+			if _, ok := result.Err.(blockchain.RuleError); ok {
+				bmgrLog.Infof("Rejected pending block %v: %v",
+					blockHash, result.Err)
+			} else {
+				bmgrLog.Errorf("Failed to process pending block "+
+					"%v: %v", blockHash, result.Err)
+			}
+			if dbErr, ok := result.Err.(database.Error); ok &&
+				dbErr.ErrorCode == database.ErrCorruption {
+				panic(dbErr)
+			}
+
+			if peer != nil {
+				code, reason := mempool.ErrToRejectErr(result.Err)
+				peer.PushRejectMsg(wire.CmdBlock, code, reason,
+					blockHash, false)
+			}
+			continue
+		}
+
+		// Request the parents for the orphan block from the peer that
+		// sent it and update the height of the peer from the height
+		// the block claims in its coinbase.
+		if result.IsOrphan {
+			if peer == nil {
+				continue
+			}
+			header := &result.Block.MsgBlock().Header
+			if blockchain.ShouldHaveSerializedBlockHeight(header) {
+				coinbaseTx := result.Block.Transactions()[0]
+				cbHeight, err := blockchain.ExtractCoinbaseHeight(
+					coinbaseTx)
+				if err == nil && cbHeight != 0 {
+					b.updatePeerHeight(peer, blockHash,
+						int32(cbHeight), true)
+				}
+			}
+			orphanRoot := b.chain.GetOrphanRoot(blockHash)
+			locator, err := b.chain.LatestBlockLocator()
+			if err != nil {
+				bmgrLog.Warnf("Failed to get block locator for the "+
+					"latest block: %v", err)
+				continue
+			}
+			peer.PushGetBlocksMsg(locator, orphanRoot)
+			continue
+		}
+
+		b.progressLogger.LogBlockHeight(result.Block)
+		best := b.chain.BestSnapshot()
+		b.updateChainState(best.Hash, best.Height)
+		rpcServer := b.server.rpcServer
+		if rpcServer != nil {
+			rpcServer.gbtWorkState.NotifyBlockConnected(blockHash)
+		}
+
+		// Continue the headers-first download with the peer the block
+		// came from, or the sync peer when it has disconnected, since
+		// the download stalls when the block was the checkpoint or one
+		// of the last blocks in flight.
+		if peer != nil && best.Height != 0 {
+			b.updatePeerHeight(peer, best.Hash, best.Height, false)
+		}
+		if peer == nil {
+			peer = b.syncPeer
+		}
+		if peer != nil {
+			isCheckpointBlock := b.nextCheckpoint != nil &&
+				blockHash.IsEqual(b.nextCheckpoint.Hash)
+			b.continueHeadersFirst(peer, blockHash,
+				isCheckpointBlock)
+		}
+	}
+
+	// Forget the peers of blocks the chain no longer holds, such as those
+	// evicted to make room for newer ones.
+	for hash := range b.pendingBlockPeers {
+		if !b.chain.IsKnownPending(&hash) {
+			delete(b.pendingBlockPeers, hash)
+		}
+	}
+}
+
 // blockHandler is the main handler for the block manager.  It must be run
 // as a goroutine.  It processes block and inv messages in a separate goroutine
 // from the peer handlers so the block (MsgBlock) messages are handled by a
@@ -1095,6 +1346,8 @@ func (b *blockManager) limitMap(m map[chainhash.Hash]struct{}, limit int) {
 // the fetching should proceed.
 func (b *blockManager) blockHandler() {
 	candidatePeers := list.New()
+	pendingTicker := time.NewTicker(pendingBlockRetryInterval)
+	defer pendingTicker.Stop()
 out:
 	for {
 		select {
@@ -1117,6 +1370,9 @@ out:
 			case *headersMsg:
 				b.handleHeadersMsg(msg)
 
+			case *msgHdrMsg:
+				b.handleMsgHdrMsg(msg)
+
 			case *donePeerMsg:
 				b.handleDonePeerMsg(candidatePeers, msg.peer)
 
@@ -1155,6 +1411,10 @@ out:
 			case isCurrentMsg:
 				msg.reply <- b.current()
 
+			case retryPendingBlocksMsg:
+				atomic.StoreInt32(&b.retryQueued, 0)
+				b.processPendingBlocks()
+
 			case pauseMsg:
 				// Wait until the sender unpauses the manager.
 				<-msg.unpause
@@ -1164,6 +1424,9 @@ out:
 					"handler: %T", msg)
 			}
 
+		case <-pendingTicker.C:
+			b.processPendingBlocks()
+
 		case <-b.quit:
 			break out
 		}
@@ -1315,6 +1578,18 @@ func (b *blockManager) QueueHeaders(headers *wire.MsgHeaders, sp *serverPeer) {
 	b.msgChan <- &headersMsg{headers: headers, peer: sp}
 }
 
+// QueueMsgHdr adds the passed msghdr message and peer to the block handling
+// queue.
+func (b *blockManager) QueueMsgHdr(header *wire.MsgMsgHdr, sp *serverPeer) {
+	// No channel handling here because peers do not need to block on
+	// msghdr messages.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		return
+	}
+
+	b.msgChan <- &msgHdrMsg{header: header, peer: sp}
+}
+
 // DonePeer informs the blockmanager that a peer has disconnected.
 func (b *blockManager) DonePeer(sp *serverPeer) {
 	// Ignore if we are shutting down.
@@ -1377,6 +1652,28 @@ func (b *blockManager) IsCurrent() bool {
 	return <-reply
 }
 
+// RetryPendingBlocks requests the blocks waiting for their nonce headers are
+// re-checked.  It is called whenever a message header is added to the message
+// header source.  It never blocks since it is also called from the block
+// handler itself.  A request is not queued while a previous one is still
+// waiting to be handled, and one which does not fit in the message channel is
+// dropped since the pending blocks are also re-checked periodically.
+func (b *blockManager) RetryPendingBlocks() {
+	// Don't bother if we're shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&b.retryQueued, 0, 1) {
+		return
+	}
+	select {
+	case b.msgChan <- retryPendingBlocksMsg{}:
+	default:
+		atomic.StoreInt32(&b.retryQueued, 0)
+	}
+}
+
 // Pause pauses the block manager until the returned channel is closed.
 //
 // Note that while paused, all peer and block processing is halted.  The
@@ -1391,14 +1688,15 @@ func (b *blockManager) Pause() chan<- struct{} {
 // Use Start to begin processing asynchronous block and inv updates.
 func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockManager, error) {
 	bm := blockManager{
-		server:          s,
-		rejectedTxns:    make(map[chainhash.Hash]struct{}),
-		requestedTxns:   make(map[chainhash.Hash]struct{}),
-		requestedBlocks: make(map[chainhash.Hash]struct{}),
-		progressLogger:  newBlockProgressLogger("Processed", bmgrLog),
-		msgChan:         make(chan interface{}, cfg.MaxPeers*3),
-		headerList:      list.New(),
-		quit:            make(chan struct{}),
+		server:            s,
+		rejectedTxns:      make(map[chainhash.Hash]struct{}),
+		requestedTxns:     make(map[chainhash.Hash]struct{}),
+		requestedBlocks:   make(map[chainhash.Hash]struct{}),
+		pendingBlockPeers: make(map[chainhash.Hash]*serverPeer),
+		progressLogger:    newBlockProgressLogger("Processed", bmgrLog),
+		msgChan:           make(chan interface{}, cfg.MaxPeers*3),
+		headerList:        list.New(),
+		quit:              make(chan struct{}),
 	}
 
 	// Create a new block chain instance with the appropriate configuration.
@@ -0,0 +1,106 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+// scalarBaseMultJacobian sets (x, y, z) to k*G in Jacobian coordinates where G
+// is the base point of the group and k is a 32-byte big endian integer.  It
+// adds up the precomputed byte points for each 8-bit window of k in the same
+// way as ScalarBaseMult.
+func (curve *KoblitzCurve) scalarBaseMultJacobian(k *[32]byte, x, y, z *fieldVal) {
+	// Point Q = ∞ (point at infinity).
+	x.Zero()
+	y.Zero()
+	z.Zero()
+	for i, byteVal := range k {
+		p := curve.bytePoints[i][byteVal]
+		curve.addJacobian(x, y, z, &p[0], &p[1], &p[2], x, y, z)
+	}
+}
+
+// putUncompressedJacobian converts the Jacobian point (x, y, z) to affine
+// coordinates using the passed inverse of z and writes it to out in the
+// uncompressed public key format.  The point at infinity, which has a z value
+// of zero and therefore an inverse of zero, is written with zero coordinates.
+func putUncompressedJacobian(x, y, zInv *fieldVal, out *[PubKeyBytesLenUncompressed]byte) {
+	var tempZ, affineX, affineY fieldVal
+	tempZ.SquareVal(zInv)               // tempZ = Z^-2
+	affineX.Set(x).Mul(&tempZ)          // X = X/Z^2 (mag: 1)
+	affineY.Set(y).Mul(tempZ.Mul(zInv)) // Y = Y/Z^3 (mag: 1)
+	affineX.Normalize()
+	affineY.Normalize()
+
+	var b [32]byte
+	out[0] = pubkeyUncompressed
+	affineX.PutBytes(&b)
+	copy(out[1:33], b[:])
+	affineY.PutBytes(&b)
+	copy(out[33:], b[:])
+}
+
+// ScalarBaseMultUncompressed computes k*G where G is the base point of the
+// group and k is a 32-byte big endian integer and writes the result to out in
+// the uncompressed public key format.
+//
+// The result is identical to serializing the public key returned by
+// PrivKeyFromBytes for k with SerializeUncompressed, including the zero
+// coordinates of the point at infinity when k is a multiple of the group
+// order.  However, it works directly with field values and the precomputed
+// base point table, so it does not perform any allocations.
+func (curve *KoblitzCurve) ScalarBaseMultUncompressed(k *[32]byte, out *[PubKeyBytesLenUncompressed]byte) {
+	var x, y, z, zInv fieldVal
+	curve.scalarBaseMultJacobian(k, &x, &y, &z)
+	zInv.Set(&z).Inverse()
+	putUncompressedJacobian(&x, &y, &zInv, out)
+}
+
+// BatchScalarBaseMultUncompressed computes k*G for each of the passed scalars
+// and writes the results to the corresponding entries of out in the same way
+// as ScalarBaseMultUncompressed.  The out slice must be at least as long as the
+// ks slice.
+//
+// Converting a point to affine coordinates requires a field inversion, which is
+// by far the most expensive part of the conversion.  The points are converted
+// with a single shared inversion instead by means of Montgomery's trick, which
+// makes this considerably faster than computing them one at a time when there
+// are many scalars.
+func (curve *KoblitzCurve) BatchScalarBaseMultUncompressed(ks [][32]byte, out [][PubKeyBytesLenUncompressed]byte) {
+	// Each point houses the x, y and z values of a result along with the
+	// product of the z values of all of the preceding results.
+	points := make([][4]fieldVal, len(ks))
+	var acc fieldVal
+	acc.SetInt(1)
+	for i := range ks {
+		p := &points[i]
+		curve.scalarBaseMultJacobian(&ks[i], &p[0], &p[1], &p[2])
+		p[3].Set(&acc)
+
+		// The point at infinity does not have an inverse, so leave it
+		// out of the product.
+		if p[2].Normalize().IsZero() {
+			continue
+		}
+		acc.Mul(&p[2])
+	}
+
+	// Invert the product of all of the z values and then work backwards to
+	// obtain the inverse of each individual z value.  The inverse of the
+	// product of the z values up to and including each point multiplied by
+	// the product of the preceding ones is the inverse of its z value, and
+	// multiplying by its z value yields the inverse of the product of the
+	// preceding z values for the next iteration.
+	acc.Inverse()
+	var zInv fieldVal
+	for i := len(ks) - 1; i >= 0; i-- {
+		p := &points[i]
+		if p[2].IsZero() {
+			zInv.Zero()
+			putUncompressedJacobian(&p[0], &p[1], &zInv, &out[i])
+			continue
+		}
+		zInv.Set(&acc).Mul(&p[3])
+		acc.Mul(&p[2])
+		putUncompressedJacobian(&p[0], &p[1], &zInv, &out[i])
+	}
+}
@@ -7,6 +7,7 @@
 package btcec_test
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
@@ -847,3 +848,50 @@ func TestVectors(t *testing.T) {
 		}
 	}
 }
+
+// TestScalarBaseMultUncompressed ensures the allocation-free and batched
+// scalar base multiplications produce the same serialized points as
+// serializing the public key returned by PrivKeyFromBytes.
+func TestScalarBaseMultUncompressed(t *testing.T) {
+	s256 := btcec.S256()
+
+	// Include the edge cases of zero and the group order, which both
+	// result in the point at infinity, along with several random scalars.
+	var ks [][32]byte
+	for _, k := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Sub(s256.N, big.NewInt(1)),
+		s256.N,
+	} {
+		var kb [32]byte
+		b := k.Bytes()
+		copy(kb[32-len(b):], b)
+		ks = append(ks, kb)
+	}
+	for i := 0; i < 64; i++ {
+		var kb [32]byte
+		if _, err := rand.Read(kb[:]); err != nil {
+			t.Fatalf("failed to read random data: %v", err)
+		}
+		ks = append(ks, kb)
+	}
+
+	batch := make([][btcec.PubKeyBytesLenUncompressed]byte, len(ks))
+	s256.BatchScalarBaseMultUncompressed(ks, batch)
+	for i := range ks {
+		_, pub := btcec.PrivKeyFromBytes(s256, ks[i][:])
+		want := pub.SerializeUncompressed()
+
+		var got [btcec.PubKeyBytesLenUncompressed]byte
+		s256.ScalarBaseMultUncompressed(&ks[i], &got)
+		if !bytes.Equal(got[:], want) {
+			t.Errorf("ScalarBaseMultUncompressed #%d (%x): got %x, "+
+				"want %x", i, ks[i], got, want)
+		}
+		if !bytes.Equal(batch[i][:], want) {
+			t.Errorf("BatchScalarBaseMultUncompressed #%d (%x): got "+
+				"%x, want %x", i, ks[i], batch[i], want)
+		}
+	}
+}
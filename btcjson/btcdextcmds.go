@@ -71,6 +71,20 @@ func NewGenerateCmd(numBlocks uint32) *GenerateCmd {
 	}
 }
 
+// GetAccessKeyCmd defines the getaccesskey JSON-RPC command.  This command is
+// not a standard Bitcoin command.  It is an extension for cttd.
+type GetAccessKeyCmd struct {
+	PubKey string
+}
+
+// NewGetAccessKeyCmd returns a new instance which can be used to issue a
+// getaccesskey JSON-RPC command.
+func NewGetAccessKeyCmd(pubKey string) *GetAccessKeyCmd {
+	return &GetAccessKeyCmd{
+		PubKey: pubKey,
+	}
+}
+
 // GetBestBlockCmd defines the getbestblock JSON-RPC command.
 type GetBestBlockCmd struct{}
 
@@ -80,6 +94,21 @@ func NewGetBestBlockCmd() *GetBestBlockCmd {
 	return &GetBestBlockCmd{}
 }
 
+// GetBlocksByNonceHeaderCmd defines the getblocksbynonceheader JSON-RPC
+// command.  This command is not a standard Bitcoin command.  It is an extension
+// for cttd.
+type GetBlocksByNonceHeaderCmd struct {
+	IKey string
+}
+
+// NewGetBlocksByNonceHeaderCmd returns a new instance which can be used to
+// issue a getblocksbynonceheader JSON-RPC command.
+func NewGetBlocksByNonceHeaderCmd(ikey string) *GetBlocksByNonceHeaderCmd {
+	return &GetBlocksByNonceHeaderCmd{
+		IKey: ikey,
+	}
+}
+
 // GetCurrentNetCmd defines the getcurrentnet JSON-RPC command.
 type GetCurrentNetCmd struct{}
 
@@ -89,6 +118,83 @@ func NewGetCurrentNetCmd() *GetCurrentNetCmd {
 	return &GetCurrentNetCmd{}
 }
 
+// GetDirectoryEntryCmd defines the getdirectoryentry JSON-RPC command.  This
+// command is not a standard Bitcoin command.  It is an extension for cttd.
+type GetDirectoryEntryCmd struct {
+	Name   string
+	PubKey *string
+}
+
+// NewGetDirectoryEntryCmd returns a new instance which can be used to issue a
+// getdirectoryentry JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetDirectoryEntryCmd(name string, pubKey *string) *GetDirectoryEntryCmd {
+	return &GetDirectoryEntryCmd{
+		Name:   name,
+		PubKey: pubKey,
+	}
+}
+
+// ListAccessKeysCmd defines the listaccesskeys JSON-RPC command.  This command
+// is not a standard Bitcoin command.  It is an extension for cttd.
+type ListAccessKeysCmd struct {
+	ValidAt *int64
+}
+
+// NewListAccessKeysCmd returns a new instance which can be used to issue a
+// listaccesskeys JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewListAccessKeysCmd(validAt *int64) *ListAccessKeysCmd {
+	return &ListAccessKeysCmd{
+		ValidAt: validAt,
+	}
+}
+
+// ListDirectoryEntriesCmd defines the listdirectoryentries JSON-RPC command.
+// This command is not a standard Bitcoin command.  It is an extension for
+// cttd.
+type ListDirectoryEntriesCmd struct {
+	PubKey         string
+	IncludeExpired *bool `jsonrpcdefault:"false"`
+}
+
+// NewListDirectoryEntriesCmd returns a new instance which can be used to issue
+// a listdirectoryentries JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewListDirectoryEntriesCmd(pubKey string, includeExpired *bool) *ListDirectoryEntriesCmd {
+	return &ListDirectoryEntriesCmd{
+		PubKey:         pubKey,
+		IncludeExpired: includeExpired,
+	}
+}
+
+// SearchDirectoryCmd defines the searchdirectory JSON-RPC command.  This
+// command is not a standard Bitcoin command.  It is an extension for cttd.
+type SearchDirectoryCmd struct {
+	Prefix         string
+	Count          *int  `jsonrpcdefault:"100"`
+	IncludeExpired *bool `jsonrpcdefault:"false"`
+}
+
+// NewSearchDirectoryCmd returns a new instance which can be used to issue a
+// searchdirectory JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSearchDirectoryCmd(prefix string, count *int, includeExpired *bool) *SearchDirectoryCmd {
+	return &SearchDirectoryCmd{
+		Prefix:         prefix,
+		Count:          count,
+		IncludeExpired: includeExpired,
+	}
+}
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
@@ -96,6 +202,12 @@ func init() {
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("getaccesskey", (*GetAccessKeyCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
+	MustRegisterCmd("getblocksbynonceheader", (*GetBlocksByNonceHeaderCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
+	MustRegisterCmd("getdirectoryentry", (*GetDirectoryEntryCmd)(nil), flags)
+	MustRegisterCmd("listaccesskeys", (*ListAccessKeysCmd)(nil), flags)
+	MustRegisterCmd("listdirectoryentries", (*ListDirectoryEntriesCmd)(nil), flags)
+	MustRegisterCmd("searchdirectory", (*SearchDirectoryCmd)(nil), flags)
 }
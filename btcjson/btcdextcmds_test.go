@@ -113,6 +113,19 @@ func TestCttdExtCmds(t *testing.T) {
 				NumBlocks: 1,
 			},
 		},
+		{
+			name: "getaccesskey",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaccesskey", "02a1b2")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAccessKeyCmd("02a1b2")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaccesskey","params":["02a1b2"],"id":1}`,
+			unmarshalled: &btcjson.GetAccessKeyCmd{
+				PubKey: "02a1b2",
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -124,6 +137,19 @@ func TestCttdExtCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getbestblock","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetBestBlockCmd{},
 		},
+		{
+			name: "getblocksbynonceheader",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocksbynonceheader", "02a1b2")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlocksByNonceHeaderCmd("02a1b2")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocksbynonceheader","params":["02a1b2"],"id":1}`,
+			unmarshalled: &btcjson.GetBlocksByNonceHeaderCmd{
+				IKey: "02a1b2",
+			},
+		},
 		{
 			name: "getcurrentnet",
 			newCmd: func() (interface{}, error) {
@@ -135,6 +161,121 @@ func TestCttdExtCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getcurrentnet","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetCurrentNetCmd{},
 		},
+		{
+			name: "getdirectoryentry",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdirectoryentry", "alice")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDirectoryEntryCmd("alice", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getdirectoryentry","params":["alice"],"id":1}`,
+			unmarshalled: &btcjson.GetDirectoryEntryCmd{
+				Name:   "alice",
+				PubKey: nil,
+			},
+		},
+		{
+			name: "getdirectoryentry optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdirectoryentry", "alice", "02a1b2")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDirectoryEntryCmd("alice",
+					btcjson.String("02a1b2"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getdirectoryentry","params":["alice","02a1b2"],"id":1}`,
+			unmarshalled: &btcjson.GetDirectoryEntryCmd{
+				Name:   "alice",
+				PubKey: btcjson.String("02a1b2"),
+			},
+		},
+		{
+			name: "listaccesskeys",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listaccesskeys")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListAccessKeysCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listaccesskeys","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListAccessKeysCmd{
+				ValidAt: nil,
+			},
+		},
+		{
+			name: "listaccesskeys optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listaccesskeys", 1475000000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListAccessKeysCmd(btcjson.Int64(1475000000))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listaccesskeys","params":[1475000000],"id":1}`,
+			unmarshalled: &btcjson.ListAccessKeysCmd{
+				ValidAt: btcjson.Int64(1475000000),
+			},
+		},
+		{
+			name: "listdirectoryentries",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listdirectoryentries", "02a1b2")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListDirectoryEntriesCmd("02a1b2", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listdirectoryentries","params":["02a1b2"],"id":1}`,
+			unmarshalled: &btcjson.ListDirectoryEntriesCmd{
+				PubKey:         "02a1b2",
+				IncludeExpired: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "listdirectoryentries optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listdirectoryentries", "02a1b2", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListDirectoryEntriesCmd("02a1b2",
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listdirectoryentries","params":["02a1b2",true],"id":1}`,
+			unmarshalled: &btcjson.ListDirectoryEntriesCmd{
+				PubKey:         "02a1b2",
+				IncludeExpired: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "searchdirectory",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("searchdirectory", "al")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSearchDirectoryCmd("al", nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"searchdirectory","params":["al"],"id":1}`,
+			unmarshalled: &btcjson.SearchDirectoryCmd{
+				Prefix:         "al",
+				Count:          btcjson.Int(100),
+				IncludeExpired: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "searchdirectory optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("searchdirectory", "al", 10, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSearchDirectoryCmd("al",
+					btcjson.Int(10), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"searchdirectory","params":["al",10,true],"id":1}`,
+			unmarshalled: &btcjson.SearchDirectoryCmd{
+				Prefix:         "al",
+				Count:          btcjson.Int(10),
+				IncludeExpired: btcjson.Bool(true),
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,60 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// NOTE: This file is intended to house the RPC result types that are returned
+// by a chain server with cttd extensions.
+
+package btcjson
+
+// AccessKeyResult models the data of a network access key registration
+// returned by the getaccesskey and listaccesskeys commands.
+type AccessKeyResult struct {
+	PubKey      string `json:"pubkey"`
+	Expire      int64  `json:"expire"`
+	Signature   string `json:"signature"`
+	Valid       bool   `json:"valid"`
+	TxID        string `json:"txid"`
+	Vout        uint32 `json:"vout"`
+	BlockHash   string `json:"blockhash"`
+	BlockHeight int32  `json:"blockheight"`
+}
+
+// DirectoryEntryResult models the decoded fields of a directory entry posted
+// by an OP_POSTDIRECTORY script as returned by the decodescript command and
+// the verbose transaction output of several commands.
+type DirectoryEntryResult struct {
+	Version   uint8  `json:"version"`
+	Expire    int64  `json:"expire"`
+	PubKey    string `json:"pubkey"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Signature string `json:"signature"`
+	Valid     bool   `json:"valid"`
+}
+
+// DirectoryIndexEntryResult models the data of a directory entry stored in the
+// directory index as returned by the getdirectoryentry, listdirectoryentries
+// and searchdirectory commands.
+type DirectoryIndexEntryResult struct {
+	Version     uint8  `json:"version"`
+	Expire      int64  `json:"expire"`
+	PubKey      string `json:"pubkey"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Signature   string `json:"signature"`
+	Expired     bool   `json:"expired"`
+	TxID        string `json:"txid"`
+	Vout        uint32 `json:"vout"`
+	BlockHash   string `json:"blockhash"`
+	BlockHeight int32  `json:"blockheight"`
+}
+
+// NonceHeaderBlockResult models the data of a block which uses a message header
+// as one of its nonces as returned by the getblocksbynonceheader command.
+type NonceHeaderBlockResult struct {
+	BlockHash   string `json:"blockhash"`
+	BlockHeight int32  `json:"blockheight"`
+	NonceA      bool   `json:"noncea"`
+	NonceB      bool   `json:"nonceb"`
+}
@@ -6,43 +6,54 @@ package btcjson
 
 import "encoding/json"
 
+// NonceHeaderResult models the decoded message header used as one of the
+// nonces of a block in the getblock and getblockheader results when the verbose
+// flag is set.
+type NonceHeaderResult struct {
+	Hex     string `json:"hex"`
+	Version string `json:"version"`
+	IKey    string `json:"ikey"`
+	Expire  int64  `json:"expire"`
+	Present bool   `json:"present"`
+}
+
 // GetBlockHeaderVerboseResult models the data from the getblockheader command when
 // the verbose flag is set.  When the verbose flag is not set, getblockheader
 // returns a hex-encoded string.
 type GetBlockHeaderVerboseResult struct {
-	Hash          string  `json:"hash"`
-	Confirmations uint64  `json:"confirmations"`
-	Height        int32   `json:"height"`
-	Version       int32   `json:"version"`
-	MerkleRoot    string  `json:"merkleroot"`
-	Time          int64   `json:"time"`
-	NonceHeaderA         []byte        `json:"nonceheadera"`
-	NonceHeaderB         []byte        `json:"nonceheaderb"`
-	Bits          string  `json:"bits"`
-	Difficulty    float64 `json:"difficulty"`
-	PreviousHash  string  `json:"previousblockhash,omitempty"`
-	NextHash      string  `json:"nextblockhash,omitempty"`
+	Hash          string             `json:"hash"`
+	Confirmations uint64             `json:"confirmations"`
+	Height        int32              `json:"height"`
+	Version       int32              `json:"version"`
+	MerkleRoot    string             `json:"merkleroot"`
+	Time          int64              `json:"time"`
+	NonceHeaderA  *NonceHeaderResult `json:"nonceheadera"`
+	NonceHeaderB  *NonceHeaderResult `json:"nonceheaderb"`
+	Bits          string             `json:"bits"`
+	Difficulty    float64            `json:"difficulty"`
+	PreviousHash  string             `json:"previousblockhash,omitempty"`
+	NextHash      string             `json:"nextblockhash,omitempty"`
 }
 
 // GetBlockVerboseResult models the data from the getblock command when the
 // verbose flag is set.  When the verbose flag is not set, getblock returns a
 // hex-encoded string.
 type GetBlockVerboseResult struct {
-	Hash          string        `json:"hash"`
-	Confirmations uint64        `json:"confirmations"`
-	Size          int32         `json:"size"`
-	Height        int64         `json:"height"`
-	Version       int32         `json:"version"`
-	MerkleRoot    string        `json:"merkleroot"`
-	Tx            []string      `json:"tx,omitempty"`
-	RawTx         []TxRawResult `json:"rawtx,omitempty"`
-	Time          int64         `json:"time"`
-	NonceHeaderA         []byte        `json:"nonceheadera"`
-	NonceHeaderB         []byte        `json:"nonceheaderb"`
-	Bits          string        `json:"bits"`
-	Difficulty    float64       `json:"difficulty"`
-	PreviousHash  string        `json:"previousblockhash"`
-	NextHash      string        `json:"nextblockhash,omitempty"`
+	Hash          string             `json:"hash"`
+	Confirmations uint64             `json:"confirmations"`
+	Size          int32              `json:"size"`
+	Height        int64              `json:"height"`
+	Version       int32              `json:"version"`
+	MerkleRoot    string             `json:"merkleroot"`
+	Tx            []string           `json:"tx,omitempty"`
+	RawTx         []TxRawResult      `json:"rawtx,omitempty"`
+	Time          int64              `json:"time"`
+	NonceHeaderA  *NonceHeaderResult `json:"nonceheadera"`
+	NonceHeaderB  *NonceHeaderResult `json:"nonceheaderb"`
+	Bits          string             `json:"bits"`
+	Difficulty    float64            `json:"difficulty"`
+	PreviousHash  string             `json:"previousblockhash"`
+	NextHash      string             `json:"nextblockhash,omitempty"`
 }
 
 // CreateMultiSigResult models the data returned from the createmultisig
@@ -54,11 +65,12 @@ type CreateMultiSigResult struct {
 
 // DecodeScriptResult models the data returned from the decodescript command.
 type DecodeScriptResult struct {
-	Asm       string   `json:"asm"`
-	ReqSigs   int32    `json:"reqSigs,omitempty"`
-	Type      string   `json:"type"`
-	Addresses []string `json:"addresses,omitempty"`
-	P2sh      string   `json:"p2sh"`
+	Asm            string                `json:"asm"`
+	ReqSigs        int32                 `json:"reqSigs,omitempty"`
+	Type           string                `json:"type"`
+	Addresses      []string              `json:"addresses,omitempty"`
+	DirectoryEntry *DirectoryEntryResult `json:"directoryentry,omitempty"`
+	P2sh           string                `json:"p2sh"`
 }
 
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
@@ -78,13 +90,26 @@ type GetAddedNodeInfoResult struct {
 // GetBlockChainInfoResult models the data returned from the getblockchaininfo
 // command.
 type GetBlockChainInfoResult struct {
-	Chain                string  `json:"chain"`
-	Blocks               int32   `json:"blocks"`
-	Headers              int32   `json:"headers"`
-	BestBlockHash        string  `json:"bestblockhash"`
-	Difficulty           float64 `json:"difficulty"`
-	VerificationProgress float64 `json:"verificationprogress"`
-	ChainWork            string  `json:"chainwork"`
+	Chain                string                 `json:"chain"`
+	Blocks               int32                  `json:"blocks"`
+	Headers              int32                  `json:"headers"`
+	BestBlockHash        string                 `json:"bestblockhash"`
+	Difficulty           float64                `json:"difficulty"`
+	MedianTime           int64                  `json:"mediantime"`
+	VerificationProgress float64                `json:"verificationprogress,omitempty"`
+	ChainWork            string                 `json:"chainwork"`
+	SoftForks            []*SoftForkDescription `json:"softforks"`
+}
+
+// SoftForkDescription describes the current state of a consensus rule change
+// deployment which miners vote on with the version bits of their blocks as
+// defined by BIP0009.
+type SoftForkDescription struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Bit       uint8  `json:"bit"`
+	StartTime int64  `json:"starttime"`
+	Timeout   int64  `json:"timeout"`
 }
 
 // GetBlockTemplateResultTx models the transactions field of the
@@ -139,6 +164,14 @@ type GetBlockTemplateResult struct {
 	// Block proposal from BIP 0023.
 	Capabilities  []string `json:"capabilities,omitempty"`
 	RejectReasion string   `json:"reject-reason,omitempty"`
+
+	// Rule change deployments from BIP 0009.
+	Rules       []string         `json:"rules,omitempty"`
+	VbAvailable map[string]uint8 `json:"vbavailable,omitempty"`
+
+	// Hex-encoded message headers which may be used as the nonce headers
+	// of the block.
+	NonceCandidates []string `json:"noncecandidates,omitempty"`
 }
 
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
@@ -199,11 +232,12 @@ type GetRawMempoolVerboseResult struct {
 // ScriptPubKeyResult models the scriptPubKey data of a tx script.  It is
 // defined separately since it is used by multiple commands.
 type ScriptPubKeyResult struct {
-	Asm       string   `json:"asm"`
-	Hex       string   `json:"hex,omitempty"`
-	ReqSigs   int32    `json:"reqSigs,omitempty"`
-	Type      string   `json:"type"`
-	Addresses []string `json:"addresses,omitempty"`
+	Asm            string                `json:"asm"`
+	Hex            string                `json:"hex,omitempty"`
+	ReqSigs        int32                 `json:"reqSigs,omitempty"`
+	Type           string                `json:"type"`
+	Addresses      []string              `json:"addresses,omitempty"`
+	DirectoryEntry *DirectoryEntryResult `json:"directoryentry,omitempty"`
 }
 
 // GetTxOutResult models the data from the gettxout command.
@@ -347,12 +381,13 @@ type GetMiningInfoResult struct {
 	TestNet          bool    `json:"testnet"`
 }
 
-// GetWorkResult models the data from the getwork command.
+// GetWorkResult models the data from the getwork command.  The data is the
+// serialized block header without its nonce headers, which are chosen from the
+// candidates.
 type GetWorkResult struct {
-	Data     string `json:"data"`
-	Hash1    string `json:"hash1"`
-	Midstate string `json:"midstate"`
-	Target   string `json:"target"`
+	Data       string   `json:"data"`
+	Candidates []string `json:"candidates"`
+	Target     string   `json:"target"`
 }
 
 // InfoChainResult models the data returned by the chain server getinfo command.
@@ -0,0 +1,79 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainhash
+
+import (
+	"testing"
+
+	"github.com/btcsuite/fastsha256"
+	"github.com/jadeblaquiere/cttd/btcec"
+)
+
+// benchHeaderLen is the length of a serialized block header, which consists of
+// the version, previous block hash, merkle root, timestamp, difficulty bits and
+// the two ciphrtxt message headers used as nonces.
+const benchHeaderLen = 4 + HashSize + HashSize + 4 + 4 + 2*192
+
+// benchBatchSize is the number of headers hashed per batch in the batch
+// benchmarks, which is on the order of what the CPU miner hashes at once.
+const benchBatchSize = 64
+
+// benchHeaders returns the passed number of distinct fake serialized block
+// headers.
+func benchHeaders(n int) [][]byte {
+	headers := make([][]byte, n)
+	for i := range headers {
+		headers[i] = make([]byte, benchHeaderLen)
+		headers[i][0] = byte(i)
+		headers[i][1] = byte(i >> 8)
+	}
+	return headers
+}
+
+// shaMulSha256Reference calculates sha256(secp256k1mul(sha256(b))) by way of a
+// private key and its serialized public key.  It is how the hash was computed
+// before the dedicated implementation and serves as the baseline for the
+// benchmarks.
+func shaMulSha256Reference(b []byte) Hash {
+	first := fastsha256.Sum256(b)
+	_, pub := btcec.PrivKeyFromBytes(btcec.S256(), first[:])
+	return Hash(fastsha256.Sum256(pub.SerializeUncompressed()))
+}
+
+// BenchmarkShaMulSha256Reference benchmarks hashing a block header by way of a
+// private key and its serialized public key.
+func BenchmarkShaMulSha256Reference(b *testing.B) {
+	header := benchHeaders(1)[0]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		shaMulSha256Reference(header)
+	}
+}
+
+// BenchmarkShaMulSha256SH benchmarks hashing block headers one at a time as is
+// done when validating the headers of blocks during the initial block
+// download.
+func BenchmarkShaMulSha256SH(b *testing.B) {
+	header := benchHeaders(1)[0]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ShaMulSha256SH(header)
+	}
+}
+
+// BenchmarkShaMulSha256Batch benchmarks hashing batches of candidate block
+// headers as is done while mining.  The time reported is per header so it is
+// directly comparable to the other benchmarks.
+func BenchmarkShaMulSha256Batch(b *testing.B) {
+	headers := benchHeaders(benchBatchSize)
+	hashes := make([]Hash, benchBatchSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += benchBatchSize {
+		ShaMulSha256Batch(headers, hashes)
+	}
+}
@@ -35,15 +35,32 @@ func DoubleHashH(b []byte) Hash {
 	return Hash(fastsha256.Sum256(first[:]))
 }
 
-// ShaMulSha256SH calculates sha256(secp256k1mul(sha256(b))) and returns the resulting bytes
-// as a ShaHash.
+// ShaMulSha256SH calculates sha256(secp256k1mul(sha256(b))) and returns the
+// resulting bytes as a Hash.  The middle step multiplies the secp256k1 base
+// point by the first hash and serializes the resulting point in the
+// uncompressed format without performing any allocations.
 func ShaMulSha256SH(b []byte) Hash {
-    //fmt.Printf("Header len = %d\n", len(b))
-    //fmt.Printf("Header = %s\n", hex.EncodeToString(b[:]))
 	first := fastsha256.Sum256(b)
-    _, pub := btcec.PrivKeyFromBytes(btcec.S256(),first[:])
-    second := pub.SerializeUncompressed()
-    third := fastsha256.Sum256(second[:])
-    //fmt.Printf("f,s,t = %s, %s, %s\n", hex.EncodeToString(first[:]), hex.EncodeToString(second), hex.EncodeToString(third[:]))
-	return Hash(third)
+	var second [btcec.PubKeyBytesLenUncompressed]byte
+	btcec.S256().ScalarBaseMultUncompressed(&first, &second)
+	return Hash(fastsha256.Sum256(second[:]))
+}
+
+// ShaMulSha256Batch calculates sha256(secp256k1mul(sha256(b))) for each of the
+// passed byte slices and stores the results in the corresponding entries of
+// hashes, which must be at least as long as bs.  The point multiplications
+// share a single field inversion, so hashing many inputs at once, such as the
+// candidate headers searched while mining or the headers downloaded during the
+// initial block download, is much faster than hashing them one at a time.
+func ShaMulSha256Batch(bs [][]byte, hashes []Hash) {
+	firsts := make([][32]byte, len(bs))
+	for i, b := range bs {
+		firsts[i] = fastsha256.Sum256(b)
+	}
+
+	seconds := make([][btcec.PubKeyBytesLenUncompressed]byte, len(bs))
+	btcec.S256().BatchScalarBaseMultUncompressed(firsts, seconds)
+	for i := range seconds {
+		hashes[i] = Hash(fastsha256.Sum256(seconds[i][:]))
+	}
 }
@@ -134,3 +134,50 @@ func TestDoubleHashFuncs(t *testing.T) {
 		}
 	}
 }
+
+// TestShaMulSha256Funcs ensures the hash functions which perform
+// hash(secp256k1mul(hash(b))) work as expected both for a single input and for
+// a batch of inputs.
+func TestShaMulSha256Funcs(t *testing.T) {
+	tests := []struct {
+		out string
+		in  string
+	}{
+		{"bafd92302640eb6d8352e51ba5b2108aafa4c32bc7f0a970ebb9453415f06c0b", ""},
+		{"a629e04c790f44ce9de5f9e61b2df0b77f06cfee356f1bfef38284ddf7bd4648", "a"},
+		{"b9c5ef015a72538ab67897298ee05b74a0f7e8c3aa98b6a7cd69b0b5f8c7222b", "abc"},
+		{"da6753a2c2a7d31484cc54ac6bcf678ca15b5de66642b51a199ec37834820e04", "abcdefghij"},
+		{"9dfeeb4dd17269b349bf2d87851c2024d0aa2545432592413903c52b83eee480", "Nepal premier won't resign."},
+		{"6fa4606289747cf570bbe40841f67b26fb15ddfacc3e2edc5c4b0b5f40cc1429", "size:  a.out:  bad magic"},
+		{"d6836fe488fc5fed0043798e849c69cd43321aef00e53cfcdfd205ae41284489", "C is as portable as Stonehedge!!"},
+	}
+
+	// Ensure the hash function which hashes a single input returns the
+	// expected result.
+	for _, test := range tests {
+		hash := ShaMulSha256SH([]byte(test.in))
+		h := fmt.Sprintf("%x", hash[:])
+		if h != test.out {
+			t.Errorf("ShaMulSha256SH(%q) = %s, want %s", test.in, h,
+				test.out)
+			continue
+		}
+	}
+
+	// Ensure the hash function which hashes a batch of inputs returns the
+	// expected results.
+	bs := make([][]byte, len(tests))
+	for i, test := range tests {
+		bs[i] = []byte(test.in)
+	}
+	hashes := make([]Hash, len(tests))
+	ShaMulSha256Batch(bs, hashes)
+	for i, test := range tests {
+		h := fmt.Sprintf("%x", hashes[i][:])
+		if h != test.out {
+			t.Errorf("ShaMulSha256Batch(%q) = %s, want %s", test.in,
+				h, test.out)
+			continue
+		}
+	}
+}
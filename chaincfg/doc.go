@@ -58,4 +58,9 @@
 // non-standard network.  As a general rule of thumb, all network parameters
 // should be unique to the network, but parameter collisions can still occur
 // (unfortunately, this is the case with regtest and testnet3 sharing magics).
+//
+// The parameters of a non-standard network may also be loaded from a JSON file
+// described by ParamsFile with LoadParams, which checks the genesis block
+// against the genesis hash, so networks can be defined without changes to the
+// source.
 package chaincfg
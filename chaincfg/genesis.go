@@ -267,3 +267,35 @@ var ctredGenesisBlock = wire.MsgBlock{
 	},
 	Transactions: []*wire.MsgTx{&ctredGenesisCoinbaseTx},
 }
+
+// ctsimNetGenesisHash is the hash of the first block in the block chain for the
+// ciphrtxt simulation test network.
+var ctsimNetGenesisHash = chainhash.Hash([chainhash.HashSize]byte{ // Make go vet happy.
+	0x35, 0x68, 0x41, 0x90, 0xf3, 0x56, 0x28, 0x2f,
+	0xb6, 0xe3, 0x03, 0xc5, 0x53, 0x6d, 0xd4, 0xa6,
+	0x21, 0x6c, 0x21, 0xb7, 0xc8, 0x64, 0x7e, 0x6c,
+	0x6d, 0xfa, 0xc9, 0xe4, 0xcf, 0xe1, 0x33, 0x08,
+})
+
+// ctsimNetGenesisMerkleRoot is the hash of the first transaction in the genesis
+// block for the ciphrtxt simulation test network.  It is the same as the merkle
+// root for the ciphrtxt indigo network.
+var ctsimNetGenesisMerkleRoot = ctindigoGenesisMerkleRoot
+
+// ctsimNetGenesisBlock defines the genesis block of the block chain which serves
+// as the public transaction ledger for the ciphrtxt simulation test network.
+// The nonce headers of the block are the same as those of the ciphrtxt indigo
+// network genesis block since the genesis block is never checked against a
+// message header source.
+var ctsimNetGenesisBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version:      101,
+		PrevBlock:    chainhash.Hash{},          // 0000000000000000000000000000000000000000000000000000000000000000
+		MerkleRoot:   ctsimNetGenesisMerkleRoot, // da5bec70209e21957c51a981bd532b0bacf8fabbb685f019b7189feb188bb9c3
+		Timestamp:    time.Unix(0x58471a00, 0),  // Tue Dec 6 20:05:20 2016
+		Bits:         0x207fffff,                // 545259519 [7fffff0000000000000000000000000000000000000000000000000000000000]
+		NonceHeaderA: ctindigoGenesisBlock.Header.NonceHeaderA,
+		NonceHeaderB: ctindigoGenesisBlock.Header.NonceHeaderB,
+	},
+	Transactions: []*wire.MsgTx{&ctindigoGenesisCoinbaseTx},
+}
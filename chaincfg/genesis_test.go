@@ -118,6 +118,25 @@ func TestSimNetGenesisBlock(t *testing.T) {
 	}
 }
 
+// TestCTSimNetGenesisBlock tests the genesis block of the ciphrtxt simulation
+// test network for validity by checking the hash and the merkle root.
+func TestCTSimNetGenesisBlock(t *testing.T) {
+	block := CTSimNetParams.GenesisBlock
+	hash := block.BlockHash()
+	if !CTSimNetParams.GenesisHash.IsEqual(&hash) {
+		t.Fatalf("TestCTSimNetGenesisBlock: Genesis block hash does "+
+			"not appear valid - got %v, want %v", spew.Sdump(hash),
+			spew.Sdump(CTSimNetParams.GenesisHash))
+	}
+
+	merkleRoot := block.Transactions[0].TxHash()
+	if !block.Header.MerkleRoot.IsEqual(&merkleRoot) {
+		t.Fatalf("TestCTSimNetGenesisBlock: Genesis block merkle root "+
+			"does not appear valid - got %v, want %v",
+			block.Header.MerkleRoot, merkleRoot)
+	}
+}
+
 // genesisBlockBytes are the wire encoded bytes for the genesis block of the
 // main network as of protocol version 60002.
 var genesisBlockBytes = []byte{
@@ -6,6 +6,7 @@ package chaincfg
 
 import (
 	"errors"
+	"math"
 	"math/big"
 	"time"
 
@@ -27,6 +28,11 @@ var (
 	// ctindigoNetPowLimit is the highest proof of work value a ciphrtxt block
 	// can have for the ciphrtxt indigo network.  It is the value 2^248 - 1.
 	ctindigoNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 248), bigOne)
+
+	// ctsimNetPowLimit is the highest proof of work value a ciphrtxt block
+	// can have for the ciphrtxt simulation test network.  It is the value
+	// 2^255 - 1, so blocks can be solved with very few hashes.
+	ctsimNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
 )
 
 // Checkpoint identifies a known good point in the block chain.  Using
@@ -41,6 +47,37 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// ConsensusDeployment defines details related to a specific consensus rule
+// change that is voted in.  This is part of BIP0009.
+type ConsensusDeployment struct {
+	// BitNumber defines the specific bit number within the block version
+	// this particular soft-fork deployment refers to.
+	BitNumber uint8
+
+	// StartTime is the median block time after which voting on the
+	// deployment starts.
+	StartTime uint64
+
+	// ExpireTime is the median block time after which the attempted
+	// deployment expires.
+	ExpireTime uint64
+}
+
+// Constants that define the deployment offset in the deployments field of the
+// parameters for each deployment.  This is useful to be able to get the details
+// of a specific deployment by name.
+const (
+	// DeploymentTestDummy defines the rule change deployment ID for testing
+	// purposes.
+	DeploymentTestDummy = iota
+
+	// NOTE: DefinedDeployments must always come last since it is used to
+	// determine how many defined deployments there currently are.
+
+	// DefinedDeployments is the number of currently defined deployments.
+	DefinedDeployments
+)
+
 // Params defines a Bitcoin network by its parameters.  These parameters may be
 // used by Bitcoin applications to differentiate networks as well as addresses
 // and keys for one network from those intended for use on another network.
@@ -108,6 +145,23 @@ type Params struct {
 	// NOTE: This only applies if ReduceMinDifficulty is true.
 	MinDiffReductionTime time.Duration
 
+	// LWMAWindow is the number of most recent blocks whose solve times
+	// are averaged by the per-block linearly weighted moving average
+	// (LWMA) difficulty algorithm.  Once the algorithm activates, the
+	// difficulty of every block is calculated from the window instead of
+	// being retargeted once every TargetTimespan worth of blocks.  A value
+	// of zero disables the algorithm.
+	//
+	// NOTE: ReduceMinDifficulty does not apply to the blocks whose
+	// difficulty is calculated with the algorithm.
+	LWMAWindow int32
+
+	// LWMAActivationHeight is the height of the first block whose
+	// difficulty is calculated with the LWMA difficulty algorithm.
+	//
+	// NOTE: This only applies if LWMAWindow is not zero.
+	LWMAActivationHeight int32
+
 	// GenerateSupported specifies whether or not CPU mining is allowed.
 	GenerateSupported bool
 
@@ -125,6 +179,41 @@ type Params struct {
 	// The number of nodes to check.  This is part of BIP0034.
 	BlockUpgradeNumToCheck uint64
 
+	// NonceHeaderReuseWindow is the number of most recent blocks whose
+	// nonce headers may not be used as a nonce header of a new block once
+	// the nonce header reuse rule is enforced.  A value of zero only
+	// forbids using the same header as both nonces of a block.
+	NonceHeaderReuseWindow int32
+
+	// NonceHeaderMaxAge is the maximum amount of time a message may have
+	// been stored before the timestamp of a block which uses its header as
+	// a nonce once nonce header lifetimes are enforced.  A value of zero
+	// disables the limit.
+	NonceHeaderMaxAge time.Duration
+
+	// NonceHeaderMinLifetime is the minimum amount of time a message must
+	// remain unexpired after the timestamp of a block which uses its
+	// header as a nonce once nonce header lifetimes are enforced.  This
+	// ensures other nodes are still able to look the message up while the
+	// block propagates.  A value of zero disables the limit.
+	NonceHeaderMinLifetime time.Duration
+
+	// Consensus rule change deployments.
+	//
+	// RuleChangeActivationThreshold is the number of blocks in a miner
+	// confirmation window which must signal for a rule change in order
+	// to lock it in.  This is part of BIP0009.
+	//
+	// MinerConfirmationWindow is the number of blocks in each threshold
+	// state retarget window.
+	//
+	// Deployments define the specific consensus rule changes to be voted
+	// on and are indexed by the deployment IDs such as
+	// DeploymentTestDummy.
+	RuleChangeActivationThreshold uint32
+	MinerConfirmationWindow       uint32
+	Deployments                   [DefinedDeployments]ConsensusDeployment
+
 	// Mempool parameters
 	RelayNonStdTxs bool
 
@@ -182,6 +271,27 @@ var CTIndigoNetParams = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// Forbid reusing the nonce headers of the blocks of the past day.
+	NonceHeaderReuseWindow: 1440,
+
+	// Only allow nonce headers of messages stored within the past week
+	// which remain available for at least another hour.
+	NonceHeaderMaxAge:      time.Hour * 24 * 7,
+	NonceHeaderMinLifetime: time.Hour,
+
+	// Consensus rule change deployments.
+	//
+	// Miners vote on rule changes over a day's worth of blocks.
+	RuleChangeActivationThreshold: 1368, // 95% of MinerConfirmationWindow
+	MinerConfirmationWindow:       1440,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTestDummy: {
+			BitNumber:  28,
+			StartTime:  1199145601, // January 1, 2008 UTC
+			ExpireTime: 1230767999, // December 31, 2008 UTC
+		},
+	},
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
@@ -239,6 +349,27 @@ var CTRedNetParams = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// Forbid reusing the nonce headers of the blocks of the past day.
+	NonceHeaderReuseWindow: 1440,
+
+	// Only allow nonce headers of messages stored within the past week
+	// which remain available for at least another hour.
+	NonceHeaderMaxAge:      time.Hour * 24 * 7,
+	NonceHeaderMinLifetime: time.Hour,
+
+	// Consensus rule change deployments.
+	//
+	// Miners vote on rule changes over a day's worth of blocks.
+	RuleChangeActivationThreshold: 1368, // 95% of MinerConfirmationWindow
+	MinerConfirmationWindow:       1440,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTestDummy: {
+			BitNumber:  28,
+			StartTime:  1199145601, // January 1, 2008 UTC
+			ExpireTime: 1230767999, // December 31, 2008 UTC
+		},
+	},
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
@@ -260,6 +391,91 @@ var CTRedNetParams = Params{
     CTMsgstorePort:  "17751",
 }
 
+// CTSimNetParams defines the network parameters for the ciphrtxt simulation
+// test network.  This network is similar to the ciphrtxt red test network
+// except it is intended for private use within a group of individuals doing
+// simulation testing.  The functionality is intended to differ in that the only
+// nodes which are specifically specified are used to create the network rather
+// than following normal discovery rules.  Blocks are trivial to solve and a
+// node which is not configured with a message store uses an in-memory message
+// header source, which the CPU miner fills with generated message headers as
+// needed, so blocks can be generated on demand.
+var CTSimNetParams = Params{
+	Name:        "ctsimnet",
+	Net:         wire.CTSimNet,
+	DefaultPort: "27761",
+	DNSSeeds:    []string{}, // NOTE: There must NOT be any seeds.
+
+	// Chain parameters
+	GenesisBlock:             &ctsimNetGenesisBlock,
+	GenesisHash:              &ctsimNetGenesisHash,
+	PowLimit:                 ctsimNetPowLimit,
+	PowLimitBits:             0x207fffff,
+	CoinbaseMaturity:         100,
+	SubsidyInitialHalflife:   10080,
+	TargetTimespan:           time.Hour * 2,   // 2 hours
+	TargetTimePerBlock:       time.Minute * 1, // 1 minute
+	RetargetAdjustmentFactor: 4,               // 25% less, 400% more
+	ReduceMinDifficulty:      true,
+	MinDiffReductionTime:     time.Minute * 2, // TargetTimePerBlock * 2
+	GenerateSupported:        true,
+
+	// Checkpoints ordered from oldest to newest.
+	Checkpoints: nil,
+
+	// Enforce current block version once majority of the network has
+	// upgraded.
+	// 51% (51 / 100)
+	// Reject previous block versions once a majority of the network has
+	// upgraded.
+	// 75% (75 / 100)
+	BlockEnforceNumRequired: 51,
+	BlockRejectNumRequired:  75,
+	BlockUpgradeNumToCheck:  100,
+
+	// Forbid reusing the nonce headers of the past 100 blocks.
+	NonceHeaderReuseWindow: 100,
+
+	// Only allow nonce headers of messages stored within the past day
+	// which remain available for at least another hour.
+	NonceHeaderMaxAge:      time.Hour * 24,
+	NonceHeaderMinLifetime: time.Hour,
+
+	// Consensus rule change deployments.
+	//
+	// Miners vote on rule changes over the past 100 blocks.
+	RuleChangeActivationThreshold: 75, // 75% of MinerConfirmationWindow
+	MinerConfirmationWindow:       100,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTestDummy: {
+			BitNumber:  28,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+	},
+
+	// Mempool parameters
+	RelayNonStdTxs: true,
+
+	// Address encoding magics
+	PubKeyHashAddrID: 0x3f, // starts with S
+	ScriptHashAddrID: 0x7b, // starts with s
+	PrivateKeyID:     0x64, // starts with 4 (uncompressed) or F (compressed)
+
+	// BIP32 hierarchical deterministic extended key magics
+	HDPrivateKeyID: [4]byte{0x04, 0x20, 0xb9, 0x00}, // starts with sprv
+	HDPublicKeyID:  [4]byte{0x04, 0x20, 0xbd, 0x3a}, // starts with spub
+
+	// BIP44 coin type used in the hierarchical deterministic path for
+	// address generation.
+	HDCoinType: 115, // ASCII for s
+
+	// There is no message store for the simulation test network by
+	// default, so an in-memory message header source is used instead.
+	CTMsgstoreHost: "",
+	CTMsgstorePort: "",
+}
+
 var (
 	// ErrDuplicateNet describes an error where the parameters for a Bitcoin
 	// network could not be set due to the network already being a standard
@@ -370,4 +586,5 @@ func init() {
 	// Register all default networks when the package is initialized.
 	mustRegister(&CTIndigoNetParams)
 	mustRegister(&CTRedNetParams)
+	mustRegister(&CTSimNetParams)
 }
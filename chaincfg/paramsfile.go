@@ -0,0 +1,314 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/wire"
+)
+
+// ParamsFile describes the JSON encoding of the parameters of a custom network
+// which is loaded by LoadParams.  Hashes, the proof of work limit and the HD
+// key magics are hex encoded and durations use the format accepted by
+// time.ParseDuration, such as "2h" or "1m30s".
+//
+// The genesis block is the hex encoded serialized block, such as the one
+// printed by the gengenesis command, and must hash to the genesis hash.
+type ParamsFile struct {
+	Name                          string           `json:"name"`
+	Net                           uint32           `json:"net"`
+	DefaultPort                   string           `json:"defaultport"`
+	DNSSeeds                      []string         `json:"dnsseeds,omitempty"`
+	GenesisBlock                  string           `json:"genesisblock"`
+	GenesisHash                   string           `json:"genesishash"`
+	PowLimit                      string           `json:"powlimit"`
+	PowLimitBits                  uint32           `json:"powlimitbits"`
+	CoinbaseMaturity              uint16           `json:"coinbasematurity"`
+	SubsidyInitialHalflife        int32            `json:"subsidyinitialhalflife"`
+	TargetTimespan                string           `json:"targettimespan"`
+	TargetTimePerBlock            string           `json:"targettimeperblock"`
+	RetargetAdjustmentFactor      int64            `json:"retargetadjustmentfactor"`
+	ReduceMinDifficulty           bool             `json:"reducemindifficulty"`
+	MinDiffReductionTime          string           `json:"mindiffreductiontime,omitempty"`
+	LWMAWindow                    int32            `json:"lwmawindow,omitempty"`
+	LWMAActivationHeight          int32            `json:"lwmaactivationheight,omitempty"`
+	GenerateSupported             bool             `json:"generatesupported"`
+	Checkpoints                   []CheckpointFile `json:"checkpoints,omitempty"`
+	BlockEnforceNumRequired       uint64           `json:"blockenforcenumrequired"`
+	BlockRejectNumRequired        uint64           `json:"blockrejectnumrequired"`
+	BlockUpgradeNumToCheck        uint64           `json:"blockupgradenumtocheck"`
+	NonceHeaderReuseWindow        int32            `json:"nonceheaderreusewindow"`
+	NonceHeaderMaxAge             string           `json:"nonceheadermaxage,omitempty"`
+	NonceHeaderMinLifetime        string           `json:"nonceheaderminlifetime,omitempty"`
+	RuleChangeActivationThreshold uint32           `json:"rulechangeactivationthreshold,omitempty"`
+	MinerConfirmationWindow       uint32           `json:"minerconfirmationwindow,omitempty"`
+	Deployments                   []DeploymentFile `json:"deployments,omitempty"`
+	RelayNonStdTxs                bool             `json:"relaynonstdtxs"`
+	PubKeyHashAddrID              byte             `json:"pubkeyhashaddrid"`
+	ScriptHashAddrID              byte             `json:"scripthashaddrid"`
+	PrivateKeyID                  byte             `json:"privatekeyid"`
+	HDPrivateKeyID                string           `json:"hdprivatekeyid"`
+	HDPublicKeyID                 string           `json:"hdpublickeyid"`
+	HDCoinType                    uint32           `json:"hdcointype"`
+	CTMsgstoreHost                string           `json:"msgstorehost"`
+	CTMsgstorePort                string           `json:"msgstoreport"`
+}
+
+// CheckpointFile describes the JSON encoding of a checkpoint of a custom
+// network.
+type CheckpointFile struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// DeploymentFile describes the JSON encoding of a consensus rule change
+// deployment of a custom network.  The deployments of a params file are listed
+// in the order of their deployment IDs, such as DeploymentTestDummy, and the
+// start and expire times are the median block times in seconds since the Unix
+// epoch.
+type DeploymentFile struct {
+	BitNumber  uint8  `json:"bitnumber"`
+	StartTime  uint64 `json:"starttime"`
+	ExpireTime uint64 `json:"expiretime"`
+}
+
+// parseDuration returns the duration for the passed string of the named
+// parameter.  An empty string is a zero duration.
+func parseDuration(name, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return d, nil
+}
+
+// parseHDKeyID returns the HD extended key magic for the passed hex string of
+// the named parameter.
+func parseHDKeyID(name, s string) ([4]byte, error) {
+	var id [4]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("invalid %s %q: must be %d hex encoded "+
+			"bytes", name, s, len(id))
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// Params returns the network parameters described by the params file.  The
+// genesis block is checked to hash to the genesis hash and to commit to its
+// coinbase transaction, so a mistake in either is caught before a node starts
+// a chain which does not match the rest of the network.
+//
+// The miner confirmation window defaults to the number of blocks per retarget
+// and the rule change activation threshold to 95% of the window when they are
+// not specified.  Deployments which are not listed have expire times of zero,
+// so they are never voted on.
+func (f *ParamsFile) Params() (*Params, error) {
+	if f.Name == "" {
+		return nil, errors.New("network name is not specified")
+	}
+	if f.DefaultPort == "" {
+		return nil, errors.New("default port is not specified")
+	}
+
+	// Decode the genesis block and ensure it matches the genesis hash.
+	genesisBytes, err := hex.DecodeString(f.GenesisBlock)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis block: %v", err)
+	}
+	var genesisBlock wire.MsgBlock
+	err = genesisBlock.Deserialize(bytes.NewReader(genesisBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis block: %v", err)
+	}
+	genesisHash, err := chainhash.NewHashFromStr(f.GenesisHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis hash: %v", err)
+	}
+	if hash := genesisBlock.BlockHash(); hash != *genesisHash {
+		return nil, fmt.Errorf("genesis block hash %v does not match "+
+			"genesis hash %v", hash, genesisHash)
+	}
+	if len(genesisBlock.Transactions) != 1 {
+		return nil, fmt.Errorf("genesis block has %d transactions "+
+			"instead of only a coinbase transaction",
+			len(genesisBlock.Transactions))
+	}
+	txHash := genesisBlock.Transactions[0].TxHash()
+	if genesisBlock.Header.MerkleRoot != txHash {
+		return nil, fmt.Errorf("genesis block merkle root %v does not "+
+			"match its coinbase transaction %v",
+			genesisBlock.Header.MerkleRoot, txHash)
+	}
+
+	powLimit, ok := new(big.Int).SetString(f.PowLimit, 16)
+	if !ok || powLimit.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid proof of work limit %q",
+			f.PowLimit)
+	}
+
+	params := &Params{
+		Name:                          f.Name,
+		Net:                           wire.BitcoinNet(f.Net),
+		DefaultPort:                   f.DefaultPort,
+		DNSSeeds:                      f.DNSSeeds,
+		GenesisBlock:                  &genesisBlock,
+		GenesisHash:                   genesisHash,
+		PowLimit:                      powLimit,
+		PowLimitBits:                  f.PowLimitBits,
+		CoinbaseMaturity:              f.CoinbaseMaturity,
+		SubsidyInitialHalflife:        f.SubsidyInitialHalflife,
+		RetargetAdjustmentFactor:      f.RetargetAdjustmentFactor,
+		ReduceMinDifficulty:           f.ReduceMinDifficulty,
+		LWMAWindow:                    f.LWMAWindow,
+		LWMAActivationHeight:          f.LWMAActivationHeight,
+		GenerateSupported:             f.GenerateSupported,
+		BlockEnforceNumRequired:       f.BlockEnforceNumRequired,
+		BlockRejectNumRequired:        f.BlockRejectNumRequired,
+		BlockUpgradeNumToCheck:        f.BlockUpgradeNumToCheck,
+		NonceHeaderReuseWindow:        f.NonceHeaderReuseWindow,
+		RuleChangeActivationThreshold: f.RuleChangeActivationThreshold,
+		MinerConfirmationWindow:       f.MinerConfirmationWindow,
+		RelayNonStdTxs:                f.RelayNonStdTxs,
+		PubKeyHashAddrID:              f.PubKeyHashAddrID,
+		ScriptHashAddrID:              f.ScriptHashAddrID,
+		PrivateKeyID:                  f.PrivateKeyID,
+		HDCoinType:                    f.HDCoinType,
+		CTMsgstoreHost:                f.CTMsgstoreHost,
+		CTMsgstorePort:                f.CTMsgstorePort,
+	}
+	if params.DNSSeeds == nil {
+		params.DNSSeeds = []string{}
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		d     *time.Duration
+	}{
+		{"target timespan", f.TargetTimespan, &params.TargetTimespan},
+		{"target time per block", f.TargetTimePerBlock,
+			&params.TargetTimePerBlock},
+		{"min difficulty reduction time", f.MinDiffReductionTime,
+			&params.MinDiffReductionTime},
+		{"nonce header max age", f.NonceHeaderMaxAge,
+			&params.NonceHeaderMaxAge},
+		{"nonce header min lifetime", f.NonceHeaderMinLifetime,
+			&params.NonceHeaderMinLifetime},
+	}
+	for _, duration := range durations {
+		*duration.d, err = parseDuration(duration.name, duration.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if params.TargetTimespan <= 0 || params.TargetTimePerBlock <= 0 {
+		return nil, errors.New("target timespan and target time per " +
+			"block must be positive")
+	}
+
+	// The difficulty retargets every target timespan, which must cover at
+	// least one block, and the adjustment factor limits the change of each
+	// retarget, so both are divisors when the chain is created.
+	if params.TargetTimespan < params.TargetTimePerBlock {
+		return nil, errors.New("target timespan must not be shorter " +
+			"than the target time per block")
+	}
+	if params.RetargetAdjustmentFactor <= 0 {
+		return nil, errors.New("retarget adjustment factor must be " +
+			"positive")
+	}
+
+	if params.LWMAWindow < 0 || params.LWMAActivationHeight < 0 {
+		return nil, errors.New("LWMA window and activation height " +
+			"must not be negative")
+	}
+
+	// A missing miner confirmation window defaults to the number of blocks
+	// per retarget and a missing rule change activation threshold to 95% of
+	// the window, which are the values of the main network.
+	if params.MinerConfirmationWindow == 0 {
+		params.MinerConfirmationWindow = uint32(params.TargetTimespan /
+			params.TargetTimePerBlock)
+	}
+	if params.RuleChangeActivationThreshold == 0 {
+		params.RuleChangeActivationThreshold =
+			params.MinerConfirmationWindow * 95 / 100
+	}
+	if params.MinerConfirmationWindow == 0 ||
+		params.RuleChangeActivationThreshold == 0 ||
+		params.RuleChangeActivationThreshold > params.MinerConfirmationWindow {
+		return nil, errors.New("rule change activation threshold must " +
+			"be positive and no more than the miner confirmation window")
+	}
+	if len(f.Deployments) > len(params.Deployments) {
+		return nil, fmt.Errorf("%d deployments are listed but only %d "+
+			"are defined", len(f.Deployments), len(params.Deployments))
+	}
+	for id, deployment := range f.Deployments {
+		// The top three bits of the block version signal deployments,
+		// so the remaining 29 bits are available to them.
+		if deployment.BitNumber >= 29 {
+			return nil, fmt.Errorf("invalid deployment %d bit number "+
+				"%d", id, deployment.BitNumber)
+		}
+		params.Deployments[id] = ConsensusDeployment{
+			BitNumber:  deployment.BitNumber,
+			StartTime:  deployment.StartTime,
+			ExpireTime: deployment.ExpireTime,
+		}
+	}
+
+	params.HDPrivateKeyID, err = parseHDKeyID("hd private key id",
+		f.HDPrivateKeyID)
+	if err != nil {
+		return nil, err
+	}
+	params.HDPublicKeyID, err = parseHDKeyID("hd public key id",
+		f.HDPublicKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, checkpoint := range f.Checkpoints {
+		hash, err := chainhash.NewHashFromStr(checkpoint.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint hash at height "+
+				"%d: %v", checkpoint.Height, err)
+		}
+		params.Checkpoints = append(params.Checkpoints, Checkpoint{
+			Height: checkpoint.Height,
+			Hash:   hash,
+		})
+	}
+
+	return params, nil
+}
+
+// LoadParams reads the JSON encoded parameters of a custom network described
+// by ParamsFile from r and returns them.  The parameters are not registered, so
+// callers which use them as the active network must also pass them to
+// Register.
+func LoadParams(r io.Reader) (*Params, error) {
+	var f ParamsFile
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	return f.Params()
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ctsimNetParamsFile returns a params file which describes the same network
+// as the ctsimnet parameters apart from its name and magic.
+func ctsimNetParamsFile(t *testing.T) ParamsFile {
+	var buf bytes.Buffer
+	if err := CTSimNetParams.GenesisBlock.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	return ParamsFile{
+		Name:                     "ctfilenet",
+		Net:                      0xfeedbeef,
+		DefaultPort:              "37761",
+		GenesisBlock:             hex.EncodeToString(buf.Bytes()),
+		GenesisHash:              CTSimNetParams.GenesisHash.String(),
+		PowLimit:                 CTSimNetParams.PowLimit.Text(16),
+		PowLimitBits:             CTSimNetParams.PowLimitBits,
+		CoinbaseMaturity:         CTSimNetParams.CoinbaseMaturity,
+		SubsidyInitialHalflife:   CTSimNetParams.SubsidyInitialHalflife,
+		TargetTimespan:           "2h",
+		TargetTimePerBlock:       "1m",
+		RetargetAdjustmentFactor: CTSimNetParams.RetargetAdjustmentFactor,
+		ReduceMinDifficulty:      true,
+		MinDiffReductionTime:     "2m",
+		GenerateSupported:        true,
+		Checkpoints: []CheckpointFile{{
+			Height: 0,
+			Hash:   CTSimNetParams.GenesisHash.String(),
+		}},
+		BlockEnforceNumRequired:       51,
+		BlockRejectNumRequired:        75,
+		BlockUpgradeNumToCheck:        100,
+		NonceHeaderReuseWindow:        100,
+		NonceHeaderMaxAge:             "24h",
+		NonceHeaderMinLifetime:        "1h",
+		RuleChangeActivationThreshold: 75,
+		MinerConfirmationWindow:       100,
+		Deployments: []DeploymentFile{{
+			BitNumber:  28,
+			StartTime:  0,
+			ExpireTime: math.MaxInt64,
+		}},
+		RelayNonStdTxs:   true,
+		PubKeyHashAddrID: 0x3f,
+		ScriptHashAddrID: 0x7b,
+		PrivateKeyID:     0x64,
+		HDPrivateKeyID:   "0420b900",
+		HDPublicKeyID:    "0420bd3a",
+		HDCoinType:       115,
+		CTMsgstoreHost:   "localhost",
+		CTMsgstorePort:   "37754",
+	}
+}
+
+// TestLoadParams ensures the parameters loaded from a params file match the
+// ones it describes.
+func TestLoadParams(t *testing.T) {
+	f := ctsimNetParamsFile(t)
+	b, err := json.Marshal(&f)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	params, err := LoadParams(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("LoadParams: %v", err)
+	}
+
+	want := CTSimNetParams
+	want.Name = "ctfilenet"
+	want.Net = 0xfeedbeef
+	want.DefaultPort = "37761"
+	want.Checkpoints = []Checkpoint{{Height: 0, Hash: want.GenesisHash}}
+	want.CTMsgstoreHost = "localhost"
+	want.CTMsgstorePort = "37754"
+	if !reflect.DeepEqual(params, &want) {
+		t.Fatalf("LoadParams: mismatched params - got %+v, want %+v",
+			params, &want)
+	}
+}
+
+// TestLoadParamsDefaults ensures a missing miner confirmation window defaults
+// to the number of blocks per retarget and a missing rule change activation
+// threshold to 95% of the window.
+func TestLoadParamsDefaults(t *testing.T) {
+	tests := []struct {
+		name          string
+		threshold     uint32
+		window        uint32
+		wantThreshold uint32
+		wantWindow    uint32
+	}{
+		{
+			name:          "no window or threshold",
+			wantThreshold: 114,
+			wantWindow:    120,
+		},
+		{
+			name:          "no threshold",
+			window:        100,
+			wantThreshold: 95,
+			wantWindow:    100,
+		},
+		{
+			name:          "no window",
+			threshold:     90,
+			wantThreshold: 90,
+			wantWindow:    120,
+		},
+	}
+
+	for _, test := range tests {
+		f := ctsimNetParamsFile(t)
+		f.RuleChangeActivationThreshold = test.threshold
+		f.MinerConfirmationWindow = test.window
+		params, err := f.Params()
+		if err != nil {
+			t.Errorf("%s: Params: %v", test.name, err)
+			continue
+		}
+		if params.RuleChangeActivationThreshold != test.wantThreshold ||
+			params.MinerConfirmationWindow != test.wantWindow {
+			t.Errorf("%s: got threshold %d and window %d, want "+
+				"threshold %d and window %d", test.name,
+				params.RuleChangeActivationThreshold,
+				params.MinerConfirmationWindow, test.wantThreshold,
+				test.wantWindow)
+		}
+	}
+}
+
+// TestLoadParamsErrors ensures params files with missing or invalid
+// parameters, and especially a genesis block which does not match the genesis
+// hash, are rejected.
+func TestLoadParamsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(f *ParamsFile)
+		err    string
+	}{
+		{
+			name:   "no name",
+			modify: func(f *ParamsFile) { f.Name = "" },
+			err:    "network name",
+		},
+		{
+			name:   "no default port",
+			modify: func(f *ParamsFile) { f.DefaultPort = "" },
+			err:    "default port",
+		},
+		{
+			name:   "malformed genesis block",
+			modify: func(f *ParamsFile) { f.GenesisBlock = f.GenesisBlock[:100] },
+			err:    "invalid genesis block",
+		},
+		{
+			name: "mismatched genesis hash",
+			modify: func(f *ParamsFile) {
+				f.GenesisHash = CTIndigoNetParams.GenesisHash.String()
+			},
+			err: "does not match genesis hash",
+		},
+		{
+			name: "modified genesis block",
+			modify: func(f *ParamsFile) {
+				// Change the low byte of the version.
+				f.GenesisBlock = "00" + f.GenesisBlock[2:]
+			},
+			err: "does not match genesis hash",
+		},
+		{
+			name:   "invalid proof of work limit",
+			modify: func(f *ParamsFile) { f.PowLimit = "xyz" },
+			err:    "proof of work limit",
+		},
+		{
+			name:   "invalid duration",
+			modify: func(f *ParamsFile) { f.NonceHeaderMaxAge = "1 day" },
+			err:    "nonce header max age",
+		},
+		{
+			name:   "no target timespan",
+			modify: func(f *ParamsFile) { f.TargetTimespan = "" },
+			err:    "target timespan",
+		},
+		{
+			name:   "target timespan shorter than time per block",
+			modify: func(f *ParamsFile) { f.TargetTimespan = "30s" },
+			err:    "target timespan must not be shorter",
+		},
+		{
+			name:   "no retarget adjustment factor",
+			modify: func(f *ParamsFile) { f.RetargetAdjustmentFactor = 0 },
+			err:    "retarget adjustment factor",
+		},
+		{
+			name:   "short hd key id",
+			modify: func(f *ParamsFile) { f.HDPublicKeyID = "0420bd" },
+			err:    "hd public key id",
+		},
+		{
+			name: "threshold exceeds window",
+			modify: func(f *ParamsFile) {
+				f.RuleChangeActivationThreshold = 101
+			},
+			err: "rule change activation threshold",
+		},
+		{
+			name: "threshold exceeds default window",
+			modify: func(f *ParamsFile) {
+				f.MinerConfirmationWindow = 0
+				f.RuleChangeActivationThreshold = 121
+			},
+			err: "rule change activation threshold",
+		},
+		{
+			name: "too many deployments",
+			modify: func(f *ParamsFile) {
+				f.Deployments = append(f.Deployments,
+					f.Deployments[0])
+			},
+			err: "deployments are listed",
+		},
+		{
+			name: "invalid deployment bit number",
+			modify: func(f *ParamsFile) {
+				f.Deployments[0].BitNumber = 29
+			},
+			err: "bit number",
+		},
+		{
+			name: "invalid checkpoint hash",
+			modify: func(f *ParamsFile) {
+				f.Checkpoints[0].Hash = "xyz"
+			},
+			err: "checkpoint hash",
+		},
+	}
+
+	for _, test := range tests {
+		f := ctsimNetParamsFile(t)
+		test.modify(&f)
+		_, err := f.Params()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want error containing %q",
+				test.name, err, test.err)
+		}
+	}
+
+	// Malformed JSON is rejected as well.
+	if _, err := LoadParams(strings.NewReader("{")); err == nil {
+		t.Errorf("LoadParams: malformed JSON was accepted")
+	}
+}
+
+// TestParamsFileDeployments ensures deployments which are not listed in a
+// params file are never voted on.
+func TestParamsFileDeployments(t *testing.T) {
+	f := ctsimNetParamsFile(t)
+	f.Deployments = nil
+	params, err := f.Params()
+	if err != nil {
+		t.Fatalf("Params: %v", err)
+	}
+	deployment := params.Deployments[DeploymentTestDummy]
+	if deployment != (ConsensusDeployment{}) {
+		t.Errorf("unlisted deployment: got %+v, want expired deployment",
+			deployment)
+	}
+}
+
+// TestParamsFileDurations ensures durations in a params file are parsed in the
+// format of time.ParseDuration.
+func TestParamsFileDurations(t *testing.T) {
+	f := ctsimNetParamsFile(t)
+	f.TargetTimespan = "1h30m"
+	f.MinDiffReductionTime = ""
+	params, err := f.Params()
+	if err != nil {
+		t.Fatalf("Params: %v", err)
+	}
+	if params.TargetTimespan != time.Hour+time.Minute*30 {
+		t.Errorf("target timespan: got %v, want 1h30m",
+			params.TargetTimespan)
+	}
+	if params.MinDiffReductionTime != 0 {
+		t.Errorf("min difficulty reduction time: got %v, want 0",
+			params.MinDiffReductionTime)
+	}
+}
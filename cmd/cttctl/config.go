@@ -104,13 +104,14 @@ type config struct {
 	ProxyUser     string `long:"proxyuser" description:"Username for proxy server"`
 	ProxyPass     string `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	CTRedNet      bool   `long:"ctrednet" description:"Connect to the ciphrtxt red test network"`
+	CTSimNet      bool   `long:"ctsimnet" description:"Connect to the ciphrtxt simulation test network"`
 	TLSSkipVerify bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
 	Wallet        bool   `long:"wallet" description:"Connect to wallet"`
 }
 
 // normalizeAddress returns addr with the passed default port appended if
 // there is not already a port specified.
-func normalizeAddress(addr string, useCTRedNet, useWallet bool) string {
+func normalizeAddress(addr string, useCTRedNet, useCTSimNet, useWallet bool) string {
 	_, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		var defaultPort string
@@ -121,6 +122,12 @@ func normalizeAddress(addr string, useCTRedNet, useWallet bool) string {
 			} else {
 				defaultPort = "17762"
 			}
+		case useCTSimNet:
+			if useWallet {
+				defaultPort = "27763"
+			} else {
+				defaultPort = "27762"
+			}
 		default:
 			if useWallet {
 				defaultPort = "7766"
@@ -243,8 +250,11 @@ func loadConfig() (*config, []string, error) {
 	if cfg.CTRedNet {
 		numNets++
 	}
+	if cfg.CTSimNet {
+		numNets++
+	}
 	if numNets > 1 {
-		str := "%s: The testnet and simnet params can't be used " +
+		str := "%s: The ctrednet and ctsimnet params can't be used " +
 			"together -- choose one of the two"
 		err := fmt.Errorf(str, "loadConfig")
 		fmt.Fprintln(os.Stderr, err)
@@ -260,9 +270,10 @@ func loadConfig() (*config, []string, error) {
 	// Handle environment variable expansion in the RPC certificate path.
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
 
-	// Add default port to RPC server based on --ctrednet and --wallet flags
-	// if needed.
-	cfg.RPCServer = normalizeAddress(cfg.RPCServer, cfg.CTRedNet, cfg.Wallet)
+	// Add default port to RPC server based on --ctrednet, --ctsimnet and
+	// --wallet flags if needed.
+	cfg.RPCServer = normalizeAddress(cfg.RPCServer, cfg.CTRedNet,
+		cfg.CTSimNet, cfg.Wallet)
 
 	return &cfg, remainingArgs, nil
 }
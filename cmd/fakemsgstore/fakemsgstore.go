@@ -0,0 +1,72 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jadeblaquiere/cttd/fakemsgstore"
+	flags "github.com/btcsuite/go-flags"
+)
+
+const (
+	defaultListen      = "127.0.0.1:7754"
+	defaultNumHeaders  = 64
+	defaultGenInterval = time.Minute
+)
+
+type config struct {
+	Listen      string        `short:"l" long:"listen" description:"Interface/port to serve the msgstore API on"`
+	NumHeaders  int           `short:"n" long:"numheaders" description:"Number of message headers to generate on startup"`
+	Lifetime    time.Duration `long:"lifetime" description:"Lifetime of generated message headers"`
+	GenInterval time.Duration `long:"geninterval" description:"Interval between generating new message headers -- Use 0 to disable"`
+}
+
+func main() {
+	cfg := config{
+		Listen:      defaultListen,
+		NumHeaders:  defaultNumHeaders,
+		Lifetime:    fakemsgstore.DefaultHeaderLifetime,
+		GenInterval: defaultGenInterval,
+	}
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+
+	store := fakemsgstore.New(&fakemsgstore.Config{
+		HeaderLifetime: cfg.Lifetime,
+	})
+	if _, err := store.GenerateHeaders(cfg.NumHeaders); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot generate message headers: %v\n", err)
+		os.Exit(1)
+	}
+	if err := store.Start(cfg.Listen); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot listen on %s: %v\n", cfg.Listen, err)
+		os.Exit(1)
+	}
+	if cfg.GenInterval > 0 {
+		store.GenerateEvery(cfg.GenInterval)
+	}
+	fmt.Printf("Serving %d message headers on %s\n", store.Count(),
+		store.Addr())
+
+	// Serve until interrupted.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+
+	if err := store.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot stop: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	flags "github.com/btcsuite/go-flags"
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/jadeblaquiere/cttd/fakemsgstore"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+const (
+	defaultBlockVersion = 101
+	defaultPowLimitBits = 0x1f07ffff
+	defaultPayout       = 1024
+	defaultNumHeaders   = 64
+)
+
+var (
+	cttdHomeDir        = cttutil.AppDataDir("cttd", false)
+	defaultHeaderDbDir = filepath.Join(cttdHomeDir, "gengenesis", "hdb")
+)
+
+// config defines the configuration options for gengenesis.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	Name            string        `short:"n" long:"name" description:"Name of the network, such as ctbluenet"`
+	GoName          string        `long:"goname" description:"Go identifier of the network used for the generated parameters, such as CTBlueNet (default derived from the network name)"`
+	Message         string        `short:"m" long:"message" description:"Timestamp message to embed in the coinbase transaction, such as a news headline of the day"`
+	Time            int64         `short:"t" long:"time" description:"Unix time of the genesis block (default now)"`
+	BlockVersion    int32         `long:"blockversion" description:"Version of the genesis block"`
+	PowLimitBits    string        `short:"b" long:"powlimitbits" description:"Proof of work limit of the network in compact form, which is also the difficulty of the genesis block"`
+	Payout          float64       `long:"payout" description:"Amount of the coinbase output of the genesis block in coins"`
+	PayoutPubKey    string        `long:"payoutpubkey" description:"Hex encoded public key the coinbase output pays to (default a newly generated key)"`
+	HeaderCacheHost string        `long:"headercachehost" description:"Host of the message store to take the nonce headers from (default generate fake message headers)"`
+	HeaderCachePort uint16        `long:"headercacheport" description:"Port of the message store to take the nonce headers from"`
+	HeaderCacheDir  string        `long:"headercachedir" description:"Directory for the header cache database of the message store"`
+	NumHeaders      int           `long:"numheaders" description:"Number of fake message headers to generate when no message store is used"`
+	HeaderLifetime  time.Duration `long:"headerlifetime" description:"Lifetime of the generated fake message headers"`
+	Magic           string        `long:"magic" description:"Hex encoded magic of the network used for the parameters file"`
+	Port            string        `long:"port" description:"Default peer-to-peer port of the network"`
+	JSON            bool          `short:"j" long:"json" description:"Print a parameters file for the netparams option of cttd instead of Go source"`
+}
+
+// parseMagic returns the network magic for the passed hex string, which may
+// have a 0x prefix.
+func parseMagic(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	magic, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("malformed network magic %q", s)
+	}
+	return uint32(magic), nil
+}
+
+// parsePowLimitBits returns the compact proof of work limit for the passed
+// hex string, which may have a 0x prefix.
+func parsePowLimitBits(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	bits, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("malformed compact bits %q", s)
+	}
+	return uint32(bits), nil
+}
+
+// defaultGoName returns the Go identifier used for the network with the passed
+// name.  The network names follow the same pattern as the existing networks,
+// so ctbluenet becomes CTBlueNet.
+func defaultGoName(name string) string {
+	stem := strings.TrimSuffix(strings.ToLower(name), "net")
+	prefix := ""
+	if strings.HasPrefix(stem, "ct") {
+		prefix = "CT"
+		stem = stem[2:]
+	}
+	if stem == "" {
+		return prefix + "Net"
+	}
+	return prefix + strings.ToUpper(stem[:1]) + stem[1:] + "Net"
+}
+
+// validGoName returns whether or not the passed name is a valid exported Go
+// identifier.
+func validGoName(name string) bool {
+	for i, r := range name {
+		switch {
+		case i == 0 && !unicode.IsUpper(r):
+			return false
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_':
+			return false
+		}
+	}
+	return name != ""
+}
+
+// loadConfig initializes and parses the config using command line options.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := config{
+		BlockVersion:   defaultBlockVersion,
+		PowLimitBits:   fmt.Sprintf("%08x", defaultPowLimitBits),
+		Payout:         defaultPayout,
+		HeaderCacheDir: defaultHeaderDbDir,
+		NumHeaders:     defaultNumHeaders,
+		HeaderLifetime: fakemsgstore.DefaultHeaderLifetime,
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	remainingArgs, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, nil, err
+	}
+
+	// The network name and timestamp message are required.
+	funcName := "loadConfig"
+	if cfg.Name == "" || cfg.Message == "" {
+		err := fmt.Errorf("%s: the network name and timestamp message "+
+			"must be specified", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	if cfg.GoName == "" {
+		cfg.GoName = defaultGoName(cfg.Name)
+	}
+	if !validGoName(cfg.GoName) {
+		err := fmt.Errorf("%s: %q is not an exported Go identifier",
+			funcName, cfg.GoName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Validate the proof of work limit and payout.
+	if _, err := parsePowLimitBits(cfg.PowLimitBits); err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	if _, err := cttutil.NewAmount(cfg.Payout); err != nil || cfg.Payout < 0 {
+		err := fmt.Errorf("%s: invalid payout %v", funcName, cfg.Payout)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	if cfg.Magic != "" {
+		if _, err := parseMagic(cfg.Magic); err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			parser.WriteHelp(os.Stderr)
+			return nil, nil, err
+		}
+	}
+	if cfg.Port != "" {
+		if _, err := strconv.ParseUint(cfg.Port, 10, 16); err != nil {
+			err := fmt.Errorf("%s: invalid port %q", funcName, cfg.Port)
+			fmt.Fprintln(os.Stderr, err)
+			parser.WriteHelp(os.Stderr)
+			return nil, nil, err
+		}
+	}
+	if cfg.PayoutPubKey != "" {
+		pubKey, err := hex.DecodeString(cfg.PayoutPubKey)
+		if err == nil {
+			_, err = btcec.ParsePubKey(pubKey, btcec.S256())
+		}
+		if err != nil {
+			err := fmt.Errorf("%s: invalid payout public key: %v",
+				funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			parser.WriteHelp(os.Stderr)
+			return nil, nil, err
+		}
+	}
+
+	// Either a message store is used for the nonce headers or enough fake
+	// message headers are generated to provide pairs of them.
+	if cfg.HeaderCacheHost != "" && cfg.HeaderCachePort == 0 {
+		err := fmt.Errorf("%s: the message store port must be specified "+
+			"along with its host", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	if cfg.HeaderCacheHost == "" && cfg.NumHeaders < 2 {
+		err := fmt.Errorf("%s: at least 2 fake message headers are "+
+			"required -- parsed [%v]", funcName, cfg.NumHeaders)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	return &cfg, remainingArgs, nil
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/fakemsgstore"
+	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/wire"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+// coinbaseFlags is pushed to the signature script of the genesis coinbase
+// transaction after the proof of work limit in the same way as the existing
+// genesis blocks.
+const coinbaseFlags = 4
+
+// genesisCoinbaseTx returns the coinbase transaction of a genesis block with
+// the passed difficulty bits which embeds the passed timestamp message and pays
+// the passed amount to the passed serialized public key.
+//
+// The signature script pushes the bits in little endian, the coinbase flags and
+// the message, which is the same layout as the existing genesis blocks.
+func genesisCoinbaseTx(bits uint32, message string, amount cttutil.Amount, pubKey []byte) (*wire.MsgTx, error) {
+	bitsLE := []byte{byte(bits), byte(bits >> 8), byte(bits >> 16),
+		byte(bits >> 24)}
+	sigScript, err := txscript.NewScriptBuilder().AddFullData(bitsLE).
+		AddFullData([]byte{coinbaseFlags}).AddData([]byte(message)).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.NewScriptBuilder().AddData(pubKey).
+		AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx()
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex), sigScript))
+	tx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+	return tx, nil
+}
+
+// loadHeaderSource returns the message header source the nonce headers of the
+// genesis block are taken from.  It is the message store configured for the
+// network when there is one or otherwise an in-memory source with fake message
+// headers stored at the passed genesis time.
+func loadHeaderSource(cfg *config, genesisTime time.Time) (blockchain.MessageHeaderSource, error) {
+	if cfg.HeaderCacheHost != "" {
+		dbdir := filepath.Join(cfg.HeaderCacheDir, cfg.HeaderCacheHost)
+		hcache, err := ciphrtxt.OpenHeaderCache(cfg.HeaderCacheHost,
+			cfg.HeaderCachePort, dbdir)
+		if err != nil {
+			return nil, err
+		}
+		return hcache, nil
+	}
+
+	hs := blockchain.NewMemHeaderSource()
+	for i := 0; i < cfg.NumHeaders; i++ {
+		rh, err := fakemsgstore.GenerateHeader(genesisTime,
+			cfg.HeaderLifetime)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := hs.Insert(rh); err != nil {
+			return nil, err
+		}
+	}
+	return hs, nil
+}
+
+// genesisBlock returns a new genesis block for the passed configuration which
+// has been solved for its proof of work limit.
+func genesisBlock(cfg *config, pubKey []byte) (*wire.MsgBlock, error) {
+	bits, err := parsePowLimitBits(cfg.PowLimitBits)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := cttutil.NewAmount(cfg.Payout)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx, err := genesisCoinbaseTx(bits, cfg.Message, amount, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	genesisTime := time.Unix(cfg.Time, 0)
+	if cfg.Time == 0 {
+		genesisTime = time.Unix(time.Now().Unix(), 0)
+	}
+	hs, err := loadHeaderSource(cfg, genesisTime)
+	if err != nil {
+		return nil, err
+	}
+	// The genesis block is not subject to the nonce header lifetime limits,
+	// so only the message headers which expired before it are excluded.
+	candidates, _, err := blockchain.NonceHeaderCandidates(hs, nil,
+		genesisTime, &chaincfg.Params{})
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) < 2 {
+		return nil, errors.New("not enough unexpired message headers " +
+			"for a pair of nonce headers")
+	}
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    cfg.BlockVersion,
+			MerkleRoot: coinbaseTx.TxHash(),
+			Timestamp:  genesisTime,
+			Bits:       bits,
+		},
+		Transactions: []*wire.MsgTx{coinbaseTx},
+	}
+
+	// The timestamp is the only other part of the header which can change,
+	// so move it forward a second whenever none of the pairs of nonce
+	// headers solve the block.
+	targetDifficulty := blockchain.CompactToBig(bits)
+	numPairs := uint64(len(candidates) * (len(candidates) - 1))
+	for {
+		solved, _ := blockchain.SolveNonceHeaders(&block.Header,
+			candidates, 0, numPairs, 1, targetDifficulty)
+		if solved {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "No solution among %d nonce header pairs "+
+			"at %v\n", numPairs, block.Header.Timestamp.UTC())
+		block.Header.Timestamp = block.Header.Timestamp.Add(time.Second)
+	}
+	return block, nil
+}
+
+func main() {
+	// Load configuration and parse command line.
+	cfg, _, err := loadConfig()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// Pay the coinbase to a new key unless a public key was given.  The
+	// private key of a new key is printed so the payout is not lost.
+	var pubKey []byte
+	if cfg.PayoutPubKey != "" {
+		pubKey, _ = hex.DecodeString(cfg.PayoutPubKey)
+	} else {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot generate payout key: %v\n",
+				err)
+			os.Exit(1)
+		}
+		pubKey = privKey.PubKey().SerializeUncompressed()
+		fmt.Fprintf(os.Stderr, "Generated payout private key %x\n",
+			privKey.Serialize())
+	}
+
+	block, err := genesisBlock(cfg, pubKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot generate genesis block: %v\n", err)
+		os.Exit(1)
+	}
+	hash := block.Header.BlockHash()
+	fmt.Fprintf(os.Stderr, "Solved genesis block %v\n", hash)
+
+	if cfg.JSON {
+		if err := writeParamsJSON(os.Stdout, cfg, block); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot write parameters file: %v\n",
+				err)
+			os.Exit(1)
+		}
+		return
+	}
+	writeGenesisSource(os.Stdout, cfg, block)
+	fmt.Fprintln(os.Stdout)
+	writeParamsSource(os.Stdout, cfg, block)
+}
@@ -0,0 +1,342 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/wire"
+)
+
+// bytesPerLine is the number of bytes written on each line of a byte array.
+const bytesPerLine = 8
+
+// varPrefix returns the prefix of the unexported genesis variables of the
+// network with the passed name, such as ctblueNet for ctbluenet.
+func varPrefix(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), "net") + "Net"
+}
+
+// powLimit returns the proof of work limit for the passed compact bits, which
+// is the largest value of the form 2^n - 1 within the limit given by them.
+func powLimit(bits uint32) *big.Int {
+	n := blockchain.CompactToBig(bits).BitLen()
+	return new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(n)),
+		big.NewInt(1))
+}
+
+// writeBytes writes the passed bytes as the elements of a Go byte array
+// literal.  Each line is followed by a comment with the printable ASCII
+// characters of its bytes when ascii is set, like the output of hexdump -C.
+func writeBytes(w io.Writer, b []byte, ascii bool) {
+	for len(b) > 0 {
+		n := bytesPerLine
+		if len(b) < n {
+			n = len(b)
+		}
+		line := b[:n]
+		b = b[n:]
+
+		for _, c := range line {
+			fmt.Fprintf(w, "0x%02x, ", c)
+		}
+		if ascii {
+			text := make([]byte, len(line))
+			for i, c := range line {
+				text[i] = '.'
+				if c >= 0x20 && c < 0x7f {
+					text[i] = c
+				}
+			}
+			fmt.Fprintf(w, "/* |%s| */", text)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeFormatted writes the passed Go declarations to w after formatting them
+// with gofmt.  They are written as is if they can't be formatted so the output
+// can still be inspected.
+func writeFormatted(w io.Writer, src []byte) {
+	if formatted, err := format.Source(src); err == nil {
+		src = formatted
+	}
+	w.Write(src)
+}
+
+// writeGenesisSource writes the Go source of the genesis block variables of the
+// passed genesis block to w in the format of chaincfg/genesis.go.
+func writeGenesisSource(w io.Writer, cfg *config, block *wire.MsgBlock) {
+	prefix := varPrefix(cfg.Name)
+	coinbaseTx := block.Transactions[0]
+	header := &block.Header
+	hash := header.BlockHash()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %sGenesisCoinbaseTx is the coinbase transaction "+
+		"for the genesis block\n// for the %s network.\n", prefix,
+		cfg.Name)
+	fmt.Fprintf(&buf, "var %sGenesisCoinbaseTx = wire.MsgTx{\n", prefix)
+	fmt.Fprintf(&buf, "Version: %d,\n", coinbaseTx.Version)
+	fmt.Fprintln(&buf, "TxIn: []*wire.TxIn{")
+	for _, txIn := range coinbaseTx.TxIn {
+		fmt.Fprintln(&buf, "{")
+		fmt.Fprintln(&buf, "PreviousOutPoint: wire.OutPoint{")
+		fmt.Fprintln(&buf, "Hash: chainhash.Hash{},")
+		fmt.Fprintf(&buf, "Index: 0x%08x,\n", txIn.PreviousOutPoint.Index)
+		fmt.Fprintln(&buf, "},")
+		fmt.Fprintln(&buf, "SignatureScript: []byte{")
+		writeBytes(&buf, txIn.SignatureScript, true)
+		fmt.Fprintln(&buf, "},")
+		fmt.Fprintf(&buf, "Sequence: 0x%08x,\n", txIn.Sequence)
+		fmt.Fprintln(&buf, "},")
+	}
+	fmt.Fprintln(&buf, "},")
+	fmt.Fprintln(&buf, "TxOut: []*wire.TxOut{")
+	for _, txOut := range coinbaseTx.TxOut {
+		fmt.Fprintln(&buf, "{")
+		fmt.Fprintf(&buf, "Value: 0x%x,\n", txOut.Value)
+		fmt.Fprintln(&buf, "PkScript: []byte{")
+		writeBytes(&buf, txOut.PkScript, true)
+		fmt.Fprintln(&buf, "},")
+		fmt.Fprintln(&buf, "},")
+	}
+	fmt.Fprintln(&buf, "},")
+	fmt.Fprintf(&buf, "LockTime: %d,\n", coinbaseTx.LockTime)
+	fmt.Fprint(&buf, "}\n\n")
+
+	fmt.Fprintf(&buf, "// %sGenesisHash is the hash of the first block in "+
+		"the block chain for the\n// %s network (genesis block).\n",
+		prefix, cfg.Name)
+	fmt.Fprintf(&buf, "var %sGenesisHash = chainhash.Hash("+
+		"[chainhash.HashSize]byte{ // Make go vet happy.\n", prefix)
+	writeBytes(&buf, hash[:], false)
+	fmt.Fprint(&buf, "})\n\n")
+
+	fmt.Fprintf(&buf, "// %sGenesisMerkleRoot is the hash of the first "+
+		"transaction in the genesis\n// block for the %s network.\n",
+		prefix, cfg.Name)
+	fmt.Fprintf(&buf, "var %sGenesisMerkleRoot = chainhash.Hash("+
+		"[chainhash.HashSize]byte{ // Make go vet happy.\n", prefix)
+	writeBytes(&buf, header.MerkleRoot[:], false)
+	fmt.Fprint(&buf, "})\n\n")
+
+	fmt.Fprintf(&buf, "// %sGenesisBlock defines the genesis block of the "+
+		"block chain which serves\n// as the public transaction ledger "+
+		"for the %s network.\n", prefix, cfg.Name)
+	fmt.Fprintf(&buf, "var %sGenesisBlock = wire.MsgBlock{\n", prefix)
+	fmt.Fprintln(&buf, "Header: wire.BlockHeader{")
+	fmt.Fprintf(&buf, "Version: %d,\n", header.Version)
+	fmt.Fprintf(&buf, "PrevBlock: chainhash.Hash{}, // %v\n",
+		header.PrevBlock)
+	fmt.Fprintf(&buf, "MerkleRoot: %sGenesisMerkleRoot, // %v\n", prefix,
+		header.MerkleRoot)
+	fmt.Fprintf(&buf, "Timestamp: time.Unix(0x%x, 0), // %s\n",
+		header.Timestamp.Unix(),
+		header.Timestamp.UTC().Format("Mon Jan 2 15:04:05 2006"))
+	fmt.Fprintf(&buf, "Bits: 0x%08x, // %d [%064x]\n", header.Bits,
+		header.Bits, blockchain.CompactToBig(header.Bits))
+	fmt.Fprintln(&buf, "NonceHeaderA: [ciphrtxt.MessageHeaderLengthV2]byte{")
+	writeBytes(&buf, header.NonceHeaderA[:], true)
+	fmt.Fprintln(&buf, "},")
+	fmt.Fprintln(&buf, "NonceHeaderB: [ciphrtxt.MessageHeaderLengthV2]byte{")
+	writeBytes(&buf, header.NonceHeaderB[:], true)
+	fmt.Fprintln(&buf, "},")
+	fmt.Fprintln(&buf, "},")
+	fmt.Fprintf(&buf, "Transactions: []*wire.MsgTx{&%sGenesisCoinbaseTx},\n",
+		prefix)
+	fmt.Fprintln(&buf, "}")
+
+	writeFormatted(w, buf.Bytes())
+}
+
+// writeParamsSource writes the Go source of a skeleton of the network
+// parameters for the passed genesis block to w in the format of
+// chaincfg/params.go.  The proof of work limit is the one returned by
+// powLimit.
+//
+// Values which must be unique to the network, such as its magic and address
+// encoding magics, are left for the caller to fill in and are marked TODO.
+func writeParamsSource(w io.Writer, cfg *config, block *wire.MsgBlock) {
+	prefix := varPrefix(cfg.Name)
+	bits := block.Header.Bits
+	powLimitLen := powLimit(bits).BitLen()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %sPowLimit is the highest proof of work value a "+
+		"ciphrtxt block\n// can have for the %s network.  It is the "+
+		"value 2^%d - 1.\n", prefix, cfg.Name, powLimitLen)
+	fmt.Fprintf(&buf, "var %sPowLimit = new(big.Int).Sub(new(big.Int)."+
+		"Lsh(bigOne, %d), bigOne)\n\n", prefix, powLimitLen)
+
+	fmt.Fprintf(&buf, "// %sParams defines the network parameters for the "+
+		"ciphrtxt token (CT)\n// %s network.\n", cfg.GoName, cfg.Name)
+	fmt.Fprintf(&buf, "var %sParams = Params{\n", cfg.GoName)
+	fmt.Fprintf(&buf, "Name: %q,\n", cfg.Name)
+	if cfg.Magic != "" {
+		magic, _ := parseMagic(cfg.Magic)
+		fmt.Fprintf(&buf, "Net: wire.%s, // TODO: Add %s BitcoinNet = "+
+			"0x%08x to wire/protocol.go.\n", cfg.GoName, cfg.GoName,
+			magic)
+	} else {
+		fmt.Fprintf(&buf, "Net: wire.%s, // TODO: Add a unique magic "+
+			"to wire/protocol.go.\n", cfg.GoName)
+	}
+	if cfg.Port != "" {
+		fmt.Fprintf(&buf, "DefaultPort: %q,\n", cfg.Port)
+	} else {
+		fmt.Fprintln(&buf, "DefaultPort: \"\", // TODO: Choose a "+
+			"unique port.")
+	}
+	fmt.Fprintln(&buf, "DNSSeeds: []string{},")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Chain parameters")
+	fmt.Fprintf(&buf, "GenesisBlock: &%sGenesisBlock,\n", prefix)
+	fmt.Fprintf(&buf, "GenesisHash: &%sGenesisHash,\n", prefix)
+	fmt.Fprintf(&buf, "PowLimit: %sPowLimit,\n", prefix)
+	fmt.Fprintf(&buf, "PowLimitBits: 0x%08x,\n", bits)
+	fmt.Fprintln(&buf, "CoinbaseMaturity: 100,")
+	fmt.Fprintln(&buf, "SubsidyInitialHalflife: 10080,")
+	fmt.Fprintln(&buf, "TargetTimespan: time.Hour * 2, // 2 hours")
+	fmt.Fprintln(&buf, "TargetTimePerBlock: time.Minute * 1, // 1 minute")
+	fmt.Fprintln(&buf, "RetargetAdjustmentFactor: 4, // 25% less, 400% more")
+	fmt.Fprintln(&buf, "ReduceMinDifficulty: false,")
+	fmt.Fprintln(&buf, "MinDiffReductionTime: 0,")
+	fmt.Fprintln(&buf, "GenerateSupported: true,")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Checkpoints ordered from oldest to newest.")
+	fmt.Fprintln(&buf, "Checkpoints: nil,")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Enforce current block version once majority of "+
+		"the network has\n// upgraded.\n// 51% (51 / 100)\n// Reject "+
+		"previous block versions once a majority of the network has\n"+
+		"// upgraded.\n// 75% (75 / 100)")
+	fmt.Fprintln(&buf, "BlockEnforceNumRequired: 51,")
+	fmt.Fprintln(&buf, "BlockRejectNumRequired: 75,")
+	fmt.Fprintln(&buf, "BlockUpgradeNumToCheck: 100,")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Forbid reusing the nonce headers of the blocks "+
+		"of the past day.")
+	fmt.Fprintln(&buf, "NonceHeaderReuseWindow: 1440,")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Only allow nonce headers of messages stored "+
+		"within the past week\n// which remain available for at least "+
+		"another hour.")
+	fmt.Fprintln(&buf, "NonceHeaderMaxAge: time.Hour * 24 * 7,")
+	fmt.Fprintln(&buf, "NonceHeaderMinLifetime: time.Hour,")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Consensus rule change deployments.\n//\n"+
+		"// Miners vote on rule changes over a day's worth of blocks.")
+	fmt.Fprintln(&buf, "RuleChangeActivationThreshold: 1368, // 95% of "+
+		"MinerConfirmationWindow")
+	fmt.Fprintln(&buf, "MinerConfirmationWindow: 1440,")
+	fmt.Fprintln(&buf, "Deployments: [DefinedDeployments]"+
+		"ConsensusDeployment{")
+	fmt.Fprintln(&buf, "DeploymentTestDummy: {")
+	fmt.Fprintln(&buf, "BitNumber: 28,")
+	fmt.Fprintln(&buf, "StartTime: 1199145601, // January 1, 2008 UTC")
+	fmt.Fprintln(&buf, "ExpireTime: 1230767999, // December 31, 2008 UTC")
+	fmt.Fprintln(&buf, "},")
+	fmt.Fprintln(&buf, "},")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Mempool parameters")
+	fmt.Fprintln(&buf, "RelayNonStdTxs: true,")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Address encoding magics")
+	fmt.Fprintln(&buf, "// TODO: Choose unique address encoding magics.")
+	fmt.Fprintln(&buf, "PubKeyHashAddrID: 0x00,")
+	fmt.Fprintln(&buf, "ScriptHashAddrID: 0x00,")
+	fmt.Fprintln(&buf, "PrivateKeyID: 0x00,")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// BIP32 hierarchical deterministic extended key "+
+		"magics")
+	fmt.Fprintln(&buf, "// TODO: Choose unique extended key magics.")
+	fmt.Fprintln(&buf, "HDPrivateKeyID: [4]byte{0x00, 0x00, 0x00, 0x00},")
+	fmt.Fprintln(&buf, "HDPublicKeyID: [4]byte{0x00, 0x00, 0x00, 0x00},")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// BIP44 coin type used in the hierarchical "+
+		"deterministic path for\n// address generation.")
+	fmt.Fprintln(&buf, "HDCoinType: 0, // TODO: Choose a unique coin type.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Ciphrtxt Msgstore Service for Header Cache")
+	fmt.Fprintf(&buf, "CTMsgstoreHost: %q,\n", cfg.HeaderCacheHost)
+	port := ""
+	if cfg.HeaderCachePort != 0 {
+		port = fmt.Sprintf("%d", cfg.HeaderCachePort)
+	}
+	fmt.Fprintf(&buf, "CTMsgstorePort: %q,\n", port)
+	fmt.Fprintln(&buf, "}")
+
+	writeFormatted(w, buf.Bytes())
+}
+
+// writeParamsJSON writes a parameters file for the passed genesis block to w
+// which can be loaded with the netparams option of cttd.  It uses the same
+// defaults as writeParamsSource, so the address encoding and extended key
+// magics along with the coin type must still be made unique to the network.
+func writeParamsJSON(w io.Writer, cfg *config, block *wire.MsgBlock) error {
+	var genesisBuf bytes.Buffer
+	if err := block.Serialize(&genesisBuf); err != nil {
+		return err
+	}
+	var magic uint32
+	if cfg.Magic != "" {
+		magic, _ = parseMagic(cfg.Magic)
+	}
+	port := ""
+	if cfg.HeaderCachePort != 0 {
+		port = fmt.Sprintf("%d", cfg.HeaderCachePort)
+	}
+
+	f := chaincfg.ParamsFile{
+		Name:                          cfg.Name,
+		Net:                           magic,
+		DefaultPort:                   cfg.Port,
+		GenesisBlock:                  hex.EncodeToString(genesisBuf.Bytes()),
+		GenesisHash:                   block.BlockHash().String(),
+		PowLimit:                      fmt.Sprintf("%x", powLimit(block.Header.Bits)),
+		PowLimitBits:                  block.Header.Bits,
+		CoinbaseMaturity:              100,
+		SubsidyInitialHalflife:        10080,
+		TargetTimespan:                (time.Hour * 2).String(),
+		TargetTimePerBlock:            time.Minute.String(),
+		RetargetAdjustmentFactor:      4,
+		GenerateSupported:             true,
+		BlockEnforceNumRequired:       51,
+		BlockRejectNumRequired:        75,
+		BlockUpgradeNumToCheck:        100,
+		NonceHeaderReuseWindow:        1440,
+		NonceHeaderMaxAge:             (time.Hour * 24 * 7).String(),
+		NonceHeaderMinLifetime:        time.Hour.String(),
+		RuleChangeActivationThreshold: 1368,
+		MinerConfirmationWindow:       1440,
+		Deployments: []chaincfg.DeploymentFile{{
+			BitNumber:  28,
+			StartTime:  1199145601,
+			ExpireTime: 1230767999,
+		}},
+		RelayNonStdTxs: true,
+		HDPrivateKeyID: "00000000",
+		HDPublicKeyID:  "00000000",
+		CTMsgstoreHost: cfg.HeaderCacheHost,
+		CTMsgstorePort: port,
+	}
+	b, err := json.MarshalIndent(&f, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
@@ -48,11 +49,16 @@ const (
 	blockMaxSizeMin              = 1000
 	blockMaxSizeMax              = wire.MaxBlockPayload - 1000
 	defaultGenerate              = false
+	defaultStratumShareDiff      = 1.0
+	defaultMaxStratumClients     = 25
 	defaultMaxOrphanTransactions = 1000
 	defaultMaxOrphanTxSize       = 5000
 	defaultSigCacheMaxSize       = 100000
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
+	defaultAKIndex               = false
+	defaultDirIndex              = false
+	defaultNonceHdrIndex         = false
 )
 
 var (
@@ -116,6 +122,8 @@ type config struct {
 	NoOnion            bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	TorIsolation       bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
 	CTRedNet           bool          `long:"ctrednet" description:"Use the ciphrtxt-red test network"`
+	CTSimNet           bool          `long:"ctsimnet" description:"Use the ciphrtxt simulation test network"`
+	NetParams          string        `long:"netparams" description:"Use the custom network defined by the specified JSON parameters file"`
 	DisableCheckpoints bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
 	DbType             string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
 	Profile            string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
@@ -123,11 +131,19 @@ type config struct {
 	DebugLevel         string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp               bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	MinRelayTxFee      float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
+	MinAccessKeyFee    float64       `long:"minaccesskeyfee" description:"The additional fee in BTC required for each output which registers a network access key"`
+	MinDirectoryFee    float64       `long:"mindirectoryfee" description:"The additional fee in BTC required for each output which posts a directory entry"`
+	DirectoryFeeRate   float64       `long:"directoryfeerate" description:"The additional fee in BTC/kB required for the data posted by each directory entry output"`
 	FreeTxRelayLimit   float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	NoRelayPriority    bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	MaxOrphanTxs       int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
 	Generate           bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
 	MiningAddrs        []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
+	StratumListeners   []string      `long:"stratumlisten" description:"Add an interface/port to listen for stratum mining connections (default port: 7767, ctrednet: 17764, ctsimnet: 27764) -- At least one mining address is required"`
+	StratumShareDiff   float64       `long:"stratumsharediff" description:"Difficulty of the shares submitted by stratum miners relative to the proof-of-work limit"`
+	StratumUser        string        `long:"stratumuser" description:"Username stratum workers must authorize with, optionally followed by a period and a worker name"`
+	StratumPass        string        `long:"stratumpass" default-mask:"-" description:"Password stratum workers must authorize with"`
+	StratumMaxClients  int           `long:"stratummaxclients" description:"Max number of stratum mining connections"`
 	BlockMinSize       uint32        `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
 	BlockMaxSize       uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
 	BlockPrioritySize  uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
@@ -139,6 +155,12 @@ type config struct {
 	DropTxIndex        bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	AddrIndex          bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	DropAddrIndex      bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	AKIndex            bool          `long:"akindex" description:"Maintain an index of registered network access keys which makes the getaccesskey and listaccesskeys RPCs available"`
+	DropAKIndex        bool          `long:"dropakindex" description:"Deletes the access key index from the database on start up and then exits."`
+	DirIndex           bool          `long:"dirindex" description:"Maintain an index of posted directory entries which makes the getdirectoryentry, listdirectoryentries and searchdirectory RPCs available"`
+	DropDirIndex       bool          `long:"dropdirindex" description:"Deletes the directory index from the database on start up and then exits."`
+	NonceHdrIndex      bool          `long:"noncehdrindex" description:"Maintain an index of the message headers used as block nonces which makes the getblocksbynonceheader RPC available"`
+	DropNonceHdrIndex  bool          `long:"dropnoncehdrindex" description:"Deletes the nonce header index from the database on start up and then exits."`
 	RelayNonStd        bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
 	RejectNonStd       bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
 	HeaderCacheHost    string        `long:"headercachehost" description:"Host for connection to header cache"`
@@ -150,6 +172,9 @@ type config struct {
 	dial               func(string, string) (net.Conn, error)
 	miningAddrs        []cttutil.Address
 	minRelayTxFee      cttutil.Amount
+	minAccessKeyFee    cttutil.Amount
+	minDirectoryFee    cttutil.Amount
+	directoryFeeRate   cttutil.Amount
 }
 
 // serviceOptions defines the configuration options for cttd as a service on
@@ -348,6 +373,9 @@ func loadConfig() (*config, []string, error) {
 		RPCKey:            defaultRPCKeyFile,
 		RPCCert:           defaultRPCCertFile,
 		MinRelayTxFee:     mempool.DefaultMinRelayTxFee.ToBTC(),
+		MinAccessKeyFee:   mempool.DefaultMinAccessKeyFee.ToBTC(),
+		MinDirectoryFee:   mempool.DefaultMinDirectoryFee.ToBTC(),
+		DirectoryFeeRate:  mempool.DefaultDirectoryFeeRate.ToBTC(),
 		FreeTxRelayLimit:  defaultFreeTxRelayLimit,
 		BlockMinSize:      defaultBlockMinSize,
 		BlockMaxSize:      defaultBlockMaxSize,
@@ -355,8 +383,13 @@ func loadConfig() (*config, []string, error) {
 		MaxOrphanTxs:      defaultMaxOrphanTransactions,
 		SigCacheMaxSize:   defaultSigCacheMaxSize,
 		Generate:          defaultGenerate,
+		StratumShareDiff:  defaultStratumShareDiff,
+		StratumMaxClients: defaultMaxStratumClients,
 		TxIndex:           defaultTxIndex,
 		AddrIndex:         defaultAddrIndex,
+		AKIndex:           defaultAKIndex,
+		DirIndex:          defaultDirIndex,
+		NonceHdrIndex:     defaultNonceHdrIndex,
 	}
 
 	// Service options which are only added on Windows.
@@ -467,9 +500,26 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams = &ctredNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.CTSimNet {
+		numNets++
+		// Also disable dns seeding on the ctsimnet test network.
+		activeNetParams = &ctsimNetParams
+		cfg.DisableDNSSeed = true
+	}
+	if cfg.NetParams != "" {
+		numNets++
+		netParams, err := loadNetParams(cleanAndExpandPath(cfg.NetParams))
+		if err != nil {
+			str := "%s: Failed to load network parameters from %s: %v"
+			err := fmt.Errorf(str, funcName, cfg.NetParams, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+		activeNetParams = netParams
+	}
 	if numNets > 1 {
-		str := "%s: The testnet, regtest, and simnet params can't be " +
-			"used together -- choose one of the three"
+		str := "%s: The ctrednet, ctsimnet and netparams params can't " +
+			"be used together -- choose one of the three"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -638,6 +688,36 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the the minaccesskeyfee.
+	cfg.minAccessKeyFee, err = cttutil.NewAmount(cfg.MinAccessKeyFee)
+	if err != nil {
+		str := "%s: invalid minaccesskeyfee: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate the the mindirectoryfee.
+	cfg.minDirectoryFee, err = cttutil.NewAmount(cfg.MinDirectoryFee)
+	if err != nil {
+		str := "%s: invalid mindirectoryfee: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate the the directoryfeerate.
+	cfg.directoryFeeRate, err = cttutil.NewAmount(cfg.DirectoryFeeRate)
+	if err != nil {
+		str := "%s: invalid directoryfeerate: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the max block size to a sane value.
 	if cfg.BlockMaxSize < blockMaxSizeMin || cfg.BlockMaxSize >
 		blockMaxSizeMax {
@@ -697,6 +777,36 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --akindex and --dropakindex do not mix.
+	if cfg.AKIndex && cfg.DropAKIndex {
+		err := fmt.Errorf("%s: the --akindex and --dropakindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --dirindex and --dropdirindex do not mix.
+	if cfg.DirIndex && cfg.DropDirIndex {
+		err := fmt.Errorf("%s: the --dirindex and --dropdirindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --noncehdrindex and --dropnoncehdrindex do not mix.
+	if cfg.NonceHdrIndex && cfg.DropNonceHdrIndex {
+		err := fmt.Errorf("%s: the --noncehdrindex and "+
+			"--dropnoncehdrindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Check getwork keys are valid and saved parsed versions.
 	cfg.miningAddrs = make([]cttutil.Address, 0, len(cfg.GetWorkKeys)+
 		len(cfg.MiningAddrs))
@@ -751,6 +861,41 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure there is at least one mining address when the stratum server
+	// is enabled.
+	if len(cfg.StratumListeners) > 0 && len(cfg.miningAddrs) == 0 {
+		str := "%s: the stratumlisten option is set, but there are no " +
+			"mining addresses specified"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure the stratum credentials are specified when the stratum server
+	// is enabled since any worker which can reach it could otherwise mine
+	// and disrupt the jobs of the other workers.
+	if len(cfg.StratumListeners) > 0 &&
+		(cfg.StratumUser == "" || cfg.StratumPass == "") {
+
+		str := "%s: the stratumlisten option is set, but the " +
+			"stratumuser and stratumpass options are not"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate the stratum share difficulty.
+	if !(cfg.StratumShareDiff > 0) || math.IsInf(cfg.StratumShareDiff, 1) {
+		str := "%s: the stratumsharediff option must be a positive " +
+			"number -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumShareDiff)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -761,6 +906,11 @@ func loadConfig() (*config, []string, error) {
 	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
 		activeNetParams.rpcPort)
 
+	// Add default port to all stratum listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.StratumListeners = normalizeAddresses(cfg.StratumListeners,
+		activeNetParams.stratumPort)
+
 	// Only allow TLS to be disabled if the RPC is bound to localhost
 	// addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
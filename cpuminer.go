@@ -11,11 +11,14 @@ import (
     //"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
 	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/fakemsgstore"
 	"github.com/jadeblaquiere/cttd/mining"
 	"github.com/jadeblaquiere/cttd/wire"
 	"github.com/jadeblaquiere/cttutil"
@@ -37,8 +40,23 @@ const (
 	// notifying the speed monitor with how many hashes have been completed
 	// while they are actively searching for a solution.  This is done to
 	// reduce the amount of syncs between the workers that must be done to
-	// keep track of the hashes per second.
-	hashUpdateSecs = 15
+	// keep track of the hashes per second.  It is shorter than the update
+	// interval of the monitor so every update includes work from all of
+	// the workers.
+	hashUpdateSecs = 5
+
+	// hashBatchSize is the number of nonce header pairs each worker
+	// searches in between checks for early quit and stale work.
+	hashBatchSize = 64
+
+	// simNonceHeaderBatch is the number of message headers generated at
+	// once for the in-memory message header source used by networks
+	// without a message store.
+	simNonceHeaderBatch = 16
+
+	// simNonceHeaderLifetime is how long the generated message headers
+	// remain unexpired beyond the nonce header min lifetime of the chain.
+	simNonceHeaderLifetime = time.Hour * 12
 )
 
 var (
@@ -59,11 +77,10 @@ type CPUMiner struct {
 	policy            *mining.Policy
 	txSource          mining.TxSource
 	server            *server
-    hCache            *ciphrtxt.HeaderCache
+	hCache            blockchain.MessageHeaderSource
 	numWorkers        uint32
 	started           bool
 	discreteMining    bool
-	submitBlockLock   sync.Mutex
 	wg                sync.WaitGroup
 	workerWg          sync.WaitGroup
 	updateNumWorkers  chan struct{}
@@ -71,6 +88,56 @@ type CPUMiner struct {
 	updateHashes      chan uint64
 	speedMonitorQuit  chan struct{}
 	quit              chan struct{}
+
+	// The following fields house the block template shared by the workers
+	// and the cached nonce header candidates they search.  candidatesLast
+	// is the last block timestamp the candidates may be used for, or zero
+	// when they do not expire.  The candidates are stale once a message
+	// header is added to the header source, which is flagged atomically
+	// since it is done from the header source's notification callback.
+	workMtx         sync.Mutex
+	work            *miningWork
+	candidateMtx    sync.Mutex
+	candidates      []ciphrtxt.BinaryMessageHeaderV2
+	candidateIKeys  map[string]struct{}
+	candidatesTip   chainhash.Hash
+	candidatesLast  time.Time
+	candidatesStale int32
+}
+
+// miningWork houses a block template which is shared by all of the workers so
+// they can split the nonce header pairs searched for each extra nonce between
+// them rather than duplicating each other's work.
+type miningWork struct {
+	block        *wire.MsgBlock
+	height       int32
+	enOffset     uint64
+	generated    time.Time
+	lastTxUpdate time.Time
+}
+
+// isStale returns whether or not the block template of the work is stale given
+// the current best block hash and the last time the memory pool was updated.
+// The template is stale if the best block has changed or the memory pool has
+// been updated since the template was generated and it has been at least one
+// minute.
+func (w *miningWork) isStale(bestHash *chainhash.Hash, lastTxUpdate time.Time) bool {
+	if !w.block.Header.PrevBlock.IsEqual(bestHash) {
+		return true
+	}
+	return lastTxUpdate != w.lastTxUpdate &&
+		time.Now().After(w.generated.Add(time.Minute))
+}
+
+// copyBlock returns a copy of the block template of the work whose extra
+// nonce, timestamp and nonce headers can be updated without affecting the
+// other workers.
+func (w *miningWork) copyBlock() *wire.MsgBlock {
+	msgBlock := *w.block
+	msgBlock.Transactions = make([]*wire.MsgTx, len(w.block.Transactions))
+	copy(msgBlock.Transactions, w.block.Transactions)
+	msgBlock.Transactions[0] = w.block.Transactions[0].Copy()
+	return &msgBlock
 }
 
 // speedMonitor handles tracking the number of hashes per second the mining
@@ -80,6 +147,7 @@ func (m *CPUMiner) speedMonitor() {
 
 	var hashesPerSec float64
 	var totalHashes uint64
+	lastUpdate := time.Now()
 	ticker := time.NewTicker(time.Second * hpsUpdateSecs)
 	defer ticker.Stop()
 
@@ -91,17 +159,21 @@ out:
 		case numHashes := <-m.updateHashes:
 			totalHashes += numHashes
 
-		// Time to update the hashes per second.
-		case <-ticker.C:
-			curHashesPerSec := float64(totalHashes) / hpsUpdateSecs
+		// Time to update the hashes per second.  Each hash is a single
+		// block hash attempt, which is far more expensive than a double
+		// sha256 since it involves a secp256k1 point multiplication.
+		case now := <-ticker.C:
+			elapsed := now.Sub(lastUpdate).Seconds()
+			lastUpdate = now
+			curHashesPerSec := float64(totalHashes) / elapsed
 			if hashesPerSec == 0 {
 				hashesPerSec = curHashesPerSec
 			}
 			hashesPerSec = (hashesPerSec + curHashesPerSec) / 2
 			totalHashes = 0
 			if hashesPerSec != 0 {
-				minrLog.Debugf("Hash speed: %6.0f kilohashes/s",
-					hashesPerSec/1000)
+				minrLog.Debugf("Hash speed: %6.2f hashes/s",
+					hashesPerSec)
 			}
 
 		// Request for the number of hashes per second.
@@ -117,66 +189,195 @@ out:
 	minrLog.Tracef("CPU miner speed monitor done")
 }
 
-// submitBlock submits the passed block to network after ensuring it passes all
-// of the consensus validation rules.
-func (m *CPUMiner) submitBlock(block *cttutil.Block) bool {
-	m.submitBlockLock.Lock()
-	defer m.submitBlockLock.Unlock()
+// submitMinedBlock submits the passed block found by the passed miner to the
+// network after ensuring it passes all of the consensus validation rules.  The
+// CPU miner and the stratum server both submit their blocks through it so the
+// submissions are serialized with each other and with the creation of new CPU
+// miner block templates.
+//
+// This function is safe for concurrent access.
+func (s *server) submitMinedBlock(block *cttutil.Block, miner string) bool {
+	s.submitBlockLock.Lock()
+	defer s.submitBlockLock.Unlock()
 
 	// Ensure the block is not stale since a new block could have shown up
 	// while the solution was being found.  Typically that condition is
 	// detected and all work on the stale block is halted to start work on
 	// a new block, but the check only happens periodically, so it is
 	// possible a block was found and submitted in between.
-	latestHash, _ := m.server.blockManager.chainState.Best()
+	latestHash, _ := s.blockManager.chainState.Best()
 	msgBlock := block.MsgBlock()
 	if !msgBlock.Header.PrevBlock.IsEqual(latestHash) {
-		minrLog.Debugf("Block submitted via CPU miner with previous "+
-			"block %s is stale", msgBlock.Header.PrevBlock)
+		minrLog.Debugf("Block submitted via %s with previous block %s "+
+			"is stale", miner, msgBlock.Header.PrevBlock)
 		return false
 	}
 
 	// Process this block using the same rules as blocks coming from other
 	// nodes.  This will in turn relay it to the network like normal.
-	isOrphan, err := m.server.blockManager.ProcessBlock(block, blockchain.BFNone)
+	isOrphan, err := s.blockManager.ProcessBlock(block, blockchain.BFNone)
 	if err != nil {
 		// Anything other than a rule violation is an unexpected error,
 		// so log that error as an internal error.
 		if _, ok := err.(blockchain.RuleError); !ok {
 			minrLog.Errorf("Unexpected error while processing "+
-				"block submitted via CPU miner: %v", err)
+				"block submitted via %s: %v", miner, err)
 			return false
 		}
 
-		minrLog.Debugf("Block submitted via CPU miner rejected: %v", err)
+		minrLog.Debugf("Block submitted via %s rejected: %v", miner, err)
 		return false
 	}
 	if isOrphan {
-		minrLog.Debugf("Block submitted via CPU miner is an orphan")
+		minrLog.Debugf("Block submitted via %s is an orphan", miner)
 		return false
 	}
 
 	// The block was accepted.
 	coinbaseTx := block.MsgBlock().Transactions[0].TxOut[0]
-	minrLog.Infof("Block submitted via CPU miner accepted (hash %s, "+
-		"amount %v)", block.Hash(), cttutil.Amount(coinbaseTx.Value))
+	minrLog.Infof("Block submitted via %s accepted (hash %s, amount %v)",
+		miner, block.Hash(), cttutil.Amount(coinbaseTx.Value))
 	return true
 }
 
-// solveBlock attempts to find some combination of a nonce, extra nonce, and
-// current timestamp which makes the passed block hash to a value less than the
-// target difficulty.  The timestamp is updated periodically and the passed
-// block is modified with all tweaks during this process.  This means that
-// when the function returns true, the block is ready for submission.
+// generateSimNonceHeaders inserts simNonceHeaderBatch newly generated message
+// headers into the passed message header source so blocks can be generated on
+// demand on networks without a message store.  The messages the headers
+// describe do not exist.
+func generateSimNonceHeaders(hs blockchain.MessageHeaderSource, params *chaincfg.Params) error {
+	now := time.Now()
+	lifetime := params.NonceHeaderMinLifetime + simNonceHeaderLifetime
+	for i := 0; i < simNonceHeaderBatch; i++ {
+		h, err := fakemsgstore.GenerateHeader(now, lifetime)
+		if err != nil {
+			return err
+		}
+		if _, err := hs.Insert(h); err != nil {
+			return err
+		}
+	}
+	minrLog.Debugf("Generated %d message headers", simNonceHeaderBatch)
+	return nil
+}
+
+// candidatesLastBlockTime returns the latest block timestamp for which all of
+// the passed nonce header candidates satisfy the nonce header lifetime limits
+// of the chain parameters.  The zero time is returned when the limits never
+// expire them.
+func candidatesLastBlockTime(candidates []ciphrtxt.BinaryMessageHeaderV2, params *chaincfg.Params) time.Time {
+	var last time.Time
+	for i := range candidates {
+		t, ok := blockchain.NonceHeaderLastBlockTime(&candidates[i], params)
+		if ok && (last.IsZero() || t.Before(last)) {
+			last = t
+		}
+	}
+	return last
+}
+
+// nonceCandidates returns the message headers which may be used as the nonce
+// headers of a block extending the current best chain.  Fetching them from the
+// message header source is expensive, so the list is cached until the best
+// chain changes, a message header is added to the header source or the first
+// of the candidates no longer satisfies the nonce header lifetime limits.  The
+// list is not cached while there are too few candidates to mine with, so the
+// headers the message store syncs on its own are picked up.  The previously
+// returned slice is kept when the fetched headers are unchanged so the workers
+// keep agreeing on the order of the candidates they split between them.
 //
-// This function will return early with false when conditions that trigger a
-// stale block such as a new block showing up or periodically when there are
-// new transactions and enough time has elapsed without finding a solution.
-func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
-	ticker *time.Ticker, quit chan struct{}) bool {
+// This function is safe for concurrent access.
+func (m *CPUMiner) nonceCandidates() ([]ciphrtxt.BinaryMessageHeaderV2, error) {
+	m.candidateMtx.Lock()
+	defer m.candidateMtx.Unlock()
+
+	bestHash, _ := m.server.blockManager.chainState.Best()
+	blockTime := time.Now().Add(nonceHeaderTimeMargin)
+	if m.candidates != nil && m.candidatesTip.IsEqual(bestHash) &&
+		atomic.LoadInt32(&m.candidatesStale) == 0 &&
+		(m.candidatesLast.IsZero() || !blockTime.After(m.candidatesLast)) {
+
+		return m.candidates, nil
+	}
+
+	// Clear the stale flag before fetching the candidates so headers which
+	// are added while they are fetched invalidate them again.
+	atomic.StoreInt32(&m.candidatesStale, 0)
+
+	candidates, ikeys, err := nonceHeaderCandidates(m.hCache,
+		m.server.blockManager.chain, m.server.chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate more message headers when the in-memory message header
+	// source does not have enough unused ones left to solve a block.
+	if len(candidates) < 2 && m.server.simHeaders != nil {
+		err := generateSimNonceHeaders(m.hCache, m.server.chainParams)
+		if err != nil {
+			return nil, err
+		}
+		atomic.StoreInt32(&m.candidatesStale, 0)
+		candidates, ikeys, err = nonceHeaderCandidates(m.hCache,
+			m.server.blockManager.chain, m.server.chainParams)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(candidates) < 2 {
+		m.candidates = nil
+		m.candidateIKeys = nil
+		return candidates, nil
+	}
+	m.candidatesTip = *bestHash
+	m.candidatesLast = candidatesLastBlockTime(candidates,
+		m.server.chainParams)
+
+	if m.candidates != nil && len(ikeys) == len(m.candidateIKeys) {
+		unchanged := true
+		for ikey := range ikeys {
+			if _, exists := m.candidateIKeys[ikey]; !exists {
+				unchanged = false
+				break
+			}
+		}
+		if unchanged {
+			return m.candidates, nil
+		}
+	}
+
+	minrLog.Debugf("Using %d nonce header candidates", len(candidates))
+	m.candidates = candidates
+	m.candidateIKeys = ikeys
+	return candidates, nil
+}
+
+// currentWork returns the block template shared by the workers, generating a
+// new one when there is none or the current one is stale.
+//
+// This function MUST be called with the submit block lock held.
+func (m *CPUMiner) currentWork() (*miningWork, error) {
+	m.workMtx.Lock()
+	defer m.workMtx.Unlock()
 
-	// Choose a random extra nonce offset for this block template and
-	// worker.
+	bestHash, curHeight := m.server.blockManager.chainState.Best()
+	lastTxUpdate := m.txSource.LastUpdated()
+	if m.work != nil && !m.work.isStale(bestHash, lastTxUpdate) {
+		return m.work, nil
+	}
+
+	// Choose a payment address at random.
+	rand.Seed(time.Now().UnixNano())
+	payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+
+	// Create a new block template using the available transactions
+	// in the memory pool as a source of transactions to potentially
+	// include in the block.
+	template, err := NewBlockTemplate(m.policy, m.server, payToAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Choose a random extra nonce offset for this block template.
 	enOffset, err := wire.RandomUint64()
 	if err != nil {
 		minrLog.Errorf("Unexpected error while generating random "+
@@ -184,14 +385,54 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 		enOffset = 0
 	}
 
+	m.work = &miningWork{
+		block:        template.Block,
+		height:       curHeight + 1,
+		enOffset:     enOffset,
+		generated:    time.Now(),
+		lastTxUpdate: lastTxUpdate,
+	}
+	return m.work, nil
+}
+
+// discardWork discards the passed work when it is still the block template
+// shared by the workers so a new one is generated.  It is used once a solution
+// is found since searching the same template again would find it again.
+func (m *CPUMiner) discardWork(work *miningWork) {
+	m.workMtx.Lock()
+	if m.work == work {
+		m.work = nil
+	}
+	m.workMtx.Unlock()
+}
+
+// solveBlock attempts to find some combination of nonce headers, extra nonce,
+// and current timestamp which makes the passed block hash to a value less than
+// the target difficulty.  The passed block is a copy of the block template of
+// the passed work, and for each extra nonce the worker only searches the pairs
+// of nonce header candidates whose index modulo numWorkers is its workerID, so
+// the workers sharing the work never try the same pair.  The timestamp is
+// updated periodically and the passed block is modified with all tweaks during
+// this process.  This means that when the function returns true, the block is
+// ready for submission.
+//
+// This function will return early with false when conditions that trigger a
+// stale block such as a new block showing up or periodically when there are
+// new transactions and enough time has elapsed without finding a solution.
+func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, work *miningWork,
+	workerID, numWorkers uint32, ticker *time.Ticker, quit chan struct{}) bool {
+
 	// Create a couple of convenience variables.
 	header := &msgBlock.Header
 	targetDifficulty := blockchain.CompactToBig(header.Bits)
 
 	// Initial state.
-	lastGenerated := time.Now()
-	lastTxUpdate := m.txSource.LastUpdated()
 	hashesCompleted := uint64(0)
+	defer func() {
+		if hashesCompleted > 0 {
+			m.updateHashes <- hashesCompleted
+		}
+	}()
 
 	// Note that the entire extra nonce range is iterated and the offset is
 	// added relying on the fact that overflow will wrap around 0 as
@@ -199,75 +440,71 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 	for extraNonce := uint64(0); extraNonce < maxExtraNonce; extraNonce++ {
 		// Update the extra nonce in the block template with the
 		// new value by regenerating the coinbase script and
-		// setting the merkle root to the new value.  The
-		UpdateExtraNonce(msgBlock, blockHeight, extraNonce+enOffset)
-        
-        binhdrs := make([]ciphrtxt.BinaryMessageHeaderV2, 0)
-        rhdrs, err := m.hCache.FindExpiringAfter(uint32(time.Now().Unix() + (60*60)))
-        if err != nil {
-            return false
-        }
-        for _, rh := range rhdrs {
-            bh := rh.ExportBinaryHeaderV2()
-            if bh != nil {
-                binhdrs = append(binhdrs, *bh)
-            }
-        }
-
-		// Search through the entire nonce range for a solution while
-		// periodically checking for early quit and stale block
-		// conditions along with updates to the speed monitor.
-		//for i := uint32(0); i <= maxNonce; i++ {
-        for _, mhA := range binhdrs {
-            for _, mhB := range binhdrs {
-            
-                select {
-                case <-quit:
-                    return false
-
-                case <-ticker.C:
-                    m.updateHashes <- hashesCompleted
-                    hashesCompleted = 0
-
-                    // The current block is stale if the best block
-                    // has changed.
-                    bestHash, _ := m.server.blockManager.chainState.Best()
-                    if !header.PrevBlock.IsEqual(bestHash) {
-                        return false
-                    }
-
-                    // The current block is stale if the memory pool
-                    // has been updated since the block template was
-                    // generated and it has been at least one
-                    // minute.
-                    if lastTxUpdate != m.txSource.LastUpdated() &&
-                        time.Now().After(lastGenerated.Add(time.Minute)) {
-
-                        return false
-                    }
-
-                    UpdateBlockTime(msgBlock, m.server.blockManager)
-
-                default:
-                    // Non-blocking select to fall through
-                }
-
-                // Update the nonce and hash the block header.  Each
-                // hash is actually a double sha256 (two hashes), so
-                // increment the number of hashes completed for each
-                // attempt accordingly.
-                header.NonceHeaderA = mhA
-                header.NonceHeaderB = mhB
-                hash := header.BlockHash()
-                hashesCompleted += 2
-
-                // The block is solved when the new block hash is less
-                // than the target difficulty.  Yay!
-                if blockchain.HashToBig(&hash).Cmp(targetDifficulty) <= 0 {
-                    m.updateHashes <- hashesCompleted
-                    return true
-                }
-            }
+		// setting the merkle root to the new value.
+		UpdateExtraNonce(msgBlock, work.height, extraNonce+work.enOffset)
+
+		// At least two candidates are needed since the same header can
+		// not be used as both nonces.  Wait a bit before giving up so
+		// the caller does not spin while the message store has too few
+		// headers.
+		candidates, err := m.nonceCandidates()
+		if err != nil || len(candidates) < 2 {
+			if err != nil {
+				minrLog.Errorf("Failed to fetch nonce header "+
+					"candidates: %v", err)
+			} else {
+				minrLog.Debugf("Not enough nonce header "+
+					"candidates to mine (%d)", len(candidates))
+			}
+			select {
+			case <-quit:
+			case <-time.After(time.Second):
+			}
+			return false
+		}
+
+		// Search through this worker's share of the nonce header pairs
+		// in batches for a solution while periodically checking for
+		// early quit and stale block conditions along with updates to
+		// the speed monitor.
+		numCandidates := uint64(len(candidates))
+		numPairs := numCandidates * (numCandidates - 1)
+		stride := uint64(numWorkers)
+		for first := uint64(workerID); first < numPairs; first += stride * hashBatchSize {
+			select {
+			case <-quit:
+				return false
+
+			case <-ticker.C:
+				m.updateHashes <- hashesCompleted
+				hashesCompleted = 0
+
+				// The current block is stale if the best block
+				// has changed or the memory pool has been
+				// updated since the block template was
+				// generated and it has been at least one
+				// minute.
+				bestHash, _ := m.server.blockManager.chainState.Best()
+				if work.isStale(bestHash, m.txSource.LastUpdated()) {
+					return false
+				}
+
+				UpdateBlockTime(msgBlock, m.server.blockManager)
+
+			default:
+				// Non-blocking select to fall through
+			}
+
+			// Hash the block header with each pair of the batch.
+			// The block is solved when the new block hash is less
+			// than the target difficulty.  Yay!
+			end := first + stride*hashBatchSize
+			solved, numHashes := blockchain.SolveNonceHeaders(header,
+				candidates, first, end, stride, targetDifficulty)
+			hashesCompleted += numHashes
+			if solved {
+				return true
+			}
 		}
 	}
 
@@ -280,8 +517,11 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 // accordingly by generating a new block template.  When a block is solved, it
 // is submitted.
 //
+// The worker searches the nonce header pairs whose index modulo numWorkers is
+// its workerID.
+//
 // It must be run as a goroutine.
-func (m *CPUMiner) generateBlocks(quit chan struct{}) {
+func (m *CPUMiner) generateBlocks(quit chan struct{}, workerID, numWorkers uint32) {
 	minrLog.Tracef("Starting generate blocks worker")
 
 	// Start a ticker which is used to signal checks for stale work and
@@ -311,23 +551,19 @@ out:
 		// submission, since the current block will be changing and
 		// this would otherwise end up building a new block template on
 		// a block that is in the process of becoming stale.
-		m.submitBlockLock.Lock()
+		m.server.submitBlockLock.Lock()
 		_, curHeight := m.server.blockManager.chainState.Best()
 		if curHeight != 0 && !m.server.blockManager.IsCurrent() {
-			m.submitBlockLock.Unlock()
+			m.server.submitBlockLock.Unlock()
 			time.Sleep(time.Second)
 			continue
 		}
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
-
-		// Create a new block template using the available transactions
-		// in the memory pool as a source of transactions to potentially
-		// include in the block.
-		template, err := NewBlockTemplate(m.policy, m.server, payToAddr)
-		m.submitBlockLock.Unlock()
+		// Use the block template shared by all of the workers, which
+		// is created from the available transactions in the memory
+		// pool when there is no current one.
+		work, err := m.currentWork()
+		m.server.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
 				"template: %v", err)
@@ -339,9 +575,11 @@ out:
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, quit) {
-			block := cttutil.NewBlock(template.Block)
-			m.submitBlock(block)
+		msgBlock := work.copyBlock()
+		if m.solveBlock(msgBlock, work, workerID, numWorkers, ticker, quit) {
+			m.discardWork(work)
+			block := cttutil.NewBlock(msgBlock)
+			m.server.submitMinedBlock(block, "CPU miner")
 		}
 	}
 
@@ -364,7 +602,7 @@ func (m *CPUMiner) miningWorkerController() {
 			runningWorkers = append(runningWorkers, quit)
 
 			m.workerWg.Add(1)
-			go m.generateBlocks(quit)
+			go m.generateBlocks(quit, i, numWorkers)
 		}
 	}
 
@@ -383,18 +621,14 @@ out:
 				continue
 			}
 
-			// Add new workers.
-			if m.numWorkers > numRunning {
-				launchWorkers(m.numWorkers - numRunning)
-				continue
-			}
-
-			// Signal the most recently created goroutines to exit.
-			for i := numRunning - 1; i >= m.numWorkers; i-- {
-				close(runningWorkers[i])
-				runningWorkers[i] = nil
-				runningWorkers = runningWorkers[:i]
+			// The nonce header pairs are split between the workers
+			// based on how many of them are running, so signal all
+			// of them to exit and launch the new number of workers.
+			for _, quit := range runningWorkers {
+				close(quit)
 			}
+			runningWorkers = runningWorkers[:0]
+			launchWorkers(m.numWorkers)
 
 		case <-m.quit:
 			for _, quit := range runningWorkers {
@@ -550,6 +784,14 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 			", as it's unlikely to be possible to CPU-mine a block.")
 	}
 
+	// Respond with an error if there is no message header source to
+	// choose the nonce headers of the blocks from.
+	if m.hCache == nil {
+		m.Unlock()
+		return nil, errors.New("No message header source is available " +
+			"to choose the nonce headers of generated blocks from.")
+	}
+
 	// Respond with an error if server is already mining.
 	if m.started || m.discreteMining {
 		m.Unlock()
@@ -588,18 +830,9 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// Grab the lock used for block submission, since the current block will
 		// be changing and this would otherwise end up building a new block
 		// template on a block that is in the process of becoming stale.
-		m.submitBlockLock.Lock()
-		_, curHeight := m.server.blockManager.chainState.Best()
-
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
-
-		// Create a new block template using the available transactions
-		// in the memory pool as a source of transactions to potentially
-		// include in the block.
-		template, err := NewBlockTemplate(m.policy, m.server, payToAddr)
-		m.submitBlockLock.Unlock()
+		m.server.submitBlockLock.Lock()
+		work, err := m.currentWork()
+		m.server.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
 				"template: %v", err)
@@ -610,10 +843,13 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// Attempt to solve the block.  The function will exit early
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
-		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, nil) {
-			block := cttutil.NewBlock(template.Block)
-			m.submitBlock(block)
+		// true a solution was found, so submit the solved block.  The
+		// only worker searches all of the nonce header pairs.
+		msgBlock := work.copyBlock()
+		if m.solveBlock(msgBlock, work, 0, 1, ticker, nil) {
+			m.discardWork(work)
+			block := cttutil.NewBlock(msgBlock)
+			m.server.submitMinedBlock(block, "CPU miner")
 			blockHashes[i] = block.Hash()
 			i++
 			if i == n {
@@ -634,7 +870,7 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 // Use Start to begin the mining process.  See the documentation for CPUMiner
 // type for more details.
 func newCPUMiner(policy *mining.Policy, s *server) *CPUMiner {
-	return &CPUMiner{
+	m := &CPUMiner{
 		policy:            policy,
 		txSource:          s.txMemPool,
         hCache:            s.headerCache,
@@ -644,4 +880,13 @@ func newCPUMiner(policy *mining.Policy, s *server) *CPUMiner {
 		queryHashesPerSec: make(chan float64),
 		updateHashes:      make(chan uint64),
 	}
+
+	// Fetch the nonce header candidates again once a message header is
+	// added to the header source, since it may be a new candidate.
+	if s.headerNotifier != nil {
+		s.headerNotifier.Subscribe(func(*ciphrtxt.RawMessageHeader) {
+			atomic.StoreInt32(&m.candidatesStale, 1)
+		})
+	}
+	return m
 }
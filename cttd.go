@@ -122,6 +122,30 @@ func cttdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropAKIndex {
+		if err := indexers.DropAccessKeyIndex(db); err != nil {
+			cttdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropDirIndex {
+		if err := indexers.DropDirectoryIndex(db); err != nil {
+			cttdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropNonceHdrIndex {
+		if err := indexers.DropNonceHeaderIndex(db); err != nil {
+			cttdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 
 	// Create server and start it.
 	server, err := newServer(cfg.Listeners, db, activeNetParams.Params)
@@ -38,6 +38,7 @@ type config struct {
 	SimNet         bool   `long:"simnet" description:"Use the simulation test network"`
 	CTIndigoNet    bool   `long:"ctindigonet" description:"Use the ciphrtxt indigo network"`
 	CTRedNet       bool   `long:"ctrednet" description:"Use the ciphrtxt red test network"`
+	CTSimNet       bool   `long:"ctsimnet" description:"Use the ciphrtxt simulation test network"`
 }
 
 // fileExists reports whether the named file or directory exists.
@@ -107,9 +108,14 @@ func setupGlobalConfig() error {
 		numNets++
 		activeNetParams = &chaincfg.CTRedNetParams
 	}
+	if cfg.CTSimNet {
+		numNets++
+		activeNetParams = &chaincfg.CTSimNetParams
+	}
 	if numNets > 1 {
-		return errors.New("The testnet, regtest, and simnet params " +
-			"can't be used together -- choose one of the three")
+		return errors.New("The testnet, regtest, simnet, ctindigonet, " +
+			"ctrednet, and ctsimnet params can't be used together " +
+			"-- choose one of them")
 	}
 
 	// Validate database type.
@@ -68,9 +68,10 @@ Application Options:
       --noonion             Disable connecting to tor hidden services
       --torisolation        Enable Tor stream isolation by randomizing user
                             credentials for each connection.
-      --testnet             Use the test network
-      --regtest             Use the regression test network
-      --simnet              Use the simulation test network
+      --ctrednet            Use the ciphrtxt-red test network
+      --ctsimnet            Use the ciphrtxt simulation test network
+      --netparams=          Use the custom network defined by the specified JSON
+                            parameters file
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
       --dbtype=             Database backend to use for the Block Chain (ffldb)
@@ -85,6 +86,12 @@ Application Options:
       --upnp                Use UPnP to map our listening port outside of NAT
       --minrelaytxfee=      The minimum transaction fee in BTC/kB to be
                             considered a non-zero fee.
+      --minaccesskeyfee=    The additional fee in BTC required for each output
+                            which registers a network access key (0.001)
+      --mindirectoryfee=    The additional fee in BTC required for each output
+                            which posts a directory entry (0.0001)
+      --directoryfeerate=   The additional fee in BTC/kB required for the data
+                            posted by each directory entry output (0.0001)
       --limitfreerelay=     Limit relay of transactions with no transaction fee
                             to the given amount in thousands of bytes per
                             minute (15)
@@ -97,6 +104,16 @@ Application Options:
                             addresses to use for generated blocks -- At least
                             one address is required if the generate option is
                             set
+      --stratumlisten=      Add an interface/port to listen for stratum mining
+                            connections (default port: 7767, ctrednet: 17764,
+                            ctsimnet: 27764) -- At least one mining address is
+                            required
+      --stratumsharediff=   Difficulty of the shares submitted by stratum miners
+                            relative to the proof-of-work limit (1)
+      --stratumuser=        Username stratum workers must authorize with,
+                            optionally followed by a period and a worker name
+      --stratumpass=        Password stratum workers must authorize with
+      --stratummaxclients=  Max number of stratum mining connections (25)
       --blockminsize=       Mininum block size in bytes to be used when creating
                             a block
       --blockmaxsize=       Maximum block size in bytes to be used when creating
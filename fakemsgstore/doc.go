@@ -0,0 +1,31 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package fakemsgstore provides a lightweight stand-in for the ciphrtxt message
+store.
+
+Blocks on ciphrtxt networks use message headers from the message store as their
+nonces, so validating and mining blocks normally requires a live message store
+which the ciphrtxt header cache mirrors.  This package implements the parts of
+the msgstore HTTP API that the header cache talks to, holds message headers in
+memory, and generates valid V2 message headers on demand.  It is intended for
+test harnesses, continuous integration and private development networks.
+
+The server may be run in-process:
+
+	store := fakemsgstore.New(&fakemsgstore.Config{})
+	if _, err := store.GenerateHeaders(16); err != nil {
+		// Handle error.
+	}
+	if err := store.Start("127.0.0.1:0"); err != nil {
+		// Handle error.
+	}
+	defer store.Stop()
+
+Since the server also implements blockchain.MessageHeaderSource, it may be
+provided directly to a chain instance instead of being reached over HTTP.  The
+fakemsgstore command runs it as a standalone process.
+*/
+package fakemsgstore
@@ -0,0 +1,383 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fakemsgstore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/blockchain"
+)
+
+const (
+	// DefaultHeaderLifetime is the default lifetime of generated message
+	// headers.
+	DefaultHeaderLifetime = time.Hour * 24 * 7
+
+	// apiPrefix is the path prefix of all msgstore API endpoints which
+	// are implemented.
+	apiPrefix = "/api/v2/"
+
+	// Version is the msgstore version reported by the status endpoint.
+	Version = "0.2.0"
+)
+
+// storedHeader is a message header along with the time it was added to the
+// store, which is the time the "since" queries of the API refer to.
+type storedHeader struct {
+	added  uint32
+	header ciphrtxt.RawMessageHeader
+}
+
+// timeResponse is the reply of the time endpoint.
+type timeResponse struct {
+	Time uint32 `json:"time"`
+}
+
+// storageStatus describes the messages held by the store in the reply of the
+// status endpoint.
+type storageStatus struct {
+	Messages int `json:"messages"`
+}
+
+// statusResponse is the reply of the status endpoint.
+type statusResponse struct {
+	Version string        `json:"version"`
+	Time    uint32        `json:"time"`
+	Storage storageStatus `json:"storage"`
+}
+
+// headerListResponse is the reply of the headers endpoint.
+type headerListResponse struct {
+	HeaderList []string `json:"header_list"`
+}
+
+// headerResponse is the reply of the header lookup endpoint.
+type headerResponse struct {
+	Header string `json:"header"`
+}
+
+// Config is a descriptor containing the fake message store configuration.
+type Config struct {
+	// HeaderLifetime is the lifetime of generated message headers.  The
+	// DefaultHeaderLifetime is used when it is zero.
+	HeaderLifetime time.Duration
+
+	// Now defines the function to use to access the current time.  The
+	// local clock is used when it is nil.
+	Now func() time.Time
+}
+
+// Server is an in-process stand-in for the ciphrtxt message store which
+// implements the parts of the msgstore HTTP API that the ciphrtxt header cache
+// uses to mirror message headers.  It stores message headers only and is able
+// to generate valid headers on demand, so nodes, the CPU miner and test
+// harnesses are able to run without the real message store.
+//
+// It also implements the blockchain.MessageHeaderSource interface, so the same
+// headers may be provided directly to a chain instance.
+//
+// It is safe for concurrent access.
+type Server struct {
+	cfg Config
+
+	mtx      sync.RWMutex
+	headers  []storedHeader
+	byIKey   *blockchain.MemHeaderSource
+	listener net.Listener
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Ensure Server implements the blockchain.MessageHeaderSource and the
+// http.Handler interfaces.
+var _ blockchain.MessageHeaderSource = (*Server)(nil)
+var _ http.Handler = (*Server)(nil)
+
+// now returns the current time according to the server configuration.
+func (s *Server) now() time.Time {
+	if s.cfg.Now != nil {
+		return s.cfg.Now()
+	}
+	return time.Now()
+}
+
+// FindByI returns the message header with the passed I key.  An error is
+// returned when the header is not known.
+//
+// This is part of the blockchain.MessageHeaderSource interface implementation.
+func (s *Server) FindByI(I []byte) (*ciphrtxt.RawMessageHeader, error) {
+	return s.byIKey.FindByI(I)
+}
+
+// FindExpiringAfter returns all known message headers which expire after the
+// passed unix time sorted by their I keys.
+//
+// This is part of the blockchain.MessageHeaderSource interface implementation.
+func (s *Server) FindExpiringAfter(tstamp uint32) ([]ciphrtxt.RawMessageHeader, error) {
+	return s.byIKey.FindExpiringAfter(tstamp)
+}
+
+// Insert adds the passed message header to the store.  It returns whether or
+// not the header was added, which is false when a header with the same I key
+// is already known.
+//
+// This is part of the blockchain.MessageHeaderSource interface implementation.
+func (s *Server) Insert(h *ciphrtxt.RawMessageHeader) (bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	added, err := s.byIKey.Insert(h)
+	if err != nil || !added {
+		return added, err
+	}
+	s.headers = append(s.headers, storedHeader{
+		added:  uint32(s.now().Unix()),
+		header: *h,
+	})
+	return true, nil
+}
+
+// GenerateHeaders generates and stores the passed number of new message
+// headers which expire after the configured header lifetime.
+func (s *Server) GenerateHeaders(count int) ([]*ciphrtxt.RawMessageHeader, error) {
+	lifetime := s.cfg.HeaderLifetime
+	if lifetime == 0 {
+		lifetime = DefaultHeaderLifetime
+	}
+
+	hdrs := make([]*ciphrtxt.RawMessageHeader, 0, count)
+	for i := 0; i < count; i++ {
+		h, err := GenerateHeader(s.now(), lifetime)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.Insert(h); err != nil {
+			return nil, err
+		}
+		hdrs = append(hdrs, h)
+	}
+	return hdrs, nil
+}
+
+// Count returns the number of message headers in the store.
+func (s *Server) Count() int {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return len(s.headers)
+}
+
+// headersSince returns the serialized form of all headers which were added to
+// the store at or after the passed unix time.
+func (s *Server) headersSince(since uint32) []string {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	hdrs := make([]string, 0, len(s.headers))
+	for i := range s.headers {
+		if s.headers[i].added < since {
+			continue
+		}
+		hdrs = append(hdrs, encodeHeader(&s.headers[i].header))
+	}
+	return hdrs
+}
+
+// encodeHeader returns the hex encoded V2 binary form of the passed header as
+// it is transferred by the msgstore API.
+func encodeHeader(h *ciphrtxt.RawMessageHeader) string {
+	b := h.ExportBinaryHeaderV2()
+	if b == nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// writeJSON writes the JSON encoding of the passed reply.
+func writeJSON(w http.ResponseWriter, reply interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// ServeHTTP handles the msgstore API requests.  The supported endpoints are:
+//
+//   GET  /api/v2/time                   the current time of the store
+//   GET  /api/v2/status                 the version and number of messages
+//   GET  /api/v2/headers?since=<time>   all headers added since the time
+//   GET  /api/v2/headers/<I>            the header with the hex I key
+//   POST /api/v2/generate?count=<n>     generate n new headers
+//
+// This is part of the http.Handler interface implementation.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	endpoint := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path,
+		apiPrefix), "/")
+
+	switch {
+	case endpoint == "time" && r.Method == "GET":
+		writeJSON(w, &timeResponse{Time: uint32(s.now().Unix())})
+
+	case endpoint == "status" && r.Method == "GET":
+		writeJSON(w, &statusResponse{
+			Version: Version,
+			Time:    uint32(s.now().Unix()),
+			Storage: storageStatus{Messages: s.Count()},
+		})
+
+	case endpoint == "headers" && r.Method == "GET":
+		var since uint64
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			since, err = strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				http.Error(w, "invalid since parameter",
+					http.StatusBadRequest)
+				return
+			}
+		}
+		writeJSON(w, &headerListResponse{
+			HeaderList: s.headersSince(uint32(since)),
+		})
+
+	case strings.HasPrefix(endpoint, "headers/") && r.Method == "GET":
+		ikey, err := hex.DecodeString(strings.TrimPrefix(endpoint,
+			"headers/"))
+		if err != nil {
+			http.Error(w, "invalid I key", http.StatusBadRequest)
+			return
+		}
+		h, err := s.FindByI(ikey)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, &headerResponse{Header: encodeHeader(h)})
+
+	case endpoint == "generate" && r.Method == "POST":
+		count := 1
+		if v := r.URL.Query().Get("count"); v != "" {
+			var err error
+			count, err = strconv.Atoi(v)
+			if err != nil || count < 1 {
+				http.Error(w, "invalid count parameter",
+					http.StatusBadRequest)
+				return
+			}
+		}
+		hdrs, err := s.GenerateHeaders(count)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reply := headerListResponse{HeaderList: make([]string, 0,
+			len(hdrs))}
+		for _, h := range hdrs {
+			reply.HeaderList = append(reply.HeaderList,
+				encodeHeader(h))
+		}
+		writeJSON(w, &reply)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Start begins serving the msgstore API on the passed address.  Use an
+// address with port 0 to listen on a random free port and Addr to find it.
+func (s *Server) Start(addr string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.listener != nil {
+		return errors.New("fake msgstore is already started")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.quit = make(chan struct{})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		http.Serve(listener, s)
+	}()
+	return nil
+}
+
+// Addr returns the address the server is listening on, or nil when it is not
+// started.
+func (s *Server) Addr() net.Addr {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// GenerateEvery generates a new message header each interval until the server
+// is stopped, which simulates the arrival of new messages.  It must only be
+// called after Start.
+func (s *Server) GenerateEvery(interval time.Duration) {
+	s.mtx.RLock()
+	quit := s.quit
+	s.mtx.RUnlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.GenerateHeaders(1)
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops serving the msgstore API and waits for all goroutines started by
+// the server to finish.
+func (s *Server) Stop() error {
+	s.mtx.Lock()
+	listener := s.listener
+	s.listener = nil
+	if listener != nil {
+		close(s.quit)
+	}
+	s.mtx.Unlock()
+
+	if listener == nil {
+		return errors.New("fake msgstore is not started")
+	}
+	err := listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// New returns a new fake message store which holds no message headers.
+func New(cfg *Config) *Server {
+	return &Server{
+		cfg:    *cfg,
+		byIKey: blockchain.NewMemHeaderSource(),
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fakemsgstore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+)
+
+// TestGenerateHeader ensures generated headers have the requested expire time
+// and unique I keys.
+func TestGenerateHeader(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1475000000, 0)
+	seen := make(map[string]struct{})
+	for i := 0; i < 8; i++ {
+		h, err := GenerateHeader(now, time.Hour)
+		if err != nil {
+			t.Fatalf("GenerateHeader #%d: unexpected error: %v", i, err)
+		}
+		if !h.ExpireTime().Equal(now.Add(time.Hour)) {
+			t.Fatalf("GenerateHeader #%d: unexpected expire time - "+
+				"got %v, want %v", i, h.ExpireTime(),
+				now.Add(time.Hour))
+		}
+		if _, ok := seen[string(h.IKey())]; ok {
+			t.Fatalf("GenerateHeader #%d: duplicate I key %x", i,
+				h.IKey())
+		}
+		seen[string(h.IKey())] = struct{}{}
+
+		// Ensure the header round trips through its binary form.
+		b := h.ExportBinaryHeaderV2()
+		if b == nil {
+			t.Fatalf("ExportBinaryHeaderV2 #%d: unable to export", i)
+		}
+		imported := ciphrtxt.ImportBinaryHeaderV2(b[:])
+		if imported == nil || !bytes.Equal(imported.IKey(), h.IKey()) {
+			t.Fatalf("ImportBinaryHeaderV2 #%d: mismatched header", i)
+		}
+	}
+}
+
+// TestServer ensures the msgstore API endpoints of the server return the
+// headers it holds.
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1475000000, 0)
+	s := New(&Config{Now: func() time.Time { return now }})
+	hdrs, err := s.GenerateHeaders(3)
+	if err != nil {
+		t.Fatalf("GenerateHeaders: unexpected error: %v", err)
+	}
+	if s.Count() != 3 {
+		t.Fatalf("Count: got %d, want 3", s.Count())
+	}
+
+	// Ensure inserting a known header does not add it again.
+	added, err := s.Insert(hdrs[0])
+	if err != nil || added {
+		t.Fatalf("Insert: unexpected result for duplicate - added %v, "+
+			"err %v", added, err)
+	}
+
+	// Ensure the generated headers are unexpired until their lifetime
+	// has passed.
+	unexpired, err := s.FindExpiringAfter(uint32(now.Unix()))
+	if err != nil || len(unexpired) != 3 {
+		t.Fatalf("FindExpiringAfter: unexpected result - got %d "+
+			"headers, err %v", len(unexpired), err)
+	}
+	expiry := now.Add(DefaultHeaderLifetime)
+	unexpired, err = s.FindExpiringAfter(uint32(expiry.Unix()))
+	if err != nil || len(unexpired) != 0 {
+		t.Fatalf("FindExpiringAfter: unexpected result - got %d "+
+			"headers, err %v", len(unexpired), err)
+	}
+
+	// get performs the passed request against the server and decodes the
+	// JSON reply into the passed value.
+	get := func(method, url string, code int, reply interface{}) {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error: %v", err)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Fatalf("%s %s: unexpected status - got %d, want %d",
+				method, url, w.Code, code)
+		}
+		if reply == nil {
+			return
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), reply); err != nil {
+			t.Fatalf("%s %s: unable to decode reply: %v", method,
+				url, err)
+		}
+	}
+
+	var timeReply timeResponse
+	get("GET", "/api/v2/time", http.StatusOK, &timeReply)
+	if int64(timeReply.Time) != now.Unix() {
+		t.Fatalf("time: got %d, want %d", timeReply.Time, now.Unix())
+	}
+
+	var status statusResponse
+	get("GET", "/api/v2/status/", http.StatusOK, &status)
+	if status.Storage.Messages != 3 || status.Version != Version {
+		t.Fatalf("status: unexpected reply %+v", status)
+	}
+
+	var list headerListResponse
+	get("GET", fmt.Sprintf("/api/v2/headers?since=%d", now.Unix()),
+		http.StatusOK, &list)
+	if len(list.HeaderList) != 3 {
+		t.Fatalf("headers: got %d headers, want 3", len(list.HeaderList))
+	}
+	for i, encoded := range list.HeaderList {
+		b, err := hex.DecodeString(encoded)
+		if err != nil {
+			t.Fatalf("headers #%d: unable to decode: %v", i, err)
+		}
+		h := ciphrtxt.ImportBinaryHeaderV2(b)
+		if h == nil || !bytes.Equal(h.IKey(), hdrs[i].IKey()) {
+			t.Fatalf("headers #%d: mismatched header", i)
+		}
+	}
+	get("GET", fmt.Sprintf("/api/v2/headers?since=%d", now.Unix()+1),
+		http.StatusOK, &list)
+	if len(list.HeaderList) != 0 {
+		t.Fatalf("headers: got %d headers, want 0", len(list.HeaderList))
+	}
+	get("GET", "/api/v2/headers?since=bogus", http.StatusBadRequest, nil)
+
+	var single headerResponse
+	get("GET", "/api/v2/headers/"+hex.EncodeToString(hdrs[1].IKey()),
+		http.StatusOK, &single)
+	if single.Header != encodeHeader(hdrs[1]) {
+		t.Fatalf("headers/<I>: mismatched header")
+	}
+	get("GET", "/api/v2/headers/00", http.StatusNotFound, nil)
+
+	get("POST", "/api/v2/generate?count=2", http.StatusOK, &list)
+	if len(list.HeaderList) != 2 || s.Count() != 5 {
+		t.Fatalf("generate: got %d headers and %d stored, want 2 and "+
+			"5", len(list.HeaderList), s.Count())
+	}
+	get("POST", "/api/v2/generate?count=0", http.StatusBadRequest, nil)
+	get("GET", "/api/v2/generate", http.StatusNotFound, nil)
+	get("GET", "/index.html", http.StatusNotFound, nil)
+}
+
+// TestServerStartStop ensures the server serves the API over HTTP once it is
+// started.
+func TestServerStartStop(t *testing.T) {
+	t.Parallel()
+
+	s := New(&Config{})
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start: unexpected error: %v", err)
+	}
+	if err := s.Start("127.0.0.1:0"); err == nil {
+		t.Fatalf("Start: did not receive expected error when started")
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/v2/time", s.Addr()))
+	if err != nil {
+		t.Fatalf("Get: unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Get: unexpected status %d", resp.StatusCode)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: unexpected error: %v", err)
+	}
+	if s.Addr() != nil {
+		t.Fatalf("Addr: got %v after stop, want nil", s.Addr())
+	}
+	if err := s.Stop(); err == nil {
+		t.Fatalf("Stop: did not receive expected error when stopped")
+	}
+}
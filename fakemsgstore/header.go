@@ -0,0 +1,102 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fakemsgstore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/btcsuite/fastsha256"
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/btcec"
+)
+
+// The V2 binary message header is laid out as follows:
+//
+//   Field            Size
+//   version          4 bytes  ("M" 0x02 0x00 0x00)
+//   time             4 bytes  (unix seconds, big endian)
+//   expire           4 bytes  (unix seconds, big endian)
+//   I                33 bytes (compressed point)
+//   J                33 bytes (compressed point)
+//   K                33 bytes (compressed point)
+//   block count      4 bytes  (big endian)
+//   reserved         8 bytes
+//   signature r      32 bytes
+//   signature s      32 bytes
+//   nonce            5 bytes
+const (
+	timeOffset      = 4
+	expireOffset    = timeOffset + 4
+	iOffset         = expireOffset + 4
+	jOffset         = iOffset + btcec.PubKeyBytesLenCompressed
+	kOffset         = jOffset + btcec.PubKeyBytesLenCompressed
+	blockLenOffset  = kOffset + btcec.PubKeyBytesLenCompressed
+	signatureOffset = blockLenOffset + 4 + 8
+	nonceOffset     = signatureOffset + 64
+)
+
+// headerVersion is the version prefix of every V2 binary message header.
+var headerVersion = [timeOffset]byte{'M', 0x02, 0x00, 0x00}
+
+// ErrInvalidHeader is returned when a generated message header is not accepted
+// by the ciphrtxt header parser.
+var ErrInvalidHeader = errors.New("generated message header is invalid")
+
+// newPoint returns the serialized compressed public key of a new random
+// private key along with the private key.
+func newPoint() ([]byte, *btcec.PrivateKey, error) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, nil, err
+	}
+	return privKey.PubKey().SerializeCompressed(), privKey, nil
+}
+
+// GenerateHeader returns a new V2 message header for a message stored at the
+// passed time which expires after the passed lifetime.  The I, J and K points
+// of the header are random, so the I key is unique, and the header is signed
+// with the private key of K.  The message the header describes does not exist.
+func GenerateHeader(now time.Time, lifetime time.Duration) (*ciphrtxt.RawMessageHeader, error) {
+	var b ciphrtxt.BinaryMessageHeaderV2
+	copy(b[:], headerVersion[:])
+	binary.BigEndian.PutUint32(b[timeOffset:], uint32(now.Unix()))
+	binary.BigEndian.PutUint32(b[expireOffset:],
+		uint32(now.Add(lifetime).Unix()))
+
+	var signKey *btcec.PrivateKey
+	for _, offset := range []int{iOffset, jOffset, kOffset} {
+		point, privKey, err := newPoint()
+		if err != nil {
+			return nil, err
+		}
+		copy(b[offset:], point)
+		signKey = privKey
+	}
+	binary.BigEndian.PutUint32(b[blockLenOffset:], 1)
+
+	hash := fastsha256.Sum256(b[:signatureOffset])
+	sig, err := signKey.Sign(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	r, s := sig.R.Bytes(), sig.S.Bytes()
+	copy(b[signatureOffset+32-len(r):signatureOffset+32], r)
+	copy(b[nonceOffset-len(s):nonceOffset], s)
+
+	// The nonce is random since the message body it would otherwise be
+	// computed over does not exist.
+	if _, err := rand.Read(b[nonceOffset:]); err != nil {
+		return nil, err
+	}
+
+	h := ciphrtxt.ImportBinaryHeaderV2(b[:])
+	if h == nil {
+		return nil, ErrInvalidHeader
+	}
+	return h, nil
+}
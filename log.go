@@ -44,6 +44,7 @@ var (
 	rpcsLog    = btclog.Disabled
 	scrpLog    = btclog.Disabled
 	srvrLog    = btclog.Disabled
+	strmLog    = btclog.Disabled
 	txmpLog    = btclog.Disabled
 )
 
@@ -62,6 +63,7 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"RPCS": rpcsLog,
 	"SCRP": scrpLog,
 	"SRVR": srvrLog,
+	"STRM": strmLog,
 	"TXMP": txmpLog,
 }
 
@@ -135,6 +137,9 @@ func useLogger(subsystemID string, logger btclog.Logger) {
 	case "SRVR":
 		srvrLog = logger
 
+	case "STRM":
+		strmLog = logger
+
 	case "TXMP":
 		txmpLog = logger
 		mempool.UseLogger(logger)
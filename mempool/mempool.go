@@ -105,6 +105,21 @@ type Policy struct {
 	// MinRelayTxFee defines the minimum transaction fee in BTC/kB to be
 	// considered a non-zero fee.
 	MinRelayTxFee cttutil.Amount
+
+	// MinAccessKeyFee defines the premium in BTC which is required in
+	// addition to the minimum relay fee for each output which registers
+	// a network access key.
+	MinAccessKeyFee cttutil.Amount
+
+	// MinDirectoryFee defines the premium in BTC which is required in
+	// addition to the minimum relay fee for each output which posts a
+	// directory entry.
+	MinDirectoryFee cttutil.Amount
+
+	// DirectoryFeeRate defines the premium in BTC/kB which is required in
+	// addition to MinDirectoryFee for the data posted by each directory
+	// entry output.
+	DirectoryFeeRate cttutil.Amount
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -535,6 +550,25 @@ func (mp *TxPool) maybeAcceptTransaction(tx *cttutil.Tx, isNew, rateLimit bool)
 		return nil, txRuleError(wire.RejectNonstandard, str)
 	}
 
+	// Don't accept transactions which register malformed, improperly
+	// signed, or already expired network access keys.  This is enforced
+	// regardless of the standardness policy since such registrations are
+	// invalid once the access key block version is active.
+	err = blockchain.CheckAccessKeys(tx, mp.cfg.TimeSource.AdjustedTime())
+	if err != nil {
+		if cerr, ok := err.(blockchain.RuleError); ok {
+			return nil, chainRuleError(cerr)
+		}
+		return nil, err
+	}
+
+	// Don't accept transactions which post malformed, improperly signed,
+	// or already expired directory entries.
+	err = checkDirectoryEntries(tx, mp.cfg.TimeSource.AdjustedTime())
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the current height of the main chain.  A standalone transaction
 	// will be mined into the next block at best, so its height is at least
 	// one more than the current height.
@@ -688,6 +722,17 @@ func (mp *TxPool) maybeAcceptTransaction(tx *cttutil.Tx, isNew, rateLimit bool)
 		return nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
 
+	// Record transactions, which register network access keys or post
+	// directory entries, are never free and must pay their premium in
+	// addition to the minimum relay fee regardless of their priority.
+	recordTxFee := calcMinRequiredRecordTxFee(tx, &mp.cfg.Policy)
+	if recordTxFee > 0 && txFee < recordTxFee {
+		str := fmt.Sprintf("transaction %v has %d fees which is under "+
+			"the required amount of %d for record outputs", txHash,
+			txFee, recordTxFee)
+		return nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
+
 	// Require that free transactions have sufficient priority to be mined
 	// in the next block.  Transactions which are being added back to the
 	// memory pool from blocks that have been disconnected during a reorg
@@ -1058,6 +1103,17 @@ func (mp *TxPool) LastUpdated() time.Time {
 	return time.Unix(atomic.LoadInt64(&mp.lastUpdated), 0)
 }
 
+// MinRequiredRecordTxFee returns the total fee which the policy of the memory
+// pool requires for the passed transaction when it has record outputs, which
+// register network access keys or post directory entries.  It is the premium
+// for the record outputs in addition to the minimum relay fee, and zero for
+// transactions without record outputs.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinRequiredRecordTxFee(tx *cttutil.Tx) int64 {
+	return calcMinRequiredRecordTxFee(tx, &mp.cfg.Policy)
+}
+
 // New returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func New(cfg *Config) *TxPool {
@@ -6,6 +6,7 @@ package mempool
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/jadeblaquiere/cttd/blockchain"
 	"github.com/jadeblaquiere/cttd/txscript"
@@ -49,6 +50,21 @@ const (
 	// for larger transactions.  This value is in Satoshi/1000 bytes.
 	DefaultMinRelayTxFee = cttutil.Amount(1000)
 
+	// DefaultMinAccessKeyFee is the default premium in satoshi which is
+	// required, in addition to the minimum relay fee, for each output of
+	// a transaction which registers a network access key.
+	DefaultMinAccessKeyFee = cttutil.Amount(100000)
+
+	// DefaultMinDirectoryFee is the default premium in satoshi which is
+	// required, in addition to the minimum relay fee, for each output of
+	// a transaction which posts a directory entry.
+	DefaultMinDirectoryFee = cttutil.Amount(10000)
+
+	// DefaultDirectoryFeeRate is the default premium which is required for
+	// each byte of data posted by a directory entry output in addition to
+	// DefaultMinDirectoryFee.  This value is in Satoshi/1000 bytes.
+	DefaultDirectoryFeeRate = cttutil.Amount(10000)
+
 	// maxStandardMultiSigKeys is the maximum number of public keys allowed
 	// in a multi-signature transaction output script for it to be
 	// considered standard.
@@ -249,6 +265,98 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 	return nil
 }
 
+// calcMinRequiredRecordFee returns the premium fee which is required for the
+// record outputs of the passed transaction in addition to the minimum relay
+// fee.  Each output which registers a network access key requires the minimum
+// access key fee, and each output which posts a directory entry requires the
+// minimum directory fee plus the directory fee rate for every byte of the
+// pushed entry.  Transactions without record outputs require no premium.
+func calcMinRequiredRecordFee(tx *cttutil.Tx, policy *Policy) int64 {
+	var recordFee int64
+	for _, txOut := range tx.MsgTx().TxOut {
+		switch {
+		case txscript.IsAccessKeyRegistration(txOut.PkScript):
+			recordFee += int64(policy.MinAccessKeyFee)
+
+		case txscript.IsDirectoryEntryPost(txOut.PkScript):
+			// Scripts which do not parse are charged for their
+			// full length.
+			payloadSize := int64(len(txOut.PkScript))
+			if pushes, err := txscript.PushedData(txOut.PkScript); err == nil {
+				payloadSize = 0
+				for _, data := range pushes {
+					payloadSize += int64(len(data))
+				}
+			}
+			recordFee += int64(policy.MinDirectoryFee) +
+				(payloadSize*int64(policy.DirectoryFeeRate))/1000
+		}
+	}
+
+	// Set the premium to the maximum possible value if the calculated fee
+	// is not in the valid range for monetary amounts.
+	if recordFee < 0 || recordFee > cttutil.MaxSatoshi {
+		recordFee = cttutil.MaxSatoshi
+	}
+
+	return recordFee
+}
+
+// calcMinRequiredRecordTxFee returns the total fee which is required for the
+// passed transaction when it has record outputs, which is the premium for its
+// record outputs in addition to the minimum relay fee for its serialized size.
+// The memory pool and the block template generator both use it, so a record
+// transaction is only mined when it pays the fee it was accepted for.  Zero is
+// returned for transactions without record outputs since their fees are not
+// subject to the premium.
+func calcMinRequiredRecordTxFee(tx *cttutil.Tx, policy *Policy) int64 {
+	recordFee := calcMinRequiredRecordFee(tx, policy)
+	if recordFee == 0 {
+		return 0
+	}
+
+	serializedSize := int64(tx.MsgTx().SerializeSize())
+	requiredFee := calcMinRequiredTxRelayFee(serializedSize,
+		policy.MinRelayTxFee) + recordFee
+
+	// Set the fee to the maximum possible value if the calculated fee is
+	// not in the valid range for monetary amounts.
+	if requiredFee < 0 || requiredFee > cttutil.MaxSatoshi {
+		requiredFee = cttutil.MaxSatoshi
+	}
+
+	return requiredFee
+}
+
+// checkDirectoryEntries ensures every output of the passed transaction which
+// begins with OP_POSTDIRECTORY posts a well-formed directory entry which is
+// signed by its owner and has not expired as of the passed time.
+func checkDirectoryEntries(tx *cttutil.Tx, adjustedTime time.Time) error {
+	for i, txOut := range tx.MsgTx().TxOut {
+		if !txscript.IsDirectoryEntryPost(txOut.PkScript) {
+			continue
+		}
+
+		entry, err := txscript.ExtractDirectoryEntry(txOut.PkScript)
+		if err == nil {
+			err = entry.Verify()
+		}
+		if err != nil {
+			str := fmt.Sprintf("transaction output %d: %v", i, err)
+			return txRuleError(wire.RejectInvalid, str)
+		}
+
+		if int64(entry.Expire) <= adjustedTime.Unix() {
+			str := fmt.Sprintf("transaction output %d: directory "+
+				"entry expired at %v", i,
+				time.Unix(int64(entry.Expire), 0))
+			return txRuleError(wire.RejectInvalid, str)
+		}
+	}
+
+	return nil
+}
+
 // isDust returns whether or not the passed transaction output amount is
 // considered dust or not based on the passed minimum transaction relay fee.
 // Dust is defined in terms of the minimum transaction relay fee.  In
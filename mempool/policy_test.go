@@ -7,12 +7,14 @@ package mempool
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/jadeblaquiere/cttd/blockchain"
 	"github.com/jadeblaquiere/cttd/btcec"
 	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
 	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
 	"github.com/jadeblaquiere/cttd/wire"
 	"github.com/jadeblaquiere/cttutil"
 )
@@ -510,3 +512,215 @@ func TestCheckTransactionStandard(t *testing.T) {
 		}
 	}
 }
+
+// TestCheckDirectoryEntries tests the checkDirectoryEntries API.
+func TestCheckDirectoryEntries(t *testing.T) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	now := time.Unix(1475000000, 0)
+
+	// postScript returns an OP_POSTDIRECTORY script which posts an entry
+	// signed by the private key with the passed expire time and optionally
+	// tampers with the signed name.
+	postScript := func(expire uint32, tamper bool) []byte {
+		entry, err := dirent.Sign(expire, "alice", []byte{0x01},
+			privKey)
+		if err != nil {
+			t.Fatalf("Sign: unexpected error: %v", err)
+		}
+		if tamper {
+			entry.Name = "mallory"
+		}
+		serialized, err := entry.Serialize()
+		if err != nil {
+			t.Fatalf("Serialize: unexpected error: %v", err)
+		}
+		script, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_POSTDIRECTORY).
+			AddFullData(serialized).Script()
+		if err != nil {
+			t.Fatalf("Script: unexpected error: %v", err)
+		}
+		return script
+	}
+
+	tests := []struct {
+		name     string
+		pkScript []byte
+		isValid  bool
+	}{
+		{
+			name:     "valid entry",
+			pkScript: postScript(uint32(now.Unix())+1, false),
+			isValid:  true,
+		},
+		{
+			name:     "expired entry",
+			pkScript: postScript(uint32(now.Unix()), false),
+			isValid:  false,
+		},
+		{
+			name:     "tampered entry",
+			pkScript: postScript(uint32(now.Unix())+1, true),
+			isValid:  false,
+		},
+		{
+			name: "malformed entry",
+			pkScript: []byte{txscript.OP_POSTDIRECTORY,
+				txscript.OP_DATA_1, dirent.Version},
+			isValid: false,
+		},
+		{
+			name:     "bare OP_POSTDIRECTORY",
+			pkScript: []byte{txscript.OP_POSTDIRECTORY},
+			isValid:  false,
+		},
+		{
+			name:     "not a directory entry",
+			pkScript: []byte{txscript.OP_RETURN},
+			isValid:  true,
+		},
+	}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx()
+		tx.AddTxOut(wire.NewTxOut(0, test.pkScript))
+		err := checkDirectoryEntries(cttutil.NewTx(tx), now)
+		if err == nil && !test.isValid {
+			t.Errorf("checkDirectoryEntries (%s): valid when it "+
+				"should not be", test.name)
+			continue
+		}
+		if err != nil && test.isValid {
+			t.Errorf("checkDirectoryEntries (%s): unexpected "+
+				"error: %v", test.name, err)
+			continue
+		}
+		if err != nil {
+			if code, ok := extractRejectCode(err); !ok ||
+				code != wire.RejectInvalid {
+
+				t.Errorf("checkDirectoryEntries (%s): unexpected "+
+					"reject code %v", test.name, code)
+			}
+		}
+	}
+}
+
+// TestCalcMinRequiredRecordFee tests the calcMinRequiredRecordFee API.
+func TestCalcMinRequiredRecordFee(t *testing.T) {
+	policy := Policy{
+		MinAccessKeyFee:  DefaultMinAccessKeyFee,
+		MinDirectoryFee:  DefaultMinDirectoryFee,
+		DirectoryFeeRate: DefaultDirectoryFeeRate,
+	}
+
+	// A directory entry post of 1000 bytes of data.
+	postScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_POSTDIRECTORY).
+		AddFullData(make([]byte, 1000)).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	akScript := append([]byte{txscript.OP_REGISTERACCESSKEY,
+		txscript.OP_PUSHDATA1, txscript.AccessKeySize},
+		make([]byte, txscript.AccessKeySize)...)
+	p2pkhScript := []byte{txscript.OP_DUP, txscript.OP_HASH160,
+		txscript.OP_DATA_20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG}
+	directoryFee := int64(DefaultMinDirectoryFee) +
+		1000*int64(DefaultDirectoryFeeRate)/1000
+
+	tests := []struct {
+		name      string
+		pkScripts [][]byte
+		want      int64
+	}{
+		{
+			name:      "no record outputs",
+			pkScripts: [][]byte{p2pkhScript, {txscript.OP_RETURN}},
+			want:      0,
+		},
+		{
+			name:      "access key registration",
+			pkScripts: [][]byte{akScript, p2pkhScript},
+			want:      int64(DefaultMinAccessKeyFee),
+		},
+		{
+			name:      "directory entry post",
+			pkScripts: [][]byte{postScript},
+			want:      directoryFee,
+		},
+		{
+			name: "access key registration and two directory " +
+				"entry posts",
+			pkScripts: [][]byte{postScript, akScript, postScript},
+			want: int64(DefaultMinAccessKeyFee) +
+				2*directoryFee,
+		},
+		{
+			name: "directory entry post which does not parse",
+			pkScripts: [][]byte{{txscript.OP_POSTDIRECTORY,
+				txscript.OP_DATA_2, 0x01}},
+			want: int64(DefaultMinDirectoryFee) +
+				3*int64(DefaultDirectoryFeeRate)/1000,
+		},
+	}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx()
+		for _, pkScript := range test.pkScripts {
+			tx.AddTxOut(wire.NewTxOut(0, pkScript))
+		}
+		got := calcMinRequiredRecordFee(cttutil.NewTx(tx), &policy)
+		if got != test.want {
+			t.Errorf("calcMinRequiredRecordFee (%s): got %d, want %d",
+				test.name, got, test.want)
+		}
+	}
+
+	// Ensure the premium is only waived by a zero fee schedule.
+	tx := wire.NewMsgTx()
+	tx.AddTxOut(wire.NewTxOut(0, akScript))
+	tx.AddTxOut(wire.NewTxOut(0, postScript))
+	if got := calcMinRequiredRecordFee(cttutil.NewTx(tx), &Policy{}); got != 0 {
+		t.Errorf("calcMinRequiredRecordFee: got %d with zero fee "+
+			"schedule, want 0", got)
+	}
+}
+
+// TestCalcMinRequiredRecordTxFee tests the calcMinRequiredRecordTxFee API.
+func TestCalcMinRequiredRecordTxFee(t *testing.T) {
+	policy := Policy{
+		MinRelayTxFee:    DefaultMinRelayTxFee,
+		MinAccessKeyFee:  DefaultMinAccessKeyFee,
+		MinDirectoryFee:  DefaultMinDirectoryFee,
+		DirectoryFeeRate: DefaultDirectoryFeeRate,
+	}
+	akScript := append([]byte{txscript.OP_REGISTERACCESSKEY,
+		txscript.OP_PUSHDATA1, txscript.AccessKeySize},
+		make([]byte, txscript.AccessKeySize)...)
+
+	// Transactions without record outputs do not require the fee.
+	tx := wire.NewMsgTx()
+	tx.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	if got := calcMinRequiredRecordTxFee(cttutil.NewTx(tx), &policy); got != 0 {
+		t.Errorf("calcMinRequiredRecordTxFee: got %d without record "+
+			"outputs, want 0", got)
+	}
+
+	// Record transactions require the minimum relay fee for their size in
+	// addition to the premium.
+	tx = wire.NewMsgTx()
+	tx.AddTxOut(wire.NewTxOut(0, akScript))
+	size := int64(tx.SerializeSize())
+	want := calcMinRequiredTxRelayFee(size, DefaultMinRelayTxFee) +
+		int64(DefaultMinAccessKeyFee)
+	if got := calcMinRequiredRecordTxFee(cttutil.NewTx(tx), &policy); got != want {
+		t.Errorf("calcMinRequiredRecordTxFee: got %d, want %d", got,
+			want)
+	}
+}
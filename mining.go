@@ -10,7 +10,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
 	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
 	"github.com/jadeblaquiere/cttd/mempool"
 	"github.com/jadeblaquiere/cttd/mining"
@@ -20,14 +22,6 @@ import (
 )
 
 const (
-	// generatedBlockVersion is the version of the block being generated.
-	// It is defined as a constant here rather than using the
-	// wire.BlockVersion constant since a change in the block version
-	// will require changes to the generated block.  Using the wire constant
-	// for generated block version could allow creation of invalid blocks
-	// for the updated version.
-	generatedBlockVersion = 101
-
 	// blockHeaderOverhead is the max number of bytes it takes to serialize
 	// a block header and max possible transaction count.
 	blockHeaderOverhead = wire.MaxBlockHeaderPayload + wire.MaxVarIntPayload
@@ -36,6 +30,18 @@ const (
 	// and is used to monitor BIP16 support as well as blocks that are
 	// generated via cttd.
 	coinbaseFlags = "/P2SH/cttd/"
+
+	// nonceHeaderTimeMargin is how far past the current time the nonce
+	// header lifetime limits of the chain parameters are evaluated when
+	// choosing nonce header candidates.  It allows for the block timestamp
+	// to advance while the candidates are cached and the block is solved.
+	nonceHeaderTimeMargin = time.Minute * 10
+
+	// accessKeyTimeMargin is how far past the timestamp of a block template
+	// the expiry of the access keys registered by its transactions is
+	// checked.  It allows for the block timestamp to be updated while the
+	// block is solved.
+	accessKeyTimeMargin = time.Minute * 10
 )
 
 // txPrioItem houses a transaction along with extra information that allows the
@@ -317,6 +323,21 @@ func medianAdjustedTime(chainState *chainState, timeSource blockchain.MedianTime
 	return newTimestamp, nil
 }
 
+// nonceHeaderCandidates returns the message headers known to the passed
+// message header source which may be used as the nonce headers of a block
+// extending the current best chain along with the set of their I keys.  Headers
+// which do not satisfy the nonce header lifetime limits of the passed chain
+// parameters or which are already used as nonces by the recent blocks of the
+// chain are excluded.
+func nonceHeaderCandidates(hs blockchain.MessageHeaderSource, chain *blockchain.BlockChain, params *chaincfg.Params) ([]ciphrtxt.BinaryMessageHeaderV2, map[string]struct{}, error) {
+	used, err := chain.RecentNonceHeaderIKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	blockTime := time.Now().Add(nonceHeaderTimeMargin)
+	return blockchain.NonceHeaderCandidates(hs, used, blockTime, params)
+}
+
 // NewBlockTemplate returns a new block template that is ready to be solved
 // using the transactions from the passed transaction source pool and a coinbase
 // that either pays to the passed address if it is not nil, or a coinbase that
@@ -391,6 +412,15 @@ func NewBlockTemplate(policy *mining.Policy, server *server, payToAddress cttuti
 	nextBlockHeight := chainState.newestHeight + 1
 	chainState.Unlock()
 
+	// Choose the timestamp of the block up front since the access keys
+	// registered by the selected transactions are checked against it.  The
+	// timestamp is potentially adjusted to ensure it comes after the median
+	// time of the last several blocks per the chain consensus rules.
+	ts, err := medianAdjustedTime(chainState, timeSource)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a standard coinbase transaction paying to the provided
 	// address.  NOTE: The coinbase value will be updated to include the
 	// fees from the selected transactions later after they have actually
@@ -466,6 +496,30 @@ mempoolLoop:
 			continue
 		}
 
+		// Skip transactions which register access keys that have expired
+		// since they were accepted into the memory pool or expire before
+		// the block is likely to be solved.  Consensus checks the access
+		// keys against the block timestamp.
+		if err := blockchain.CheckAccessKeys(tx,
+			ts.Add(accessKeyTimeMargin)); err != nil {
+
+			minrLog.Tracef("Skipping tx %s with invalid access key: %v",
+				tx.Hash(), err)
+			continue
+		}
+
+		// Skip record transactions which do not pay the premium fee
+		// required by the memory pool policy for their record outputs
+		// in addition to the minimum relay fee.  The fee is never
+		// waived for free or high priority transactions.
+		recordTxFee := server.txMemPool.MinRequiredRecordTxFee(tx)
+		if recordTxFee > 0 && txDesc.Fee < recordTxFee {
+			minrLog.Tracef("Skipping tx %s with fee %d under the "+
+				"record fee %d", tx.Hash(), txDesc.Fee,
+				recordTxFee)
+			continue
+		}
+
 		// Fetch all of the utxos referenced by the this transaction.
 		// NOTE: This intentionally does not fetch inputs from the
 		// mempool since a transaction which depends on other
@@ -708,14 +762,16 @@ mempoolLoop:
 	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
 	txFees[0] = -totalFees
 
-	// Calculate the required difficulty for the block.  The timestamp
-	// is potentially adjusted to ensure it comes after the median time of
-	// the last several blocks per the chain consensus rules.
-	ts, err := medianAdjustedTime(chainState, timeSource)
+	// Calculate the required difficulty for the block.
+	reqDifficulty, err := blockManager.chain.CalcNextRequiredDifficulty(ts)
 	if err != nil {
 		return nil, err
 	}
-	reqDifficulty, err := blockManager.chain.CalcNextRequiredDifficulty(ts)
+
+	// Calculate the next expected block version based on the state of the
+	// rule change deployments.  The version signals the deployments which
+	// are being voted on or are locked in.
+	nextBlockVersion, err := blockManager.chain.CalcNextBlockVersion()
 	if err != nil {
 		return nil, err
 	}
@@ -724,7 +780,7 @@ mempoolLoop:
 	merkles := blockchain.BuildMerkleTreeStore(blockTxns)
 	var msgBlock wire.MsgBlock
 	msgBlock.Header = wire.BlockHeader{
-		Version:    generatedBlockVersion,
+		Version:    nextBlockVersion,
 		PrevBlock:  *prevHash,
 		MerkleRoot: *merkles[len(merkles)-1],
 		Timestamp:  ts,
@@ -5,6 +5,12 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
 	"github.com/jadeblaquiere/cttd/chaincfg"
 )
 
@@ -16,21 +22,32 @@ var activeNetParams = &ctindigoNetParams
 // network and test networks.
 type params struct {
 	*chaincfg.Params
-	rpcPort string
+	rpcPort     string
+	stratumPort string
 }
 
 // ctindigoNetParams contains parameters specific to the CT Indigo test network
 // (wire.CTIndigoNet).
 var ctindigoNetParams = params{
-	Params:  &chaincfg.CTIndigoNetParams,
-	rpcPort: "7765",
+	Params:      &chaincfg.CTIndigoNetParams,
+	rpcPort:     "7765",
+	stratumPort: "7767",
 }
 
 // ctredNetParams contains parameters specific to the CT Red test network
 // (wire.CTRedNet).
 var ctredNetParams = params{
-	Params:  &chaincfg.CTRedNetParams,
-	rpcPort: "17762",
+	Params:      &chaincfg.CTRedNetParams,
+	rpcPort:     "17762",
+	stratumPort: "17764",
+}
+
+// ctsimNetParams contains parameters specific to the CT simulation test network
+// (wire.CTSimNet).
+var ctsimNetParams = params{
+	Params:      &chaincfg.CTSimNetParams,
+	rpcPort:     "27762",
+	stratumPort: "27764",
 }
 
 // netName returns the name used when referring to a bitcoin network.  At the
@@ -45,3 +62,63 @@ var ctredNetParams = params{
 func netName(chainParams *params) string {
 	return chainParams.Name
 }
+
+// netParamsFile houses the ports of a custom network which are specific to
+// cttd and therefore not part of the chain parameters.  They are read from
+// the same file as the chain parameters.
+type netParamsFile struct {
+	RPCPort     string `json:"rpcport"`
+	StratumPort string `json:"stratumport"`
+}
+
+// loadNetParams returns the parameters of the custom network defined by the
+// JSON file at the passed path and registers them with chaincfg.  The RPC and
+// stratum ports default to one and three more than the peer-to-peer port,
+// like the standard networks.
+func loadNetParams(path string) (*params, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	chainParams, err := chaincfg.LoadParams(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	var ports netParamsFile
+	if err := json.Unmarshal(b, &ports); err != nil {
+		return nil, err
+	}
+
+	p2pPort, err := strconv.ParseUint(chainParams.DefaultPort, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default port %q",
+			chainParams.DefaultPort)
+	}
+	if ports.RPCPort == "" {
+		ports.RPCPort = strconv.FormatUint(p2pPort+1, 10)
+	}
+	if ports.StratumPort == "" {
+		ports.StratumPort = strconv.FormatUint(p2pPort+3, 10)
+	}
+
+	// The name of the network namespaces its data and log directories, so
+	// it must not be the name of a standard network.
+	for _, std := range []*params{&ctindigoNetParams, &ctredNetParams,
+		&ctsimNetParams} {
+
+		if chainParams.Name == std.Name {
+			return nil, fmt.Errorf("network name %s is already used "+
+				"by a standard network", chainParams.Name)
+		}
+	}
+
+	if err := chaincfg.Register(chainParams); err != nil {
+		return nil, fmt.Errorf("cannot register network %s (%v): %v",
+			chainParams.Name, chainParams.Net, err)
+	}
+	return &params{
+		Params:      chainParams,
+		rpcPort:     ports.RPCPort,
+		stratumPort: ports.StratumPort,
+	}, nil
+}
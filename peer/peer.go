@@ -27,7 +27,7 @@ import (
 
 const (
 	// MaxProtocolVersion is the max protocol version the peer supports.
-	MaxProtocolVersion = wire.SendHeadersVersion
+	MaxProtocolVersion = wire.MsgHdrVersion
 
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 50
@@ -180,6 +180,13 @@ type MessageListeners struct {
 	// message.
 	OnSendHeaders func(p *Peer, msg *wire.MsgSendHeaders)
 
+	// OnGetMsgHdr is invoked when a peer receives a getmsghdr bitcoin
+	// message.
+	OnGetMsgHdr func(p *Peer, msg *wire.MsgGetMsgHdr)
+
+	// OnMsgHdr is invoked when a peer receives a msghdr bitcoin message.
+	OnMsgHdr func(p *Peer, msg *wire.MsgMsgHdr)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -1565,6 +1572,16 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgGetMsgHdr:
+			if p.cfg.Listeners.OnGetMsgHdr != nil {
+				p.cfg.Listeners.OnGetMsgHdr(p, msg)
+			}
+
+		case *wire.MsgMsgHdr:
+			if p.cfg.Listeners.OnMsgHdr != nil {
+				p.cfg.Listeners.OnMsgHdr(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -383,6 +383,12 @@ func TestPeerListeners(t *testing.T) {
 			OnSendHeaders: func(p *peer.Peer, msg *wire.MsgSendHeaders) {
 				ok <- msg
 			},
+			OnGetMsgHdr: func(p *peer.Peer, msg *wire.MsgGetMsgHdr) {
+				ok <- msg
+			},
+			OnMsgHdr: func(p *peer.Peer, msg *wire.MsgMsgHdr) {
+				ok <- msg
+			},
 		},
 		UserAgentName:    "peer",
 		UserAgentVersion: "1.0",
@@ -503,6 +509,14 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnGetMsgHdr",
+			wire.NewMsgGetMsgHdr(),
+		},
+		{
+			"OnMsgHdr",
+			&wire.MsgMsgHdr{},
+		},
 	}
 	t.Logf("Running %d tests", len(tests))
 	for _, test := range tests {
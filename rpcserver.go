@@ -9,13 +9,13 @@ import (
 	"crypto/subtle"
 	"crypto/tls"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/blockchain/indexers"
 	"github.com/jadeblaquiere/cttd/btcec"
 	"github.com/jadeblaquiere/cttd/btcjson"
 	"github.com/jadeblaquiere/cttd/chaincfg"
@@ -37,6 +38,7 @@ import (
 	"github.com/jadeblaquiere/cttd/mempool"
 	"github.com/jadeblaquiere/cttd/mining"
 	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
 	"github.com/jadeblaquiere/cttd/wire"
 	"github.com/jadeblaquiere/cttutil"
 	"github.com/btcsuite/fastsha256"
@@ -49,26 +51,14 @@ const (
 	// is closed.
 	rpcAuthTimeoutSeconds = 10
 
-	// uint256Size is the number of bytes needed to represent an unsigned
-	// 256-bit integer.
-	uint256Size = 32
-
-	// getworkDataLen is the length of the data field of the getwork RPC.
-	// It consists of the serialized block header plus the internal sha256
-	// padding.  The internal sha256 padding consists of a single 1 bit
-	// followed by enough zeros to pad the message out to 56 bytes followed
-	// by length of the message in bits encoded as a big-endian uint64
-	// (8 bytes).  Thus, the resulting length is a multiple of the sha256
-	// block size (64 bytes).
-	getworkDataLen = (1 + ((wire.MaxBlockHeaderPayload + 8) /
-		fastsha256.BlockSize)) * fastsha256.BlockSize
-
-	// hash1Len is the length of the hash1 field of the getwork RPC.  It
-	// consists of a zero hash plus the internal sha256 padding.  See
-	// the getworkDataLen comment for details about the internal sha256
-	// padding format.
-	hash1Len = (1 + ((chainhash.HashSize + 8) / fastsha256.BlockSize)) *
-		fastsha256.BlockSize
+	// getworkPrefixLen is the length of the data field of the getwork RPC
+	// when work is requested.  It consists of the serialized block header
+	// without the message headers which are used as its nonces.  Those are
+	// chosen by the caller from the candidates provided along with the data
+	// and appended to it when submitting a solution, so the data field of
+	// a submission is the full serialized block header.
+	getworkPrefixLen = wire.MaxBlockHeaderPayload -
+		2*ciphrtxt.MessageHeaderLengthV2
 
 	// gbtNonceRange is two 32-bit big-endian hexadecimal integers which
 	// represent the valid ranges of nonces returned by the getblocktemplate
@@ -107,7 +97,14 @@ var (
 	// block template generated by the getblocktemplate RPC.    It is
 	// declared here to avoid the overhead of creating the slice on every
 	// invocation for constant data.
-	gbtCapabilities = []string{"proposal"}
+	gbtCapabilities = []string{"proposal", "noncecandidates"}
+
+	// deploymentNames houses the names the rule change deployments are
+	// reported with by the getblockchaininfo and getblocktemplate RPCs
+	// indexed by their deployment IDs.
+	deploymentNames = [chaincfg.DefinedDeployments]string{
+		chaincfg.DeploymentTestDummy: "testdummy",
+	}
 )
 
 // Errors
@@ -134,46 +131,53 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
-	"addnode":               handleAddNode,
-	"createrawtransaction":  handleCreateRawTransaction,
-	"debuglevel":            handleDebugLevel,
-	"decoderawtransaction":  handleDecodeRawTransaction,
-	"decodescript":          handleDecodeScript,
-	"generate":              handleGenerate,
-	"getaddednodeinfo":      handleGetAddedNodeInfo,
-	"getbestblock":          handleGetBestBlock,
-	"getbestblockhash":      handleGetBestBlockHash,
-	"getblock":              handleGetBlock,
-	"getblockcount":         handleGetBlockCount,
-	"getblockhash":          handleGetBlockHash,
-	"getblockheader":        handleGetBlockHeader,
-	"getblocktemplate":      handleGetBlockTemplate,
-	"getconnectioncount":    handleGetConnectionCount,
-	"getcurrentnet":         handleGetCurrentNet,
-	"getdifficulty":         handleGetDifficulty,
-	"getgenerate":           handleGetGenerate,
-	"gethashespersec":       handleGetHashesPerSec,
-	"getinfo":               handleGetInfo,
-	"getmempoolinfo":        handleGetMempoolInfo,
-	"getmininginfo":         handleGetMiningInfo,
-	"getnettotals":          handleGetNetTotals,
-	"getnetworkhashps":      handleGetNetworkHashPS,
-	"getpeerinfo":           handleGetPeerInfo,
-	"getrawmempool":         handleGetRawMempool,
-	"getrawtransaction":     handleGetRawTransaction,
-	"gettxout":              handleGetTxOut,
-	"getwork":               handleGetWork,
-	"help":                  handleHelp,
-	"node":                  handleNode,
-	"ping":                  handlePing,
-	"searchrawtransactions": handleSearchRawTransactions,
-	"sendrawtransaction":    handleSendRawTransaction,
-	"setgenerate":           handleSetGenerate,
-	"stop":                  handleStop,
-	"submitblock":           handleSubmitBlock,
-	"validateaddress":       handleValidateAddress,
-	"verifychain":           handleVerifyChain,
-	"verifymessage":         handleVerifyMessage,
+	"addnode":                handleAddNode,
+	"createrawtransaction":   handleCreateRawTransaction,
+	"debuglevel":             handleDebugLevel,
+	"decoderawtransaction":   handleDecodeRawTransaction,
+	"decodescript":           handleDecodeScript,
+	"generate":               handleGenerate,
+	"getaccesskey":           handleGetAccessKey,
+	"getaddednodeinfo":       handleGetAddedNodeInfo,
+	"getbestblock":           handleGetBestBlock,
+	"getbestblockhash":       handleGetBestBlockHash,
+	"getblock":               handleGetBlock,
+	"getblockchaininfo":      handleGetBlockChainInfo,
+	"getblockcount":          handleGetBlockCount,
+	"getblockhash":           handleGetBlockHash,
+	"getblockheader":         handleGetBlockHeader,
+	"getblocksbynonceheader": handleGetBlocksByNonceHeader,
+	"getblocktemplate":       handleGetBlockTemplate,
+	"getconnectioncount":     handleGetConnectionCount,
+	"getcurrentnet":          handleGetCurrentNet,
+	"getdirectoryentry":      handleGetDirectoryEntry,
+	"getdifficulty":          handleGetDifficulty,
+	"getgenerate":            handleGetGenerate,
+	"gethashespersec":        handleGetHashesPerSec,
+	"getinfo":                handleGetInfo,
+	"getmempoolinfo":         handleGetMempoolInfo,
+	"getmininginfo":          handleGetMiningInfo,
+	"getnettotals":           handleGetNetTotals,
+	"getnetworkhashps":       handleGetNetworkHashPS,
+	"getpeerinfo":            handleGetPeerInfo,
+	"getrawmempool":          handleGetRawMempool,
+	"getrawtransaction":      handleGetRawTransaction,
+	"gettxout":               handleGetTxOut,
+	"getwork":                handleGetWork,
+	"help":                   handleHelp,
+	"listaccesskeys":         handleListAccessKeys,
+	"listdirectoryentries":   handleListDirectoryEntries,
+	"node":                   handleNode,
+	"ping":                   handlePing,
+	"searchdirectory":        handleSearchDirectory,
+	"searchrawtransactions":  handleSearchRawTransactions,
+	"sendrawtransaction":     handleSendRawTransaction,
+	"setgenerate":            handleSetGenerate,
+	"stop":                   handleStop,
+	"submitblock":            handleSubmitBlock,
+	"validateaddress":        handleValidateAddress,
+	"verifychain":            handleVerifyChain,
+	"verifymessage":          handleVerifyMessage,
 }
 
 // list of commands that we recognize, but for which cttd has no support because
@@ -226,11 +230,10 @@ var rpcAskWallet = map[string]struct{}{
 
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
-	"estimatefee":       {},
-	"estimatepriority":  {},
-	"getblockchaininfo": {},
-	"getchaintips":      {},
-	"getnetworkinfo":    {},
+	"estimatefee":      {},
+	"estimatepriority": {},
+	"getchaintips":     {},
+	"getnetworkinfo":   {},
 }
 
 // Commands that are available to a limited user
@@ -247,27 +250,34 @@ var rpcLimited = map[string]struct{}{
 	"help": {},
 
 	// HTTP/S-only commands
-	"createrawtransaction":  {},
-	"decoderawtransaction":  {},
-	"decodescript":          {},
-	"getbestblock":          {},
-	"getbestblockhash":      {},
-	"getblock":              {},
-	"getblockcount":         {},
-	"getblockhash":          {},
-	"getcurrentnet":         {},
-	"getdifficulty":         {},
-	"getinfo":               {},
-	"getnettotals":          {},
-	"getnetworkhashps":      {},
-	"getrawmempool":         {},
-	"getrawtransaction":     {},
-	"gettxout":              {},
-	"searchrawtransactions": {},
-	"sendrawtransaction":    {},
-	"submitblock":           {},
-	"validateaddress":       {},
-	"verifymessage":         {},
+	"createrawtransaction":   {},
+	"decoderawtransaction":   {},
+	"decodescript":           {},
+	"getaccesskey":           {},
+	"getbestblock":           {},
+	"getbestblockhash":       {},
+	"getblock":               {},
+	"getblockchaininfo":      {},
+	"getblockcount":          {},
+	"getblockhash":           {},
+	"getblocksbynonceheader": {},
+	"getcurrentnet":          {},
+	"getdirectoryentry":      {},
+	"getdifficulty":          {},
+	"getinfo":                {},
+	"getnettotals":           {},
+	"getnetworkhashps":       {},
+	"getrawmempool":          {},
+	"getrawtransaction":      {},
+	"gettxout":               {},
+	"listaccesskeys":         {},
+	"listdirectoryentries":   {},
+	"searchdirectory":        {},
+	"searchrawtransactions":  {},
+	"sendrawtransaction":     {},
+	"submitblock":            {},
+	"validateaddress":        {},
+	"verifymessage":          {},
 }
 
 // builderScript is a convenience function which is used for hard-coded scripts
@@ -314,10 +324,13 @@ func rpcNoTxInfoError(txHash *chainhash.Hash) *btcjson.RPCError {
 }
 
 // workStateBlockInfo houses information about how to reconstruct a block given
-// its template and signature script.
+// its template and signature script along with the header prefix and nonce
+// header candidates which were provided to the caller to solve it.
 type workStateBlockInfo struct {
 	msgBlock        *wire.MsgBlock
 	signatureScript []byte
+	prefix          []byte
+	candidateIKeys  map[string]struct{}
 }
 
 // workState houses state that is used in between multiple RPC invocations to
@@ -351,6 +364,16 @@ type gbtWorkState struct {
 	template      *BlockTemplate
 	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
+
+	// nonceCandidates houses the hex-encoded message headers which may be
+	// used as the nonce headers of the block template.
+	nonceCandidates []string
+
+	// rules and vbAvailable house the names of the rule change deployments
+	// which are active for the block template and the bits of the ones
+	// its version signals for, respectively.
+	rules       []string
+	vbAvailable map[string]uint8
 }
 
 // newGbtWorkState returns a new instance of a gbtWorkState with all internal
@@ -677,6 +700,26 @@ func createVinList(mtx *wire.MsgTx) []btcjson.Vin {
 	return vinList
 }
 
+// createDirectoryEntryResult returns the decoded directory entry posted by the
+// passed public key script.  nil is returned when the script is not of the
+// form OP_POSTDIRECTORY <data> or the data is not a well-formed entry.
+func createDirectoryEntryResult(pkScript []byte) *btcjson.DirectoryEntryResult {
+	entry, err := txscript.ExtractDirectoryEntry(pkScript)
+	if err != nil {
+		return nil
+	}
+
+	return &btcjson.DirectoryEntryResult{
+		Version:   entry.Version,
+		Expire:    int64(entry.Expire),
+		PubKey:    hex.EncodeToString(entry.PubKey),
+		Name:      entry.Name,
+		Value:     hex.EncodeToString(entry.Value),
+		Signature: hex.EncodeToString(entry.Signature),
+		Valid:     entry.Verify() == nil,
+	}
+}
+
 // createVoutList returns a slice of JSON objects for the outputs of the passed
 // transaction.
 func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap map[string]struct{}) []btcjson.Vout {
@@ -722,6 +765,8 @@ func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap
 		vout.ScriptPubKey.Hex = hex.EncodeToString(v.PkScript)
 		vout.ScriptPubKey.Type = scriptClass.String()
 		vout.ScriptPubKey.ReqSigs = int32(reqSigs)
+		vout.ScriptPubKey.DirectoryEntry = createDirectoryEntryResult(
+			v.PkScript)
 
 		voutList = append(voutList, vout)
 	}
@@ -830,11 +875,12 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 
 	// Generate and return the reply.
 	reply := btcjson.DecodeScriptResult{
-		Asm:       disbuf,
-		ReqSigs:   int32(reqSigs),
-		Type:      scriptClass.String(),
-		Addresses: addresses,
-		P2sh:      p2sh.EncodeAddress(),
+		Asm:            disbuf,
+		ReqSigs:        int32(reqSigs),
+		Type:           scriptClass.String(),
+		Addresses:      addresses,
+		DirectoryEntry: createDirectoryEntryResult(script),
+		P2sh:           p2sh.EncodeAddress(),
 	}
 	return reply, nil
 }
@@ -881,6 +927,77 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
+// accessKeyIndexOrError returns the access key index of the server or an RPC
+// error which indicates it must be enabled when it is not.
+func accessKeyIndexOrError(s *rpcServer) (*indexers.AccessKeyIndex, error) {
+	akIndex := s.server.akIndex
+	if akIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Access key index must be enabled (--akindex)",
+		}
+	}
+	return akIndex, nil
+}
+
+// createAccessKeyResult converts the passed access key index entry into the
+// result returned by the getaccesskey and listaccesskeys commands.  The
+// access key is reported as valid when it has not expired at the passed time.
+func createAccessKeyResult(s *rpcServer, entry *indexers.AccessKeyEntry, now int64) (*btcjson.AccessKeyResult, error) {
+	blockHash, err := s.server.blockManager.chain.BlockHashByHeight(
+		entry.BlockHeight)
+	if err != nil {
+		context := "Failed to fetch block hash"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.AccessKeyResult{
+		PubKey:      hex.EncodeToString(entry.PubKey),
+		Expire:      int64(entry.Expire),
+		Signature:   hex.EncodeToString(entry.Signature),
+		Valid:       int64(entry.Expire) > now,
+		TxID:        entry.TxHash.String(),
+		Vout:        entry.OutputIndex,
+		BlockHash:   blockHash.String(),
+		BlockHeight: entry.BlockHeight,
+	}, nil
+}
+
+// handleGetAccessKey implements the getaccesskey command.
+func handleGetAccessKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	akIndex, err := accessKeyIndexOrError(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetAccessKeyCmd)
+	pubKey, err := hex.DecodeString(c.PubKey)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.PubKey)
+	}
+	if len(pubKey) != txscript.AccessKeyPubKeySize {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Public key must be a %d-byte "+
+				"compressed public key", txscript.AccessKeyPubKeySize),
+		}
+	}
+
+	entry, err := akIndex.AccessKey(pubKey)
+	if err != nil {
+		context := "Failed to fetch access key"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if entry == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "No access key registered for " + c.PubKey,
+		}
+	}
+
+	return createAccessKeyResult(s, entry, time.Now().Unix())
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
@@ -1003,6 +1120,33 @@ func getDifficultyRatio(bits uint32) float64 {
 	return diff
 }
 
+// nonceHeaderResult decodes the passed message header used as a nonce of a
+// block into a result for the verbose getblock and getblockheader commands.
+// The message is reported as present when it is known to the passed message
+// header source, which may be nil.  Only the raw header is reported when it
+// fails to import.
+func nonceHeaderResult(bh *ciphrtxt.BinaryMessageHeaderV2, hs blockchain.MessageHeaderSource) *btcjson.NonceHeaderResult {
+	result := &btcjson.NonceHeaderResult{
+		Hex: hex.EncodeToString(bh[:]),
+	}
+	rh := ciphrtxt.ImportBinaryHeaderV2(bh[:])
+	if rh == nil {
+		return result
+	}
+
+	// The version prefix of a message header is the letter M followed by
+	// the major and minor version, so format it the same way as the text
+	// headers do, such as M0200.
+	result.Version = fmt.Sprintf("%c%02x%02x", bh[0], bh[1], bh[2])
+	result.IKey = hex.EncodeToString(rh.IKey())
+	result.Expire = rh.ExpireTime().Unix()
+	if hs != nil {
+		_, err := hs.FindByI(rh.IKey())
+		result.Present = err == nil
+	}
+	return result
+}
+
 // handleGetBlock implements the getblock command.
 func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockCmd)
@@ -1061,13 +1205,17 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	}
 
 	blockHeader := &blk.MsgBlock().Header
+	nonceHeaderA := nonceHeaderResult(&blockHeader.NonceHeaderA,
+		s.server.headerCache)
+	nonceHeaderB := nonceHeaderResult(&blockHeader.NonceHeaderB,
+		s.server.headerCache)
 	blockReply := btcjson.GetBlockVerboseResult{
 		Hash:          c.Hash,
 		Version:       blockHeader.Version,
 		MerkleRoot:    blockHeader.MerkleRoot.String(),
 		PreviousHash:  blockHeader.PrevBlock.String(),
-		NonceHeaderA:         blockHeader.NonceHeaderA[:],
-		NonceHeaderB:         blockHeader.NonceHeaderB[:],
+		NonceHeaderA:  nonceHeaderA,
+		NonceHeaderB:  nonceHeaderB,
 		Time:          blockHeader.Timestamp.Unix(),
 		Confirmations: uint64(1 + best.Height - blockHeight),
 		Height:        int64(blockHeight),
@@ -1103,6 +1251,69 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return blockReply, nil
 }
 
+// softForkStatus returns the status of a rule change deployment in the passed
+// threshold state as reported by the getblockchaininfo command.
+func softForkStatus(state blockchain.ThresholdState) (string, error) {
+	switch state {
+	case blockchain.ThresholdDefined:
+		return "defined", nil
+	case blockchain.ThresholdStarted:
+		return "started", nil
+	case blockchain.ThresholdLockedIn:
+		return "lockedin", nil
+	case blockchain.ThresholdActive:
+		return "active", nil
+	case blockchain.ThresholdFailed:
+		return "failed", nil
+	default:
+		return "", fmt.Errorf("unknown deployment state: %v", state)
+	}
+}
+
+// handleGetBlockChainInfo implements the getblockchaininfo command.
+func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Obtain a snapshot of the current best known blockchain state.  The
+	// deployment states are the ones which apply to the next block.
+	params := s.server.chainParams
+	best := s.chain.BestSnapshot()
+	chainInfo := &btcjson.GetBlockChainInfoResult{
+		Chain:         params.Name,
+		Blocks:        best.Height,
+		Headers:       best.Height,
+		BestBlockHash: best.Hash.String(),
+		Difficulty:    getDifficultyRatio(best.Bits),
+		MedianTime:    best.MedianTime.Unix(),
+		ChainWork:     fmt.Sprintf("%064x", best.WorkSum),
+		SoftForks: make([]*btcjson.SoftForkDescription, 0,
+			len(params.Deployments)),
+	}
+
+	for id, name := range deploymentNames {
+		state, err := s.chain.ThresholdState(uint32(id))
+		if err != nil {
+			context := "Failed to obtain deployment status"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		status, err := softForkStatus(state)
+		if err != nil {
+			context := "Failed to obtain deployment status"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		deployment := &params.Deployments[id]
+		chainInfo.SoftForks = append(chainInfo.SoftForks,
+			&btcjson.SoftForkDescription{
+				ID:        name,
+				Status:    status,
+				Bit:       deployment.BitNumber,
+				StartTime: int64(deployment.StartTime),
+				Timeout:   int64(deployment.ExpireTime),
+			})
+	}
+
+	return chainInfo, nil
+}
+
 // handleGetBlockCount implements the getblockcount command.
 func handleGetBlockCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := s.chain.BestSnapshot()
@@ -1180,6 +1391,10 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		nextHashString = nextHash.String()
 	}
 
+	nonceHeaderA := nonceHeaderResult(&blockHeader.NonceHeaderA,
+		s.server.headerCache)
+	nonceHeaderB := nonceHeaderResult(&blockHeader.NonceHeaderB,
+		s.server.headerCache)
 	blockHeaderReply := btcjson.GetBlockHeaderVerboseResult{
 		Hash:          c.Hash,
 		Confirmations: uint64(1 + best.Height - blockHeight),
@@ -1188,8 +1403,8 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		MerkleRoot:    blockHeader.MerkleRoot.String(),
 		NextHash:      nextHashString,
 		PreviousHash:  blockHeader.PrevBlock.String(),
-		NonceHeaderA:         blockHeader.NonceHeaderA[:],
-		NonceHeaderB:         blockHeader.NonceHeaderB[:],
+		NonceHeaderA:  nonceHeaderA,
+		NonceHeaderB:  nonceHeaderB,
 		Time:          blockHeader.Timestamp.Unix(),
 		Bits:          strconv.FormatInt(int64(blockHeader.Bits), 16),
 		Difficulty:    getDifficultyRatio(blockHeader.Bits),
@@ -1197,6 +1412,39 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 	return blockHeaderReply, nil
 }
 
+// handleGetBlocksByNonceHeader implements the getblocksbynonceheader command.
+func handleGetBlocksByNonceHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	nonceHdrIndex := s.server.nonceHdrIndex
+	if nonceHdrIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Nonce header index must be enabled (--noncehdrindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetBlocksByNonceHeaderCmd)
+	ikey, err := hex.DecodeString(c.IKey)
+	if err != nil || len(ikey) == 0 {
+		return nil, rpcDecodeHexError(c.IKey)
+	}
+
+	uses, err := nonceHdrIndex.BlocksByNonceHeader(ikey)
+	if err != nil {
+		context := "Failed to fetch blocks by nonce header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	results := make([]btcjson.NonceHeaderBlockResult, 0, len(uses))
+	for _, use := range uses {
+		results = append(results, btcjson.NonceHeaderBlockResult{
+			BlockHash:   use.BlockHash.String(),
+			BlockHeight: use.BlockHeight,
+			NonceA:      use.NonceA,
+			NonceB:      use.NonceB,
+		})
+	}
+	return results, nil
+}
+
 // encodeTemplateID encodes the passed details into an ID that can be used to
 // uniquely identify a block template.
 func encodeTemplateID(prevHash *chainhash.Hash, lastGenerated time.Time) string {
@@ -1415,6 +1663,15 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		state.prevHash = latestHash
 		state.minTimestamp = minTimestamp
 
+		// Report the deployments which are active for the new template
+		// and the ones its version signals for.
+		err = state.updateDeploymentRules(s.chain, s.server.chainParams,
+			msgBlock.Header.Version)
+		if err != nil {
+			context := "Failed to obtain deployment status"
+			return internalRPCError(err.Error(), context)
+		}
+
 		rpcsLog.Debugf("Generated block template (timestamp %v, "+
 			"target %s, merkle root %s)",
 			msgBlock.Header.Timestamp, targetDifficulty,
@@ -1522,6 +1779,49 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 			targetDifficulty)
 	}
 
+	// The nonce headers are not part of the block template, so provide
+	// the message headers which may currently be used to solve it instead.
+	// They are refreshed on every update since messages expire and new ones
+	// arrive independently of the block template.
+	state.nonceCandidates = nil
+	if s.server.headerCache != nil {
+		candidates, _, err := nonceHeaderCandidates(s.server.headerCache,
+			s.server.blockManager.chain, s.server.chainParams)
+		if err != nil {
+			context := "Failed to fetch nonce header candidates"
+			return internalRPCError(err.Error(), context)
+		}
+		state.nonceCandidates = make([]string, 0, len(candidates))
+		for i := range candidates {
+			state.nonceCandidates = append(state.nonceCandidates,
+				hex.EncodeToString(candidates[i][:]))
+		}
+	}
+
+	return nil
+}
+
+// updateDeploymentRules updates the names of the rule change deployments which
+// are active for the block template along with the bits of the ones the passed
+// block version of the template signals for.
+//
+// This function MUST be called with the state locked.
+func (state *gbtWorkState) updateDeploymentRules(chain *blockchain.BlockChain, params *chaincfg.Params, version int32) error {
+	state.rules = nil
+	state.vbAvailable = make(map[string]uint8)
+	for id, name := range deploymentNames {
+		deploymentState, err := chain.ThresholdState(uint32(id))
+		if err != nil {
+			return err
+		}
+		bit := params.Deployments[id].BitNumber
+		switch {
+		case deploymentState == blockchain.ThresholdActive:
+			state.rules = append(state.rules, name)
+		case uint32(version)&(uint32(1)<<bit) != 0:
+			state.vbAvailable[name] = bit
+		}
+	}
 	return nil
 }
 
@@ -1622,6 +1922,10 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		Mutable:      gbtMutableFields,
 		NonceRange:   gbtNonceRange,
 		Capabilities: gbtCapabilities,
+
+		Rules:           state.rules,
+		VbAvailable:     state.vbAvailable,
+		NonceCandidates: state.nonceCandidates,
 	}
 	if useCoinbaseValue {
 		reply.CoinbaseAux = gbtCoinbaseAux
@@ -1930,6 +2234,16 @@ func chainErrToGBTErrString(err error) string {
 		return "bad-script-malformed"
 	case blockchain.ErrScriptValidation:
 		return "bad-script-validate"
+	case blockchain.ErrNonceValidation:
+		return "bad-nonce-header"
+	case blockchain.ErrNonceHeaderReuse:
+		return "bad-nonce-reuse"
+	case blockchain.ErrMissingNonceHeader:
+		return "bad-nonce-unknown"
+	case blockchain.ErrNonceHeaderTooOld:
+		return "bad-nonce-age"
+	case blockchain.ErrNonceHeaderExpiring:
+		return "bad-nonce-lifetime"
 	}
 
 	return "rejected: " + err.Error()
@@ -2043,6 +2357,113 @@ func handleGetDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return getDifficultyRatio(best.Bits), nil
 }
 
+// directoryIndexOrError returns the directory index of the server or an RPC
+// error which indicates it must be enabled when it is not.
+func directoryIndexOrError(s *rpcServer) (*indexers.DirectoryIndex, error) {
+	dirIndex := s.server.dirIndex
+	if dirIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Directory index must be enabled (--dirindex)",
+		}
+	}
+	return dirIndex, nil
+}
+
+// createDirectoryIndexEntryResults converts the passed directory index entries
+// into the results returned by the directory commands.  Entries are reported
+// as expired when they expire at or before the passed time.
+func createDirectoryIndexEntryResults(s *rpcServer, entries []*indexers.DirectoryEntry, now int64) ([]btcjson.DirectoryIndexEntryResult, error) {
+	results := make([]btcjson.DirectoryIndexEntryResult, 0, len(entries))
+	for _, dirEntry := range entries {
+		blockHash, err := s.server.blockManager.chain.BlockHashByHeight(
+			dirEntry.BlockHeight)
+		if err != nil {
+			context := "Failed to fetch block hash"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		entry := dirEntry.Entry
+		results = append(results, btcjson.DirectoryIndexEntryResult{
+			Version:     entry.Version,
+			Expire:      int64(entry.Expire),
+			PubKey:      hex.EncodeToString(entry.PubKey),
+			Name:        entry.Name,
+			Value:       hex.EncodeToString(entry.Value),
+			Signature:   hex.EncodeToString(entry.Signature),
+			Expired:     int64(entry.Expire) <= now,
+			TxID:        dirEntry.TxHash.String(),
+			Vout:        dirEntry.OutputIndex,
+			BlockHash:   blockHash.String(),
+			BlockHeight: dirEntry.BlockHeight,
+		})
+	}
+	return results, nil
+}
+
+// decodeDirectoryPubKey decodes the passed hex-encoded owner public key of a
+// directory entry and ensures it has the expected size.
+func decodeDirectoryPubKey(pubKeyStr string) ([]byte, error) {
+	pubKey, err := hex.DecodeString(pubKeyStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(pubKeyStr)
+	}
+	if len(pubKey) != dirent.PubKeySize {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Public key must be a %d-byte "+
+				"compressed public key", dirent.PubKeySize),
+		}
+	}
+	return pubKey, nil
+}
+
+// handleGetDirectoryEntry implements the getdirectoryentry command.
+func handleGetDirectoryEntry(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	dirIndex, err := directoryIndexOrError(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetDirectoryEntryCmd)
+	var pubKey []byte
+	if c.PubKey != nil {
+		pubKey, err = decodeDirectoryPubKey(*c.PubKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Only entries which have not expired are considered and the most
+	// recently posted one which matches the owner, when provided, is
+	// returned.
+	now := time.Now().Unix()
+	entries, err := dirIndex.EntriesByName(c.Name, uint32(now))
+	if err != nil {
+		context := "Failed to fetch directory entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	var found *indexers.DirectoryEntry
+	for _, entry := range entries {
+		if pubKey == nil || bytes.Equal(entry.Entry.PubKey, pubKey) {
+			found = entry
+		}
+	}
+	if found == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "No directory entry posted for " + c.Name,
+		}
+	}
+
+	results, err := createDirectoryIndexEntryResults(s,
+		[]*indexers.DirectoryEntry{found}, now)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
 // handleGetGenerate implements the getgenerate command.
 func handleGetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.server.cpuMiner.IsMining(), nil
@@ -2433,41 +2854,6 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
-// bigToLEUint256 returns the passed big integer as an unsigned 256-bit integer
-// encoded as little-endian bytes.  Numbers which are larger than the max
-// unsigned 256-bit integer are truncated.
-func bigToLEUint256(n *big.Int) [uint256Size]byte {
-	// Pad or truncate the big-endian big int to correct number of bytes.
-	nBytes := n.Bytes()
-	nlen := len(nBytes)
-	pad := 0
-	start := 0
-	if nlen <= uint256Size {
-		pad = uint256Size - nlen
-	} else {
-		start = nlen - uint256Size
-	}
-	var buf [uint256Size]byte
-	copy(buf[pad:], nBytes[start:])
-
-	// Reverse the bytes to little endian and return them.
-	for i := 0; i < uint256Size/2; i++ {
-		buf[i], buf[uint256Size-1-i] = buf[uint256Size-1-i], buf[i]
-	}
-	return buf
-}
-
-// reverseUint32Array treats the passed bytes as a series of uint32s and
-// reverses the byte order of each uint32.  The passed byte slice must be a
-// multiple of 4 for a correct result.  The passed bytes slice is modified.
-func reverseUint32Array(b []byte) {
-	blen := len(b)
-	for i := 0; i < blen; i += 4 {
-		b[i], b[i+3] = b[i+3], b[i]
-		b[i+1], b[i+2] = b[i+2], b[i+1]
-	}
-}
-
 // handleGetTxOut handles gettxout commands.
 func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutCmd)
@@ -2665,77 +3051,66 @@ func handleGetWorkRequest(s *rpcServer) (interface{}, error) {
 			msgBlock.Transactions[0].TxIn[0].SignatureScript)
 	}
 
+	// Fetch the message headers the caller may choose from to use as the
+	// nonce headers of the block.  Any ordered pair of two distinct
+	// candidates is acceptable.
+	var candidates []ciphrtxt.BinaryMessageHeaderV2
+	var candidateIKeys map[string]struct{}
+	if s.server.headerCache != nil {
+		var err error
+		candidates, candidateIKeys, err = nonceHeaderCandidates(
+			s.server.headerCache, s.server.blockManager.chain,
+			s.server.chainParams)
+		if err != nil {
+			context := "Failed to fetch nonce header candidates"
+			return nil, internalRPCError(err.Error(), context)
+		}
+	}
+	if len(candidates) < 2 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "Not enough message headers are available " +
+				"to use as the nonce headers of a block",
+		}
+	}
+
+	// Serialize the block header and strip the nonce headers to obtain the
+	// header prefix which is returned as the data below.
+	var buf bytes.Buffer
+	buf.Grow(wire.MaxBlockHeaderPayload)
+	err := msgBlock.Header.Serialize(&buf)
+	if err != nil {
+		errStr := fmt.Sprintf("Failed to serialize data: %v", err)
+		return nil, internalRPCError(errStr, "")
+	}
+	data := buf.Bytes()[:getworkPrefixLen]
+
 	// In order to efficiently store the variations of block templates that
 	// have been provided to callers, save a pointer to the block as well as
 	// the modified signature script keyed by the merkle root.  This
 	// information, along with the data that is included in a work
 	// submission, is used to rebuild the block before checking the
-	// submitted solution.
+	// submitted solution.  The header prefix and the candidates are saved
+	// as well so the submission can be validated against the work which
+	// was actually provided.
 	coinbaseTx := msgBlock.Transactions[0]
 	state.blockInfo[msgBlock.Header.MerkleRoot] = &workStateBlockInfo{
 		msgBlock:        msgBlock,
 		signatureScript: coinbaseTx.TxIn[0].SignatureScript,
+		prefix:          data,
+		candidateIKeys:  candidateIKeys,
 	}
 
-	// Serialize the block header into a buffer large enough to hold the
-	// the block header and the internal sha256 padding that is added and
-	// retuned as part of the data below.
-	data := make([]byte, 0, getworkDataLen)
-	buf := bytes.NewBuffer(data)
-	err := msgBlock.Header.Serialize(buf)
-	if err != nil {
-		errStr := fmt.Sprintf("Failed to serialize data: %v", err)
-		return nil, internalRPCError(errStr, "")
+	hexCandidates := make([]string, 0, len(candidates))
+	for i := range candidates {
+		hexCandidates = append(hexCandidates,
+			hex.EncodeToString(candidates[i][:]))
 	}
-
-	// Calculate the midstate for the block header.  The midstate here is
-	// the internal state of the sha256 algorithm for the first chunk of the
-	// block header (sha256 operates on 64-byte chunks) which is before the
-	// nonce.  This allows sophisticated callers to avoid hashing the first
-	// chunk over and over while iterating the nonce range.
-	data = data[:buf.Len()]
-	midstate := fastsha256.MidState256(data)
-
-	// Expand the data slice to include the full data buffer and apply the
-	// internal sha256 padding which consists of a single 1 bit followed
-	// by enough zeros to pad the message out to 56 bytes followed by the
-	// length of the message in bits encoded as a big-endian uint64
-	// (8 bytes).  Thus, the resulting length is a multiple of the sha256
-	// block size (64 bytes).  This makes the data ready for sophisticated
-	// caller to make use of only the second chunk along with the midstate
-	// for the first chunk.
-	data = data[:getworkDataLen]
-	data[wire.MaxBlockHeaderPayload] = 0x80
-	binary.BigEndian.PutUint64(data[len(data)-8:],
-		wire.MaxBlockHeaderPayload*8)
-
-	// Create the hash1 field which is a zero hash along with the internal
-	// sha256 padding as described above.  This field is really quite
-	// useless, but it is required for compatibility with the reference
-	// implementation.
-	var hash1 [hash1Len]byte
-	hash1[chainhash.HashSize] = 0x80
-	binary.BigEndian.PutUint64(hash1[len(hash1)-8:], chainhash.HashSize*8)
-
-	// The final result reverses each of the fields to little endian.
-	// In particular, the data, hash1, and midstate fields are treated as
-	// arrays of uint32s (per the internal sha256 hashing state) which are
-	// in big endian, and thus each 4 bytes is byte swapped.  The target is
-	// also in big endian, but it is treated as a uint256 and byte swapped
-	// to little endian accordingly.
-	//
-	// The fact the fields are reversed in this way is rather odd and likey
-	// an artifact of some legacy internal state in the reference
-	// implementation, but it is required for compatibility.
-	reverseUint32Array(data)
-	reverseUint32Array(hash1[:])
-	reverseUint32Array(midstate[:])
-	target := bigToLEUint256(blockchain.CompactToBig(msgBlock.Header.Bits))
 	reply := &btcjson.GetWorkResult{
-		Data:     hex.EncodeToString(data),
-		Hash1:    hex.EncodeToString(hash1[:]),
-		Midstate: hex.EncodeToString(midstate[:]),
-		Target:   hex.EncodeToString(target[:]),
+		Data:       hex.EncodeToString(data),
+		Candidates: hexCandidates,
+		Target: fmt.Sprintf("%064x",
+			blockchain.CompactToBig(msgBlock.Header.Bits)),
 	}
 	return reply, nil
 }
@@ -2753,26 +3128,18 @@ func handleGetWorkSubmission(s *rpcServer, hexData string) (interface{}, error)
 	if err != nil {
 		return false, rpcDecodeHexError(hexData)
 	}
-	if len(data) != getworkDataLen {
+	if len(data) != wire.MaxBlockHeaderPayload {
 		return false, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInvalidParameter,
 			Message: fmt.Sprintf("Argument must be "+
-				"%d bytes (not %d)", getworkDataLen,
+				"%d bytes (not %d)", wire.MaxBlockHeaderPayload,
 				len(data)),
 		}
 	}
 
-	// Reverse the data as if it were an array of 32-bit unsigned integers.
-	// The fact the getwork request and submission data is reversed in this
-	// way is rather odd and likey an artifact of some legacy internal state
-	// in the reference implementation, but it is required for
-	// compatibility.
-	reverseUint32Array(data)
-
 	// Deserialize the block header from the data.
 	var submittedHeader wire.BlockHeader
-	bhBuf := bytes.NewReader(data[0:wire.MaxBlockHeaderPayload])
-	err = submittedHeader.Deserialize(bhBuf)
+	err = submittedHeader.Deserialize(bytes.NewReader(data))
 	if err != nil {
 		return false, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInvalidParameter,
@@ -2793,6 +3160,33 @@ func handleGetWorkSubmission(s *rpcServer, hexData string) (interface{}, error)
 		return false, nil
 	}
 
+	// Ensure the submitted header is the one which was provided as work
+	// apart from the nonce headers.
+	if !bytes.Equal(data[:getworkPrefixLen], blockInfo.prefix) {
+		rpcsLog.Debugf("Block submitted via getwork does not match "+
+			"the provided work for merkle root %s",
+			submittedHeader.MerkleRoot)
+		return false, nil
+	}
+
+	// Ensure the nonce headers are two distinct headers chosen from the
+	// candidates which were provided with the work.
+	headerA := ciphrtxt.ImportBinaryHeaderV2(submittedHeader.NonceHeaderA[:])
+	headerB := ciphrtxt.ImportBinaryHeaderV2(submittedHeader.NonceHeaderB[:])
+	if headerA == nil || headerB == nil {
+		rpcsLog.Debug("Block submitted via getwork has malformed " +
+			"nonce headers")
+		return false, nil
+	}
+	ikeyA, ikeyB := string(headerA.IKey()), string(headerB.IKey())
+	_, knownA := blockInfo.candidateIKeys[ikeyA]
+	_, knownB := blockInfo.candidateIKeys[ikeyB]
+	if ikeyA == ikeyB || !knownA || !knownB {
+		rpcsLog.Debug("Block submitted via getwork does not use a " +
+			"pair of distinct nonce header candidates")
+		return false, nil
+	}
+
 	// Reconstruct the block using the submitted header stored block info.
 	msgBlock := blockInfo.msgBlock
 	block := cttutil.NewBlock(msgBlock)
@@ -2935,6 +3329,72 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// handleListAccessKeys implements the listaccesskeys command.
+func handleListAccessKeys(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	akIndex, err := accessKeyIndexOrError(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Default to the access keys which are currently valid.
+	c := cmd.(*btcjson.ListAccessKeysCmd)
+	now := time.Now().Unix()
+	validAt := now
+	if c.ValidAt != nil {
+		validAt = *c.ValidAt
+	}
+	if validAt < 0 || validAt > math.MaxUint32 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid time %d", validAt),
+		}
+	}
+
+	entries, err := akIndex.AccessKeysValidAt(uint32(validAt))
+	if err != nil {
+		context := "Failed to fetch access keys"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]btcjson.AccessKeyResult, 0, len(entries))
+	for _, entry := range entries {
+		result, err := createAccessKeyResult(s, entry, now)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// handleListDirectoryEntries implements the listdirectoryentries command.
+func handleListDirectoryEntries(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	dirIndex, err := directoryIndexOrError(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.ListDirectoryEntriesCmd)
+	pubKey, err := decodeDirectoryPubKey(c.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// A time of zero includes expired entries.
+	now := time.Now().Unix()
+	validAt := uint32(now)
+	if c.IncludeExpired != nil && *c.IncludeExpired {
+		validAt = 0
+	}
+	entries, err := dirIndex.EntriesByOwner(pubKey, validAt)
+	if err != nil {
+		context := "Failed to fetch directory entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return createDirectoryIndexEntryResults(s, entries, now)
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -3165,6 +3625,40 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr cttutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handleSearchDirectory implements the searchdirectory command.
+func handleSearchDirectory(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	dirIndex, err := directoryIndexOrError(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.SearchDirectoryCmd)
+	count := 100
+	if c.Count != nil {
+		count = *c.Count
+		if count <= 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Count must be positive",
+			}
+		}
+	}
+
+	// A time of zero includes expired entries.
+	now := time.Now().Unix()
+	validAt := uint32(now)
+	if c.IncludeExpired != nil && *c.IncludeExpired {
+		validAt = 0
+	}
+	entries, err := dirIndex.SearchEntries(c.Prefix, validAt, count)
+	if err != nil {
+		context := "Failed to search directory entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return createDirectoryIndexEntryResults(s, entries, now)
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -3564,7 +4058,9 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 
 	_, err = s.server.blockManager.ProcessBlock(block, blockchain.BFNone)
 	if err != nil {
-		return fmt.Sprintf("rejected: %s", err.Error()), nil
+		rpcsLog.Infof("Rejected block %s via submitblock: %v",
+			block.Hash(), err)
+		return chainErrToGBTErrString(err), nil
 	}
 
 	rpcsLog.Infof("Accepted block %s via submitblock", block.Hash())
@@ -3609,7 +4105,7 @@ func verifyChain(s *rpcServer, level, depth int32) error {
 		// Level 1 does basic chain sanity checks.
 		if level > 0 {
 			err := blockchain.CheckBlockSanity(block,
-				activeNetParams.PowLimit, s.server.timeSource, s.server.headerCache)
+				activeNetParams.Params, s.server.timeSource, s.server.headerCache)
 			if err != nil {
 				rpcsLog.Errorf("Verify is unable to validate "+
 					"block at hash %v height %d: %v",
@@ -20,7 +20,7 @@ var helpDescsEnUS = map[string]string{
 		"The levelspec can either a debug level or of the form:\n" +
 		"<subsystem>=<level>,<subsystem2>=<level2>,...\n" +
 		"The valid debug levels are trace, debug, info, warn, error, and critical.\n" +
-		"The valid subsystems are AMGR, ADXR, BCDB, BMGR, CTTD, CHAN, DISC, PEER, RPCS, SCRP, SRVR, and TXMP.\n" +
+		"The valid subsystems are AMGR, ADXR, BCDB, BMGR, CTTD, CHAN, DISC, PEER, RPCS, SCRP, SRVR, STRM, and TXMP.\n" +
 		"Finally the keyword 'show' will return a list of the available subsystems.",
 	"debuglevel-levelspec":   "The debug level(s) to use or the keyword 'show'",
 	"debuglevel--condition0": "levelspec!=show",
@@ -79,11 +79,12 @@ var helpDescsEnUS = map[string]string{
 	"vin-sequence":  "The script sequence number",
 
 	// ScriptPubKeyResult help.
-	"scriptpubkeyresult-asm":       "Disassembly of the script",
-	"scriptpubkeyresult-hex":       "Hex-encoded bytes of the script",
-	"scriptpubkeyresult-reqSigs":   "The number of required signatures",
-	"scriptpubkeyresult-type":      "The type of the script (e.g. 'pubkeyhash')",
-	"scriptpubkeyresult-addresses": "The bitcoin addresses associated with this script",
+	"scriptpubkeyresult-asm":            "Disassembly of the script",
+	"scriptpubkeyresult-hex":            "Hex-encoded bytes of the script",
+	"scriptpubkeyresult-reqSigs":        "The number of required signatures",
+	"scriptpubkeyresult-type":           "The type of the script (e.g. 'pubkeyhash')",
+	"scriptpubkeyresult-addresses":      "The bitcoin addresses associated with this script",
+	"scriptpubkeyresult-directoryentry": "The decoded directory entry posted by the script (only for well-formed OP_POSTDIRECTORY scripts)",
 
 	// Vout help.
 	"vout-value":        "The amount in BTC",
@@ -102,11 +103,12 @@ var helpDescsEnUS = map[string]string{
 	"decoderawtransaction-hextx":     "Serialized, hex-encoded transaction",
 
 	// DecodeScriptResult help.
-	"decodescriptresult-asm":       "Disassembly of the script",
-	"decodescriptresult-reqSigs":   "The number of required signatures",
-	"decodescriptresult-type":      "The type of the script (e.g. 'pubkeyhash')",
-	"decodescriptresult-addresses": "The bitcoin addresses associated with this script",
-	"decodescriptresult-p2sh":      "The script hash for use in pay-to-script-hash transactions",
+	"decodescriptresult-asm":            "Disassembly of the script",
+	"decodescriptresult-reqSigs":        "The number of required signatures",
+	"decodescriptresult-type":           "The type of the script (e.g. 'pubkeyhash')",
+	"decodescriptresult-addresses":      "The bitcoin addresses associated with this script",
+	"decodescriptresult-directoryentry": "The decoded directory entry posted by the script (only for well-formed OP_POSTDIRECTORY scripts)",
+	"decodescriptresult-p2sh":           "The script hash for use in pay-to-script-hash transactions",
 
 	// DecodeScriptCmd help.
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
@@ -118,6 +120,29 @@ var helpDescsEnUS = map[string]string{
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// AccessKeyResult help.
+	"accesskeyresult-pubkey":      "The hex-encoded compressed public key of the access key",
+	"accesskeyresult-expire":      "The time the access key expires in seconds since 1 Jan 1970 GMT",
+	"accesskeyresult-signature":   "The hex-encoded signature over the expiration time and public key",
+	"accesskeyresult-valid":       "Whether or not the access key has not yet expired",
+	"accesskeyresult-txid":        "The hash of the transaction which registered the access key",
+	"accesskeyresult-vout":        "The index of the output which registered the access key",
+	"accesskeyresult-blockhash":   "The hash of the block which contains the registration",
+	"accesskeyresult-blockheight": "The height of the block which contains the registration",
+
+	// DirectoryEntryResult help.
+	"directoryentryresult-version":   "The version of the directory entry format",
+	"directoryentryresult-expire":    "The time the directory entry expires in seconds since 1 Jan 1970 GMT",
+	"directoryentryresult-pubkey":    "The hex-encoded compressed public key of the owner of the entry",
+	"directoryentryresult-name":      "The name the entry is posted under",
+	"directoryentryresult-value":     "The hex-encoded value bound to the name",
+	"directoryentryresult-signature": "The hex-encoded signature of the owner over the entry",
+	"directoryentryresult-valid":     "Whether or not the signature of the entry was produced by its owner",
+
+	// GetAccessKeyCmd help.
+	"getaccesskey--synopsis": "Returns the most recent registration of a network access key (requires --akindex).",
+	"getaccesskey-pubkey":    "The hex-encoded compressed public key of the access key",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -180,6 +205,13 @@ var helpDescsEnUS = map[string]string{
 	"searchrawtransactionsresult-time":          "Transaction time in seconds since 1 Jan 1970 GMT",
 	"searchrawtransactionsresult-blocktime":     "Block time in seconds since the 1 Jan 1970 GMT",
 
+	// NonceHeaderResult help.
+	"nonceheaderresult-hex":     "Hex-encoded serialized message header",
+	"nonceheaderresult-version": "The message header version such as M0200",
+	"nonceheaderresult-ikey":    "The hex-encoded I key of the message header",
+	"nonceheaderresult-expire":  "The message expiration time in seconds since 1 Jan 1970 GMT",
+	"nonceheaderresult-present": "Whether the message header is known to the local header cache",
+
 	// GetBlockVerboseResult help.
 	"getblockverboseresult-hash":              "The hash of the block (same as provided)",
 	"getblockverboseresult-confirmations":     "The number of confirmations",
@@ -190,12 +222,34 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-tx":                "The transaction hashes (only when verbosetx=false)",
 	"getblockverboseresult-rawtx":             "The transactions as JSON objects (only when verbosetx=true)",
 	"getblockverboseresult-time":              "The block time in seconds since 1 Jan 1970 GMT",
-	"getblockverboseresult-nonce":             "The block nonce",
+	"getblockverboseresult-nonceheadera":      "The message header used as the first nonce of the block",
+	"getblockverboseresult-nonceheaderb":      "The message header used as the second nonce of the block",
 	"getblockverboseresult-bits":              "The bits which represent the block difficulty",
 	"getblockverboseresult-difficulty":        "The proof-of-work difficulty as a multiple of the minimum difficulty",
 	"getblockverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
+	// GetBlockChainInfoCmd help.
+	"getblockchaininfo--synopsis": "Returns information about the current state of the block chain and the rule change deployments voted on with the version bits of blocks.",
+
+	// GetBlockChainInfoResult help.
+	"getblockchaininforesult-chain":                "The name of the network the chain belongs to",
+	"getblockchaininforesult-blocks":               "The height of the best block in the longest block chain",
+	"getblockchaininforesult-headers":              "The height of the best known block header",
+	"getblockchaininforesult-bestblockhash":        "The hex-encoded hash of the best block in the longest block chain",
+	"getblockchaininforesult-difficulty":           "The proof-of-work difficulty of the best block as a multiple of the minimum difficulty",
+	"getblockchaininforesult-mediantime":           "The median time of the past several blocks in seconds since 1 Jan 1970 GMT",
+	"getblockchaininforesult-verificationprogress": "An estimate of the fraction of the block chain which has been verified (not provided)",
+	"getblockchaininforesult-chainwork":            "The hex-encoded total work of the longest block chain",
+	"getblockchaininforesult-softforks":            "The status of each consensus rule change deployment as of the next block",
+
+	// SoftForkDescription help.
+	"softforkdescription-id":        "The name of the deployment",
+	"softforkdescription-status":    "The state of the deployment: 'defined', 'started', 'lockedin', 'active' or 'failed'",
+	"softforkdescription-bit":       "The bit of the block version which signals for the deployment",
+	"softforkdescription-starttime": "The median block time in seconds since 1 Jan 1970 GMT after which voting on the deployment starts",
+	"softforkdescription-timeout":   "The median block time in seconds since 1 Jan 1970 GMT after which the deployment fails if it has not been locked in",
+
 	// GetBlockCountCmd help.
 	"getblockcount--synopsis": "Returns the number of blocks in the longest block chain.",
 	"getblockcount--result0":  "The current block count",
@@ -220,12 +274,23 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-version":           "The block version",
 	"getblockheaderverboseresult-merkleroot":        "Root hash of the merkle tree",
 	"getblockheaderverboseresult-time":              "The block time in seconds since 1 Jan 1970 GMT",
-	"getblockheaderverboseresult-nonce":             "The block nonce",
+	"getblockheaderverboseresult-nonceheadera":      "The message header used as the first nonce of the block",
+	"getblockheaderverboseresult-nonceheaderb":      "The message header used as the second nonce of the block",
 	"getblockheaderverboseresult-bits":              "The bits which represent the block difficulty",
 	"getblockheaderverboseresult-difficulty":        "The proof-of-work difficulty as a multiple of the minimum difficulty",
 	"getblockheaderverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockheaderverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
+	// GetBlocksByNonceHeaderCmd help.
+	"getblocksbynonceheader--synopsis": "Returns the main chain blocks which use the message header with the provided I key as one of their nonces ordered by height (requires --noncehdrindex).",
+	"getblocksbynonceheader-ikey":      "The hex-encoded I key of the message header",
+
+	// NonceHeaderBlockResult help.
+	"nonceheaderblockresult-blockhash":   "The hash of the block",
+	"nonceheaderblockresult-blockheight": "The height of the block",
+	"nonceheaderblockresult-noncea":      "Whether or not the message header is the NonceHeaderA of the block",
+	"nonceheaderblockresult-nonceb":      "Whether or not the message header is the NonceHeaderB of the block",
+
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
 	"templaterequest-capabilities": "List of capabilities",
@@ -269,8 +334,16 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplateresult-mintime":           "Minimum allowed time",
 	"getblocktemplateresult-mutable":           "List of mutations the server explicitly allows",
 	"getblocktemplateresult-noncerange":        "Two concatenated hex-encoded big-endian 32-bit integers which represent the valid ranges of nonces the miner may scan",
-	"getblocktemplateresult-capabilities":      "List of server capabilities including 'proposal' to indicate support for block proposals",
+	"getblocktemplateresult-capabilities":      "List of server capabilities including 'proposal' to indicate support for block proposals and 'noncecandidates' to indicate the message headers which may be used as nonce headers are provided",
 	"getblocktemplateresult-reject-reason":     "Reason the proposal was invalid as-is (only applies to proposal responses)",
+	"getblocktemplateresult-noncecandidates":   "Hex-encoded message headers which may be used as the nonce headers of the block (two distinct headers must be chosen)",
+
+	// Rule change deployments of the GetBlockTemplateResult help.
+	"getblocktemplateresult-rules":              "The names of the rule change deployments which are active for the block",
+	"getblocktemplateresult-vbavailable":        "The rule change deployments the block version signals for",
+	"getblocktemplateresult-vbavailable--key":   "name",
+	"getblocktemplateresult-vbavailable--value": "bit",
+	"getblocktemplateresult-vbavailable--desc":  "The name of each deployment which is being voted on or is locked in and the bit of the block version which signals for it",
 
 	// GetBlockTemplateCmd help.
 	"getblocktemplate--synopsis": "Returns a JSON object with information necessary to construct a block to mine or accepts a proposal to validate.\n" +
@@ -293,6 +366,24 @@ var helpDescsEnUS = map[string]string{
 	"getdifficulty--synopsis": "Returns the proof-of-work difficulty as a multiple of the minimum difficulty.",
 	"getdifficulty--result0":  "The difficulty",
 
+	// DirectoryIndexEntryResult help.
+	"directoryindexentryresult-version":     "The version of the directory entry format",
+	"directoryindexentryresult-expire":      "The time the directory entry expires in seconds since 1 Jan 1970 GMT",
+	"directoryindexentryresult-pubkey":      "The hex-encoded compressed public key of the owner of the entry",
+	"directoryindexentryresult-name":        "The name the entry is posted under",
+	"directoryindexentryresult-value":       "The hex-encoded value bound to the name",
+	"directoryindexentryresult-signature":   "The hex-encoded signature of the owner over the entry",
+	"directoryindexentryresult-expired":     "Whether or not the entry has expired",
+	"directoryindexentryresult-txid":        "The hash of the transaction which posted the entry",
+	"directoryindexentryresult-vout":        "The index of the output which posted the entry",
+	"directoryindexentryresult-blockhash":   "The hash of the block which contains the posting",
+	"directoryindexentryresult-blockheight": "The height of the block which contains the posting",
+
+	// GetDirectoryEntryCmd help.
+	"getdirectoryentry--synopsis": "Returns the most recently posted directory entry with the provided name which has not expired (requires --dirindex).",
+	"getdirectoryentry-name":      "The name of the directory entry",
+	"getdirectoryentry-pubkey":    "Only consider entries owned by this hex-encoded compressed public key",
+
 	// GetGenerateCmd help.
 	"getgenerate--synopsis": "Returns if the server is set to generate coins (mine) or not.",
 	"getgenerate--result0":  "True if mining, false if not",
@@ -434,14 +525,13 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-includemempool": "Include the mempool when true",
 
 	// GetWorkResult help.
-	"getworkresult-data":     "Hex-encoded block data",
-	"getworkresult-hash1":    "(DEPRECATED) Hex-encoded formatted hash buffer",
-	"getworkresult-midstate": "(DEPRECATED) Hex-encoded precomputed hash state after hashing first half of the data",
-	"getworkresult-target":   "Hex-encoded little-endian hash target",
+	"getworkresult-data":       "Hex-encoded serialized block header without the nonce headers",
+	"getworkresult-candidates": "Hex-encoded message headers which may be used as the nonce headers (any two distinct candidates)",
+	"getworkresult-target":     "Hex-encoded big-endian hash target",
 
 	// GetWorkCmd help.
 	"getwork--synopsis":   "(DEPRECATED - Use getblocktemplate instead) Returns formatted hash data to work on or checks and submits solved data.",
-	"getwork-data":        "Hex-encoded data to check",
+	"getwork-data":        "Hex-encoded serialized block header to check which consists of the provided data followed by the two chosen nonce headers",
 	"getwork--condition0": "no data provided",
 	"getwork--condition1": "data provided",
 	"getwork--result1":    "Whether or not the solved data is valid and was added to the chain",
@@ -454,10 +544,25 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ListAccessKeysCmd help.
+	"listaccesskeys--synopsis": "Returns all registered network access keys which are valid at the provided time (requires --akindex).",
+	"listaccesskeys-validat":   "The time in seconds since 1 Jan 1970 GMT at which the returned access keys must still be valid (default: current time)",
+
+	// ListDirectoryEntriesCmd help.
+	"listdirectoryentries--synopsis":      "Returns all directory entries owned by the provided public key ordered by the height of the block which contains them (requires --dirindex).",
+	"listdirectoryentries-pubkey":         "The hex-encoded compressed public key of the owner",
+	"listdirectoryentries-includeexpired": "Also return entries which have expired",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// SearchDirectoryCmd help.
+	"searchdirectory--synopsis":      "Returns directory entries whose name begins with the provided prefix ordered by name (requires --dirindex).",
+	"searchdirectory-prefix":         "The prefix of the names to search for",
+	"searchdirectory-count":          "The maximum number of entries to return",
+	"searchdirectory-includeexpired": "Also return entries which have expired",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -499,7 +604,7 @@ var helpDescsEnUS = map[string]string{
 	"submitblock-options":     "This parameter is currently ignored",
 	"submitblock--condition0": "Block successfully submitted",
 	"submitblock--condition1": "Block rejected",
-	"submitblock--result1":    "The reason the block was rejected",
+	"submitblock--result1":    "The BIP0022 reason the block was rejected such as 'bad-nonce-header'",
 
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
@@ -581,46 +686,53 @@ var helpDescsEnUS = map[string]string{
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
-	"addnode":               nil,
-	"createrawtransaction":  {(*string)(nil)},
-	"debuglevel":            {(*string)(nil), (*string)(nil)},
-	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
-	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
-	"generate":              {(*[]string)(nil)},
-	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
-	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
-	"getbestblockhash":      {(*string)(nil)},
-	"getblock":              {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
-	"getblockcount":         {(*int64)(nil)},
-	"getblockhash":          {(*string)(nil)},
-	"getblockheader":        {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
-	"getblocktemplate":      {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
-	"getconnectioncount":    {(*int32)(nil)},
-	"getcurrentnet":         {(*uint32)(nil)},
-	"getdifficulty":         {(*float64)(nil)},
-	"getgenerate":           {(*bool)(nil)},
-	"gethashespersec":       {(*float64)(nil)},
-	"getinfo":               {(*btcjson.InfoChainResult)(nil)},
-	"getmempoolinfo":        {(*btcjson.GetMempoolInfoResult)(nil)},
-	"getmininginfo":         {(*btcjson.GetMiningInfoResult)(nil)},
-	"getnettotals":          {(*btcjson.GetNetTotalsResult)(nil)},
-	"getnetworkhashps":      {(*int64)(nil)},
-	"getpeerinfo":           {(*[]btcjson.GetPeerInfoResult)(nil)},
-	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
-	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
-	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
-	"getwork":               {(*btcjson.GetWorkResult)(nil), (*bool)(nil)},
-	"node":                  nil,
-	"help":                  {(*string)(nil), (*string)(nil)},
-	"ping":                  nil,
-	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
-	"sendrawtransaction":    {(*string)(nil)},
-	"setgenerate":           nil,
-	"stop":                  {(*string)(nil)},
-	"submitblock":           {nil, (*string)(nil)},
-	"validateaddress":       {(*btcjson.ValidateAddressChainResult)(nil)},
-	"verifychain":           {(*bool)(nil)},
-	"verifymessage":         {(*bool)(nil)},
+	"addnode":                nil,
+	"createrawtransaction":   {(*string)(nil)},
+	"debuglevel":             {(*string)(nil), (*string)(nil)},
+	"decoderawtransaction":   {(*btcjson.TxRawDecodeResult)(nil)},
+	"decodescript":           {(*btcjson.DecodeScriptResult)(nil)},
+	"generate":               {(*[]string)(nil)},
+	"getaccesskey":           {(*btcjson.AccessKeyResult)(nil)},
+	"getaddednodeinfo":       {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getbestblock":           {(*btcjson.GetBestBlockResult)(nil)},
+	"getbestblockhash":       {(*string)(nil)},
+	"getblock":               {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
+	"getblockchaininfo":      {(*btcjson.GetBlockChainInfoResult)(nil)},
+	"getblockcount":          {(*int64)(nil)},
+	"getblockhash":           {(*string)(nil)},
+	"getblockheader":         {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblocksbynonceheader": {(*[]btcjson.NonceHeaderBlockResult)(nil)},
+	"getblocktemplate":       {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
+	"getconnectioncount":     {(*int32)(nil)},
+	"getcurrentnet":          {(*uint32)(nil)},
+	"getdirectoryentry":      {(*btcjson.DirectoryIndexEntryResult)(nil)},
+	"getdifficulty":          {(*float64)(nil)},
+	"getgenerate":            {(*bool)(nil)},
+	"gethashespersec":        {(*float64)(nil)},
+	"getinfo":                {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolinfo":         {(*btcjson.GetMempoolInfoResult)(nil)},
+	"getmininginfo":          {(*btcjson.GetMiningInfoResult)(nil)},
+	"getnettotals":           {(*btcjson.GetNetTotalsResult)(nil)},
+	"getnetworkhashps":       {(*int64)(nil)},
+	"getpeerinfo":            {(*[]btcjson.GetPeerInfoResult)(nil)},
+	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
+	"getrawtransaction":      {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"gettxout":               {(*btcjson.GetTxOutResult)(nil)},
+	"getwork":                {(*btcjson.GetWorkResult)(nil), (*bool)(nil)},
+	"node":                   nil,
+	"help":                   {(*string)(nil), (*string)(nil)},
+	"listaccesskeys":         {(*[]btcjson.AccessKeyResult)(nil)},
+	"listdirectoryentries":   {(*[]btcjson.DirectoryIndexEntryResult)(nil)},
+	"searchdirectory":        {(*[]btcjson.DirectoryIndexEntryResult)(nil)},
+	"ping":                   nil,
+	"searchrawtransactions":  {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
+	"sendrawtransaction":     {(*string)(nil)},
+	"setgenerate":            nil,
+	"stop":                   {(*string)(nil)},
+	"submitblock":            {nil, (*string)(nil)},
+	"validateaddress":        {(*btcjson.ValidateAddressChainResult)(nil)},
+	"verifychain":            {(*bool)(nil)},
+	"verifymessage":          {(*bool)(nil)},
 
 	// Websocket commands.
 	"session":                   {(*btcjson.SessionResult)(nil)},
@@ -6,11 +6,11 @@ package rpctest
 
 import (
 	"errors"
-	"math"
 	"math/big"
 	"runtime"
 	"time"
 
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
 	"github.com/jadeblaquiere/cttd/blockchain"
 	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
@@ -19,57 +19,67 @@ import (
 	"github.com/jadeblaquiere/cttutil"
 )
 
-// solveBlock attempts to find a nonce which makes the passed block header hash
-// to a value less than the target difficulty. When a successful solution is
-// found true is returned and the nonce field of the passed header is updated
-// with the solution. False is returned if no solution exists.
-func solveBlock(header *wire.BlockHeader, targetDifficulty *big.Int) bool {
+// solveChunkSize is the number of nonce header pairs each solver goroutine
+// searches in between checks for an early quit.
+const solveChunkSize = 64
+
+// solveBlock attempts to find a pair of nonce headers from the passed
+// candidates which makes the passed block header hash to a value less than the
+// target difficulty. When a successful solution is found true is returned and
+// the nonce header fields of the passed header are updated with the solution.
+// False is returned if no solution exists.
+func solveBlock(header *wire.BlockHeader,
+	candidates []ciphrtxt.BinaryMessageHeaderV2, targetDifficulty *big.Int) bool {
+
 	// sbResult is used by the solver goroutines to send results.
 	type sbResult struct {
-		found bool
-		nonce uint32
+		found  bool
+		header wire.BlockHeader
+	}
+
+	numCandidates := uint64(len(candidates))
+	if numCandidates < 2 {
+		return false
 	}
+	numPairs := numCandidates * (numCandidates - 1)
 
-	// solver accepts a block header and a nonce range to test. It is
-	// intended to be run as a goroutine.
+	// solver accepts a block header and tests every numCores pairs of
+	// nonce headers starting with the passed pair, checking for an early
+	// quit after each chunk of solveChunkSize of them. It is intended to
+	// be run as a goroutine.
 	quit := make(chan bool)
 	results := make(chan sbResult)
-	solver := func(hdr wire.BlockHeader, startNonce, stopNonce uint32) {
-		// We need to modify the nonce field of the header, so make sure
-		// we work with a copy of the original header.
-		for i := startNonce; i >= startNonce && i <= stopNonce; i++ {
+	numCores := uint64(runtime.NumCPU())
+	chunk := numCores * solveChunkSize
+	solver := func(hdr wire.BlockHeader, startPair uint64) {
+		// We need to modify the nonce header fields of the header, so
+		// make sure we work with a copy of the original header.
+		for first := startPair; first < numPairs; first += chunk {
 			select {
 			case <-quit:
 				return
 			default:
-				hdr.Nonce = i
-				hash := hdr.BlockHash()
-				if blockchain.HashToBig(&hash).Cmp(targetDifficulty) <= 0 {
-					results <- sbResult{true, i}
+				solved, _ := blockchain.SolveNonceHeaders(&hdr,
+					candidates, first, first+chunk, numCores,
+					targetDifficulty)
+				if solved {
+					results <- sbResult{true, hdr}
 					return
 				}
 			}
 		}
-		results <- sbResult{false, 0}
+		results <- sbResult{false, hdr}
 	}
 
-	startNonce := uint32(0)
-	stopNonce := uint32(math.MaxUint32)
-	numCores := uint32(runtime.NumCPU())
-	noncesPerCore := (stopNonce - startNonce) / numCores
-	for i := uint32(0); i < numCores; i++ {
-		rangeStart := startNonce + (noncesPerCore * i)
-		rangeStop := startNonce + (noncesPerCore * (i + 1)) - 1
-		if i == numCores-1 {
-			rangeStop = stopNonce
-		}
-		go solver(*header, rangeStart, rangeStop)
+	for i := uint64(0); i < numCores; i++ {
+		go solver(*header, i)
 	}
-	for i := uint32(0); i < numCores; i++ {
+	for i := uint64(0); i < numCores; i++ {
 		result := <-results
 		if result.found {
 			close(quit)
-			header.Nonce = result.nonce
+			header.NonceHeaderA = result.header.NonceHeaderA
+			header.NonceHeaderB = result.header.NonceHeaderB
 			return true
 		}
 	}
@@ -112,59 +122,74 @@ func createCoinbaseTx(coinbaseScript []byte, nextBlockHeight int32,
 	return cttutil.NewTx(tx), nil
 }
 
-// createBlock creates a new block building from the previous block.
+// nextBlockTime returns the timestamp of a block building from the passed
+// previous block.  The passed target block time is used when it is specified.
+// Otherwise, one second is added to the timestamp of the previous block.
+func nextBlockTime(prevBlock *cttutil.Block, blockTime time.Time) time.Time {
+	if !blockTime.IsZero() {
+		return blockTime
+	}
+	return prevBlock.MsgBlock().Header.Timestamp.Add(time.Second)
+}
+
+// createBlock creates a new block building from the previous block with the
+// passed difficulty bits whose nonce headers are chosen from the passed
+// candidates.
 func createBlock(prevBlock *cttutil.Block, inclusionTxs []*cttutil.Tx,
-	blockVersion int32, blockTime time.Time,
+	blockVersion int32, blockTime time.Time, bits uint32,
+	candidates []ciphrtxt.BinaryMessageHeaderV2,
 	miningAddr cttutil.Address, net *chaincfg.Params) (*cttutil.Block, error) {
 
+	// At least two distinct message headers are required to solve a block.
+	if len(candidates) < 2 {
+		return nil, errors.New("not enough message headers to use as " +
+			"nonce headers")
+	}
+
 	prevHash := prevBlock.Hash()
 	blockHeight := prevBlock.Height() + 1
 
-	// If a target block time was specified, then use that as the header's
-	// timestamp. Otherwise, add one second to the previous block unless
-	// it's the genesis block in which case use the current time.
-	var ts time.Time
-	switch {
-	case !blockTime.IsZero():
-		ts = blockTime
-	default:
-		ts = prevBlock.MsgBlock().Header.Timestamp.Add(time.Second)
-	}
+	ts := nextBlockTime(prevBlock, blockTime)
 
-	extraNonce := uint64(0)
-	coinbaseScript, err := standardCoinbaseScript(blockHeight, extraNonce)
-	if err != nil {
-		return nil, err
-	}
-	coinbaseTx, err := createCoinbaseTx(coinbaseScript, blockHeight,
-		miningAddr, net)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create a new block ready to be solved.
-	blockTxns := []*cttutil.Tx{coinbaseTx}
-	if inclusionTxs != nil {
-		blockTxns = append(blockTxns, inclusionTxs...)
-	}
-	merkles := blockchain.BuildMerkleTreeStore(blockTxns)
+	// Create a new block ready to be solved. The extra nonce of the
+	// coinbase is incremented, which changes the merkle root, whenever no
+	// pair of the candidate nonce headers solves the block.
+	target := blockchain.CompactToBig(bits)
 	var block wire.MsgBlock
-	block.Header = wire.BlockHeader{
-		Version:    blockVersion,
-		PrevBlock:  *prevHash,
-		MerkleRoot: *merkles[len(merkles)-1],
-		Timestamp:  ts,
-		Bits:       net.PowLimitBits,
-	}
-	for _, tx := range blockTxns {
-		if err := block.AddTransaction(tx.MsgTx()); err != nil {
+	for extraNonce := uint64(0); ; extraNonce++ {
+		coinbaseScript, err := standardCoinbaseScript(blockHeight,
+			extraNonce)
+		if err != nil {
+			return nil, err
+		}
+		coinbaseTx, err := createCoinbaseTx(coinbaseScript, blockHeight,
+			miningAddr, net)
+		if err != nil {
 			return nil, err
 		}
-	}
 
-	found := solveBlock(&block.Header, net.PowLimit)
-	if !found {
-		return nil, errors.New("Unable to solve block")
+		blockTxns := []*cttutil.Tx{coinbaseTx}
+		if inclusionTxs != nil {
+			blockTxns = append(blockTxns, inclusionTxs...)
+		}
+		merkles := blockchain.BuildMerkleTreeStore(blockTxns)
+		block = wire.MsgBlock{}
+		block.Header = wire.BlockHeader{
+			Version:    blockVersion,
+			PrevBlock:  *prevHash,
+			MerkleRoot: *merkles[len(merkles)-1],
+			Timestamp:  ts,
+			Bits:       bits,
+		}
+		for _, tx := range blockTxns {
+			if err := block.AddTransaction(tx.MsgTx()); err != nil {
+				return nil, err
+			}
+		}
+
+		if solveBlock(&block.Header, candidates, target) {
+			break
+		}
 	}
 
 	utilBlock := cttutil.NewBlock(&block)
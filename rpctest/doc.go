@@ -5,6 +5,11 @@
 // creating new addresses, and crafting fully signed transactions paying to an
 // arbitrary set of outputs.
 //
+// Blocks on ciphrtxt networks use message headers as their nonces, so every
+// harness launches its node against an in-process fake message store, which is
+// shared by all harnesses so their nodes accept each other's blocks.  Blocks
+// created by the harness itself choose their nonce headers from the same store.
+//
 // This package was designed specifically to act as an RPC testing harness for
 // `cttd`. However, the constructs presented are general enough to be adapted to
 // any project wishing to programmatically drive a `cttd` instance of its
@@ -103,7 +103,7 @@ type memWallet struct {
 
 	net *chaincfg.Params
 
-	rpc *ctcrpcclient.Client
+	rpc *cttrpcclient.Client
 
 	sync.RWMutex
 }
@@ -172,7 +172,7 @@ func (m *memWallet) SyncedHeight() int32 {
 
 // SetRPCClient saves the passed rpc connection to cttd as the wallet's
 // personal rpc connection.
-func (m *memWallet) SetRPCClient(rpcClient *ctcrpcclient.Client) {
+func (m *memWallet) SetRPCClient(rpcClient *cttrpcclient.Client) {
 	m.rpc = rpcClient
 }
 
@@ -35,6 +35,7 @@ type nodeConfig struct {
 	debugLevel string
 	extra      []string
 	prefix     string
+	net        wire.BitcoinNet
 
 	exe          string
 	endpoint     string
@@ -43,8 +44,11 @@ type nodeConfig struct {
 	certificates []byte
 }
 
-// newConfig returns a newConfig with all default values.
-func newConfig(prefix, certFile, keyFile string, extra []string) (*nodeConfig, error) {
+// newConfig returns a newConfig with all default values for a node which runs
+// on the passed network.
+func newConfig(prefix, certFile, keyFile string, net wire.BitcoinNet,
+	extra []string) (*nodeConfig, error) {
+
 	a := &nodeConfig{
 		listen:    "127.0.0.1:7764",
 		rpcListen: "127.0.0.1:7765",
@@ -52,6 +56,7 @@ func newConfig(prefix, certFile, keyFile string, extra []string) (*nodeConfig, e
 		rpcPass:   "pass",
 		extra:     extra,
 		prefix:    prefix,
+		net:       net,
 
 		exe:      "cttd",
 		endpoint: "ws",
@@ -90,8 +95,11 @@ func (n *nodeConfig) setDefaults() error {
 // process.
 func (n *nodeConfig) arguments() []string {
 	args := []string{}
-	// --simnet
-	args = append(args, fmt.Sprintf("--%s", strings.ToLower(wire.SimNet.String())))
+	// --ctrednet or --ctsimnet, unless the node runs on the default network.
+	if n.net != wire.CTIndigoNet {
+		args = append(args, fmt.Sprintf("--%s",
+			strings.ToLower(n.net.String())))
+	}
 	if n.rpcUser != "" {
 		// --rpcuser
 		args = append(args, fmt.Sprintf("--rpcuser=%s", n.rpcUser))
@@ -15,13 +15,23 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jadeblaquiere/cttd/blockchain"
 	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/fakemsgstore"
 	"github.com/jadeblaquiere/cttd/wire"
 	"github.com/jadeblaquiere/cttrpcclient"
 	"github.com/jadeblaquiere/cttutil"
 )
 
+const (
+	// numMsgStoreHeaders is the number of message headers the fake message
+	// store shared by the test harnesses is started with.  Every block
+	// uses two of them as nonce headers which may not be reused by the
+	// blocks of the same chain, so there must be plenty of them.
+	numMsgStoreHeaders = 1024
+)
+
 var (
 	// current number of active test nodes.
 	numTestInstances = 0
@@ -46,6 +56,12 @@ var (
 	// etc.
 	testInstances = make(map[string]*Harness)
 
+	// msgStore is the in-process stand-in for the ciphrtxt message store
+	// which all test harnesses launch their nodes against, so every node
+	// knows the message headers used as the nonce headers of the blocks
+	// of the others.  It is started along with the first test harness.
+	msgStore *fakemsgstore.Server
+
 	// Used to protest concurrent access to above declared variables.
 	harnessStateMtx sync.RWMutex
 )
@@ -56,8 +72,10 @@ type HarnessTestCase func(r *Harness, t *testing.T)
 
 // Harness fully encapsulates an active cttd process to provide a unified
 // platform for creating rpc driven integration tests involving cttd. The
-// active cttd node will typically be run in simnet mode in order to allow for
-// easy generation of test blockchains.  The active cttd process is fully
+// active cttd node will typically be run on the ciphrtxt simulation test
+// network in order to allow for easy generation of test blockchains, and it
+// mirrors the message headers of a local fake message store which are used as
+// the nonce headers of its blocks.  The active cttd process is fully
 // managed by Harness, which handles the necessary initialization, and teardown
 // of the process along with any temporary directories created as a result.
 // Multiple Harness instances may be run concurrently, in order to allow for
@@ -68,9 +86,9 @@ type Harness struct {
 	// to.
 	ActiveNet *chaincfg.Params
 
-	Node     *ctcrpcclient.Client
+	Node     *cttrpcclient.Client
 	node     *node
-	handlers *ctcrpcclient.NotificationHandlers
+	handlers *cttrpcclient.NotificationHandlers
 
 	wallet *memWallet
 
@@ -87,7 +105,7 @@ type Harness struct {
 // used.
 //
 // NOTE: This function is safe for concurrent access.
-func New(activeNet *chaincfg.Params, handlers *ctcrpcclient.NotificationHandlers,
+func New(activeNet *chaincfg.Params, handlers *cttrpcclient.NotificationHandlers,
 	extraArgs []string) (*Harness, error) {
 
 	harnessStateMtx.Lock()
@@ -110,10 +128,27 @@ func New(activeNet *chaincfg.Params, handlers *ctcrpcclient.NotificationHandlers
 		return nil, err
 	}
 
+	// Point the header cache of the node at the shared fake message store,
+	// starting it first if this is the first test harness.
+	if msgStore == nil {
+		if err := startMsgStore(); err != nil {
+			return nil, err
+		}
+	}
+	msgStoreHost, msgStorePort, err := net.SplitHostPort(
+		msgStore.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+
 	miningAddr := fmt.Sprintf("--miningaddr=%s", wallet.coinbaseAddr)
-	extraArgs = append(extraArgs, miningAddr)
+	headerCacheHost := fmt.Sprintf("--headercachehost=%s", msgStoreHost)
+	headerCachePort := fmt.Sprintf("--headercacheport=%s", msgStorePort)
+	extraArgs = append(extraArgs, miningAddr, headerCacheHost,
+		headerCachePort)
 
-	config, err := newConfig("rpctest", certFile, keyFile, extraArgs)
+	config, err := newConfig("rpctest", certFile, keyFile, activeNet.Net,
+		extraArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +156,7 @@ func New(activeNet *chaincfg.Params, handlers *ctcrpcclient.NotificationHandlers
 	// Generate p2p+rpc listening addresses.
 	config.listen, config.rpcListen = generateListeningAddresses()
 
-	// Create the testing node bounded to the simnet.
+	// Create the testing node bounded to the active network.
 	node, err := newNode(config, nodeTestData)
 	if err != nil {
 		return nil, err
@@ -131,7 +166,7 @@ func New(activeNet *chaincfg.Params, handlers *ctcrpcclient.NotificationHandlers
 	numTestInstances++
 
 	if handlers == nil {
-		handlers = &ctcrpcclient.NotificationHandlers{}
+		handlers = &cttrpcclient.NotificationHandlers{}
 	}
 
 	// If a handler for the OnBlockConnected/OnBlockDisconnected callback
@@ -176,7 +211,7 @@ func New(activeNet *chaincfg.Params, handlers *ctcrpcclient.NotificationHandlers
 }
 
 // SetUp initializes the rpc test state. Initialization includes: starting up a
-// simnet node, creating a websockets client and connecting to the started
+// node which uses the shared fake message store as its header cache, creating a websockets client and connecting to the started
 // node, and finally: optionally generating and submitting a testchain with a
 // configurable number of mature coinbase outputs coinbase outputs.
 //
@@ -262,12 +297,12 @@ func (h *Harness) TearDown() error {
 // we're not able to establish a connection, this function returns with an
 // error.
 func (h *Harness) connectRPCClient() error {
-	var client *ctcrpcclient.Client
+	var client *cttrpcclient.Client
 	var err error
 
 	rpcConf := h.node.config.rpcConnConfig()
 	for i := 0; i < h.maxConnRetries; i++ {
-		if client, err = ctcrpcclient.New(&rpcConf, h.handlers); err != nil {
+		if client, err = cttrpcclient.New(&rpcConf, h.handlers); err != nil {
 			time.Sleep(time.Duration(i) * 50 * time.Millisecond)
 			continue
 		}
@@ -338,12 +373,12 @@ func (h *Harness) UnlockOutputs(inputs []*wire.TxIn) {
 // RPCConfig returns the harnesses current rpc configuration. This allows other
 // potential RPC clients created within tests to connect to a given test
 // harness instance.
-func (h *Harness) RPCConfig() ctcrpcclient.ConnConfig {
+func (h *Harness) RPCConfig() cttrpcclient.ConnConfig {
 	return h.node.config.rpcConnConfig()
 }
 
 // GenerateAndSubmitBlock creates a block whose contents include the passed
-// transactions and submits it to the running simnet node. For generating
+// transactions and submits it to the running node. For generating
 // blocks with only a coinbase tx, callers can simply pass nil instead of
 // transactions to be mined. Additionally, a custom block version can be set by
 // the caller. A blockVersion of -1 indicates that the current default block
@@ -371,14 +406,29 @@ func (h *Harness) GenerateAndSubmitBlock(txns []*cttutil.Tx, blockVersion int32,
 	}
 	prevBlock.SetHeight(prevBlockHeight)
 
+	// Choose the nonce headers of the new block from the message headers of
+	// the shared fake message store which are not used by the recent blocks
+	// of the chain and satisfy the nonce header lifetime limits as of the
+	// timestamp of the new block.
+	blockTime = nextBlockTime(prevBlock, blockTime)
+	used, bits, err := h.nextBlockState(prevBlock, blockTime)
+	if err != nil {
+		return nil, err
+	}
+	candidates, _, err := blockchain.NonceHeaderCandidates(msgStore, used,
+		blockTime, h.ActiveNet)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a new block including the specified transactions
 	newBlock, err := createBlock(prevBlock, txns, blockVersion,
-		blockTime, h.wallet.coinbaseAddr, h.ActiveNet)
+		blockTime, bits, candidates, h.wallet.coinbaseAddr, h.ActiveNet)
 	if err != nil {
 		return nil, err
 	}
 
-	// Submit the block to the simnet node.
+	// Submit the block to the node.
 	if err := h.Node.SubmitBlock(newBlock, nil); err != nil {
 		return nil, err
 	}
@@ -386,6 +436,82 @@ func (h *Harness) GenerateAndSubmitBlock(txns []*cttutil.Tx, blockVersion int32,
 	return newBlock, nil
 }
 
+// nextBlockState walks back through the chain which ends with the passed block
+// and returns the I keys of the message headers used as nonce headers by the
+// blocks within the nonce header reuse window along with the difficulty bits
+// required of a block with the passed timestamp which extends it.  The bits are
+// calculated by the blockchain package from the headers of the chain, so the
+// harness always applies the same difficulty rules as the node.
+//
+// This function is safe for concurrent access.
+func (h *Harness) nextBlockState(prevBlock *cttutil.Block,
+	blockTime time.Time) (map[string]struct{}, uint32, error) {
+
+	params := h.ActiveNet
+	nextHeight := prevBlock.Height() + 1
+	numHeaders := blockchain.DifficultyHeadersNeeded(params)
+	if nextHeight < numHeaders {
+		numHeaders = nextHeight
+	}
+	stopHeight := nextHeight - params.NonceHeaderReuseWindow
+	if nextHeight-numHeaders < stopHeight {
+		stopHeight = nextHeight - numHeaders
+	}
+
+	// Gather the headers needed to calculate the difficulty from newest
+	// to oldest along with the nonce header I keys of the blocks within
+	// the reuse window.
+	used := make(map[string]struct{})
+	headers := make([]wire.BlockHeader, numHeaders)
+	block := prevBlock
+	for height := prevBlock.Height(); ; height-- {
+		header := &block.MsgBlock().Header
+		if nextHeight-height <= params.NonceHeaderReuseWindow {
+			// The nonce headers of the genesis block do not parse.
+			ikeyA, ikeyB, err := blockchain.NonceHeaderIKeys(header)
+			if err == nil {
+				used[string(ikeyA)] = struct{}{}
+				used[string(ikeyB)] = struct{}{}
+			}
+		}
+		if i := nextHeight - height; i <= numHeaders {
+			headers[numHeaders-i] = *header
+		}
+		if height <= stopHeight || height == 0 {
+			break
+		}
+
+		var err error
+		block, err = h.Node.GetBlock(&header.PrevBlock)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	bits, err := blockchain.CalcNextRequiredDifficultyFromHeaders(headers,
+		prevBlock.Height(), blockTime, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return used, bits, nil
+}
+
+// startMsgStore starts the fake message store shared by all test harnesses
+// with numMsgStoreHeaders message headers.
+//
+// This function MUST be called with the harness state lock held (for writes).
+func startMsgStore() error {
+	store := fakemsgstore.New(&fakemsgstore.Config{})
+	if _, err := store.GenerateHeaders(numMsgStoreHeaders); err != nil {
+		return err
+	}
+	if err := store.Start("127.0.0.1:0"); err != nil {
+		return err
+	}
+	msgStore = store
+	return nil
+}
+
 // generateListeningAddresses returns two strings representing listening
 // addresses designated for the current rpc test. If there haven't been any
 // test instances created, the default ports are used. Otherwise, in order to
@@ -4,6 +4,7 @@
 package rpctest
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net"
 	"os"
@@ -11,13 +12,32 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/jadeblaquiere/cttd/btcjson"
 	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
 	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
 	"github.com/jadeblaquiere/cttd/wire"
 	"github.com/jadeblaquiere/cttutil"
 )
 
+// recordFeeRate is the fee rate in satoshi per byte used for transactions with
+// record outputs.  It is high enough for the fee to also cover the premium the
+// default policy requires for the record outputs.
+const recordFeeRate = 1000
+
+// matureSubsidy returns the total subsidy of the coinbases of the first
+// numBlocks blocks after the genesis block of the passed network.
+func matureSubsidy(params *chaincfg.Params, numBlocks uint32) cttutil.Amount {
+	var total int64
+	for height := int32(1); height <= int32(numBlocks); height++ {
+		total += blockchain.CalcBlockSubsidy(height, params)
+	}
+	return cttutil.Amount(total)
+}
+
 func testSendOutputs(r *Harness, t *testing.T) {
 	genSpend := func(amt cttutil.Amount) *chainhash.Hash {
 		// Grab a fresh address from the wallet.
@@ -73,7 +93,7 @@ func testSendOutputs(r *Harness, t *testing.T) {
 
 	// Next, generate a spend much greater than the block reward. This
 	// transaction should also have been mined properly.
-	txid = genSpend(cttutil.Amount(500 * cttutil.SatoshiPerBitcoin))
+	txid = genSpend(cttutil.Amount(2000 * cttutil.SatoshiPerBitcoin))
 	blockHashes, err = r.Node.Generate(1)
 	if err != nil {
 		t.Fatalf("unable to generate single block: %v", err)
@@ -105,7 +125,7 @@ func assertConnectedTo(t *testing.T, nodeA *Harness, nodeB *Harness) {
 
 func testConnectNode(r *Harness, t *testing.T) {
 	// Create a fresh test harness.
-	harness, err := New(&chaincfg.SimNetParams, nil, nil)
+	harness, err := New(&chaincfg.CTSimNetParams, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -153,7 +173,7 @@ func testActiveHarnesses(r *Harness, t *testing.T) {
 	numInitialHarnesses := len(ActiveHarnesses())
 
 	// Create a single test harness.
-	harness1, err := New(&chaincfg.SimNetParams, nil, nil)
+	harness1, err := New(&chaincfg.CTSimNetParams, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -181,7 +201,7 @@ func testJoinMempools(r *Harness, t *testing.T) {
 	// Create a local test harness with only the genesis block.  The nodes
 	// will be synced below so the same transaction can be sent to both
 	// nodes without it being an orphan.
-	harness, err := New(&chaincfg.SimNetParams, nil, nil)
+	harness, err := New(&chaincfg.CTSimNetParams, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -281,7 +301,7 @@ func testJoinMempools(r *Harness, t *testing.T) {
 func testJoinBlocks(r *Harness, t *testing.T) {
 	// Create a second harness with only the genesis block so it is behind
 	// the main harness.
-	harness, err := New(&chaincfg.SimNetParams, nil, nil)
+	harness, err := New(&chaincfg.CTSimNetParams, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -394,7 +414,7 @@ func testGenerateAndSubmitBlock(r *Harness, t *testing.T) {
 func testMemWalletReorg(r *Harness, t *testing.T) {
 	// Create a fresh harness, we'll be using the main harness to force a
 	// re-org on this local harness.
-	harness, err := New(&chaincfg.SimNetParams, nil, nil)
+	harness, err := New(&chaincfg.CTSimNetParams, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -403,8 +423,9 @@ func testMemWalletReorg(r *Harness, t *testing.T) {
 	}
 	defer harness.TearDown()
 
-	// The internal wallet of this harness should now have 250 BTC.
-	expectedBalance := cttutil.Amount(250 * cttutil.SatoshiPerBitcoin)
+	// The internal wallet of this harness should now have the subsidy of
+	// the first 5 blocks.
+	expectedBalance := matureSubsidy(harness.ActiveNet, 5)
 	walletBalance := harness.ConfirmedBalance()
 	if expectedBalance != walletBalance {
 		t.Fatalf("wallet balance incorrect: expected %v, got %v",
@@ -471,6 +492,140 @@ func testMemWalletLockedOutputs(r *Harness, t *testing.T) {
 	}
 }
 
+func testGenerateNonceHeaders(r *Harness, t *testing.T) {
+	// Mine a few blocks with the CPU miner of the node.
+	const numBlocks = 5
+	blockHashes, err := r.Node.Generate(numBlocks)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	if len(blockHashes) != numBlocks {
+		t.Fatalf("generated %v blocks, expected %v", len(blockHashes),
+			numBlocks)
+	}
+
+	// Each block must use two message headers of the shared message store
+	// as its nonce headers which are not used by any of the other blocks.
+	used := make(map[string]*chainhash.Hash)
+	for _, blockHash := range blockHashes {
+		block, err := r.Node.GetBlock(blockHash)
+		if err != nil {
+			t.Fatalf("unable to get block: %v", err)
+		}
+		ikeyA, ikeyB, err := blockchain.NonceHeaderIKeys(
+			&block.MsgBlock().Header)
+		if err != nil {
+			t.Fatalf("invalid nonce headers: %v", err)
+		}
+		for _, ikey := range [][]byte{ikeyA, ikeyB} {
+			if _, err := msgStore.FindByI(ikey); err != nil {
+				t.Fatalf("block %v uses nonce header %x which "+
+					"is not in the message store", blockHash,
+					ikey)
+			}
+			if otherHash, ok := used[string(ikey)]; ok {
+				t.Fatalf("block %v reuses nonce header %x of "+
+					"block %v", blockHash, ikey, otherHash)
+			}
+			used[string(ikey)] = blockHash
+		}
+	}
+}
+
+// mineRecordOutput ensures a transaction with the passed record output is
+// rejected when it does not pay the premium for the record, then sends it with
+// the premium, mines it and returns the verbose form of the mined transaction.
+func mineRecordOutput(r *Harness, t *testing.T, pkScript []byte) *btcjson.TxRawResult {
+	output := wire.NewTxOut(0, pkScript)
+
+	// A standard fee does not cover the premium of the record output.
+	tx, err := r.CreateTransaction([]*wire.TxOut{output}, 10)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	if _, err := r.Node.SendRawTransaction(tx, true); err == nil {
+		t.Fatalf("transaction without record premium was accepted")
+	}
+	r.UnlockOutputs(tx.TxIn)
+
+	txid, err := r.SendOutputs([]*wire.TxOut{output}, recordFeeRate)
+	if err != nil {
+		t.Fatalf("unable to send record transaction: %v", err)
+	}
+	blockHashes, err := r.Node.Generate(1)
+	if err != nil {
+		t.Fatalf("unable to generate single block: %v", err)
+	}
+
+	txResult, err := r.Node.GetRawTransactionVerbose(txid)
+	if err != nil {
+		t.Fatalf("unable to get transaction: %v", err)
+	}
+	if txResult.BlockHash != blockHashes[0].String() {
+		t.Fatalf("record transaction %v mined in block %q, expected "+
+			"%v", txid, txResult.BlockHash, blockHashes[0])
+	}
+	if len(txResult.Vout) == 0 {
+		t.Fatalf("record transaction %v has no outputs", txid)
+	}
+	return txResult
+}
+
+func testAccessKeyTransaction(r *Harness, t *testing.T) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to create private key: %v", err)
+	}
+	expire := uint32(time.Now().Add(time.Hour * 24).Unix())
+	pkScript, err := txscript.RegisterAccessKeyScript(expire,
+		privKey.PubKey(), privKey)
+	if err != nil {
+		t.Fatalf("unable to create access key script: %v", err)
+	}
+
+	txResult := mineRecordOutput(r, t, pkScript)
+	scriptPubKey := txResult.Vout[0].ScriptPubKey
+	if scriptPubKey.Type != txscript.AccessKeyTy.String() {
+		t.Fatalf("access key output has type %q, expected %q",
+			scriptPubKey.Type, txscript.AccessKeyTy)
+	}
+}
+
+func testDirectoryTransaction(r *Harness, t *testing.T) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to create private key: %v", err)
+	}
+	const name = "rpctest"
+	value := []byte{0x01, 0x02, 0x03}
+	expire := uint32(time.Now().Add(time.Hour * 24).Unix())
+	entry, err := dirent.Sign(expire, name, value, privKey)
+	if err != nil {
+		t.Fatalf("unable to sign directory entry: %v", err)
+	}
+	pkScript, err := txscript.PostDirectoryScript(entry)
+	if err != nil {
+		t.Fatalf("unable to create directory script: %v", err)
+	}
+
+	txResult := mineRecordOutput(r, t, pkScript)
+	scriptPubKey := txResult.Vout[0].ScriptPubKey
+	if scriptPubKey.Type != txscript.DirectoryTy.String() {
+		t.Fatalf("directory output has type %q, expected %q",
+			scriptPubKey.Type, txscript.DirectoryTy)
+	}
+	dirEntry := scriptPubKey.DirectoryEntry
+	if dirEntry == nil {
+		t.Fatalf("directory output has no decoded directory entry")
+	}
+	if dirEntry.Name != name || dirEntry.Value != hex.EncodeToString(value) ||
+		!dirEntry.Valid {
+
+		t.Fatalf("mined directory entry %+v does not match posted "+
+			"entry %q: %x", dirEntry, name, value)
+	}
+}
+
 var harnessTestCases = []HarnessTestCase{
 	testSendOutputs,
 	testConnectNode,
@@ -480,6 +635,9 @@ var harnessTestCases = []HarnessTestCase{
 	testGenerateAndSubmitBlock,
 	testMemWalletReorg,
 	testMemWalletLockedOutputs,
+	testGenerateNonceHeaders,
+	testAccessKeyTransaction,
+	testDirectoryTransaction,
 }
 
 var mainHarness *Harness
@@ -490,7 +648,7 @@ const (
 
 func TestMain(m *testing.M) {
 	var err error
-	mainHarness, err = New(&chaincfg.SimNetParams, nil, nil)
+	mainHarness, err = New(&chaincfg.CTSimNetParams, nil, nil)
 	if err != nil {
 		fmt.Println("unable to create main harness: ", err)
 		os.Exit(1)
@@ -518,9 +676,9 @@ func TestMain(m *testing.M) {
 }
 
 func TestHarness(t *testing.T) {
-	// We should have (numMatureOutputs * 50 BTC) of mature unspendable
-	// outputs.
-	expectedBalance := cttutil.Amount(numMatureOutputs * 50 * cttutil.SatoshiPerBitcoin)
+	// We should have the subsidy of the first numMatureOutputs blocks in
+	// mature spendable outputs.
+	expectedBalance := matureSubsidy(mainHarness.ActiveNet, numMatureOutputs)
 	harnessBalance := mainHarness.ConfirmedBalance()
 	if harnessBalance != expectedBalance {
 		t.Fatalf("expected wallet balance of %v instead have %v",
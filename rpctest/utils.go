@@ -122,7 +122,7 @@ func ConnectNode(from *Harness, to *Harness) error {
 	}
 	numPeers := len(peerInfo)
 
-	if err := from.Node.AddNode(targetAddr, ctcrpcclient.ANAdd); err != nil {
+	if err := from.Node.AddNode(targetAddr, cttrpcclient.ANAdd); err != nil {
 		return err
 	}
 
@@ -152,6 +152,13 @@ func TearDownAll() error {
 		}
 	}
 
+	// Stop the fake message store shared by the torn down harnesses.
+	if msgStore != nil {
+		err := msgStore.Stop()
+		msgStore = nil
+		return err
+	}
+
 	return nil
 }
 
@@ -184,12 +184,16 @@ type server struct {
 	listeners            []net.Listener
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
-    headerCache          *ciphrtxt.HeaderCache
+	headerCache          blockchain.MessageHeaderSource
+	headerNotifier       *blockchain.NotifyHeaderSource
+	simHeaders           *blockchain.MemHeaderSource
 	sigCache             *txscript.SigCache
 	rpcServer            *rpcServer
 	blockManager         *blockManager
 	txMemPool            *mempool.TxPool
 	cpuMiner             *CPUMiner
+	stratumServer        *stratumServer
+	submitBlockLock      sync.Mutex
 	modifyRebroadcastInv chan interface{}
 	pendingPeers         chan *serverPeer
 	newPeers             chan *serverPeer
@@ -212,8 +216,11 @@ type server struct {
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
+	txIndex       *indexers.TxIndex
+	addrIndex     *indexers.AddrIndex
+	akIndex       *indexers.AccessKeyIndex
+	dirIndex      *indexers.DirectoryIndex
+	nonceHdrIndex *indexers.NonceHeaderIndex
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
@@ -221,18 +228,19 @@ type server struct {
 type serverPeer struct {
 	*peer.Peer
 
-	server          *server
-	persistent      bool
-	continueHash    *chainhash.Hash
-	relayMtx        sync.Mutex
-	disableRelayTx  bool
-	requestQueue    []*wire.InvVect
-	requestedTxns   map[chainhash.Hash]struct{}
-	requestedBlocks map[chainhash.Hash]struct{}
-	filter          *bloom.Filter
-	knownAddresses  map[string]struct{}
-	banScore        dynamicBanScore
-	quit            chan struct{}
+	server           *server
+	persistent       bool
+	continueHash     *chainhash.Hash
+	relayMtx         sync.Mutex
+	disableRelayTx   bool
+	requestQueue     []*wire.InvVect
+	requestedTxns    map[chainhash.Hash]struct{}
+	requestedBlocks  map[chainhash.Hash]struct{}
+	requestedMsgHdrs map[string]struct{}
+	filter           *bloom.Filter
+	knownAddresses   map[string]struct{}
+	banScore         dynamicBanScore
+	quit             chan struct{}
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
 	blockProcessed chan struct{}
@@ -242,15 +250,16 @@ type serverPeer struct {
 // the caller.
 func newServerPeer(s *server, isPersistent bool) *serverPeer {
 	return &serverPeer{
-		server:          s,
-		persistent:      isPersistent,
-		requestedTxns:   make(map[chainhash.Hash]struct{}),
-		requestedBlocks: make(map[chainhash.Hash]struct{}),
-		filter:          bloom.LoadFilter(nil),
-		knownAddresses:  make(map[string]struct{}),
-		quit:            make(chan struct{}),
-		txProcessed:     make(chan struct{}, 1),
-		blockProcessed:  make(chan struct{}, 1),
+		server:           s,
+		persistent:       isPersistent,
+		requestedTxns:    make(map[chainhash.Hash]struct{}),
+		requestedBlocks:  make(map[chainhash.Hash]struct{}),
+		requestedMsgHdrs: make(map[string]struct{}),
+		filter:           bloom.LoadFilter(nil),
+		knownAddresses:   make(map[string]struct{}),
+		quit:             make(chan struct{}),
+		txProcessed:      make(chan struct{}, 1),
+		blockProcessed:   make(chan struct{}, 1),
 	}
 }
 
@@ -619,6 +628,46 @@ func (sp *serverPeer) OnGetData(p *peer.Peer, msg *wire.MsgGetData) {
 	}
 }
 
+// OnGetMsgHdr is invoked when a peer receives a getmsghdr bitcoin message and
+// is used to deliver the requested ciphrtxt message headers from the message
+// header source.  Headers which are not known are reported in a notfound
+// message using the message header inventory type.
+func (sp *serverPeer) OnGetMsgHdr(p *peer.Peer, msg *wire.MsgGetMsgHdr) {
+	// A decaying ban score increase is applied to prevent exhausting
+	// resources with repeated message header queries.  Requesting the
+	// maximum number of headers per message is scored the same as a
+	// maximum size getdata request.
+	sp.addBanScore(0, uint32(len(msg.IKeys))*99/wire.MaxGetMsgHdrPerMsg,
+		"getmsghdr")
+
+	headerCache := sp.server.headerCache
+	notFound := wire.NewMsgNotFound()
+	for i := range msg.IKeys {
+		ikey := msg.IKeys[i][:]
+		if headerCache != nil {
+			h, err := headerCache.FindByI(ikey)
+			if err == nil && h != nil {
+				if b := h.ExportBinaryHeaderV2(); b != nil {
+					p.QueueMessage(wire.NewMsgMsgHdr(b), nil)
+					continue
+				}
+			}
+		}
+
+		hash := wire.MsgHeaderInvHash(ikey)
+		notFound.AddInvVect(wire.NewInvVect(wire.InvTypeMsgHeader, &hash))
+	}
+	if len(notFound.InvList) != 0 {
+		p.QueueMessage(notFound, nil)
+	}
+}
+
+// OnMsgHdr is invoked when a peer receives a msghdr bitcoin message.  The
+// message is passed down to the block manager.
+func (sp *serverPeer) OnMsgHdr(p *peer.Peer, msg *wire.MsgMsgHdr) {
+	sp.server.blockManager.QueueMsgHdr(msg, sp)
+}
+
 // OnGetBlocks is invoked when a peer receives a getblocks bitcoin
 // message.
 func (sp *serverPeer) OnGetBlocks(p *peer.Peer, msg *wire.MsgGetBlocks) {
@@ -1531,6 +1580,8 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnGetData:     sp.OnGetData,
 			OnGetBlocks:   sp.OnGetBlocks,
 			OnGetHeaders:  sp.OnGetHeaders,
+			OnGetMsgHdr:   sp.OnGetMsgHdr,
+			OnMsgHdr:      sp.OnMsgHdr,
 			OnFilterAdd:   sp.OnFilterAdd,
 			OnFilterClear: sp.OnFilterClear,
 			OnFilterLoad:  sp.OnFilterLoad,
@@ -2144,6 +2195,11 @@ func (s *server) Start() {
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start the stratum server if it's enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2169,6 +2225,11 @@ func (s *server) Stop() error {
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Shutdown the stratum server if it's enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Stop()
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
@@ -2337,6 +2398,84 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		services &^= wire.SFNodeBloom
 	}
 
+	// Connect to the message store of the network unless a different one
+	// is configured.  Networks without a default message store, such as
+	// the simulation test network, only use one when it is configured.
+	hcache := (*ciphrtxt.HeaderCache)(nil)
+	host := activeNetParams.CTMsgstoreHost
+	if len(cfg.HeaderCacheHost) > 0 {
+		host = cfg.HeaderCacheHost
+	}
+	var port uint64
+	if len(activeNetParams.CTMsgstorePort) > 0 {
+		// if this fails we have real issues.
+		var err error
+		port, err = strconv.ParseUint(activeNetParams.CTMsgstorePort, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.HeaderCachePort != 0 {
+		port = uint64(cfg.HeaderCachePort)
+	}
+	if len(host) > 0 && port != 0 {
+		// Keep the header cache with the rest of the node data so
+		// nodes with separate data directories do not share it.
+		dbdir := filepath.Join(cfg.DataDir, "hdb", host)
+
+		retries := 10
+		if cfg.HeaderCacheRetries != 0 {
+			retries = cfg.HeaderCacheRetries
+		}
+
+		var err error
+		hcache, err = ciphrtxt.OpenHeaderCache(host, uint16(port), dbdir)
+		if err != nil {
+			srvrLog.Warnf("Can't connect to HeaderCache or db: %v, retry in 30 sec", err)
+			for i := 0; i < retries; i++ {
+				time.Sleep(time.Second * 30)
+				hcache, err = ciphrtxt.OpenHeaderCache(host, uint16(port), dbdir)
+				if err == nil {
+					break
+				}
+				srvrLog.Warnf("Can't connect to HeaderCache or db: %v, %d retries remaining", err, (retries - (i + 1)))
+			}
+		}
+		if err == nil {
+			srvrLog.Infof("Opened MSGSTORE at: %s:%d", host, port)
+		}
+	}
+
+	// Only use the header cache as the message header source when it was
+	// opened, since a nil cache would otherwise be a non-nil interface.
+	// Peers are told the message headers can be served from it.
+	//
+	// The simulation test network falls back to an in-memory message
+	// header source when no message store is configured.  The CPU miner
+	// generates the message headers it needs into it, which are relayed
+	// to the other nodes of the network like any other message headers.
+	var headerSource blockchain.MessageHeaderSource
+	var simHeaders *blockchain.MemHeaderSource
+	switch {
+	case hcache != nil:
+		headerSource = hcache
+		services |= wire.SFNodeMsgStore
+	case chainParams.Net == wire.CTSimNet:
+		srvrLog.Infof("Using an in-memory message header source")
+		simHeaders = blockchain.NewMemHeaderSource()
+		headerSource = simHeaders
+		services |= wire.SFNodeMsgStore
+	}
+
+	// Headers are added to the message header source through a notifier
+	// so the pending blocks are re-checked as soon as the headers they
+	// are waiting for arrive.
+	var headerNotifier *blockchain.NotifyHeaderSource
+	if headerSource != nil {
+		headerNotifier = blockchain.NewNotifyHeaderSource(headerSource)
+		headerSource = headerNotifier
+	}
+
 	amgr := addrmgr.New(cfg.DataDir, cttdLookup)
 
 	var listeners []net.Listener
@@ -2462,49 +2601,14 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			return nil, errors.New("no valid listen address")
 		}
 	}
-    
-    hcache := (*ciphrtxt.HeaderCache)(nil)
-    if len(activeNetParams.CTMsgstoreHost) > 0 && len(activeNetParams.CTMsgstorePort) > 0 {
-        // if this fails we have real issues.
-        port, err := strconv.ParseUint(activeNetParams.CTMsgstorePort, 10, 16)
-        if (err == nil) {
-            host := activeNetParams.CTMsgstoreHost
-            if len(cfg.HeaderCacheHost) > 0 {
-                host = cfg.HeaderCacheHost
-            }
-            if cfg.HeaderCachePort != 0 {
-                port = uint64(cfg.HeaderCachePort)
-            }
-            dbdir := filepath.Join(defaultDataDir,"hdb",host)
-
-            retries := 10
-            if cfg.HeaderCacheRetries != 0 {
-                retries = cfg.HeaderCacheRetries
-            }
-            
-            hcache, err = ciphrtxt.OpenHeaderCache(host, uint16(port), dbdir)
-            if err != nil {
-                srvrLog.Warnf("Can't connect to HeaderCache or db: %v, retry in 30 sec", err)
-                for i := 0; i < retries; i++ {
-                    time.Sleep(time.Second * 30)
-                    hcache, err = ciphrtxt.OpenHeaderCache(host, uint16(port), dbdir)
-                    if err == nil {
-                        break
-                    }
-                    srvrLog.Warnf("Can't connect to HeaderCache or db: %v, %d retries remaining", err, (retries - (i+1)))
-                }
-            }
-            if err == nil {
-                srvrLog.Infof("Opened MSGSTORE at: %s:%d", host, port)
-            }
-        }
-    }
 
 	s := server{
 		listeners:            listeners,
 		chainParams:          chainParams,
 		addrManager:          amgr,
-        headerCache:          hcache,
+		headerCache:          headerSource,
+		headerNotifier:       headerNotifier,
+		simHeaders:           simHeaders,
 		newPeers:             make(chan *serverPeer, cfg.MaxPeers),
 		donePeers:            make(chan *serverPeer, cfg.MaxPeers),
 		banPeers:             make(chan *serverPeer, cfg.MaxPeers),
@@ -2549,6 +2653,21 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.AKIndex {
+		indxLog.Info("Access key index is enabled")
+		s.akIndex = indexers.NewAccessKeyIndex(db)
+		indexes = append(indexes, s.akIndex)
+	}
+	if cfg.DirIndex {
+		indxLog.Info("Directory index is enabled")
+		s.dirIndex = indexers.NewDirectoryIndex(db)
+		indexes = append(indexes, s.dirIndex)
+	}
+	if cfg.NonceHdrIndex {
+		indxLog.Info("Nonce header index is enabled")
+		s.nonceHdrIndex = indexers.NewNonceHeaderIndex(db)
+		indexes = append(indexes, s.nonceHdrIndex)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
@@ -2560,6 +2679,11 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		return nil, err
 	}
 	s.blockManager = bm
+	if headerNotifier != nil {
+		headerNotifier.Subscribe(func(*ciphrtxt.RawMessageHeader) {
+			bm.RetryPendingBlocks()
+		})
+	}
 
 	txC := mempool.Config{
 		Policy: mempool.Policy{
@@ -2570,6 +2694,9 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
 			MaxSigOpsPerTx:       blockchain.MaxSigOpsPerBlock / 5,
 			MinRelayTxFee:        cfg.minRelayTxFee,
+			MinAccessKeyFee:      cfg.minAccessKeyFee,
+			MinDirectoryFee:      cfg.minDirectoryFee,
+			DirectoryFeeRate:     cfg.directoryFeeRate,
 		},
 		ChainParams:   chainParams,
 		FetchUtxoView: s.blockManager.chain.FetchUtxoView,
@@ -2591,6 +2718,14 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	}
 	s.cpuMiner = newCPUMiner(&policy, &s)
 
+	if len(cfg.StratumListeners) > 0 {
+		s.stratumServer, err = newStratumServer(cfg.StratumListeners,
+			&policy, &s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if !cfg.DisableRPC {
 		s.rpcServer, err = newRPCServer(cfg.RPCListeners, &policy, &s)
 		if err != nil {
@@ -0,0 +1,1033 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/fastsha256"
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/mining"
+	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/wire"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+// The stratum server speaks a variant of the Stratum v1 mining protocol which
+// is adapted to blocks that are solved by choosing a pair of message headers
+// as their nonces instead of iterating a 32-bit nonce.  Requests, responses
+// and notifications are newline delimited JSON objects as usual and the
+// mining.subscribe, mining.authorize and mining.set_difficulty messages are
+// unchanged.
+//
+// The worker name of the mining.authorize request is the configured stratum
+// username, optionally followed by a period and a name which identifies the
+// mining machine, and the password is the configured stratum password.  Jobs
+// are only handed out once the connection has subscribed and authorized.
+//
+// The mining.notify parameters are:
+//   0. job id
+//   1. hex-encoded previous block hash in the order it is serialized in the
+//      block header
+//   2. hex-encoded first part of the coinbase transaction
+//   3. hex-encoded second part of the coinbase transaction
+//   4. list of hex-encoded merkle branch hashes in serialized order
+//   5. block version as a hex-encoded big-endian 32-bit integer
+//   6. compact difficulty bits as a hex-encoded big-endian 32-bit integer
+//   7. block time as a hex-encoded big-endian 32-bit integer
+//   8. whether or not all previous jobs must be abandoned
+//   9. list of hex-encoded message headers which are the nonce candidates
+//  10. first nonce header pair index assigned to the connection as a
+//      hex-encoded big-endian 64-bit integer
+//  11. end (exclusive) of the nonce header pair indices assigned to the
+//      connection as a hex-encoded big-endian 64-bit integer
+//
+// The coinbase transaction consists of the first part, the extra nonce
+// assigned to the connection, the extra nonce chosen by the miner and the
+// second part.  The merkle root is obtained by hashing the double sha256 hash
+// of the coinbase transaction with each hash of the merkle branch in turn.
+//
+// Nonce header pair index p selects candidate a = p / (n - 1) as nonce header
+// A and candidate b = p % (n - 1) as nonce header B, where n is the number of
+// candidates, except b is incremented by one when it is greater than or equal
+// to a.  Thus every ordered pair of two distinct candidates has exactly one
+// index in [0, n * (n - 1)).  The block header is the usual serialization of
+// the version, previous block hash, merkle root, time, bits and the two nonce
+// headers and it is hashed with ShaMulSha256.
+//
+// The mining.submit parameters are the worker name, the job id, the
+// hex-encoded extra nonce chosen by the miner, the block time as a hex-encoded
+// big-endian 32-bit integer and the nonce header pair index as a hex-encoded
+// big-endian 64-bit integer.
+
+const (
+	// stratumExtraNonce1Size is the number of bytes of the extra nonce
+	// which is assigned to each connection by the server.
+	stratumExtraNonce1Size = 4
+
+	// stratumExtraNonce2Size is the number of bytes of the extra nonce
+	// which is chosen by the miner.
+	stratumExtraNonce2Size = 4
+
+	// stratumJobRefreshSecs is the number of seconds after which a new job
+	// is generated when the best chain has not changed so the miners work
+	// on recent transactions and nonce header candidates.
+	stratumJobRefreshSecs = 30
+
+	// stratumMaxJobs is the maximum number of recent jobs of a connection
+	// for which shares are accepted.
+	stratumMaxJobs = 8
+
+	// stratumMaxLineLen is the maximum length of a request.
+	stratumMaxLineLen = 4096
+
+	// stratumIdleTimeout is the duration after which a connection which
+	// has not sent any requests is disconnected.
+	stratumIdleTimeout = time.Minute * 10
+
+	// stratumWriteTimeout is the duration after which writing a message
+	// to a connection is abandoned and the connection is disconnected.
+	stratumWriteTimeout = time.Second * 30
+
+	// stratumSendQueueSize is the maximum number of messages which may be
+	// queued for a connection before it is considered too slow and is
+	// disconnected.
+	stratumSendQueueSize = 32
+)
+
+// Stratum error codes.
+const (
+	stratumErrOther          = 20
+	stratumErrJobNotFound    = 21
+	stratumErrDuplicateShare = 22
+	stratumErrLowDifficulty  = 23
+	stratumErrUnauthorized   = 24
+	stratumErrNotSubscribed  = 25
+)
+
+// stratumError describes an error which is returned to a stratum client.
+type stratumError struct {
+	code    int
+	message string
+}
+
+// MarshalJSON encodes the error in the [code, message, traceback] format
+// expected by stratum clients.
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.code, e.message, nil})
+}
+
+// stratumRequest models a request received from a stratum client.
+type stratumRequest struct {
+	ID     interface{}       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// stratumResponse models a response sent to a stratum client.
+type stratumResponse struct {
+	ID     interface{}   `json:"id"`
+	Result interface{}   `json:"result"`
+	Error  *stratumError `json:"error"`
+}
+
+// stratumNotification models a notification sent to a stratum client.
+type stratumNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// stratumTemplate houses a block template which is handed out as jobs along
+// with the pieces of it the miners need to solve it.
+type stratumTemplate struct {
+	block        *wire.MsgBlock
+	height       int32
+	coinb1       []byte
+	coinb2       []byte
+	merkleBranch []*chainhash.Hash
+	candidates   []ciphrtxt.BinaryMessageHeaderV2
+	target       *big.Int
+	generated    time.Time
+}
+
+// stratumShare identifies a share submitted for a job so duplicate submissions
+// can be detected.
+type stratumShare struct {
+	extraNonce2 [stratumExtraNonce2Size]byte
+	timestamp   uint32
+	pair        uint64
+}
+
+// stratumJob houses a template which was handed out to a client along with the
+// nonce header pairs assigned to the client and the shares it submitted.
+type stratumJob struct {
+	id        string
+	template  *stratumTemplate
+	pairStart uint64
+	pairEnd   uint64
+	shares    map[stratumShare]struct{}
+}
+
+// stratumClient houses the state of a connection to the stratum server.
+type stratumClient struct {
+	server      *stratumServer
+	conn        net.Conn
+	extraNonce1 [stratumExtraNonce1Size]byte
+	sendQueue   chan []byte
+	quit        chan struct{}
+
+	mtx        sync.Mutex
+	subscribed bool
+	authorized bool
+	worker     string
+	jobs       map[string]*stratumJob
+	jobOrder   []string
+	accepted   uint64
+	rejected   uint64
+}
+
+// stratumServer provides a stratum mining server which hands out jobs built
+// from block templates to the connected miners and submits the blocks they
+// solve.
+type stratumServer struct {
+	started  int32
+	shutdown int32
+
+	server      *server
+	policy      *mining.Policy
+	listeners   []net.Listener
+	shareTarget *big.Int
+	userSHA     [fastsha256.Size]byte
+	passSHA     [fastsha256.Size]byte
+
+	mtx             sync.Mutex
+	conns           map[*stratumClient]struct{}
+	clients         map[*stratumClient]struct{}
+	template        *stratumTemplate
+	nextJobID       uint64
+	nextExtraNonce1 uint32
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// stratumShareTarget returns the target shares must meet for the passed share
+// difficulty, which is relative to the proof-of-work limit.
+func stratumShareTarget(powLimit *big.Int, difficulty float64) *big.Int {
+	target := new(big.Rat).SetInt(powLimit)
+	target.Quo(target, new(big.Rat).SetFloat64(difficulty))
+	return new(big.Int).Quo(target.Num(), target.Denom())
+}
+
+// stratumCoinbaseScript returns the signature script of the coinbase
+// transaction of a block handed out by the stratum server.  It is the same as
+// the standard coinbase script except the extra nonce consists of the extra
+// nonce assigned to the connection followed by the one chosen by the miner.
+// The returned offset is the position of the extra nonce in the script.
+func stratumCoinbaseScript(nextBlockHeight int32, extraNonce1, extraNonce2 []byte) ([]byte, int, error) {
+	heightScript, err := txscript.NewScriptBuilder().
+		AddInt64(int64(nextBlockHeight)).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	extraNonce := make([]byte, 0, len(extraNonce1)+len(extraNonce2))
+	extraNonce = append(extraNonce, extraNonce1...)
+	extraNonce = append(extraNonce, extraNonce2...)
+	script, err := txscript.NewScriptBuilder().
+		AddInt64(int64(nextBlockHeight)).AddData(extraNonce).
+		AddData([]byte(coinbaseFlags)).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The extra nonce follows the height and the opcode which pushes it.
+	return script, len(heightScript) + 1, nil
+}
+
+// stratumCoinbaseParts splits the serialized coinbase transaction of the passed
+// block around the extra nonce in its signature script, which must be located
+// at the passed offset.
+func stratumCoinbaseParts(msgBlock *wire.MsgBlock, extraNonceOffset int) ([]byte, []byte, error) {
+	coinbaseTx := msgBlock.Transactions[0]
+	script := coinbaseTx.TxIn[0].SignatureScript
+	var buf bytes.Buffer
+	buf.Grow(coinbaseTx.SerializeSize())
+	if err := coinbaseTx.Serialize(&buf); err != nil {
+		return nil, nil, err
+	}
+	serialized := buf.Bytes()
+	scriptOffset := bytes.Index(serialized, script)
+	if scriptOffset < 0 {
+		return nil, nil, errors.New("coinbase signature script not " +
+			"found in serialized transaction")
+	}
+	extraNonceStart := scriptOffset + extraNonceOffset
+	extraNonceEnd := extraNonceStart + stratumExtraNonce1Size +
+		stratumExtraNonce2Size
+	return serialized[:extraNonceStart], serialized[extraNonceEnd:], nil
+}
+
+// stratumMerkleBranch returns the hashes which the hash of the coinbase
+// transaction of the passed transactions is combined with in turn to obtain the
+// merkle root.
+func stratumMerkleBranch(transactions []*cttutil.Tx) []*chainhash.Hash {
+	// The coinbase transaction is the leftmost leaf of the tree, so the
+	// hashes are the second node of each level of the tree below the root.
+	// See BuildMerkleTreeStore for details about the layout of the store.
+	// The leftmost node of a level is never the only node of that level
+	// unless it is the root, so none of the hashes are nil.
+	merkles := blockchain.BuildMerkleTreeStore(transactions)
+	var branch []*chainhash.Hash
+	offset := 0
+	for width := (len(merkles) + 1) / 2; width > 1; width /= 2 {
+		branch = append(branch, merkles[offset+1])
+		offset += width
+	}
+	return branch
+}
+
+// stratumMerkleRoot returns the merkle root of a block with the passed coinbase
+// transaction hash and merkle branch.
+func stratumMerkleRoot(coinbaseHash chainhash.Hash, merkleBranch []*chainhash.Hash) chainhash.Hash {
+	root := coinbaseHash
+	for _, hash := range merkleBranch {
+		root = *blockchain.HashMerkleBranches(&root, hash)
+	}
+	return root
+}
+
+// stratumPairSlice returns the range [start, end) of nonce header pair indices
+// which is assigned to the client with the passed index out of the passed
+// number of clients.  The ranges are disjoint unless there are more clients
+// than pairs, in which case the clients still search distinct blocks thanks to
+// their distinct extra nonces.
+func stratumPairSlice(index, numClients, numPairs uint64) (uint64, uint64) {
+	if numClients > numPairs {
+		start := index % numPairs
+		return start, start + 1
+	}
+	return index * numPairs / numClients, (index + 1) * numPairs / numClients
+}
+
+// parseStratumHexUint parses a hex-encoded big-endian unsigned integer of the
+// passed size in bits from a stratum request parameter.
+func parseStratumHexUint(param json.RawMessage, bitSize int) (uint64, error) {
+	var s string
+	if err := json.Unmarshal(param, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 16, bitSize)
+}
+
+// send queues the passed message to be written to the client by its out
+// handler.  It never blocks, so it may be called with the stratum server lock
+// held.  The connection is closed when the queue is full since the client is
+// not keeping up, which in turn causes the client to be removed.
+//
+// This function is safe for concurrent access.
+func (c *stratumClient) send(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		strmLog.Errorf("Failed to marshal stratum message: %v", err)
+		return
+	}
+	b = append(b, '\n')
+
+	select {
+	case c.sendQueue <- b:
+	default:
+		strmLog.Debugf("Send queue of stratum client %s is full",
+			c.conn.RemoteAddr())
+		c.conn.Close()
+	}
+}
+
+// notifyJob hands out a new job for the passed template to the client with
+// the passed range of nonce header pair indices.  All previous jobs are
+// forgotten when clean is set.
+//
+// This function MUST be called with the stratum server lock held.
+func (c *stratumClient) notifyJob(template *stratumTemplate, pairStart, pairEnd uint64, clean bool) {
+	c.server.nextJobID++
+	job := &stratumJob{
+		id:        strconv.FormatUint(c.server.nextJobID, 16),
+		template:  template,
+		pairStart: pairStart,
+		pairEnd:   pairEnd,
+		shares:    make(map[stratumShare]struct{}),
+	}
+
+	c.mtx.Lock()
+	if clean {
+		c.jobs = make(map[string]*stratumJob)
+		c.jobOrder = c.jobOrder[:0]
+	}
+	if len(c.jobOrder) >= stratumMaxJobs {
+		delete(c.jobs, c.jobOrder[0])
+		c.jobOrder = c.jobOrder[1:]
+	}
+	c.jobs[job.id] = job
+	c.jobOrder = append(c.jobOrder, job.id)
+	c.mtx.Unlock()
+
+	header := &template.block.Header
+	branch := make([]string, 0, len(template.merkleBranch))
+	for _, hash := range template.merkleBranch {
+		branch = append(branch, hex.EncodeToString(hash[:]))
+	}
+	candidates := make([]string, 0, len(template.candidates))
+	for i := range template.candidates {
+		candidates = append(candidates,
+			hex.EncodeToString(template.candidates[i][:]))
+	}
+	c.send(&stratumNotification{
+		Method: "mining.notify",
+		Params: []interface{}{
+			job.id,
+			hex.EncodeToString(header.PrevBlock[:]),
+			hex.EncodeToString(template.coinb1),
+			hex.EncodeToString(template.coinb2),
+			branch,
+			fmt.Sprintf("%08x", uint32(header.Version)),
+			fmt.Sprintf("%08x", header.Bits),
+			fmt.Sprintf("%08x", uint32(header.Timestamp.Unix())),
+			clean,
+			candidates,
+			fmt.Sprintf("%016x", pairStart),
+			fmt.Sprintf("%016x", pairEnd),
+		},
+	})
+}
+
+// handleSubscribe handles the mining.subscribe request.
+func (c *stratumClient) handleSubscribe() (interface{}, *stratumError) {
+	c.mtx.Lock()
+	c.subscribed = true
+	c.mtx.Unlock()
+
+	subscriptionID := hex.EncodeToString(c.extraNonce1[:])
+	return []interface{}{
+		[]interface{}{
+			[]interface{}{"mining.set_difficulty", subscriptionID},
+			[]interface{}{"mining.notify", subscriptionID},
+		},
+		hex.EncodeToString(c.extraNonce1[:]),
+		stratumExtraNonce2Size,
+	}, nil
+}
+
+// handleAuthorize handles the mining.authorize request.  The worker is
+// authorized when it provides the configured stratum credentials.  The
+// credentials are compared in constant time like the RPC server does.
+func (c *stratumClient) handleAuthorize(params []json.RawMessage) (interface{}, *stratumError) {
+	var worker, pass string
+	if len(params) < 2 || json.Unmarshal(params[0], &worker) != nil ||
+		json.Unmarshal(params[1], &pass) != nil {
+
+		return nil, &stratumError{stratumErrOther, "Invalid parameters"}
+	}
+
+	// The part of the worker name after the first period only identifies
+	// the mining machine.
+	user := worker
+	if i := strings.IndexByte(worker, '.'); i >= 0 {
+		user = worker[:i]
+	}
+	userSHA := fastsha256.Sum256([]byte(user))
+	passSHA := fastsha256.Sum256([]byte(pass))
+	userCmp := subtle.ConstantTimeCompare(userSHA[:], c.server.userSHA[:])
+	passCmp := subtle.ConstantTimeCompare(passSHA[:], c.server.passSHA[:])
+	if userCmp&passCmp != 1 {
+		strmLog.Warnf("Stratum authentication failure for worker %q "+
+			"from %s", worker, c.conn.RemoteAddr())
+		return nil, &stratumError{stratumErrUnauthorized,
+			"Unauthorized worker"}
+	}
+
+	c.mtx.Lock()
+	c.authorized = true
+	c.worker = worker
+	c.mtx.Unlock()
+
+	strmLog.Infof("Authorized stratum worker %q from %s", worker,
+		c.conn.RemoteAddr())
+	return true, nil
+}
+
+// handleSubmit handles the mining.submit request.  The share is checked against
+// the job it was found for and the share target, and the block is submitted
+// when it also meets the target of the block.
+func (c *stratumClient) handleSubmit(params []json.RawMessage) (interface{}, *stratumError) {
+	c.mtx.Lock()
+	subscribed, authorized := c.subscribed, c.authorized
+	c.mtx.Unlock()
+	if !subscribed {
+		return nil, &stratumError{stratumErrNotSubscribed, "Not subscribed"}
+	}
+	if !authorized {
+		return nil, &stratumError{stratumErrUnauthorized, "Unauthorized worker"}
+	}
+
+	invalidParams := &stratumError{stratumErrOther, "Invalid parameters"}
+	if len(params) < 5 {
+		return nil, invalidParams
+	}
+	var jobID, extraNonce2Hex string
+	if json.Unmarshal(params[1], &jobID) != nil ||
+		json.Unmarshal(params[2], &extraNonce2Hex) != nil {
+
+		return nil, invalidParams
+	}
+	extraNonce2, err := hex.DecodeString(extraNonce2Hex)
+	if err != nil || len(extraNonce2) != stratumExtraNonce2Size {
+		return nil, invalidParams
+	}
+	timestamp, err := parseStratumHexUint(params[3], 32)
+	if err != nil {
+		return nil, invalidParams
+	}
+	pair, err := parseStratumHexUint(params[4], 64)
+	if err != nil {
+		return nil, invalidParams
+	}
+
+	share := stratumShare{timestamp: uint32(timestamp), pair: pair}
+	copy(share.extraNonce2[:], extraNonce2)
+	c.mtx.Lock()
+	job, ok := c.jobs[jobID]
+	if !ok {
+		c.rejected++
+		c.mtx.Unlock()
+		return nil, &stratumError{stratumErrJobNotFound, "Job not found"}
+	}
+	if _, exists := job.shares[share]; exists {
+		c.rejected++
+		c.mtx.Unlock()
+		return nil, &stratumError{stratumErrDuplicateShare,
+			"Duplicate share"}
+	}
+	job.shares[share] = struct{}{}
+	c.mtx.Unlock()
+
+	serr := c.checkShare(job, &share)
+	c.mtx.Lock()
+	if serr != nil {
+		c.rejected++
+	} else {
+		c.accepted++
+	}
+	c.mtx.Unlock()
+	if serr != nil {
+		return nil, serr
+	}
+	return true, nil
+}
+
+// checkShare reconstructs the block for the passed share of the passed job and
+// ensures it meets the share target.  The block is submitted when it also
+// meets the target of the block.
+func (c *stratumClient) checkShare(job *stratumJob, share *stratumShare) *stratumError {
+	template := job.template
+	if share.pair < job.pairStart || share.pair >= job.pairEnd {
+		return &stratumError{stratumErrOther,
+			"Nonce header pair not assigned"}
+	}
+
+	// The block time may be rolled forward from the template up to the
+	// maximum allowed time for a block.
+	timestamp := time.Unix(int64(share.timestamp), 0)
+	maxTime := c.server.server.timeSource.AdjustedTime().Add(time.Second *
+		blockchain.MaxTimeOffsetSeconds)
+	if timestamp.Before(template.block.Header.Timestamp) ||
+		timestamp.After(maxTime) {
+
+		return &stratumError{stratumErrOther, "Time out of range"}
+	}
+
+	// Reconstruct the coinbase transaction and the block header.
+	coinbaseScript, _, err := stratumCoinbaseScript(template.height,
+		c.extraNonce1[:], share.extraNonce2[:])
+	if err != nil {
+		strmLog.Errorf("Failed to create coinbase script: %v", err)
+		return &stratumError{stratumErrOther, "Internal error"}
+	}
+	coinbaseTx := template.block.Transactions[0].Copy()
+	coinbaseTx.TxIn[0].SignatureScript = coinbaseScript
+	header := template.block.Header
+	header.MerkleRoot = stratumMerkleRoot(coinbaseTx.TxHash(),
+		template.merkleBranch)
+	header.Timestamp = timestamp
+	numCandidates := uint64(len(template.candidates))
+	a, b := blockchain.NonceHeaderPair(share.pair, numCandidates)
+	header.NonceHeaderA = template.candidates[a]
+	header.NonceHeaderB = template.candidates[b]
+
+	hash := header.BlockHash()
+	hashNum := blockchain.HashToBig(&hash)
+	if hashNum.Cmp(c.server.shareTarget) > 0 &&
+		hashNum.Cmp(template.target) > 0 {
+
+		return &stratumError{stratumErrLowDifficulty,
+			"Low difficulty share"}
+	}
+	if hashNum.Cmp(template.target) > 0 {
+		return nil
+	}
+
+	// The share solves the block, so submit it.
+	transactions := make([]*wire.MsgTx, 0, len(template.block.Transactions))
+	transactions = append(transactions, coinbaseTx)
+	transactions = append(transactions, template.block.Transactions[1:]...)
+	block := cttutil.NewBlock(&wire.MsgBlock{
+		Header:       header,
+		Transactions: transactions,
+	})
+	c.mtx.Lock()
+	worker := c.worker
+	c.mtx.Unlock()
+	strmLog.Infof("Stratum worker %q found block %s", worker, block.Hash())
+	c.server.server.submitMinedBlock(block, "stratum")
+	return nil
+}
+
+// handleRequest handles the passed request and returns the result or error
+// which is sent back to the client.
+func (c *stratumClient) handleRequest(req *stratumRequest) (interface{}, *stratumError) {
+	switch req.Method {
+	case "mining.subscribe":
+		return c.handleSubscribe()
+	case "mining.authorize":
+		return c.handleAuthorize(req.Params)
+	case "mining.submit":
+		return c.handleSubmit(req.Params)
+	case "mining.extranonce.subscribe":
+		return false, nil
+	}
+	return nil, &stratumError{stratumErrOther, "Method not found"}
+}
+
+// inHandler handles the requests of the client until the connection is closed
+// or the client misbehaves.
+//
+// This MUST be run as a goroutine.
+func (c *stratumClient) inHandler() {
+	reader := bufio.NewReaderSize(c.conn, stratumMaxLineLen)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(stratumIdleTimeout))
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			if atomic.LoadInt32(&c.server.shutdown) == 0 {
+				strmLog.Debugf("Stratum client %s disconnected: "+
+					"%v", c.conn.RemoteAddr(), err)
+			}
+			break
+		}
+		if isPrefix {
+			strmLog.Debugf("Stratum client %s sent a request which "+
+				"is too long", c.conn.RemoteAddr())
+			break
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var req stratumRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			strmLog.Debugf("Stratum client %s sent a malformed "+
+				"request: %v", c.conn.RemoteAddr(), err)
+			break
+		}
+		result, serr := c.handleRequest(&req)
+		c.send(&stratumResponse{ID: req.ID, Result: result, Error: serr})
+
+		// Provide the share difficulty and work right away once the
+		// client has both subscribed and authorized, in either order.
+		if serr != nil || (req.Method != "mining.subscribe" &&
+			req.Method != "mining.authorize") {
+
+			continue
+		}
+		c.mtx.Lock()
+		ready := c.subscribed && c.authorized
+		c.mtx.Unlock()
+		if ready && !c.server.hasClient(c) {
+			c.send(&stratumNotification{
+				Method: "mining.set_difficulty",
+				Params: []interface{}{cfg.StratumShareDiff},
+			})
+			c.server.addClient(c)
+		}
+	}
+
+	c.server.removeClient(c)
+	c.conn.Close()
+	close(c.quit)
+	c.mtx.Lock()
+	strmLog.Infof("Stratum client %s disconnected (worker %q, %d accepted "+
+		"shares, %d rejected shares)", c.conn.RemoteAddr(), c.worker,
+		c.accepted, c.rejected)
+	c.mtx.Unlock()
+	c.server.wg.Done()
+}
+
+// outHandler writes the messages queued for the client in the order they were
+// queued until the client disconnects.  The connection is closed when a write
+// fails, which in turn causes the client to be removed.
+//
+// This MUST be run as a goroutine.
+func (c *stratumClient) outHandler() {
+out:
+	for {
+		select {
+		case b := <-c.sendQueue:
+			c.conn.SetWriteDeadline(time.Now().Add(
+				stratumWriteTimeout))
+			if _, err := c.conn.Write(b); err != nil {
+				strmLog.Debugf("Failed to write to stratum "+
+					"client %s: %v", c.conn.RemoteAddr(), err)
+				c.conn.Close()
+				break out
+			}
+		case <-c.quit:
+			break out
+		}
+	}
+	c.server.wg.Done()
+}
+
+// notifyClients hands out new jobs for the current template to all subscribed
+// clients, assigning each of them a disjoint range of the nonce header pairs.
+//
+// This function MUST be called with the stratum server lock held.
+func (s *stratumServer) notifyClients(clean bool) {
+	template := s.template
+	if template == nil || len(s.clients) == 0 {
+		return
+	}
+
+	numCandidates := uint64(len(template.candidates))
+	numPairs := numCandidates * (numCandidates - 1)
+	numClients := uint64(len(s.clients))
+	index := uint64(0)
+	for c := range s.clients {
+		start, end := stratumPairSlice(index, numClients, numPairs)
+		c.notifyJob(template, start, end, clean)
+		index++
+	}
+}
+
+// hasClient returns whether or not the passed client has already been added
+// and is being handed out jobs.
+func (s *stratumServer) hasClient(c *stratumClient) bool {
+	s.mtx.Lock()
+	_, exists := s.clients[c]
+	s.mtx.Unlock()
+	return exists
+}
+
+// addClient adds the passed subscribed and authorized client and hands out a
+// job for the current template to it.  The jobs of the other clients are left
+// alone, so a connecting client can't make everyone else abandon their work.
+// The new client is assigned all of the nonce header pairs of the current
+// template since its extra nonce already makes its blocks distinct from the
+// ones of the other clients.  The pairs are split among all clients again
+// with the next job.
+func (s *stratumServer) addClient(c *stratumClient) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, exists := s.clients[c]; exists {
+		return
+	}
+	s.clients[c] = struct{}{}
+	if template := s.template; template != nil {
+		numCandidates := uint64(len(template.candidates))
+		c.notifyJob(template, 0, numCandidates*(numCandidates-1), true)
+	}
+}
+
+// removeClient removes the passed client regardless of whether or not it has
+// subscribed.
+func (s *stratumServer) removeClient(c *stratumClient) {
+	s.mtx.Lock()
+	delete(s.conns, c)
+	delete(s.clients, c)
+	s.mtx.Unlock()
+}
+
+// newTemplate returns a new template extending the current best chain which
+// pays to a random mining address and uses the current nonce header
+// candidates.
+func (s *stratumServer) newTemplate() (*stratumTemplate, error) {
+	if s.server.headerCache == nil {
+		return nil, errors.New("no message header source available")
+	}
+	candidates, _, err := nonceHeaderCandidates(s.server.headerCache,
+		s.server.blockManager.chain, s.server.chainParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) < 2 {
+		return nil, fmt.Errorf("only %d nonce header candidates are "+
+			"available", len(candidates))
+	}
+
+	payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+	blkTemplate, err := NewBlockTemplate(s.policy, s.server, payToAddr)
+	if err != nil {
+		return nil, err
+	}
+	msgBlock := blkTemplate.Block
+
+	// Replace the coinbase script with one which has room for the extra
+	// nonces and split the coinbase transaction around them.
+	var zeroExtraNonce1 [stratumExtraNonce1Size]byte
+	var zeroExtraNonce2 [stratumExtraNonce2Size]byte
+	coinbaseScript, extraNonceOffset, err := stratumCoinbaseScript(
+		blkTemplate.Height, zeroExtraNonce1[:], zeroExtraNonce2[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(coinbaseScript) > blockchain.MaxCoinbaseScriptLen {
+		return nil, fmt.Errorf("coinbase transaction script length "+
+			"of %d is out of range (min: %d, max: %d)",
+			len(coinbaseScript), blockchain.MinCoinbaseScriptLen,
+			blockchain.MaxCoinbaseScriptLen)
+	}
+	msgBlock.Transactions[0].TxIn[0].SignatureScript = coinbaseScript
+	coinb1, coinb2, err := stratumCoinbaseParts(msgBlock, extraNonceOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	block := cttutil.NewBlock(msgBlock)
+	return &stratumTemplate{
+		block:        msgBlock,
+		height:       blkTemplate.Height,
+		coinb1:       coinb1,
+		coinb2:       coinb2,
+		merkleBranch: stratumMerkleBranch(block.Transactions()),
+		candidates:   candidates,
+		target:       blockchain.CompactToBig(msgBlock.Header.Bits),
+		generated:    time.Now(),
+	}, nil
+}
+
+// jobHandler generates new templates when the best chain changes or the
+// current template is old and hands out jobs for them to the clients.
+//
+// This MUST be run as a goroutine.
+func (s *stratumServer) jobHandler() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			break out
+		}
+
+		s.mtx.Lock()
+		numClients := len(s.clients)
+		template := s.template
+		s.mtx.Unlock()
+
+		// There is no point in generating work when nobody is working
+		// on it, when there is no way to relay a found block or before
+		// the chain is synced.
+		if numClients == 0 || s.server.ConnectedCount() == 0 {
+			continue
+		}
+		_, curHeight := s.server.blockManager.chainState.Best()
+		if curHeight != 0 && !s.server.blockManager.IsCurrent() {
+			continue
+		}
+
+		bestHash, _ := s.server.blockManager.chainState.Best()
+		clean := template == nil ||
+			!template.block.Header.PrevBlock.IsEqual(bestHash)
+		if !clean && time.Since(template.generated) <
+			time.Second*stratumJobRefreshSecs {
+
+			continue
+		}
+
+		template, err := s.newTemplate()
+		if err != nil {
+			strmLog.Debugf("Unable to create stratum job: %v", err)
+			continue
+		}
+
+		strmLog.Debugf("Generated stratum job (height %d, target %064x, "+
+			"%d nonce header candidates)", template.height,
+			template.target, len(template.candidates))
+		s.mtx.Lock()
+		s.template = template
+		s.notifyClients(clean)
+		s.mtx.Unlock()
+	}
+
+	s.wg.Done()
+	strmLog.Tracef("Stratum job handler done")
+}
+
+// listenHandler accepts stratum connections on the passed listener.
+//
+// This MUST be run as a goroutine.
+func (s *stratumServer) listenHandler(listener net.Listener) {
+	strmLog.Infof("Stratum server listening on %s", listener.Addr())
+	for atomic.LoadInt32(&s.shutdown) == 0 {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Only log the error if we're not forcibly shutting down.
+			if atomic.LoadInt32(&s.shutdown) == 0 {
+				strmLog.Errorf("Can't accept connection: %v", err)
+			}
+			continue
+		}
+
+		// Limit the number of connections so a flood of them can't
+		// exhaust the resources of the server.
+		s.mtx.Lock()
+		numConns := len(s.conns)
+		s.mtx.Unlock()
+		if numConns >= cfg.StratumMaxClients {
+			strmLog.Infof("Max stratum clients exceeded [%d] - "+
+				"disconnecting client %s", cfg.StratumMaxClients,
+				conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		c := &stratumClient{
+			server:    s,
+			conn:      conn,
+			sendQueue: make(chan []byte, stratumSendQueueSize),
+			quit:      make(chan struct{}),
+			jobs:      make(map[string]*stratumJob),
+		}
+		s.mtx.Lock()
+		s.nextExtraNonce1++
+		extraNonce1 := s.nextExtraNonce1
+		s.conns[c] = struct{}{}
+		s.mtx.Unlock()
+		for i := range c.extraNonce1 {
+			c.extraNonce1[i] = byte(extraNonce1 >> uint(8*i))
+		}
+
+		strmLog.Infof("New stratum client %s", conn.RemoteAddr())
+		s.wg.Add(2)
+		go c.inHandler()
+		go c.outHandler()
+	}
+	s.wg.Done()
+	strmLog.Tracef("Stratum listener done for %s", listener.Addr())
+}
+
+// Start begins accepting stratum connections and handing out jobs.
+func (s *stratumServer) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	strmLog.Trace("Starting stratum server")
+	for _, listener := range s.listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+
+	s.wg.Add(1)
+	go s.jobHandler()
+}
+
+// Stop gracefully shuts down the stratum server by closing the listeners and
+// disconnecting all clients.
+func (s *stratumServer) Stop() error {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		strmLog.Infof("Stratum server is already in the process of " +
+			"shutting down")
+		return nil
+	}
+	strmLog.Warnf("Stratum server shutting down")
+	for _, listener := range s.listeners {
+		if err := listener.Close(); err != nil {
+			strmLog.Errorf("Problem shutting down stratum: %v", err)
+			return err
+		}
+	}
+	close(s.quit)
+
+	s.mtx.Lock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+	strmLog.Infof("Stratum server shutdown complete")
+	return nil
+}
+
+// newStratumServer returns a new stratum server listening on the passed
+// addresses which creates block templates with the passed mining policy.
+func newStratumServer(listenAddrs []string, policy *mining.Policy, s *server) (*stratumServer, error) {
+	ipv4ListenAddrs, ipv6ListenAddrs, _, err := parseListeners(listenAddrs)
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]net.Listener, 0,
+		len(ipv6ListenAddrs)+len(ipv4ListenAddrs))
+	for _, addr := range ipv4ListenAddrs {
+		listener, err := net.Listen("tcp4", addr)
+		if err != nil {
+			strmLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	for _, addr := range ipv6ListenAddrs {
+		listener, err := net.Listen("tcp6", addr)
+		if err != nil {
+			strmLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, errors.New("STRM: No valid listen address")
+	}
+
+	return &stratumServer{
+		server:    s,
+		policy:    policy,
+		listeners: listeners,
+		shareTarget: stratumShareTarget(activeNetParams.PowLimit,
+			cfg.StratumShareDiff),
+		userSHA: fastsha256.Sum256([]byte(cfg.StratumUser)),
+		passSHA: fastsha256.Sum256([]byte(cfg.StratumPass)),
+		conns:   make(map[*stratumClient]struct{}),
+		clients: make(map[*stratumClient]struct{}),
+		quit:    make(chan struct{}),
+	}, nil
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/btcsuite/fastsha256"
+	"github.com/jadeblaquiere/cttd/blockchain"
+	"github.com/jadeblaquiere/cttd/chaincfg/chainhash"
+	"github.com/jadeblaquiere/cttd/wire"
+	"github.com/jadeblaquiere/cttutil"
+)
+
+// TestStratumCoinbaseMerkleRoot ensures the coinbase parts and merkle branch
+// handed out by the stratum server produce the same coinbase transaction and
+// merkle root as the block itself for any extra nonces.
+func TestStratumCoinbaseMerkleRoot(t *testing.T) {
+	extraNonce1 := []byte{0x01, 0x02, 0x03, 0x04}
+	extraNonce2 := []byte{0xfa, 0xfb, 0xfc, 0xfd}
+	for numTxns := 1; numTxns <= 9; numTxns++ {
+		var zeroExtraNonce [stratumExtraNonce1Size + stratumExtraNonce2Size]byte
+		script, offset, err := stratumCoinbaseScript(int32(numTxns),
+			zeroExtraNonce[:stratumExtraNonce1Size],
+			zeroExtraNonce[stratumExtraNonce1Size:])
+		if err != nil {
+			t.Fatalf("stratumCoinbaseScript: %v", err)
+		}
+		coinbaseTx := wire.NewMsgTx()
+		coinbaseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(
+			&chainhash.Hash{}, wire.MaxPrevOutIndex), script))
+		coinbaseTx.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+		msgBlock := &wire.MsgBlock{Transactions: []*wire.MsgTx{coinbaseTx}}
+		for i := 1; i < numTxns; i++ {
+			tx := wire.NewMsgTx()
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(
+				&chainhash.Hash{byte(i)}, 0), nil))
+			tx.AddTxOut(wire.NewTxOut(int64(i), []byte{0x51}))
+			msgBlock.Transactions = append(msgBlock.Transactions, tx)
+		}
+
+		coinb1, coinb2, err := stratumCoinbaseParts(msgBlock, offset)
+		if err != nil {
+			t.Fatalf("stratumCoinbaseParts: %v", err)
+		}
+		branch := stratumMerkleBranch(cttutil.NewBlock(msgBlock).
+			Transactions())
+
+		// Fill in the extra nonces the way a miner would and ensure
+		// the result matches the block with the same extra nonces.
+		var serialized []byte
+		serialized = append(serialized, coinb1...)
+		serialized = append(serialized, extraNonce1...)
+		serialized = append(serialized, extraNonce2...)
+		serialized = append(serialized, coinb2...)
+		script, _, err = stratumCoinbaseScript(int32(numTxns),
+			extraNonce1, extraNonce2)
+		if err != nil {
+			t.Fatalf("stratumCoinbaseScript: %v", err)
+		}
+		coinbaseTx.TxIn[0].SignatureScript = script
+		var buf bytes.Buffer
+		if err := coinbaseTx.Serialize(&buf); err != nil {
+			t.Fatalf("Serialize: %v", err)
+		}
+		if !bytes.Equal(serialized, buf.Bytes()) {
+			t.Fatalf("%d transactions: coinbase mismatch - got %x, "+
+				"want %x", numTxns, serialized, buf.Bytes())
+		}
+
+		merkles := blockchain.BuildMerkleTreeStore(
+			cttutil.NewBlock(msgBlock).Transactions())
+		want := *merkles[len(merkles)-1]
+		got := stratumMerkleRoot(chainhash.DoubleHashH(serialized),
+			branch)
+		if got != want {
+			t.Fatalf("%d transactions: merkle root mismatch - got "+
+				"%v, want %v", numTxns, got, want)
+		}
+	}
+}
+
+// TestStratumPairSlice ensures the nonce header pairs are split among the
+// stratum clients without gaps or overlap when there are enough pairs and
+// that every client is assigned a pair otherwise.
+func TestStratumPairSlice(t *testing.T) {
+	for numPairs := uint64(2); numPairs <= 42; numPairs += 4 {
+		for numClients := uint64(1); numClients <= 50; numClients++ {
+			next := uint64(0)
+			for i := uint64(0); i < numClients; i++ {
+				start, end := stratumPairSlice(i, numClients,
+					numPairs)
+				if numClients > numPairs {
+					if end != start+1 || end > numPairs {
+						t.Fatalf("%d pairs, %d clients: "+
+							"client %d assigned [%d, "+
+							"%d)", numPairs, numClients,
+							i, start, end)
+					}
+					continue
+				}
+				if start != next || end <= start {
+					t.Fatalf("%d pairs, %d clients: client "+
+						"%d assigned [%d, %d), want start "+
+						"%d", numPairs, numClients, i,
+						start, end, next)
+				}
+				next = end
+			}
+			if numClients <= numPairs && next != numPairs {
+				t.Fatalf("%d pairs, %d clients: pairs end at "+
+					"%d, want %d", numPairs, numClients,
+					next, numPairs)
+			}
+		}
+	}
+}
+
+// TestStratumShareTarget ensures the share target is the proof-of-work limit
+// divided by the share difficulty.
+func TestStratumShareTarget(t *testing.T) {
+	powLimit := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 224),
+		big.NewInt(1))
+	tests := []struct {
+		difficulty float64
+		want       *big.Int
+	}{
+		{1, powLimit},
+		{2, new(big.Int).Rsh(powLimit, 1)},
+		{0.5, new(big.Int).Lsh(powLimit, 1)},
+		{256, new(big.Int).Rsh(powLimit, 8)},
+	}
+	for _, test := range tests {
+		got := stratumShareTarget(powLimit, test.difficulty)
+		if got.Cmp(test.want) != 0 {
+			t.Errorf("stratumShareTarget(%v): got %064x, want %064x",
+				test.difficulty, got, test.want)
+		}
+	}
+}
+
+// TestStratumAuthorize ensures only workers which provide the configured
+// stratum credentials are authorized.
+func TestStratumAuthorize(t *testing.T) {
+	server := &stratumServer{
+		userSHA: fastsha256.Sum256([]byte("user")),
+		passSHA: fastsha256.Sum256([]byte("pass")),
+	}
+	tests := []struct {
+		worker string
+		pass   string
+		want   bool
+	}{
+		{"user", "pass", true},
+		{"user.rig1", "pass", true},
+		{"user", "wrong", false},
+		{"other", "pass", false},
+		{"other.user", "pass", false},
+		{"userx", "pass", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		conn, peer := net.Pipe()
+		c := &stratumClient{server: server, conn: conn}
+		worker, _ := json.Marshal(test.worker)
+		pass, _ := json.Marshal(test.pass)
+		_, serr := c.handleAuthorize([]json.RawMessage{worker, pass})
+		conn.Close()
+		peer.Close()
+		if got := serr == nil; got != test.want || c.authorized != got {
+			t.Errorf("handleAuthorize(%q, %q): got authorized %v, "+
+				"want %v", test.worker, test.pass, c.authorized,
+				test.want)
+		}
+		if serr != nil && serr.code != stratumErrUnauthorized {
+			t.Errorf("handleAuthorize(%q, %q): got error code %d, "+
+				"want %d", test.worker, test.pass, serr.code,
+				stratumErrUnauthorized)
+		}
+	}
+
+	// The password is required.
+	c := &stratumClient{server: server}
+	worker, _ := json.Marshal("user")
+	_, serr := c.handleAuthorize([]json.RawMessage{worker})
+	if serr == nil || serr.code != stratumErrOther || c.authorized {
+		t.Errorf("handleAuthorize without password: got error %v, "+
+			"want invalid parameters", serr)
+	}
+}
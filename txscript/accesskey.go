@@ -0,0 +1,191 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/btcsuite/fastsha256"
+)
+
+const (
+	// AccessKeyExpireSize is the number of bytes used to encode the
+	// expiration time of a network access key.
+	AccessKeyExpireSize = 4
+
+	// AccessKeyPubKeySize is the number of bytes used to encode the
+	// compressed public key of a network access key.
+	AccessKeyPubKeySize = 33
+
+	// AccessKeySignatureSize is the number of bytes used to encode the
+	// (r, s) signature of a network access key.
+	AccessKeySignatureSize = 64
+
+	// AccessKeySize is the exact number of bytes of a serialized network
+	// access key as pushed by an OP_REGISTERACCESSKEY script.
+	AccessKeySize = AccessKeyExpireSize + AccessKeyPubKeySize +
+		AccessKeySignatureSize
+)
+
+var (
+	// ErrNotAccessKey is returned when a script that is expected to
+	// register a network access key does not have the form
+	// OP_REGISTERACCESSKEY <data>.
+	ErrNotAccessKey = errors.New("script is not an access key registration")
+
+	// ErrAccessKeySize is returned when the data pushed by an access key
+	// registration script is not exactly AccessKeySize bytes.
+	ErrAccessKeySize = errors.New("access key registration has invalid " +
+		"length")
+
+	// ErrAccessKeyPubKey is returned when the public key of an access key
+	// is not a valid point on the secp256k1 curve.
+	ErrAccessKeyPubKey = errors.New("access key public key is invalid")
+
+	// ErrAccessKeySignature is returned when the signature of an access
+	// key does not verify against its own public key.
+	ErrAccessKeySignature = errors.New("access key signature is invalid")
+)
+
+// AccessKey describes a network access key (NAK) registered by an
+// OP_REGISTERACCESSKEY output.  The serialized form of an access key is:
+//
+//   Field           Type              Size
+//   expire          uint32 (BE)       4 bytes
+//   public key      compressed point  33 bytes
+//   signature       r || s            64 bytes
+//   -----
+//   Total: 101 bytes
+type AccessKey struct {
+	// Expire is the unix time at which the access key expires.
+	Expire uint32
+
+	// PubKey is the serialized compressed public key.
+	PubKey []byte
+
+	// Signature is the 64-byte (r, s) signature over the expire time and
+	// the public key.
+	Signature []byte
+}
+
+// Serialize returns the serialized form of the access key as it is pushed by
+// an OP_REGISTERACCESSKEY script.
+func (ak *AccessKey) Serialize() []byte {
+	serialized := make([]byte, AccessKeySize)
+	binary.BigEndian.PutUint32(serialized, ak.Expire)
+	copy(serialized[AccessKeyExpireSize:], ak.PubKey)
+	copy(serialized[AccessKeyExpireSize+AccessKeyPubKeySize:],
+		ak.Signature)
+	return serialized
+}
+
+// ParseAccessKey decodes the passed serialized access key.  An
+// ErrAccessKeySize error is returned when the data is not exactly
+// AccessKeySize bytes.  No checks are performed on the public key or the
+// signature.
+func ParseAccessKey(serialized []byte) (*AccessKey, error) {
+	if len(serialized) != AccessKeySize {
+		return nil, ErrAccessKeySize
+	}
+
+	sigOffset := AccessKeyExpireSize + AccessKeyPubKeySize
+	ak := AccessKey{
+		Expire:    binary.BigEndian.Uint32(serialized),
+		PubKey:    make([]byte, AccessKeyPubKeySize),
+		Signature: make([]byte, AccessKeySignatureSize),
+	}
+	copy(ak.PubKey, serialized[AccessKeyExpireSize:sigOffset])
+	copy(ak.Signature, serialized[sigOffset:])
+	return &ak, nil
+}
+
+// isAccessKey returns true if the passed script has the form
+// OP_REGISTERACCESSKEY <data>, false otherwise.
+func isAccessKey(pops []parsedOpcode) bool {
+	return len(pops) == 2 &&
+		pops[0].opcode.value == OP_REGISTERACCESSKEY &&
+		pops[1].opcode.value <= OP_PUSHDATA4
+}
+
+// IsAccessKeyRegistration returns whether or not the passed public key script
+// begins with OP_REGISTERACCESSKEY and is therefore meant to register a network
+// access key, regardless of whether or not it is well formed.
+func IsAccessKeyRegistration(pkScript []byte) bool {
+	return len(pkScript) > 0 && pkScript[0] == OP_REGISTERACCESSKEY
+}
+
+// ExtractAccessKey returns the network access key registered by the passed
+// public key script.  ErrNotAccessKey is returned when the script is not of
+// the form OP_REGISTERACCESSKEY <data>.
+func ExtractAccessKey(pkScript []byte) (*AccessKey, error) {
+	pops, err := parseScript(pkScript)
+	if err != nil {
+		return nil, err
+	}
+	if !isAccessKey(pops) {
+		return nil, ErrNotAccessKey
+	}
+
+	return ParseAccessKey(pops[1].data)
+}
+
+// SigHash returns the hash committed to by the signature of the access key,
+// which is the sha256 of the serialized expire time followed by the public
+// key.
+func (ak *AccessKey) SigHash() []byte {
+	buf := make([]byte, AccessKeyExpireSize+AccessKeyPubKeySize)
+	binary.BigEndian.PutUint32(buf, ak.Expire)
+	copy(buf[AccessKeyExpireSize:], ak.PubKey)
+	hash := fastsha256.Sum256(buf)
+	return hash[:]
+}
+
+// Verify ensures the public key of the access key parses and that the
+// signature over the expire time and public key was produced by the private
+// key which corresponds to it.  ErrAccessKeyPubKey or ErrAccessKeySignature
+// is returned when either check fails.  The expire time is not checked.
+func (ak *AccessKey) Verify() error {
+	pubKey, err := btcec.ParsePubKey(ak.PubKey, btcec.S256())
+	if err != nil {
+		return ErrAccessKeyPubKey
+	}
+
+	if len(ak.Signature) != AccessKeySignatureSize {
+		return ErrAccessKeySignature
+	}
+	half := AccessKeySignatureSize / 2
+	sig := btcec.Signature{
+		R: new(big.Int).SetBytes(ak.Signature[:half]),
+		S: new(big.Int).SetBytes(ak.Signature[half:]),
+	}
+	if !sig.Verify(ak.SigHash(), pubKey) {
+		return ErrAccessKeySignature
+	}
+	return nil
+}
+
+// SignAccessKey returns an access key for the public key of the passed
+// private key which expires at the passed unix time and is signed by it.
+func SignAccessKey(expire uint32, privKey *btcec.PrivateKey) (*AccessKey, error) {
+	ak := AccessKey{
+		Expire:    expire,
+		PubKey:    privKey.PubKey().SerializeCompressed(),
+		Signature: make([]byte, AccessKeySignatureSize),
+	}
+	sig, err := privKey.Sign(ak.SigHash())
+	if err != nil {
+		return nil, err
+	}
+
+	// The r and s values are left padded to their fixed sizes.
+	half := AccessKeySignatureSize / 2
+	r, s := sig.R.Bytes(), sig.S.Bytes()
+	copy(ak.Signature[half-len(r):half], r)
+	copy(ak.Signature[AccessKeySignatureSize-len(s):], s)
+	return &ak, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/jadeblaquiere/cttd/txscript"
+)
+
+// TestAccessKey ensures signed access keys round trip through their
+// serialized form and that tampered access keys fail verification.
+func TestAccessKey(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	ak, err := txscript.SignAccessKey(1475000000, privKey)
+	if err != nil {
+		t.Fatalf("SignAccessKey: unexpected error: %v", err)
+	}
+	if err := ak.Verify(); err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+
+	// Ensure the access key round trips through its serialized form.
+	serialized := ak.Serialize()
+	if len(serialized) != txscript.AccessKeySize {
+		t.Fatalf("Serialize: unexpected length - got %d, want %d",
+			len(serialized), txscript.AccessKeySize)
+	}
+	parsed, err := txscript.ParseAccessKey(serialized)
+	if err != nil {
+		t.Fatalf("ParseAccessKey: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, ak) {
+		t.Fatalf("ParseAccessKey: mismatched access key - got %+v, "+
+			"want %+v", parsed, ak)
+	}
+
+	// Ensure data which is not exactly the access key size is rejected.
+	_, err = txscript.ParseAccessKey(serialized[1:])
+	if err != txscript.ErrAccessKeySize {
+		t.Fatalf("ParseAccessKey: did not receive expected error - "+
+			"got %v, want %v", err, txscript.ErrAccessKeySize)
+	}
+	_, err = txscript.ParseAccessKey(append(serialized, 0x00))
+	if err != txscript.ErrAccessKeySize {
+		t.Fatalf("ParseAccessKey: did not receive expected error - "+
+			"got %v, want %v", err, txscript.ErrAccessKeySize)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(ak *txscript.AccessKey)
+		err    error
+	}{
+		{
+			name: "changed expire time",
+			mutate: func(ak *txscript.AccessKey) {
+				ak.Expire++
+			},
+			err: txscript.ErrAccessKeySignature,
+		},
+		{
+			name: "invalid public key",
+			mutate: func(ak *txscript.AccessKey) {
+				ak.PubKey = bytes.Repeat([]byte{0x05},
+					txscript.AccessKeyPubKeySize)
+			},
+			err: txscript.ErrAccessKeyPubKey,
+		},
+		{
+			name: "other public key",
+			mutate: func(ak *txscript.AccessKey) {
+				other, _ := btcec.NewPrivateKey(btcec.S256())
+				ak.PubKey = other.PubKey().SerializeCompressed()
+			},
+			err: txscript.ErrAccessKeySignature,
+		},
+		{
+			name: "zero signature",
+			mutate: func(ak *txscript.AccessKey) {
+				ak.Signature = make([]byte,
+					txscript.AccessKeySignatureSize)
+			},
+			err: txscript.ErrAccessKeySignature,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		tampered, err := txscript.ParseAccessKey(serialized)
+		if err != nil {
+			t.Fatalf("ParseAccessKey: unexpected error: %v", err)
+		}
+		test.mutate(tampered)
+		if err := tampered.Verify(); err != test.err {
+			t.Errorf("Verify #%d (%s): did not receive expected "+
+				"error - got %v, want %v", i, test.name, err,
+				test.err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"errors"
+
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
+)
+
+// ErrNotDirectoryEntry is returned when a script that is expected to post a
+// directory entry does not have the form OP_POSTDIRECTORY <data>.
+var ErrNotDirectoryEntry = errors.New("script is not a directory entry post")
+
+// isDirectoryEntry returns true if the passed script has the form
+// OP_POSTDIRECTORY <data>, false otherwise.
+func isDirectoryEntry(pops []parsedOpcode) bool {
+	return len(pops) == 2 &&
+		pops[0].opcode.value == OP_POSTDIRECTORY &&
+		pops[1].opcode.value <= OP_PUSHDATA4
+}
+
+// IsDirectoryEntryPost returns whether or not the passed public key script
+// begins with OP_POSTDIRECTORY and is therefore meant to post a directory
+// entry, regardless of whether or not it is well formed.
+func IsDirectoryEntryPost(pkScript []byte) bool {
+	return len(pkScript) > 0 && pkScript[0] == OP_POSTDIRECTORY
+}
+
+// ExtractDirectoryEntry returns the directory entry posted by the passed
+// public key script.  ErrNotDirectoryEntry is returned when the script is not
+// of the form OP_POSTDIRECTORY <data>, otherwise any error from decoding the
+// pushed data is returned.  The signature of the entry is not verified.
+func ExtractDirectoryEntry(pkScript []byte) (*dirent.Entry, error) {
+	pops, err := parseScript(pkScript)
+	if err != nil {
+		return nil, err
+	}
+	if !isDirectoryEntry(pops) {
+		return nil, ErrNotDirectoryEntry
+	}
+
+	return dirent.Decode(pops[1].data)
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dirent
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/btcsuite/fastsha256"
+)
+
+const (
+	// Version is the latest directory entry version.
+	Version = 1
+
+	// PubKeySize is the size of the serialized compressed owner public key.
+	PubKeySize = 33
+
+	// SignatureSize is the size of the serialized (r, s) signature.
+	SignatureSize = 64
+
+	// MaxNameLen is the maximum length in bytes of an entry name.
+	MaxNameLen = 64
+
+	// MaxSerializedSize is the maximum size of a serialized entry.  It
+	// matches the maximum size of the data pushed by a standard
+	// OP_POSTDIRECTORY script.
+	MaxSerializedSize = 4096
+
+	// fixedSize is the size of all fields of a serialized entry which
+	// do not vary in length: version, expire, owner public key, name
+	// length, value length and signature.
+	fixedSize = 1 + 4 + PubKeySize + 1 + 2 + SignatureSize
+
+	// MinSerializedSize is the minimum size of a serialized entry, which
+	// has a single byte name and an empty value.
+	MinSerializedSize = fixedSize + 1
+
+	// MaxValueLen is the maximum length in bytes of an entry value, which
+	// is only possible with a single byte name.
+	MaxValueLen = MaxSerializedSize - MinSerializedSize
+)
+
+var (
+	// ErrShortEntry is returned when a serialized entry ends before all
+	// of the fields it describes.
+	ErrShortEntry = errors.New("directory entry is truncated")
+
+	// ErrEntryTooLong is returned when a serialized entry exceeds
+	// MaxSerializedSize bytes or has data after its signature.
+	ErrEntryTooLong = errors.New("directory entry is too long")
+
+	// ErrUnsupportedVersion is returned when an entry has a version other
+	// than Version.
+	ErrUnsupportedVersion = errors.New("directory entry version is not " +
+		"supported")
+
+	// ErrInvalidName is returned when an entry name is empty, longer than
+	// MaxNameLen bytes, not valid UTF-8 or contains control characters.
+	ErrInvalidName = errors.New("directory entry name is invalid")
+
+	// ErrInvalidPubKey is returned when the owner public key of an entry
+	// does not parse.
+	ErrInvalidPubKey = errors.New("directory entry public key is invalid")
+
+	// ErrInvalidSignature is returned when the signature of an entry was
+	// not produced by its owner.
+	ErrInvalidSignature = errors.New("directory entry signature is " +
+		"invalid")
+)
+
+// Entry is a directory entry which binds a name to a value on behalf of the
+// owner of a public key until an expiration time.
+type Entry struct {
+	// Version is the version of the entry format.
+	Version uint8
+
+	// Expire is the unix time at which the entry expires.
+	Expire uint32
+
+	// PubKey is the serialized compressed public key of the owner.
+	PubKey []byte
+
+	// Name is the name the entry is posted under.
+	Name string
+
+	// Value is the opaque value bound to the name.
+	Value []byte
+
+	// Signature is the (r, s) signature of the owner over all of the
+	// other fields.
+	Signature []byte
+}
+
+// checkName returns ErrInvalidName when the passed name is not allowed as the
+// name of an entry.
+func checkName(name string) error {
+	if len(name) == 0 || len(name) > MaxNameLen || !utf8.ValidString(name) {
+		return ErrInvalidName
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return ErrInvalidName
+		}
+	}
+	return nil
+}
+
+// checkSanity ensures the fields of the entry are able to be serialized.
+func (e *Entry) checkSanity() error {
+	if e.Version != Version {
+		return ErrUnsupportedVersion
+	}
+	if err := checkName(e.Name); err != nil {
+		return err
+	}
+	if len(e.PubKey) != PubKeySize {
+		return ErrInvalidPubKey
+	}
+	if len(e.Signature) != SignatureSize {
+		return ErrInvalidSignature
+	}
+	if fixedSize+len(e.Name)+len(e.Value) > MaxSerializedSize {
+		return ErrEntryTooLong
+	}
+	return nil
+}
+
+// serializeUnsigned returns the serialized entry without the trailing
+// signature.  The signature size is reserved in the capacity of the returned
+// slice so it may be appended without reallocating.
+func (e *Entry) serializeUnsigned() []byte {
+	unsignedSize := fixedSize - SignatureSize + len(e.Name) + len(e.Value)
+	b := make([]byte, unsignedSize, unsignedSize+SignatureSize)
+	offset := 0
+	b[offset] = e.Version
+	offset++
+	binary.BigEndian.PutUint32(b[offset:], e.Expire)
+	offset += 4
+	copy(b[offset:], e.PubKey)
+	offset += PubKeySize
+	b[offset] = uint8(len(e.Name))
+	offset++
+	copy(b[offset:], e.Name)
+	offset += len(e.Name)
+	binary.BigEndian.PutUint16(b[offset:], uint16(len(e.Value)))
+	offset += 2
+	copy(b[offset:], e.Value)
+	return b
+}
+
+// SigHash returns the hash committed to by the signature of the entry, which
+// is the sha256 of the serialized entry without its signature.
+func (e *Entry) SigHash() []byte {
+	hash := fastsha256.Sum256(e.serializeUnsigned())
+	return hash[:]
+}
+
+// Serialize returns the serialized form of the entry as it is pushed by an
+// OP_POSTDIRECTORY script.  An error is returned when any of the fields are
+// not able to be serialized.  The signature is not verified.
+func (e *Entry) Serialize() ([]byte, error) {
+	if err := e.checkSanity(); err != nil {
+		return nil, err
+	}
+	return append(e.serializeUnsigned(), e.Signature...), nil
+}
+
+// Decode parses the passed serialized entry.  An error is returned when the
+// entry is malformed.  The owner public key and signature are not verified.
+func Decode(serialized []byte) (*Entry, error) {
+	if len(serialized) > MaxSerializedSize {
+		return nil, ErrEntryTooLong
+	}
+	if len(serialized) < MinSerializedSize {
+		return nil, ErrShortEntry
+	}
+	if serialized[0] != Version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	e := Entry{Version: serialized[0]}
+	offset := 1
+	e.Expire = binary.BigEndian.Uint32(serialized[offset:])
+	offset += 4
+	e.PubKey = make([]byte, PubKeySize)
+	copy(e.PubKey, serialized[offset:])
+	offset += PubKeySize
+	nameLen := int(serialized[offset])
+	offset++
+
+	// The name length is checked against the remaining data, leaving
+	// room for the value length and signature.
+	if offset+nameLen+2+SignatureSize > len(serialized) {
+		return nil, ErrShortEntry
+	}
+	e.Name = string(serialized[offset : offset+nameLen])
+	if err := checkName(e.Name); err != nil {
+		return nil, err
+	}
+	offset += nameLen
+	valueLen := int(binary.BigEndian.Uint16(serialized[offset:]))
+	offset += 2
+
+	// The value and signature must account for exactly the remaining
+	// data.
+	switch remaining := len(serialized) - offset; {
+	case valueLen+SignatureSize > remaining:
+		return nil, ErrShortEntry
+	case valueLen+SignatureSize < remaining:
+		return nil, ErrEntryTooLong
+	}
+	e.Value = make([]byte, valueLen)
+	copy(e.Value, serialized[offset:])
+	offset += valueLen
+	e.Signature = make([]byte, SignatureSize)
+	copy(e.Signature, serialized[offset:])
+	return &e, nil
+}
+
+// Verify ensures the owner public key of the entry parses and that the
+// signature of the entry was produced by the private key which corresponds
+// to it.  The expire time is not checked.
+func (e *Entry) Verify() error {
+	if err := e.checkSanity(); err != nil {
+		return err
+	}
+	pubKey, err := btcec.ParsePubKey(e.PubKey, btcec.S256())
+	if err != nil {
+		return ErrInvalidPubKey
+	}
+
+	half := SignatureSize / 2
+	sig := btcec.Signature{
+		R: new(big.Int).SetBytes(e.Signature[:half]),
+		S: new(big.Int).SetBytes(e.Signature[half:]),
+	}
+	if !sig.Verify(e.SigHash(), pubKey) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign returns a version 1 entry owned by the public key of the passed
+// private key which binds the passed name to the passed value until the
+// passed unix time and is signed by the private key.
+func Sign(expire uint32, name string, value []byte, privKey *btcec.PrivateKey) (*Entry, error) {
+	e := Entry{
+		Version:   Version,
+		Expire:    expire,
+		PubKey:    privKey.PubKey().SerializeCompressed(),
+		Name:      name,
+		Value:     value,
+		Signature: make([]byte, SignatureSize),
+	}
+	if err := e.checkSanity(); err != nil {
+		return nil, err
+	}
+	sig, err := privKey.Sign(e.SigHash())
+	if err != nil {
+		return nil, err
+	}
+
+	// The r and s values are left padded to their fixed sizes.
+	half := SignatureSize / 2
+	r, s := sig.R.Bytes(), sig.S.Bytes()
+	copy(e.Signature[half-len(r):half], r)
+	copy(e.Signature[SignatureSize-len(s):], s)
+	return &e, nil
+}
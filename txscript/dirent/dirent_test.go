@@ -0,0 +1,185 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dirent_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jadeblaquiere/cttd/btcec"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
+)
+
+// TestEntryRoundTrip ensures signed entries serialize, decode and verify and
+// that tampered entries fail verification.
+func TestEntryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{"a", []byte{}},
+		{"alice", []byte("https://ciphrtxt.example/alice")},
+		{strings.Repeat("n", dirent.MaxNameLen), []byte{0x00, 0x01}},
+		{"x", bytes.Repeat([]byte{0xaa}, dirent.MaxValueLen)},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		entry, err := dirent.Sign(1475000000, test.name, test.value,
+			privKey)
+		if err != nil {
+			t.Errorf("Sign #%d: unexpected error: %v", i, err)
+			continue
+		}
+		serialized, err := entry.Serialize()
+		if err != nil {
+			t.Errorf("Serialize #%d: unexpected error: %v", i, err)
+			continue
+		}
+		decoded, err := dirent.Decode(serialized)
+		if err != nil {
+			t.Errorf("Decode #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, entry) {
+			t.Errorf("Decode #%d: mismatched entry - got %+v, "+
+				"want %+v", i, decoded, entry)
+			continue
+		}
+		if err := decoded.Verify(); err != nil {
+			t.Errorf("Verify #%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		decoded.Expire++
+		if err := decoded.Verify(); err != dirent.ErrInvalidSignature {
+			t.Errorf("Verify #%d: did not receive expected error "+
+				"for tampered entry - got %v", i, err)
+		}
+	}
+}
+
+// TestDecodeErrors ensures malformed serialized entries are rejected with the
+// expected error.
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	entry, err := dirent.Sign(1475000000, "bob", []byte{0x01, 0x02},
+		privKey)
+	if err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+	valid, err := entry.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+
+	// mutate returns a copy of the valid entry modified by the passed
+	// function.
+	mutate := func(f func(b []byte) []byte) []byte {
+		b := make([]byte, len(valid))
+		copy(b, valid)
+		return f(b)
+	}
+
+	// The name length is at offset 38 and the value length at offset 42.
+	tests := []struct {
+		name       string
+		serialized []byte
+		err        error
+	}{
+		{
+			name:       "empty",
+			serialized: nil,
+			err:        dirent.ErrShortEntry,
+		},
+		{
+			name:       "truncated signature",
+			serialized: valid[:len(valid)-1],
+			err:        dirent.ErrShortEntry,
+		},
+		{
+			name:       "trailing data",
+			serialized: append(append([]byte{}, valid...), 0x00),
+			err:        dirent.ErrEntryTooLong,
+		},
+		{
+			name:       "oversized",
+			serialized: make([]byte, dirent.MaxSerializedSize+1),
+			err:        dirent.ErrEntryTooLong,
+		},
+		{
+			name: "unsupported version",
+			serialized: mutate(func(b []byte) []byte {
+				b[0] = dirent.Version + 1
+				return b
+			}),
+			err: dirent.ErrUnsupportedVersion,
+		},
+		{
+			name: "empty name",
+			serialized: mutate(func(b []byte) []byte {
+				b[38] = 0
+				return b
+			}),
+			err: dirent.ErrInvalidName,
+		},
+		{
+			name: "control character in name",
+			serialized: mutate(func(b []byte) []byte {
+				b[39] = '\n'
+				return b
+			}),
+			err: dirent.ErrInvalidName,
+		},
+		{
+			name: "name length past end",
+			serialized: mutate(func(b []byte) []byte {
+				b[38] = 0xff
+				return b
+			}),
+			err: dirent.ErrShortEntry,
+		},
+		{
+			name: "value length past end",
+			serialized: mutate(func(b []byte) []byte {
+				b[42] = 0x01
+				return b
+			}),
+			err: dirent.ErrShortEntry,
+		},
+		{
+			name: "value length short of end",
+			serialized: mutate(func(b []byte) []byte {
+				b[43] = 0x01
+				return b
+			}),
+			err: dirent.ErrEntryTooLong,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, err := dirent.Decode(test.serialized)
+		if err != test.err {
+			t.Errorf("Decode #%d (%s): did not receive expected "+
+				"error - got %v, want %v", i, test.name, err,
+				test.err)
+		}
+	}
+}
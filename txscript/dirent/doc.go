@@ -0,0 +1,32 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package dirent implements the directory entry format posted to the block chain
+by OP_POSTDIRECTORY scripts.
+
+A directory entry binds a name to an arbitrary value on behalf of the owner of
+a public key (typically a registered network access key) until an expiration
+time.  Entries are signed by the owner so that anyone can verify the entry was
+posted by the holder of the corresponding private key.
+
+Serialized Format
+
+All integers are encoded big-endian.  The signature is over the sha256 of all
+of the preceding fields.
+
+  Field           Type              Size
+  version         uint8             1 byte
+  expire          uint32            4 bytes
+  owner pubkey    compressed point  33 bytes
+  name length     uint8             1 byte
+  name            UTF-8             1 to MaxNameLen bytes
+  value length    uint16            2 bytes
+  value           []byte            variable
+  signature       r || s            64 bytes
+
+The total serialized size of an entry may not exceed MaxSerializedSize bytes.
+Only version 1 entries are currently defined.
+*/
+package dirent
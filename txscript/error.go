@@ -151,4 +151,10 @@ var (
 	// ErrBadNumRequired is returned from MultiSigScript when nrequired is
 	// larger than the number of provided public keys.
 	ErrBadNumRequired = errors.New("more signatures required than keys present")
+
+	// ErrAccessKeyMismatch is returned from RegisterAccessKeyScript when
+	// the private key does not correspond to the public key being
+	// registered.
+	ErrAccessKeyMismatch = errors.New("private key does not match access " +
+		"key public key")
 )
@@ -152,6 +152,9 @@ func sign(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
 	case NullDataTy:
 		return nil, class, nil, 0,
 			errors.New("can't sign NULLDATA transactions")
+	case AccessKeyTy, DirectoryTy:
+		return nil, class, nil, 0,
+			errors.New("can't sign record transactions")
 	default:
 		return nil, class, nil, 0,
 			errors.New("can't sign unknown transactions")
@@ -5,7 +5,9 @@
 package txscript
 
 import (
+	"github.com/jadeblaquiere/cttd/btcec"
 	"github.com/jadeblaquiere/cttd/chaincfg"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
 	"github.com/jadeblaquiere/cttutil"
 )
 
@@ -15,12 +17,14 @@ const (
 	MaxDataCarrierSize = 80
 
 	// MaxAccessKeySize is the maximum number of bytes allowed in pushed
-	// in a OP_REGISTERACCCESSKEY txn to be considered a nulldata transaction
+	// data of an OP_REGISTERACCESSKEY script to be considered an access key
+	// transaction
 	MaxAccessKeySize = 256
 
 	// MaxDirectoryEntrySize is the maximum number of bytes allowed in pushed
-	// in a OP_POSTDIRECTORY txn to be considered a nulldata transaction
-	MaxDirectoryEntrySize = 4096
+	// data of an OP_POSTDIRECTORY script to be considered a directory
+	// transaction
+	MaxDirectoryEntrySize = dirent.MaxSerializedSize
 
 	// StandardVerifyFlags are the script flags which are used when
 	// executing transaction scripts to enforce additional checks which
@@ -54,6 +58,8 @@ const (
 	ScriptHashTy                     // Pay to script hash.
 	MultiSigTy                       // Multi signature.
 	NullDataTy                       // Empty data-only (provably prunable).
+	AccessKeyTy                      // Network access key registration.
+	DirectoryTy                      // Directory entry post.
 )
 
 // scriptClassToName houses the human-readable strings which describe each
@@ -65,6 +71,8 @@ var scriptClassToName = []string{
 	ScriptHashTy:  "scripthash",
 	MultiSigTy:    "multisig",
 	NullDataTy:    "nulldata",
+	AccessKeyTy:   "accesskey",
+	DirectoryTy:   "directory",
 }
 
 // String implements the Stringer interface by returning the name of
@@ -138,12 +146,7 @@ func isNullData(pops []parsedOpcode) bool {
 	// A nulldata transaction is either a single OP_RETURN or an
 	// OP_RETURN SMALLDATA (where SMALLDATA is a data push up to
 	// MaxDataCarrierSize bytes).
-    
-    // OP_REGISTERACCESSKEY and OP_POSTDIRECTORY also push limited data
-    // to stack - at higher cost with higher limits - (TODO) policy should enforce
-    // higher transaction costs for these Record transactions
 	l := len(pops)
-    
 	if l == 1 && pops[0].opcode.value == OP_RETURN {
 		return true
 	}
@@ -152,15 +155,22 @@ func isNullData(pops []parsedOpcode) bool {
 		pops[0].opcode.value == OP_RETURN &&
 		pops[1].opcode.value <= OP_PUSHDATA4 &&
 		len(pops[1].data) <= MaxDataCarrierSize
+}
 
-	return l == 2 &&
-		pops[0].opcode.value == OP_REGISTERACCESSKEY &&
-		pops[1].opcode.value <= OP_PUSHDATA4 &&
-		len(pops[1].data) <= MaxAccessKeySize
+// isStandardAccessKey returns true if the passed script is an access key
+// transaction, false otherwise.  An access key transaction is an
+// OP_REGISTERACCESSKEY DATA (where DATA is a data push up to MaxAccessKeySize
+// bytes).  The pushed data is not validated.
+func isStandardAccessKey(pops []parsedOpcode) bool {
+	return isAccessKey(pops) && len(pops[1].data) <= MaxAccessKeySize
+}
 
-	return l == 2 &&
-		pops[0].opcode.value == OP_POSTDIRECTORY &&
-		pops[1].opcode.value <= OP_PUSHDATA4 &&
+// isStandardDirectoryEntry returns true if the passed script is a directory
+// transaction, false otherwise.  A directory transaction is an
+// OP_POSTDIRECTORY DATA (where DATA is a data push up to
+// MaxDirectoryEntrySize bytes).  The pushed data is not validated.
+func isStandardDirectoryEntry(pops []parsedOpcode) bool {
+	return isDirectoryEntry(pops) &&
 		len(pops[1].data) <= MaxDirectoryEntrySize
 }
 
@@ -177,6 +187,10 @@ func typeOfScript(pops []parsedOpcode) ScriptClass {
 		return MultiSigTy
 	} else if isNullData(pops) {
 		return NullDataTy
+	} else if isStandardAccessKey(pops) {
+		return AccessKeyTy
+	} else if isStandardDirectoryEntry(pops) {
+		return DirectoryTy
 	}
 	return NonStandardTy
 }
@@ -219,7 +233,7 @@ func expectedInputs(pops []parsedOpcode, class ScriptClass) int {
 		// for the extra push that is required to compensate.
 		return asSmallInt(pops[0].opcode) + 1
 
-	case NullDataTy:
+	case NullDataTy, AccessKeyTy, DirectoryTy:
 		fallthrough
 	default:
 		return -1
@@ -391,6 +405,39 @@ func MultiSigScript(pubkeys []*cttutil.AddressPubKey, nrequired int) ([]byte, er
 	return builder.Script()
 }
 
+// RegisterAccessKeyScript returns a script which registers the passed public
+// key as a network access key which expires at the passed unix time.  The
+// registration is signed by the passed private key, which must correspond to
+// the public key, and ErrAccessKeyMismatch is returned when it does not.
+func RegisterAccessKeyScript(expire uint32, pubKey *btcec.PublicKey, privKey *btcec.PrivateKey) ([]byte, error) {
+	if !privKey.PubKey().IsEqual(pubKey) {
+		return nil, ErrAccessKeyMismatch
+	}
+
+	ak, err := SignAccessKey(expire, privKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewScriptBuilder().AddOp(OP_REGISTERACCESSKEY).
+		AddData(ak.Serialize()).Script()
+}
+
+// PostDirectoryScript returns a script which posts the passed directory entry.
+// An error is returned when the entry can not be serialized.  The signature of
+// the entry is not verified.
+func PostDirectoryScript(entry *dirent.Entry) ([]byte, error) {
+	serialized, err := entry.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries may be larger than the maximum size of a pushed element
+	// that is allowed when executing scripts, which does not apply since
+	// the script is never executed.
+	return NewScriptBuilder().AddOp(OP_POSTDIRECTORY).
+		AddFullData(serialized).Script()
+}
+
 // PushedData returns an array of byte slices containing any pushed data found
 // in the passed script.  This includes OP_0, but not OP_1 - OP_16.
 func PushedData(script []byte) ([][]byte, error) {
@@ -485,6 +532,32 @@ func ExtractPkScriptAddrs(pkScript []byte, chainParams *chaincfg.Params) (Script
 		// Null data transactions have no addresses or required
 		// signatures.
 
+	case AccessKeyTy:
+		// Access key transactions have no required signatures since
+		// they are unspendable.  The address is the registered public
+		// key when it is valid.
+		ak, err := ParseAccessKey(pops[1].data)
+		if err != nil {
+			break
+		}
+		addr, err := cttutil.NewAddressPubKey(ak.PubKey, chainParams)
+		if err == nil {
+			addrs = append(addrs, addr)
+		}
+
+	case DirectoryTy:
+		// Directory transactions have no required signatures since
+		// they are unspendable.  The address is the public key of the
+		// owner of the entry when the entry is well formed.
+		entry, err := dirent.Decode(pops[1].data)
+		if err != nil {
+			break
+		}
+		addr, err := cttutil.NewAddressPubKey(entry.PubKey, chainParams)
+		if err == nil {
+			addrs = append(addrs, addr)
+		}
+
 	case NonStandardTy:
 		// Don't attempt to extract addresses or required signatures for
 		// nonstandard transactions.
@@ -8,10 +8,13 @@ import (
 	"bytes"
 	"encoding/hex"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/jadeblaquiere/cttd/btcec"
 	"github.com/jadeblaquiere/cttd/chaincfg"
 	"github.com/jadeblaquiere/cttd/txscript"
+	"github.com/jadeblaquiere/cttd/txscript/dirent"
 	"github.com/jadeblaquiere/cttutil"
 )
 
@@ -885,6 +888,42 @@ var scriptClassTests = []scriptClassTest{
 		script: "RETURN 4 TRUE",
 		class:  txscript.NonStandardTy,
 	},
+	{
+		// Access key registration with the exact size of an access
+		// key.  The pushed data is not validated.
+		name: "accesskey",
+		script: "REGISTERACCESSKEY PUSHDATA1 0x65 0x" +
+			strings.Repeat("00", txscript.AccessKeySize),
+		class: txscript.AccessKeyTy,
+	},
+	{
+		// Access key registration with more than max allowed data.
+		name: "accesskey2",
+		script: "REGISTERACCESSKEY PUSHDATA2 0x0101 0x" +
+			strings.Repeat("00", txscript.MaxAccessKeySize+1),
+		class: txscript.NonStandardTy,
+	},
+	{
+		// Almost an access key registration, but add an additional
+		// opcode after the data to make it nonstandard.
+		name:   "accesskey3",
+		script: "REGISTERACCESSKEY 4 TRUE",
+		class:  txscript.NonStandardTy,
+	},
+	{
+		// Directory entry post with max allowed data.
+		name: "directory",
+		script: "POSTDIRECTORY PUSHDATA2 0x0010 0x" +
+			strings.Repeat("00", txscript.MaxDirectoryEntrySize),
+		class: txscript.DirectoryTy,
+	},
+	{
+		// Directory entry post with more than max allowed data.
+		name: "directory2",
+		script: "POSTDIRECTORY PUSHDATA2 0x0110 0x" +
+			strings.Repeat("00", txscript.MaxDirectoryEntrySize+1),
+		class: txscript.NonStandardTy,
+	},
 
 	// The next few are almost multisig (it is the more complex script type)
 	// but with various changes to make it fail.
@@ -998,6 +1037,16 @@ func TestStringifyClass(t *testing.T) {
 			class:    txscript.NullDataTy,
 			stringed: "nulldata",
 		},
+		{
+			name:     "accesskeyty",
+			class:    txscript.AccessKeyTy,
+			stringed: "accesskey",
+		},
+		{
+			name:     "directoryty",
+			class:    txscript.DirectoryTy,
+			stringed: "directory",
+		},
 		{
 			name:     "broken",
 			class:    txscript.ScriptClass(255),
@@ -1013,3 +1062,118 @@ func TestStringifyClass(t *testing.T) {
 		}
 	}
 }
+
+// TestRegisterAccessKeyScript ensures the RegisterAccessKeyScript function
+// creates scripts which register a valid access key and rejects a private key
+// which does not correspond to the public key.
+func TestRegisterAccessKeyScript(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	script, err := txscript.RegisterAccessKeyScript(1475000000,
+		privKey.PubKey(), privKey)
+	if err != nil {
+		t.Fatalf("RegisterAccessKeyScript: unexpected error: %v", err)
+	}
+	if class := txscript.GetScriptClass(script); class != txscript.AccessKeyTy {
+		t.Fatalf("RegisterAccessKeyScript: unexpected script class - "+
+			"got %s, want %s", class, txscript.AccessKeyTy)
+	}
+	ak, err := txscript.ExtractAccessKey(script)
+	if err != nil {
+		t.Fatalf("ExtractAccessKey: unexpected error: %v", err)
+	}
+	if ak.Expire != 1475000000 {
+		t.Fatalf("ExtractAccessKey: unexpected expire - got %d, want %d",
+			ak.Expire, 1475000000)
+	}
+	if !bytes.Equal(ak.PubKey, privKey.PubKey().SerializeCompressed()) {
+		t.Fatalf("ExtractAccessKey: unexpected public key - got %x, "+
+			"want %x", ak.PubKey,
+			privKey.PubKey().SerializeCompressed())
+	}
+	if err := ak.Verify(); err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+
+	// Ensure the registered public key is extracted as the address.
+	_, addrs, reqSigs, err := txscript.ExtractPkScriptAddrs(script,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("ExtractPkScriptAddrs: unexpected error: %v", err)
+	}
+	want := []cttutil.Address{newAddressPubKey(ak.PubKey)}
+	if !reflect.DeepEqual(addrs, want) || reqSigs != 0 {
+		t.Fatalf("ExtractPkScriptAddrs: unexpected result - got %v "+
+			"(%d), want %v (0)", addrs, reqSigs, want)
+	}
+
+	otherKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	_, err = txscript.RegisterAccessKeyScript(1475000000,
+		otherKey.PubKey(), privKey)
+	if err != txscript.ErrAccessKeyMismatch {
+		t.Fatalf("RegisterAccessKeyScript: did not receive expected "+
+			"error - got %v, want %v", err,
+			txscript.ErrAccessKeyMismatch)
+	}
+}
+
+// TestPostDirectoryScript ensures the PostDirectoryScript function creates
+// scripts which post the passed directory entry, including entries which are
+// larger than the maximum size of a pushed element.
+func TestPostDirectoryScript(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+
+	values := [][]byte{
+		[]byte("https://ciphrtxt.example/alice"),
+		bytes.Repeat([]byte{0xaa}, dirent.MaxValueLen),
+	}
+	for i, value := range values {
+		entry, err := dirent.Sign(1475000000, "a", value, privKey)
+		if err != nil {
+			t.Errorf("Sign #%d: unexpected error: %v", i, err)
+			continue
+		}
+		script, err := txscript.PostDirectoryScript(entry)
+		if err != nil {
+			t.Errorf("PostDirectoryScript #%d: unexpected error: %v",
+				i, err)
+			continue
+		}
+		class := txscript.GetScriptClass(script)
+		if class != txscript.DirectoryTy {
+			t.Errorf("PostDirectoryScript #%d: unexpected script "+
+				"class - got %s, want %s", i, class,
+				txscript.DirectoryTy)
+			continue
+		}
+		extracted, err := txscript.ExtractDirectoryEntry(script)
+		if err != nil {
+			t.Errorf("ExtractDirectoryEntry #%d: unexpected error: "+
+				"%v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(extracted, entry) {
+			t.Errorf("ExtractDirectoryEntry #%d: mismatched entry - "+
+				"got %+v, want %+v", i, extracted, entry)
+		}
+	}
+
+	// Ensure entries which can not be serialized are rejected.
+	_, err = txscript.PostDirectoryScript(&dirent.Entry{Version: 2})
+	if err != dirent.ErrUnsupportedVersion {
+		t.Fatalf("PostDirectoryScript: did not receive expected error "+
+			"- got %v, want %v", err, dirent.ErrUnsupportedVersion)
+	}
+}
@@ -14,7 +14,7 @@ import (
 )
 
 // BlockVersion is the current latest supported block version.
-const BlockVersion = 101
+const BlockVersion = 104
 
 // MaxBlockHeaderPayload is the maximum number of bytes a block header can be.
 // Version 4 bytes + Timestamp 4 bytes + Bits 4 bytes + 
@@ -29,6 +29,7 @@ const (
 	InvTypeTx            InvType = 1
 	InvTypeBlock         InvType = 2
 	InvTypeFilteredBlock InvType = 3
+	InvTypeMsgHeader     InvType = 4
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -37,6 +38,7 @@ var ivStrings = map[InvType]string{
 	InvTypeTx:            "MSG_TX",
 	InvTypeBlock:         "MSG_BLOCK",
 	InvTypeFilteredBlock: "MSG_FILTERED_BLOCK",
+	InvTypeMsgHeader:     "MSG_MSGHEADER",
 }
 
 // String returns the InvType in human-readable form.
@@ -48,6 +50,14 @@ func (invtype InvType) String() string {
 	return fmt.Sprintf("Unknown InvType (%d)", uint32(invtype))
 }
 
+// MsgHeaderInvHash returns the hash which identifies the ciphrtxt message
+// header with the passed I key in inventory vectors of type InvTypeMsgHeader.
+// Since I keys are larger than a hash, the hash is the double sha256 of the I
+// key.
+func MsgHeaderInvHash(ikey []byte) chainhash.Hash {
+	return chainhash.DoubleHashH(ikey)
+}
+
 // InvVect defines a bitcoin inventory vector which is used to describe data,
 // as specified by the Type field, that a peer wants, has, or does not have to
 // another peer.
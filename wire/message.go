@@ -50,6 +50,8 @@ const (
 	CmdMerkleBlock = "merkleblock"
 	CmdReject      = "reject"
 	CmdSendHeaders = "sendheaders"
+	CmdGetMsgHdr   = "getmsghdr"
+	CmdMsgHdr      = "msghdr"
 )
 
 // Message is an interface that describes a bitcoin message.  A type that
@@ -134,6 +136,12 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdSendHeaders:
 		msg = &MsgSendHeaders{}
 
+	case CmdGetMsgHdr:
+		msg = &MsgGetMsgHdr{}
+
+	case CmdMsgHdr:
+		msg = &MsgMsgHdr{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
@@ -75,6 +75,8 @@ func TestMessage(t *testing.T) {
 	bh := NewBlockHeader(&chainhash.Hash{}, &chainhash.Hash{}, 0, 0)
 	msgMerkleBlock := NewMsgMerkleBlock(bh)
 	msgReject := NewMsgReject("block", RejectDuplicate, "duplicate block")
+	msgGetMsgHdr := NewMsgGetMsgHdr()
+	msgMsgHdr := &MsgMsgHdr{}
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -104,6 +106,8 @@ func TestMessage(t *testing.T) {
 		{msgFilterLoad, msgFilterLoad, pver, MainNet, 35},
 		{msgMerkleBlock, msgMerkleBlock, pver, MainNet, 110},
 		{msgReject, msgReject, pver, MainNet, 79},
+		{msgGetMsgHdr, msgGetMsgHdr, pver, MainNet, 25},
+		{msgMsgHdr, msgMsgHdr, pver, MainNet, 216},
 	}
 
 	t.Logf("Running %d tests", len(tests))
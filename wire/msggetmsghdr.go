@@ -0,0 +1,139 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// MsgHdrIKeySize is the size of the I key which identifies a ciphrtxt
+	// message header.  It is a compressed public key.
+	MsgHdrIKeySize = 33
+
+	// MaxGetMsgHdrPerMsg is the maximum number of message headers that can
+	// be requested in a single getmsghdr message.
+	MaxGetMsgHdrPerMsg = 16
+)
+
+// MsgGetMsgHdr implements the Message interface and represents a bitcoin
+// getmsghdr message.  It is used to request the ciphrtxt message headers with
+// the given I keys, typically the nonce headers of a block which are not yet
+// known to the requesting node.  Each header which is known to the peer is
+// returned via a msghdr message (MsgMsgHdr) and the others are returned via a
+// notfound message (MsgNotFound) with inventory vectors of type
+// InvTypeMsgHeader.
+//
+// Use the AddIKey function to build up the list of I keys to request.
+//
+// This message was not added until protocol version MsgHdrVersion.
+type MsgGetMsgHdr struct {
+	IKeys [][MsgHdrIKeySize]byte
+}
+
+// AddIKey adds a new I key to the message.
+func (msg *MsgGetMsgHdr) AddIKey(ikey []byte) error {
+	if len(msg.IKeys)+1 > MaxGetMsgHdrPerMsg {
+		str := fmt.Sprintf("too many I keys for message [max %v]",
+			MaxGetMsgHdrPerMsg)
+		return messageError("MsgGetMsgHdr.AddIKey", str)
+	}
+	if len(ikey) != MsgHdrIKeySize {
+		str := fmt.Sprintf("I key is %d bytes instead of %d",
+			len(ikey), MsgHdrIKeySize)
+		return messageError("MsgGetMsgHdr.AddIKey", str)
+	}
+
+	var key [MsgHdrIKeySize]byte
+	copy(key[:], ikey)
+	msg.IKeys = append(msg.IKeys, key)
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetMsgHdr) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < MsgHdrVersion {
+		str := fmt.Sprintf("getmsghdr message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetMsgHdr.BtcDecode", str)
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Limit to max I keys per message.
+	if count > MaxGetMsgHdrPerMsg {
+		str := fmt.Sprintf("too many I keys for message "+
+			"[count %v, max %v]", count, MaxGetMsgHdrPerMsg)
+		return messageError("MsgGetMsgHdr.BtcDecode", str)
+	}
+
+	msg.IKeys = make([][MsgHdrIKeySize]byte, count)
+	for i := uint64(0); i < count; i++ {
+		err := readElement(r, &msg.IKeys[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetMsgHdr) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < MsgHdrVersion {
+		str := fmt.Sprintf("getmsghdr message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetMsgHdr.BtcEncode", str)
+	}
+
+	// Limit to max I keys per message.
+	count := len(msg.IKeys)
+	if count > MaxGetMsgHdrPerMsg {
+		str := fmt.Sprintf("too many I keys for message "+
+			"[count %v, max %v]", count, MaxGetMsgHdrPerMsg)
+		return messageError("MsgGetMsgHdr.BtcEncode", str)
+	}
+
+	err := WriteVarInt(w, pver, uint64(count))
+	if err != nil {
+		return err
+	}
+
+	for i := range msg.IKeys {
+		err = writeElement(w, &msg.IKeys[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetMsgHdr) Command() string {
+	return CmdGetMsgHdr
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetMsgHdr) MaxPayloadLength(pver uint32) uint32 {
+	// Num I keys (varInt) + max allowed I keys.
+	return MaxVarIntPayload + (MaxGetMsgHdrPerMsg * MsgHdrIKeySize)
+}
+
+// NewMsgGetMsgHdr returns a new bitcoin getmsghdr message that conforms to the
+// Message interface.  See MsgGetMsgHdr for details.
+func NewMsgGetMsgHdr() *MsgGetMsgHdr {
+	return &MsgGetMsgHdr{
+		IKeys: make([][MsgHdrIKeySize]byte, 0, 2),
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetMsgHdr tests the MsgGetMsgHdr API.
+func TestGetMsgHdr(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "getmsghdr"
+	msg := NewMsgGetMsgHdr()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetMsgHdr: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Num I keys (varInt) + max allowed I keys.
+	wantPayload := uint32(9 + (16 * 33))
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure I keys of the wrong size are not added.
+	if err := msg.AddIKey(make([]byte, MsgHdrIKeySize-1)); err == nil {
+		t.Errorf("AddIKey: expected error on I key of wrong size")
+	}
+
+	// Ensure I keys are added properly up to the max.
+	ikey := bytes.Repeat([]byte{0x02}, MsgHdrIKeySize)
+	for i := 0; i < MaxGetMsgHdrPerMsg; i++ {
+		if err := msg.AddIKey(ikey); err != nil {
+			t.Fatalf("AddIKey #%d: %v", i, err)
+		}
+	}
+	if !bytes.Equal(msg.IKeys[0][:], ikey) {
+		t.Errorf("AddIKey: wrong I key added - got %x, want %x",
+			msg.IKeys[0], ikey)
+	}
+
+	// Ensure adding more than the max allowed I keys per message returns
+	// an error.
+	if err := msg.AddIKey(ikey); err == nil {
+		t.Errorf("AddIKey: expected error on too many I keys not " +
+			"received")
+	}
+}
+
+// TestGetMsgHdrWire tests the MsgGetMsgHdr wire encode and decode.
+func TestGetMsgHdrWire(t *testing.T) {
+	ikey := bytes.Repeat([]byte{0x03}, MsgHdrIKeySize)
+	msg := NewMsgGetMsgHdr()
+	if err := msg.AddIKey(ikey); err != nil {
+		t.Fatalf("AddIKey: %v", err)
+	}
+	msgEncoded := append([]byte{0x01}, ikey...)
+
+	// Encode the message to wire format.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), msgEncoded) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(msgEncoded))
+	}
+
+	// Decode the message from wire format.
+	var readmsg MsgGetMsgHdr
+	err := readmsg.BtcDecode(bytes.NewReader(msgEncoded), ProtocolVersion)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Fatalf("BtcDecode\n got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+}
+
+// TestGetMsgHdrWireErrors performs negative tests against wire encode and
+// decode of MsgGetMsgHdr to confirm error paths work correctly.
+func TestGetMsgHdrWireErrors(t *testing.T) {
+	msg := NewMsgGetMsgHdr()
+
+	// Ensure the message is rejected prior to MsgHdrVersion.
+	oldPver := MsgHdrVersion - 1
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, oldPver); err == nil {
+		t.Errorf("BtcEncode: expected error for protocol version %d",
+			oldPver)
+	}
+	err := msg.BtcDecode(bytes.NewReader([]byte{0x00}), oldPver)
+	if err == nil {
+		t.Errorf("BtcDecode: expected error for protocol version %d",
+			oldPver)
+	}
+
+	// Ensure a message which claims too many I keys is rejected.
+	var readmsg MsgGetMsgHdr
+	tooMany := []byte{MaxGetMsgHdrPerMsg + 1}
+	err = readmsg.BtcDecode(bytes.NewReader(tooMany), ProtocolVersion)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: wrong error for too many I keys - got %v",
+			err)
+	}
+
+	// Ensure a truncated I key is rejected.
+	truncated := append([]byte{0x01}, make([]byte, MsgHdrIKeySize-1)...)
+	err = readmsg.BtcDecode(bytes.NewReader(truncated), ProtocolVersion)
+	if err == nil {
+		t.Errorf("BtcDecode: expected error for truncated I key")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+)
+
+// MsgMsgHdr implements the Message interface and represents a bitcoin msghdr
+// message.  It is used to deliver a ciphrtxt message header in response to a
+// getmsghdr message (MsgGetMsgHdr).
+//
+// This message was not added until protocol version MsgHdrVersion.
+type MsgMsgHdr struct {
+	Header ciphrtxt.BinaryMessageHeaderV2
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgMsgHdr) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < MsgHdrVersion {
+		str := fmt.Sprintf("msghdr message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgMsgHdr.BtcDecode", str)
+	}
+
+	return readElement(r, &msg.Header)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgMsgHdr) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < MsgHdrVersion {
+		str := fmt.Sprintf("msghdr message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgMsgHdr.BtcEncode", str)
+	}
+
+	return writeElement(w, &msg.Header)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgMsgHdr) Command() string {
+	return CmdMsgHdr
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgMsgHdr) MaxPayloadLength(pver uint32) uint32 {
+	return ciphrtxt.MessageHeaderLengthV2
+}
+
+// NewMsgMsgHdr returns a new bitcoin msghdr message that conforms to the
+// Message interface.  See MsgMsgHdr for details.
+func NewMsgMsgHdr(header *ciphrtxt.BinaryMessageHeaderV2) *MsgMsgHdr {
+	return &MsgMsgHdr{
+		Header: *header,
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/jadeblaquiere/ciphrtxt-go/ciphrtxt"
+)
+
+// TestMsgHdr tests the MsgMsgHdr API and wire encode and decode.
+func TestMsgHdr(t *testing.T) {
+	pver := ProtocolVersion
+
+	var header ciphrtxt.BinaryMessageHeaderV2
+	for i := range header {
+		header[i] = byte(i)
+	}
+	msg := NewMsgMsgHdr(&header)
+
+	// Ensure the command is expected value.
+	wantCmd := "msghdr"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgMsgHdr: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(ciphrtxt.MessageHeaderLengthV2)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the header round trips through the wire encoding unchanged.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), header[:]) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(header[:]))
+	}
+	var readmsg MsgMsgHdr
+	if err := readmsg.BtcDecode(&buf, pver); err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Fatalf("BtcDecode\n got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure a truncated header is rejected.
+	err := readmsg.BtcDecode(bytes.NewReader(header[:10]), pver)
+	if err == nil {
+		t.Errorf("BtcDecode: expected error for truncated header")
+	}
+
+	// Ensure the message is rejected prior to MsgHdrVersion.
+	oldPver := MsgHdrVersion - 1
+	if err := msg.BtcEncode(&buf, oldPver); err == nil {
+		t.Errorf("BtcEncode: expected error for protocol version %d",
+			oldPver)
+	}
+	err = readmsg.BtcDecode(bytes.NewReader(header[:]), oldPver)
+	if err == nil {
+		t.Errorf("BtcDecode: expected error for protocol version %d",
+			oldPver)
+	}
+}
@@ -12,7 +12,7 @@ import (
 
 const (
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 70012
+	ProtocolVersion uint32 = 70013
 
 	// MultipleAddressVersion is the protocol version which added multiple
 	// addresses per message (pver >= MultipleAddressVersion).
@@ -46,6 +46,10 @@ const (
 	// RejectVersion is the protocol version which added a new reject
 	// message.
 	RejectVersion uint32 = 70002
+
+	// MsgHdrVersion is the protocol version which added the getmsghdr and
+	// msghdr messages used to relay ciphrtxt message headers.
+	MsgHdrVersion uint32 = 70013
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -62,13 +66,19 @@ const (
 	// SFNodeBloom is a flag used to indiciate a peer supports bloom
 	// filtering.
 	SFNodeBloom
+
+	// SFNodeMsgStore is a flag used to indicate a peer is connected to a
+	// ciphrtxt message store and can serve message headers with the
+	// getmsghdr command.
+	SFNodeMsgStore
 )
 
 // Map of service flags back to their constant names for pretty printing.
 var sfStrings = map[ServiceFlag]string{
-	SFNodeNetwork: "SFNodeNetwork",
-	SFNodeGetUTXO: "SFNodeGetUTXO",
-	SFNodeBloom:   "SFNodeBloom",
+	SFNodeNetwork:  "SFNodeNetwork",
+	SFNodeGetUTXO:  "SFNodeGetUTXO",
+	SFNodeBloom:    "SFNodeBloom",
+	SFNodeMsgStore: "SFNodeMsgStore",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -77,6 +87,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeNetwork,
 	SFNodeGetUTXO,
 	SFNodeBloom,
+	SFNodeMsgStore,
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -129,6 +140,9 @@ const (
 
 	// CTRedNet represents the ciphrtxt-red test network.
 	CTRedNet BitcoinNet = 0xdeadbeef
+
+	// CTSimNet represents the ciphrtxt simulation test network.
+	CTSimNet BitcoinNet = 0xc0debabe
 )
 
 // bnStrings is a map of bitcoin networks back to their constant names for
@@ -140,6 +154,7 @@ var bnStrings = map[BitcoinNet]string{
 	//SimNet:   "SimNet",
 	CTIndigoNet:   "CTIndigoNet",
 	CTRedNet:   "CTRedNet",
+	CTSimNet:   "CTSimNet",
 }
 
 // String returns the BitcoinNet in human-readable form.